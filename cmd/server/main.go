@@ -40,13 +40,21 @@ func main() {
 
 	// Initialize repositories
 	actRepo := repository.NewActRepository(mongoClient)
+	templateRepo := repository.NewTemplateRepository(mongoClient)
+	contractRepo := repository.NewContractRepository(mongoClient)
 
 	// Initialize services
 	excelService := services.NewExcelService(cfg)
-	actService := services.NewActService(actRepo, excelService, cfg)
+	templateService := services.NewTemplateService(templateRepo, actRepo, excelService, cfg)
+	assetService := services.NewAssetService(cfg)
+	contractService := services.NewContractService(contractRepo, cfg)
+	actService := services.NewActService(actRepo, excelService, templateService, assetService, contractService, cfg)
 
 	// Initialize handlers
 	actHandler := handlers.NewActHandler(actService, cfg)
+	templateHandler := handlers.NewTemplateHandler(templateService, cfg)
+	assetHandler := handlers.NewAssetHandler(assetService, cfg)
+	contractHandler := handlers.NewContractHandler(contractService, actService, cfg)
 
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
@@ -81,8 +89,34 @@ func main() {
 		act := api.Group("/act")
 		{
 			act.POST("/create", actHandler.CreateAct)
+			act.POST("/update", actHandler.UpdateAct)
+			act.POST("/status", actHandler.TransitionAct)
 			act.GET("/generate", actHandler.GenerateAct)
 			act.GET("/download/:filename", actHandler.DownloadAct)
+			act.GET("/verify", actHandler.VerifyAct)
+			act.POST("/import", actHandler.ImportAct)
+			act.POST("/batch", actHandler.GenerateBatch)
+			act.POST("/combined", actHandler.GenerateCombined)
+		}
+
+		template := api.Group("/template")
+		{
+			template.POST("/upload", templateHandler.UploadTemplate)
+			template.GET("/list", templateHandler.ListTemplates)
+			template.POST("/activate", templateHandler.ActivateTemplate)
+			template.GET("/inspect", templateHandler.InspectTemplate)
+		}
+
+		asset := api.Group("/asset")
+		{
+			asset.POST("/upload", assetHandler.UploadAsset)
+		}
+
+		contract := api.Group("/contract")
+		{
+			contract.POST("/create", contractHandler.CreateContract)
+			contract.GET("/list", contractHandler.ListContracts)
+			contract.GET("/get", contractHandler.GetContract)
 		}
 	}
 
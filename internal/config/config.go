@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
 )
 
 // Config holds all configuration for the application
@@ -17,15 +18,37 @@ type Config struct {
 	BaseURL    string
 
 	// MongoDB configuration
-	MongoDBURI        string
-	MongoDBDatabase   string
-	MongoDBCollection string
-	MongoDBTimeout    time.Duration
+	MongoDBURI                 string
+	MongoDBDatabase            string
+	MongoDBCollection          string
+	MongoDBTemplatesCollection string
+	MongoDBContractsCollection string
+	MongoDBTimeout             time.Duration
 
 	// File paths
 	TemplatePath  string
+	TemplatesDir  string
+	AssetsDir     string
+	FormsDir      string
 	GeneratedPath string
 
+	// Uploads and batches
+	MaxUploadSize int64
+	MaxBatchSize  int
+
+	// Rendering
+	TemplateCacheSize  int64
+	MissingKeyPolicy   string
+	Locale             string
+	StreamingThreshold int
+	DefaultVATRate     models.VATRate
+	TimeZone           *time.Location
+	DraftWatermark     string
+
+	// PDF export
+	PDFFontPath     string
+	PDFBoldFontPath string
+
 	// Logging
 	LogLevel  string
 	LogFormat string
@@ -44,20 +67,36 @@ func Load() *Config {
 	}
 
 	config := &Config{
-		ServerPort:        getEnv("SERVER_PORT", "8080"),
-		ServerHost:        getEnv("SERVER_HOST", "0.0.0.0"),
-		BaseURL:           getEnv("BASE_URL", "http://localhost:8080"),
-		MongoDBURI:        getEnv("MONGODB_URI", "mongodb://localhost:27017"),
-		MongoDBDatabase:   getEnv("MONGODB_DATABASE", "acts_db"),
-		MongoDBCollection: getEnv("MONGODB_COLLECTION", "acts"),
-		MongoDBTimeout:    parseDuration(getEnv("MONGODB_TIMEOUT", "10s"), 10*time.Second),
-		TemplatePath:      getEnv("TEMPLATE_PATH", "./templates/act_template.xlsx"),
-		GeneratedPath:     getEnv("GENERATED_PATH", "./generated"),
-		LogLevel:          getEnv("LOG_LEVEL", "info"),
-		LogFormat:         getEnv("LOG_FORMAT", "json"),
-		CleanupEnabled:    getEnv("CLEANUP_ENABLED", "false") == "true",
-		CleanupInterval:   parseDuration(getEnv("CLEANUP_INTERVAL", "24h"), 24*time.Hour),
-		FileRetentionDays: parseInt(getEnv("FILE_RETENTION_DAYS", "7"), 7),
+		ServerPort:                 getEnv("SERVER_PORT", "8080"),
+		ServerHost:                 getEnv("SERVER_HOST", "0.0.0.0"),
+		BaseURL:                    getEnv("BASE_URL", "http://localhost:8080"),
+		MongoDBURI:                 getEnv("MONGODB_URI", "mongodb://localhost:27017"),
+		MongoDBDatabase:            getEnv("MONGODB_DATABASE", "acts_db"),
+		MongoDBCollection:          getEnv("MONGODB_COLLECTION", "acts"),
+		MongoDBTemplatesCollection: getEnv("MONGODB_TEMPLATES_COLLECTION", "templates"),
+		MongoDBContractsCollection: getEnv("MONGODB_CONTRACTS_COLLECTION", "contracts"),
+		MongoDBTimeout:             parseDuration(getEnv("MONGODB_TIMEOUT", "10s"), 10*time.Second),
+		TemplatePath:               getEnv("TEMPLATE_PATH", "./templates/act_template.xlsx"),
+		TemplatesDir:               getEnv("TEMPLATES_DIR", "./templates/uploaded"),
+		AssetsDir:                  getEnv("ASSETS_DIR", "./templates/assets"),
+		FormsDir:                   getEnv("FORMS_DIR", "./templates/forms"),
+		GeneratedPath:              getEnv("GENERATED_PATH", "./generated"),
+		MaxUploadSize:              int64(parseInt(getEnv("MAX_UPLOAD_SIZE", "20971520"), 20971520)),
+		MaxBatchSize:               parseInt(getEnv("MAX_BATCH_SIZE", "500"), 500),
+		TemplateCacheSize:          int64(parseInt(getEnv("TEMPLATE_CACHE_SIZE", "268435456"), 268435456)),
+		MissingKeyPolicy:           getEnv("MISSING_KEY_POLICY", "keep"),
+		Locale:                     getEnv("LOCALE", "default"),
+		StreamingThreshold:         parseInt(getEnv("STREAMING_THRESHOLD", "5000"), 5000),
+		DefaultVATRate:             parseVATRate(getEnv("DEFAULT_VAT_RATE", "20")),
+		TimeZone:                   parseTimeZone(getEnv("TIME_ZONE", "Europe/Moscow")),
+		DraftWatermark:             getEnv("DRAFT_WATERMARK", "DRAFT"),
+		PDFFontPath:                getEnv("PDF_FONT_PATH", ""),
+		PDFBoldFontPath:            getEnv("PDF_BOLD_FONT_PATH", ""),
+		LogLevel:                   getEnv("LOG_LEVEL", "info"),
+		LogFormat:                  getEnv("LOG_FORMAT", "json"),
+		CleanupEnabled:             getEnv("CLEANUP_ENABLED", "false") == "true",
+		CleanupInterval:            parseDuration(getEnv("CLEANUP_INTERVAL", "24h"), 24*time.Hour),
+		FileRetentionDays:          parseInt(getEnv("FILE_RETENTION_DAYS", "7"), 7),
 	}
 
 	log.Printf("Configuration loaded successfully")
@@ -90,3 +129,25 @@ func parseInt(value string, defaultValue int) int {
 	}
 	return result
 }
+
+// parseVATRate parses the rate of positions without a VAT rate, an invalid
+// rate falls back to 20%
+func parseVATRate(value string) models.VATRate {
+	rate := models.VATRate(value)
+	if !rate.IsValid() {
+		log.Printf("Invalid DEFAULT_VAT_RATE %q, using 20", value)
+		return models.VATRate20
+	}
+	return rate
+}
+
+// parseTimeZone loads the business time zone acts are dated in, an unknown
+// zone falls back to UTC
+func parseTimeZone(value string) *time.Location {
+	location, err := time.LoadLocation(value)
+	if err != nil || location == time.Local {
+		log.Printf("Invalid TIME_ZONE %q, using UTC", value)
+		return time.UTC
+	}
+	return location
+}
@@ -1,13 +1,20 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/services"
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
 )
@@ -52,6 +59,18 @@ func (h *ActHandler) CreateAct(c *gin.Context) {
 	id, err := h.service.CreateAct(c.Request.Context(), &act)
 	if err != nil {
 		utils.LogMethodError("ActHandler.CreateAct", err)
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			utils.RespondWithError(c, http.StatusBadRequest, "Template not found")
+			return
+		}
+		if errors.Is(err, repository.ErrContractNotFound) {
+			utils.RespondWithError(c, http.StatusBadRequest, "Contract not found")
+			return
+		}
+		if errors.Is(err, services.ErrInvalidAct) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create act")
 		return
 	}
@@ -64,7 +83,107 @@ func (h *ActHandler) CreateAct(c *gin.Context) {
 	})
 }
 
-// GenerateAct handles GET /api/act/generate?id=xxx
+// UpdateAct handles POST /api/act/update?id=xxx with the new content of the
+// act. Signed and cancelled acts can not be edited.
+func (h *ActHandler) UpdateAct(c *gin.Context) {
+	utils.LogMethodInit("ActHandler.UpdateAct")
+
+	actID := c.Query("id")
+	if actID == "" {
+		utils.LogError("ID parameter is missing in request")
+		utils.RespondWithError(c, http.StatusBadRequest, "ID parameter is required")
+		return
+	}
+
+	utils.LogInfo("Received request to update act with ID: %s from IP: %s", actID, c.ClientIP())
+
+	var act models.Act
+	if err := c.ShouldBindJSON(&act); err != nil {
+		utils.LogError("Error binding JSON: %v", err)
+		utils.LogMethodError("ActHandler.UpdateAct", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if act.BigAct == nil {
+		utils.LogError("BigAct is required but not provided")
+		utils.RespondWithError(c, http.StatusBadRequest, "BigAct is required")
+		return
+	}
+
+	if err := h.service.UpdateAct(c.Request.Context(), actID, &act); err != nil {
+		utils.LogMethodError("ActHandler.UpdateAct", err)
+		switch {
+		case errors.Is(err, repository.ErrActNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Act not found")
+		case errors.Is(err, services.ErrActLocked):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		case errors.Is(err, repository.ErrActConflict):
+			utils.RespondWithError(c, http.StatusConflict, "Act was changed concurrently, reload it and try again")
+		case errors.Is(err, repository.ErrTemplateNotFound):
+			utils.RespondWithError(c, http.StatusBadRequest, "Template not found")
+		case errors.Is(err, repository.ErrContractNotFound):
+			utils.RespondWithError(c, http.StatusBadRequest, "Contract not found")
+		case errors.Is(err, services.ErrInvalidAct):
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update act")
+		}
+		return
+	}
+
+	utils.LogMethodSuccess("ActHandler.UpdateAct")
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"id": actID,
+	})
+}
+
+// TransitionAct handles POST /api/act/status?id=xxx with the new status,
+// the actor and an optional comment. It returns the status and its history.
+func (h *ActHandler) TransitionAct(c *gin.Context) {
+	utils.LogMethodInit("ActHandler.TransitionAct")
+
+	actID := c.Query("id")
+	if actID == "" {
+		utils.LogError("ID parameter is missing in request")
+		utils.RespondWithError(c, http.StatusBadRequest, "ID parameter is required")
+		return
+	}
+
+	var req services.TransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("Error binding JSON: %v", err)
+		utils.LogMethodError("ActHandler.TransitionAct", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	utils.LogInfo("Received request to change act %s to %s from IP: %s", actID, req.Status, c.ClientIP())
+
+	act, err := h.service.TransitionAct(c.Request.Context(), actID, req)
+	if err != nil {
+		utils.LogMethodError("ActHandler.TransitionAct", err)
+		switch {
+		case errors.Is(err, repository.ErrActNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Act not found")
+		case errors.Is(err, services.ErrInvalidTransition):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		case errors.Is(err, repository.ErrActConflict):
+			utils.RespondWithError(c, http.StatusConflict, "Act was changed concurrently, reload it and try again")
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to change act status")
+		}
+		return
+	}
+
+	utils.LogMethodSuccess("ActHandler.TransitionAct")
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"id":            actID,
+		"status":        act.Status,
+		"statusHistory": act.StatusHistory,
+	})
+}
+
+// GenerateAct handles GET /api/act/generate?id=xxx&missing=keep|blank|error&form=ks2|ks3&format=xlsx|pdf
 func (h *ActHandler) GenerateAct(c *gin.Context) {
 	utils.LogMethodInit("ActHandler.GenerateAct")
 
@@ -76,12 +195,55 @@ func (h *ActHandler) GenerateAct(c *gin.Context) {
 		return
 	}
 
+	// Get the optional missing key policy
+	policy, err := services.ParseMissingKeyPolicy(c.Query("missing"))
+	if err != nil {
+		utils.LogError("Invalid missing parameter: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Get the optional standard form
+	form, err := services.ParseFormType(c.Query("form"))
+	if err != nil {
+		utils.LogError("Invalid form parameter: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Get the optional output format
+	format, err := services.ParseOutputFormat(c.Query("format"))
+	if err != nil {
+		utils.LogError("Invalid format parameter: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	utils.LogInfo("Received request to generate act with ID: %s from IP: %s", actID, c.ClientIP())
 
 	// Generate act
-	downloadLink, err := h.service.GenerateAct(c.Request.Context(), actID)
+	downloadLink, err := h.service.GenerateAct(c.Request.Context(), actID, services.GenerateOptions{
+		MissingKeyPolicy: policy,
+		Form:             form,
+		Format:           format,
+	})
 	if err != nil {
 		utils.LogMethodError("ActHandler.GenerateAct", err)
+		var missingErr *services.MissingPlaceholdersError
+		if errors.As(err, &missingErr) {
+			utils.RespondWithErrorDetails(c, http.StatusUnprocessableEntity, "Template has unresolved placeholders", gin.H{
+				"missing": missingErr.Placeholders,
+			})
+			return
+		}
+		if errors.Is(err, repository.ErrActConflict) {
+			utils.RespondWithError(c, http.StatusConflict, "Act was changed concurrently, try again")
+			return
+		}
+		if errors.Is(err, services.ErrPDFUnsupportedText) {
+			utils.RespondWithError(c, http.StatusUnprocessableEntity, "Act has characters the PDF font can not print")
+			return
+		}
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to generate act")
 		return
 	}
@@ -94,6 +256,282 @@ func (h *ActHandler) GenerateAct(c *gin.Context) {
 	})
 }
 
+// VerifyAct handles GET /api/act/verify?id=xxx, the link encoded in the QR
+// code of printed acts. It confirms that the act exists and returns its status.
+func (h *ActHandler) VerifyAct(c *gin.Context) {
+	utils.LogMethodInit("ActHandler.VerifyAct")
+
+	actID := c.Query("id")
+	if actID == "" {
+		utils.LogError("ID parameter is missing in request")
+		utils.RespondWithError(c, http.StatusBadRequest, "ID parameter is required")
+		return
+	}
+
+	utils.LogInfo("Received request to verify act with ID: %s from IP: %s", actID, c.ClientIP())
+
+	act, err := h.service.VerifyAct(c.Request.Context(), actID)
+	if err != nil {
+		utils.LogMethodError("ActHandler.VerifyAct", err)
+		if errors.Is(err, repository.ErrActNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Act not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to verify act")
+		return
+	}
+
+	// Anyone holding the printed act can call this, so amounts are left out
+	response := gin.H{
+		"id":         act.ID.Hex(),
+		"templateId": act.TemplateID,
+		"status":     act.CurrentStatus(),
+		"createdAt":  act.CreatedAt,
+		"updatedAt":  act.UpdatedAt,
+	}
+
+	utils.LogMethodSuccess("ActHandler.VerifyAct")
+	utils.RespondWithJSON(c, http.StatusOK, response)
+}
+
+// ImportAct handles POST /api/act/import (multipart form with file and
+// optional templateId, version and save fields). It returns the act read
+// from the filled workbook; with save=true the act is also created unless
+// some cells could not be read.
+func (h *ActHandler) ImportAct(c *gin.Context) {
+	utils.LogMethodInit("ActHandler.ImportAct")
+	utils.LogInfo("Received request to import act from IP: %s", c.ClientIP())
+
+	if !parseUploadForm(c, h.config.MaxUploadSize) {
+		return
+	}
+
+	version := 0
+	if versionText := c.PostForm("version"); versionText != "" {
+		var err error
+		version, err = strconv.Atoi(versionText)
+		if err != nil || version < 1 {
+			utils.LogError("Invalid version parameter: %s", versionText)
+			utils.RespondWithError(c, http.StatusBadRequest, "version must be a positive number")
+			return
+		}
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.LogError("Error reading uploaded file: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.LogMethodError("ActHandler.ImportAct", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		utils.LogMethodError("ActHandler.ImportAct", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+
+	save := c.PostForm("save") == "true"
+	result, err := h.service.ImportAct(c.Request.Context(), services.ImportOptions{
+		TemplateID:      c.PostForm("templateId"),
+		TemplateVersion: version,
+		Content:         content,
+		Save:            save,
+	})
+	if err != nil {
+		utils.LogMethodError("ActHandler.ImportAct", err)
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Template not found")
+			return
+		}
+		if errors.Is(err, services.ErrInvalidAct) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to import act")
+		return
+	}
+
+	if save && result.ID == "" {
+		utils.LogError("Act was not created, %d cells could not be read", len(result.Issues))
+		utils.RespondWithErrorDetails(c, http.StatusUnprocessableEntity, "Workbook has cells that could not be read", gin.H{
+			"act":    result.Act,
+			"issues": result.Issues,
+		})
+		return
+	}
+
+	status := http.StatusOK
+	if result.ID != "" {
+		status = http.StatusCreated
+		utils.LogInfo("Successfully imported act via API, ID: %s", result.ID)
+	}
+	utils.LogMethodSuccess("ActHandler.ImportAct")
+	utils.RespondWithJSON(c, status, result)
+}
+
+// batchRequest is the body of POST /api/act/batch. Acts are selected by ID
+// or by contract and a creation period given as dates, to included.
+type batchRequest struct {
+	IDs            []string `json:"ids"`
+	ContractID     string   `json:"contractId"`
+	ContractNumber string   `json:"contractNumber"`
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	Missing        string   `json:"missing"`
+	Form           string   `json:"form"`
+	Format         string   `json:"format"`
+}
+
+// batchDateLayout is the layout of the period dates of a batch request
+const batchDateLayout = "2006-01-02"
+
+// GenerateBatch handles POST /api/act/batch. It generates the selected acts
+// and streams a ZIP archive of the files with a manifest.json recording the
+// status of each act.
+func (h *ActHandler) GenerateBatch(c *gin.Context) {
+	utils.LogMethodInit("ActHandler.GenerateBatch")
+	utils.LogInfo("Received request to generate a batch of acts from IP: %s", c.ClientIP())
+
+	ids, opts, ok := h.bindBatchRequest(c, "ActHandler.GenerateBatch")
+	if !ok {
+		return
+	}
+
+	// The archive is streamed, errors of single acts end up in the manifest
+	filename := fmt.Sprintf("acts_%d.zip", time.Now().Unix())
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	manifest, err := h.service.GenerateBatch(c.Request.Context(), ids, opts, c.Writer)
+	if err != nil {
+		// The response has started, the client gets a truncated archive
+		utils.LogMethodError("ActHandler.GenerateBatch", err)
+		return
+	}
+
+	utils.LogInfo("Successfully streamed batch %s: %d generated, %d failed", filename, manifest.Generated, manifest.Failed)
+	utils.LogMethodSuccess("ActHandler.GenerateBatch")
+}
+
+// bindBatchRequest reads a batchRequest and finds the acts it selects. When
+// it fails the error response is already written.
+func (h *ActHandler) bindBatchRequest(c *gin.Context, method string) ([]string, services.GenerateOptions, bool) {
+	var opts services.GenerateOptions
+	var req batchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("Error binding JSON: %v", err)
+		utils.LogMethodError(method, err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request body")
+		return nil, opts, false
+	}
+
+	batch := services.BatchRequest{IDs: req.IDs, ContractID: req.ContractID, ContractNumber: req.ContractNumber}
+	var err error
+	if req.From != "" {
+		if batch.From, err = time.ParseInLocation(batchDateLayout, req.From, h.config.TimeZone); err != nil {
+			utils.LogError("Invalid from date: %s", req.From)
+			utils.RespondWithError(c, http.StatusBadRequest, "from must be a date like 2026-01-31")
+			return nil, opts, false
+		}
+	}
+	if req.To != "" {
+		if batch.To, err = time.ParseInLocation(batchDateLayout, req.To, h.config.TimeZone); err != nil {
+			utils.LogError("Invalid to date: %s", req.To)
+			utils.RespondWithError(c, http.StatusBadRequest, "to must be a date like 2026-01-31")
+			return nil, opts, false
+		}
+		batch.To = batch.To.AddDate(0, 0, 1)
+	}
+
+	policy, err := services.ParseMissingKeyPolicy(req.Missing)
+	if err != nil {
+		utils.LogError("Invalid missing parameter: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return nil, opts, false
+	}
+	form, err := services.ParseFormType(req.Form)
+	if err != nil {
+		utils.LogError("Invalid form parameter: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return nil, opts, false
+	}
+	format, err := services.ParseOutputFormat(req.Format)
+	if err != nil {
+		utils.LogError("Invalid format parameter: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return nil, opts, false
+	}
+	opts = services.GenerateOptions{MissingKeyPolicy: policy, Form: form, Format: format}
+
+	ids, err := h.service.FindBatchActs(c.Request.Context(), batch)
+	if err != nil {
+		utils.LogMethodError(method, err)
+		if errors.Is(err, services.ErrInvalidBatch) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return nil, opts, false
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to find acts")
+		return nil, opts, false
+	}
+	if len(ids) == 0 {
+		utils.LogError("No acts match the batch filter")
+		utils.RespondWithError(c, http.StatusNotFound, "No acts match the filter")
+		return nil, opts, false
+	}
+	return ids, opts, true
+}
+
+// GenerateCombined handles POST /api/act/combined. It renders the selected
+// acts into one workbook with a sheet per act and a summary sheet, and
+// returns its download link. The body is the same as of /api/act/batch.
+func (h *ActHandler) GenerateCombined(c *gin.Context) {
+	utils.LogMethodInit("ActHandler.GenerateCombined")
+	utils.LogInfo("Received request to generate a combined workbook from IP: %s", c.ClientIP())
+
+	ids, opts, ok := h.bindBatchRequest(c, "ActHandler.GenerateCombined")
+	if !ok {
+		return
+	}
+
+	downloadLink, err := h.service.GenerateCombined(c.Request.Context(), ids, opts)
+	if err != nil {
+		utils.LogMethodError("ActHandler.GenerateCombined", err)
+		var missingErr *services.MissingPlaceholdersError
+		if errors.As(err, &missingErr) {
+			utils.RespondWithErrorDetails(c, http.StatusUnprocessableEntity, "Template has unresolved placeholders", gin.H{
+				"missing": missingErr.Placeholders,
+			})
+			return
+		}
+		if errors.Is(err, services.ErrPDFUnsupportedText) {
+			utils.RespondWithError(c, http.StatusUnprocessableEntity, "Acts have characters the PDF font can not print")
+			return
+		}
+		if errors.Is(err, services.ErrInvalidBatch) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to generate combined workbook")
+		return
+	}
+
+	utils.LogInfo("Successfully generated combined workbook via API, download link: %s", downloadLink)
+	utils.LogMethodSuccess("ActHandler.GenerateCombined")
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"downloadLink": downloadLink,
+	})
+}
+
 // DownloadAct handles GET /api/act/download/:filename
 func (h *ActHandler) DownloadAct(c *gin.Context) {
 	utils.LogMethodInit("ActHandler.DownloadAct")
@@ -123,7 +561,11 @@ func (h *ActHandler) DownloadAct(c *gin.Context) {
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Transfer-Encoding", "binary")
 	c.Header("Content-Disposition", "attachment; filename="+filename)
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	if strings.EqualFold(filepath.Ext(filename), ".pdf") {
+		c.Header("Content-Type", "application/pdf")
+	} else {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	}
 
 	utils.LogInfo("Sending file to client: %s", filename)
 
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/services"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+// AssetHandler handles HTTP requests for image assets
+type AssetHandler struct {
+	service services.AssetService
+	config  *config.Config
+}
+
+// NewAssetHandler creates a new AssetHandler
+func NewAssetHandler(service services.AssetService, cfg *config.Config) *AssetHandler {
+	return &AssetHandler{
+		service: service,
+		config:  cfg,
+	}
+}
+
+// UploadAsset handles POST /api/asset/upload (multipart form with file)
+func (h *AssetHandler) UploadAsset(c *gin.Context) {
+	utils.LogMethodInit("AssetHandler.UploadAsset")
+	utils.LogInfo("Received request to upload asset from IP: %s", c.ClientIP())
+
+	if !parseUploadForm(c, h.config.MaxUploadSize) {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.LogError("Error reading uploaded file: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.LogMethodError("AssetHandler.UploadAsset", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		utils.LogMethodError("AssetHandler.UploadAsset", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+
+	id, err := h.service.Upload(fileHeader.Filename, content)
+	if err != nil {
+		utils.LogMethodError("AssetHandler.UploadAsset", err)
+		if errors.Is(err, services.ErrInvalidAsset) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to upload asset")
+		return
+	}
+
+	utils.LogMethodSuccess("AssetHandler.UploadAsset")
+	utils.RespondWithJSON(c, http.StatusCreated, gin.H{
+		"id": id,
+	})
+}
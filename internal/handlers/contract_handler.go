@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/services"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+// ContractHandler handles HTTP requests for contracts
+type ContractHandler struct {
+	service    services.ContractService
+	actService services.ActService
+	config     *config.Config
+}
+
+// NewContractHandler creates a new ContractHandler
+func NewContractHandler(service services.ContractService, actService services.ActService, cfg *config.Config) *ContractHandler {
+	return &ContractHandler{
+		service:    service,
+		actService: actService,
+		config:     cfg,
+	}
+}
+
+// CreateContract handles POST /api/contract/create
+func (h *ContractHandler) CreateContract(c *gin.Context) {
+	utils.LogMethodInit("ContractHandler.CreateContract")
+	utils.LogInfo("Received request to create contract from IP: %s", c.ClientIP())
+
+	var contract models.Contract
+	if err := c.ShouldBindJSON(&contract); err != nil {
+		utils.LogError("Error binding JSON: %v", err)
+		utils.LogMethodError("ContractHandler.CreateContract", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	id, err := h.service.CreateContract(c.Request.Context(), &contract)
+	if err != nil {
+		utils.LogMethodError("ContractHandler.CreateContract", err)
+		if errors.Is(err, services.ErrInvalidContract) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create contract")
+		return
+	}
+
+	utils.LogInfo("Successfully created contract via API, ID: %s", id)
+	utils.LogMethodSuccess("ContractHandler.CreateContract")
+	utils.RespondWithJSON(c, http.StatusCreated, gin.H{
+		"id": id,
+	})
+}
+
+// ListContracts handles GET /api/contract/list
+func (h *ContractHandler) ListContracts(c *gin.Context) {
+	utils.LogMethodInit("ContractHandler.ListContracts")
+
+	contracts, err := h.service.ListContracts(c.Request.Context())
+	if err != nil {
+		utils.LogMethodError("ContractHandler.ListContracts", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list contracts")
+		return
+	}
+
+	utils.LogMethodSuccess("ContractHandler.ListContracts")
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"contracts": contracts,
+	})
+}
+
+// GetContract handles GET /api/contract/get?id=xxx. It returns the contract
+// with the cumulative totals of its acts to date and its remaining balance.
+func (h *ContractHandler) GetContract(c *gin.Context) {
+	utils.LogMethodInit("ContractHandler.GetContract")
+
+	contractID := c.Query("id")
+	if contractID == "" {
+		utils.LogError("ID parameter is missing in request")
+		utils.RespondWithError(c, http.StatusBadRequest, "ID parameter is required")
+		return
+	}
+
+	utils.LogInfo("Received request to get contract with ID: %s from IP: %s", contractID, c.ClientIP())
+
+	summary, err := h.actService.ContractTotals(c.Request.Context(), contractID)
+	if err != nil {
+		utils.LogMethodError("ContractHandler.GetContract", err)
+		if errors.Is(err, repository.ErrContractNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Contract not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to get contract")
+		return
+	}
+
+	utils.LogMethodSuccess("ContractHandler.GetContract")
+	utils.RespondWithJSON(c, http.StatusOK, summary)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/services"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+// TemplateHandler handles HTTP requests for the template registry
+type TemplateHandler struct {
+	service services.TemplateService
+	config  *config.Config
+}
+
+// NewTemplateHandler creates a new TemplateHandler
+func NewTemplateHandler(service services.TemplateService, cfg *config.Config) *TemplateHandler {
+	return &TemplateHandler{
+		service: service,
+		config:  cfg,
+	}
+}
+
+// activateTemplateRequest is the body of POST /api/template/activate
+type activateTemplateRequest struct {
+	TemplateID string `json:"templateId" binding:"required"`
+	Version    int    `json:"version" binding:"required,min=1"`
+}
+
+// UploadTemplate handles POST /api/template/upload (multipart form with
+// file, templateId and optional activate and missingKeyPolicy fields)
+func (h *TemplateHandler) UploadTemplate(c *gin.Context) {
+	utils.LogMethodInit("TemplateHandler.UploadTemplate")
+	utils.LogInfo("Received request to upload template from IP: %s", c.ClientIP())
+
+	if !parseUploadForm(c, h.config.MaxUploadSize) {
+		return
+	}
+
+	templateID := c.PostForm("templateId")
+	if templateID == "" {
+		utils.LogError("templateId is missing in request")
+		utils.RespondWithError(c, http.StatusBadRequest, "templateId is required")
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.LogError("Error reading uploaded file: %v", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.LogMethodError("TemplateHandler.UploadTemplate", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		utils.LogMethodError("TemplateHandler.UploadTemplate", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+
+	template, err := h.service.Upload(c.Request.Context(), services.TemplateUpload{
+		TemplateID:       templateID,
+		FileName:         fileHeader.Filename,
+		Content:          content,
+		Activate:         c.PostForm("activate") == "true",
+		MissingKeyPolicy: c.PostForm("missingKeyPolicy"),
+		Locale:           c.PostForm("locale"),
+	})
+	if err != nil {
+		utils.LogMethodError("TemplateHandler.UploadTemplate", err)
+		if errors.Is(err, services.ErrInvalidTemplate) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrTemplateVersionExists) {
+			utils.RespondWithError(c, http.StatusConflict, "Template was uploaded concurrently, retry the upload")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to upload template")
+		return
+	}
+
+	utils.LogMethodSuccess("TemplateHandler.UploadTemplate")
+	utils.RespondWithJSON(c, http.StatusCreated, template)
+}
+
+// ListTemplates handles GET /api/template/list
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+	utils.LogMethodInit("TemplateHandler.ListTemplates")
+
+	templates, err := h.service.List(c.Request.Context())
+	if err != nil {
+		utils.LogMethodError("TemplateHandler.ListTemplates", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list templates")
+		return
+	}
+
+	utils.LogMethodSuccess("TemplateHandler.ListTemplates")
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"templates": templates,
+	})
+}
+
+// ActivateTemplate handles POST /api/template/activate
+func (h *TemplateHandler) ActivateTemplate(c *gin.Context) {
+	utils.LogMethodInit("TemplateHandler.ActivateTemplate")
+
+	var req activateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("Error binding JSON: %v", err)
+		utils.LogMethodError("TemplateHandler.ActivateTemplate", err)
+		utils.RespondWithError(c, http.StatusBadRequest, "templateId and version are required")
+		return
+	}
+
+	utils.LogInfo("Received request to activate template %s version %d from IP: %s", req.TemplateID, req.Version, c.ClientIP())
+
+	if err := h.service.Activate(c.Request.Context(), req.TemplateID, req.Version); err != nil {
+		utils.LogMethodError("TemplateHandler.ActivateTemplate", err)
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Template not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to activate template")
+		return
+	}
+
+	utils.LogMethodSuccess("TemplateHandler.ActivateTemplate")
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"templateId": req.TemplateID,
+		"version":    req.Version,
+	})
+}
+
+// InspectTemplate handles GET /api/template/inspect?templateId=xxx&version=1&actId=xxx.
+// All parameters are optional: without templateId the default template is
+// inspected, with actId the act is used as sample data.
+func (h *TemplateHandler) InspectTemplate(c *gin.Context) {
+	utils.LogMethodInit("TemplateHandler.InspectTemplate")
+
+	templateID := c.Query("templateId")
+	actID := c.Query("actId")
+
+	version := 0
+	if versionText := c.Query("version"); versionText != "" {
+		var err error
+		version, err = strconv.Atoi(versionText)
+		if err != nil || version < 1 {
+			utils.LogError("Invalid version parameter: %s", versionText)
+			utils.RespondWithError(c, http.StatusBadRequest, "version must be a positive number")
+			return
+		}
+	}
+
+	utils.LogInfo("Received request to inspect template %q version %d with act %q from IP: %s", templateID, version, actID, c.ClientIP())
+
+	report, err := h.service.Inspect(c.Request.Context(), templateID, version, actID)
+	if err != nil {
+		utils.LogMethodError("TemplateHandler.InspectTemplate", err)
+		switch {
+		case errors.Is(err, repository.ErrTemplateNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Template not found")
+		case errors.Is(err, repository.ErrActNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Act not found")
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to inspect template")
+		}
+		return
+	}
+
+	utils.LogMethodSuccess("TemplateHandler.InspectTemplate")
+	utils.RespondWithJSON(c, http.StatusOK, report)
+}
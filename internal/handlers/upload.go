@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+// parseUploadForm parses a multipart upload whose body may not exceed
+// maxSize bytes. It responds with 413 to larger bodies and 400 to malformed
+// ones and reports whether the handler may go on.
+func parseUploadForm(c *gin.Context, maxSize int64) bool {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+
+	err := c.Request.ParseMultipartForm(32 << 20)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		utils.LogError("Upload from %s exceeds %d bytes", c.ClientIP(), maxSize)
+		utils.RespondWithError(c, http.StatusRequestEntityTooLarge, "Upload is too large")
+		return false
+	}
+	utils.LogError("Error parsing upload: %v", err)
+	utils.RespondWithError(c, http.StatusBadRequest, "Invalid multipart form")
+	return false
+}
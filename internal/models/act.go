@@ -8,9 +8,16 @@ import (
 
 // Act represents the main act document
 type Act struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	BigAct    *BigAct            `json:"bigAct,omitempty" bson:"bigAct,omitempty"`
-	Positions []Position         `json:"positions,omitempty" bson:"positions,omitempty"`
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TemplateID      string             `json:"templateId,omitempty" bson:"templateId,omitempty"`
+	TemplateVersion int                `json:"templateVersion,omitempty" bson:"templateVersion,omitempty"`
+	ContractID      string             `json:"contractId,omitempty" bson:"contractId,omitempty"`
+	Locale          string             `json:"locale,omitempty" bson:"locale,omitempty"`
+	Status          ActStatus          `json:"status,omitempty" bson:"status,omitempty"`
+	StatusHistory   []StatusChange     `json:"statusHistory,omitempty" bson:"statusHistory,omitempty"`
+	Revision        int64              `json:"revision" bson:"revision"`
+	BigAct          *BigAct            `json:"bigAct,omitempty" bson:"bigAct,omitempty"`
+	Positions       []Position         `json:"positions,omitempty" bson:"positions,omitempty"`
+	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
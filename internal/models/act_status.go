@@ -0,0 +1,81 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// ActStatus is the lifecycle state of an act
+type ActStatus string
+
+// Act statuses
+const (
+	ActStatusDraft     ActStatus = "draft"
+	ActStatusSubmitted ActStatus = "submitted" // sent to the customer for review
+	ActStatusApproved  ActStatus = "approved"  // accepted by the customer
+	ActStatusSigned    ActStatus = "signed"
+	ActStatusCancelled ActStatus = "cancelled"
+)
+
+// ActStatuses lists the statuses in lifecycle order
+var ActStatuses = []ActStatus{ActStatusDraft, ActStatusSubmitted, ActStatusApproved, ActStatusSigned, ActStatusCancelled}
+
+// actTransitions lists the statuses every status may change to. Submitted
+// acts may be returned to draft; signed and cancelled acts are final.
+var actTransitions = map[ActStatus][]ActStatus{
+	ActStatusDraft:     {ActStatusSubmitted, ActStatusCancelled},
+	ActStatusSubmitted: {ActStatusDraft, ActStatusApproved, ActStatusCancelled},
+	ActStatusApproved:  {ActStatusSigned, ActStatusCancelled},
+}
+
+// IsValid reports whether the status is one of the known statuses
+func (s ActStatus) IsValid() bool {
+	for _, status := range ActStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition reports whether an act may change from the status to another
+func (s ActStatus) CanTransition(to ActStatus) bool {
+	for _, next := range actTransitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLocked reports whether acts with the status can no longer be edited
+func (s ActStatus) IsLocked() bool {
+	return s == ActStatusSigned || s == ActStatusCancelled
+}
+
+// FinalStatuses are the statuses of acts accepted by the customer
+var FinalStatuses = []ActStatus{ActStatusApproved, ActStatusSigned}
+
+// IsFinal reports whether the act has been accepted by the customer, so
+// that it counts towards the totals of its contract
+func (s ActStatus) IsFinal() bool {
+	return slices.Contains(FinalStatuses, s)
+}
+
+// StatusChange records a transition of an act from one status to another
+type StatusChange struct {
+	From    ActStatus `json:"from" bson:"from"`
+	To      ActStatus `json:"to" bson:"to"`
+	Actor   string    `json:"actor" bson:"actor"`
+	Comment string    `json:"comment,omitempty" bson:"comment,omitempty"`
+	At      time.Time `json:"at" bson:"at"`
+}
+
+// CurrentStatus returns the status of the act; acts created before
+// statuses were introduced are drafts
+func (a *Act) CurrentStatus() ActStatus {
+	if a.Status == "" {
+		return ActStatusDraft
+	}
+	return a.Status
+}
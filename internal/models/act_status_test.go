@@ -0,0 +1,45 @@
+package models
+
+import "testing"
+
+func TestActStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from     ActStatus
+		to       ActStatus
+		expected bool
+	}{
+		{from: ActStatusDraft, to: ActStatusSubmitted, expected: true},
+		{from: ActStatusDraft, to: ActStatusApproved, expected: false},
+		{from: ActStatusDraft, to: ActStatusCancelled, expected: true},
+		{from: ActStatusSubmitted, to: ActStatusDraft, expected: true},
+		{from: ActStatusSubmitted, to: ActStatusApproved, expected: true},
+		{from: ActStatusSubmitted, to: ActStatusSigned, expected: false},
+		{from: ActStatusApproved, to: ActStatusSigned, expected: true},
+		{from: ActStatusApproved, to: ActStatusDraft, expected: false},
+		{from: ActStatusSigned, to: ActStatusCancelled, expected: false},
+		{from: ActStatusCancelled, to: ActStatusDraft, expected: false},
+		{from: ActStatusDraft, to: ActStatusDraft, expected: false},
+	}
+
+	for _, tt := range tests {
+		if result := tt.from.CanTransition(tt.to); result != tt.expected {
+			t.Errorf("%s.CanTransition(%s) = %v, expected %v", tt.from, tt.to, result, tt.expected)
+		}
+	}
+}
+
+func TestActCurrentStatus(t *testing.T) {
+	// Acts stored before statuses were introduced are drafts
+	act := &Act{}
+	if status := act.CurrentStatus(); status != ActStatusDraft || status.IsLocked() || status.IsFinal() {
+		t.Errorf("CurrentStatus() = %q, expected an unlocked draft", status)
+	}
+
+	act.Status = ActStatusSigned
+	if status := act.CurrentStatus(); !status.IsLocked() || !status.IsFinal() {
+		t.Errorf("%s acts are expected to be locked and final", status)
+	}
+	if ActStatus("archived").IsValid() {
+		t.Error("unknown status is reported as valid")
+	}
+}
@@ -3,9 +3,12 @@ package models
 // BigAct represents aggregated information for a big act
 type BigAct struct {
 	Changed                 bool                   `json:"changed" bson:"changed"`
-	TotalCost               float64                `json:"totalCost,omitempty" bson:"totalCost,omitempty"`
-	TotalCostInspection     float64                `json:"totalCostInspection,omitempty" bson:"totalCostInspection,omitempty"`
-	TotalCostConsiderations float64                `json:"totalCostConsiderations,omitempty" bson:"totalCostConsiderations,omitempty"`
+	TotalCost               Money                  `json:"totalCost,omitempty" bson:"totalCost,omitempty"`
+	TotalCostInspection     Money                  `json:"totalCostInspection,omitempty" bson:"totalCostInspection,omitempty"`
+	TotalCostConsiderations Money                  `json:"totalCostConsiderations,omitempty" bson:"totalCostConsiderations,omitempty"`
+	VATTotal                Money                  `json:"vatTotal,omitempty" bson:"vatTotal,omitempty"`
+	TotalWithVAT            Money                  `json:"totalWithVat,omitempty" bson:"totalWithVat,omitempty"`
+	VATBreakdown            []VATAmount            `json:"vatBreakdown,omitempty" bson:"vatBreakdown,omitempty"`
 	PositionIDs             string                 `json:"positionIds,omitempty" bson:"positionIds,omitempty"`
 	BigActLink              string                 `json:"bigActLink,omitempty" bson:"bigActLink,omitempty"`
 	TextFields              map[string]interface{} `json:"textFields,omitempty" bson:"textFields,omitempty"`
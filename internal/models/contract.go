@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Contract is the construction contract acts are issued under. Amount is
+// the price of the contract including VAT; zero when it is open.
+type Contract struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Number     string             `json:"number" bson:"number"`
+	Date       time.Time          `json:"date" bson:"date"`
+	Customer   string             `json:"customer,omitempty" bson:"customer,omitempty"`
+	Contractor string             `json:"contractor,omitempty" bson:"contractor,omitempty"`
+	ObjectName string             `json:"objectName,omitempty" bson:"objectName,omitempty"`
+	Amount     Money              `json:"amount,omitempty" bson:"amount,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,248 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// MoneyScale is the number of decimal places money amounts are kept with
+const MoneyScale = 2
+
+// moneyUnit is the number of kopecks in a ruble
+const moneyUnit = 100
+
+// Money is an exact amount of money kept as a whole number of kopecks.
+// Amounts with more than two decimal places are rounded to kopecks half away
+// from zero, e.g. 0.125 -> 0.13 and -0.125 -> -0.13; sums are exact.
+// It is written as a JSON number and stored as a BSON Decimal128.
+type Money int64
+
+// ErrInvalidMoney is returned when a text is not a decimal amount
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// NewMoney creates an amount from rubles and kopecks, e.g. NewMoney(10, 50) is 10.50.
+// The kopecks are added to the rubles, so a negative amount takes negative
+// kopecks: NewMoney(-10, -50) is -10.50, while NewMoney(-10, 50) is -9.50.
+func NewMoney(rubles, kopecks int64) Money {
+	return Money(rubles*moneyUnit + kopecks)
+}
+
+// MoneyFromFloat converts a float amount, rounding it to kopecks half away from zero
+func MoneyFromFloat(value float64) Money {
+	return Money(math.Round(value * moneyUnit))
+}
+
+// ParseMoney parses a decimal amount such as "1234.56", "-0.5" or "1e3"
+// exactly, rounding it to kopecks half away from zero
+func ParseMoney(text string) (Money, error) {
+	text = strings.TrimSpace(text)
+	rat, ok := new(big.Rat).SetString(text)
+	if !ok || strings.ContainsAny(text, "/") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, text)
+	}
+	return moneyFromRat(rat, text)
+}
+
+// moneyFromRat rounds an exact rational amount to kopecks half away from zero
+func moneyFromRat(rat *big.Rat, text string) (Money, error) {
+	units, ok := roundRat(rat, moneyUnit)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidMoney, text)
+	}
+	return Money(units), nil
+}
+
+// roundRat converts an exact rational number into whole units of 1/unit,
+// rounding half away from zero; false means it does not fit into int64
+func roundRat(rat *big.Rat, unit int64) (int64, bool) {
+	scaled := new(big.Rat).Mul(rat, big.NewRat(unit, 1))
+
+	// Round the absolute value half up, then restore the sign
+	num := new(big.Int).Abs(scaled.Num())
+	den := scaled.Denom()
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	if remainder.Lsh(remainder, 1).Cmp(den) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if scaled.Sign() < 0 {
+		quotient.Neg(quotient)
+	}
+
+	if !quotient.IsInt64() {
+		return 0, false
+	}
+	return quotient.Int64(), true
+}
+
+// Units returns the amount in the smallest units, kopecks
+func (m Money) Units() int64 {
+	return int64(m)
+}
+
+// Scale returns the number of decimal places of the amount
+func (m Money) Scale() int {
+	return MoneyScale
+}
+
+// Float64 returns the amount as a float, for Excel cells and charts
+func (m Money) Float64() float64 {
+	return float64(m) / moneyUnit
+}
+
+// Add returns the sum of the amounts
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+// Sub returns the difference of the amounts
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+// Percent returns the given percentage of the amount, rounded to kopecks
+// half away from zero. The percentage is at most 100 either way, so the
+// result is never larger than the amount; a larger one panics.
+func (m Money) Percent(percent int64) Money {
+	if percent < -100 || percent > 100 {
+		panic(fmt.Sprintf("models: percentage %d is out of range", percent))
+	}
+	product := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(percent)),
+		big.NewInt(100*moneyUnit),
+	)
+	units, _ := roundRat(product, moneyUnit)
+	return Money(units)
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m == 0
+}
+
+// String returns the amount with two decimal places, e.g. "-1234.50"
+func (m Money) String() string {
+	sign := ""
+	kopecks := int64(m)
+	if kopecks < 0 {
+		sign = "-"
+	}
+	abs := uint64(kopecks)
+	if kopecks < 0 {
+		abs = uint64(-kopecks)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/moneyUnit, abs%moneyUnit)
+}
+
+// MarshalJSON writes the amount as a JSON number with two decimal places
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads the amount from a JSON number or a string, parsing
+// the text exactly instead of going through a float
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	text := string(data)
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	value, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = value
+	return nil
+}
+
+// MarshalBSONValue stores the amount as a Decimal128
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	value, err := primitive.ParseDecimal128(m.String())
+	if err != nil {
+		return 0, nil, err
+	}
+	return bsontype.Decimal128, bsoncore.AppendDecimal128(nil, value), nil
+}
+
+// UnmarshalBSONValue reads the amount from a Decimal128. Doubles, integers
+// and strings written before amounts were stored as decimals are accepted too.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	text, err := bsonDecimalText(t, data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMoney, err)
+	}
+	if text == "" {
+		*m = 0
+		return nil
+	}
+
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// bsonDecimalText returns the text of a decimal, double, integer or string
+// BSON value, an empty text for null
+func bsonDecimalText(t bsontype.Type, data []byte) (string, error) {
+	value := bsoncore.Value{Type: t, Data: data}
+
+	switch t {
+	case bsontype.Null, bsontype.Undefined:
+		return "", nil
+	case bsontype.Decimal128:
+		decimal, ok := value.Decimal128OK()
+		if !ok {
+			return "", errors.New("malformed decimal")
+		}
+		return decimal.String(), nil
+	case bsontype.Double:
+		double, ok := value.DoubleOK()
+		if !ok {
+			return "", errors.New("malformed double")
+		}
+		// The shortest text of the double is the number that was meant
+		return strconv.FormatFloat(double, 'f', -1, 64), nil
+	case bsontype.Int32:
+		integer, ok := value.Int32OK()
+		if !ok {
+			return "", errors.New("malformed int32")
+		}
+		return strconv.FormatInt(int64(integer), 10), nil
+	case bsontype.Int64:
+		integer, ok := value.Int64OK()
+		if !ok {
+			return "", errors.New("malformed int64")
+		}
+		return strconv.FormatInt(integer, 10), nil
+	case bsontype.String:
+		text, ok := value.StringValueOK()
+		if !ok {
+			return "", errors.New("malformed string")
+		}
+		if strings.TrimSpace(text) == "" {
+			return "", errors.New("empty string")
+		}
+		return text, nil
+	default:
+		return "", fmt.Errorf("cannot decode %v into a number", t)
+	}
+}
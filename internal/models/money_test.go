@@ -0,0 +1,147 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Money
+		wantErr  bool
+	}{
+		{name: "two decimals", input: "1234.56", expected: 123456},
+		{name: "integer", input: "1000000", expected: 100000000},
+		{name: "exponent", input: "1.5e3", expected: 150000},
+		{name: "half rounds up", input: "0.125", expected: 13},
+		{name: "negative half rounds away from zero", input: "-0.125", expected: -13},
+		{name: "below half rounds down", input: "10.12499", expected: 1012},
+		{name: "fraction is rejected", input: "1/3", wantErr: true},
+		{name: "text is rejected", input: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseMoney(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMoney(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && result != tt.expected {
+				t.Errorf("ParseMoney(%q) = %d; expected %d", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var position Position
+	if err := json.Unmarshal([]byte(`{"currentPeriodCost": 0.1, "accumulatedCost": "20.30"}`), &position); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if position.CurrentPeriodCost == nil || *position.CurrentPeriodCost != 10 {
+		t.Errorf("currentPeriodCost = %v, expected 0.10", position.CurrentPeriodCost)
+	}
+	if position.AccumulatedCost == nil || *position.AccumulatedCost != 2030 {
+		t.Errorf("accumulatedCost = %v, expected 20.30", position.AccumulatedCost)
+	}
+	if position.CurrentPeriodCostInspection != nil {
+		t.Errorf("currentPeriodCostInspection = %v, expected nil", position.CurrentPeriodCostInspection)
+	}
+
+	// Ten times 0.1 is exactly 1.00, unlike with float addition
+	var total Money
+	for i := 0; i < 10; i++ {
+		total = total.Add(*position.CurrentPeriodCost)
+	}
+	data, err := json.Marshal(BigAct{TotalCost: total})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if expected := `{"changed":false,"totalCost":1.00}`; string(data) != expected {
+		t.Errorf("Marshal() = %s; expected %s", data, expected)
+	}
+}
+
+func TestMoneyBSON(t *testing.T) {
+	cost := Money(-123456)
+	data, err := bson.Marshal(Position{CurrentPeriodCost: &cost})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var raw bson.M
+	if err = bson.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decimal, ok := raw["currentPeriodCost"].(primitive.Decimal128); !ok || decimal.String() != "-1234.56" {
+		t.Errorf("stored value = %#v, expected Decimal128 -1234.56", raw["currentPeriodCost"])
+	}
+
+	var position Position
+	if err = bson.Unmarshal(data, &position); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if position.CurrentPeriodCost == nil || *position.CurrentPeriodCost != cost {
+		t.Errorf("currentPeriodCost = %v, expected %v", position.CurrentPeriodCost, cost)
+	}
+
+	// Amounts stored as doubles before decimals are still read
+	legacy, err := bson.Marshal(bson.M{"totalCost": 1234.56, "totalCostInspection": int32(7)})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var bigAct BigAct
+	if err = bson.Unmarshal(legacy, &bigAct); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if bigAct.TotalCost != 123456 || bigAct.TotalCostInspection != 700 {
+		t.Errorf("totals = %v, %v; expected 1234.56, 7.00", bigAct.TotalCost, bigAct.TotalCostInspection)
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		percent  int64
+		expected Money
+	}{
+		{amount: 30003, percent: 20, expected: 6001}, // 60.006 -> 60.01
+		{amount: 5005, percent: 10, expected: 501},   // 5.005 -> 5.01
+		{amount: -5005, percent: 10, expected: -501}, // -5.005 -> -5.01
+		{amount: 10004, percent: 10, expected: 1000}, // 10.004 -> 10.00
+		{amount: 123456, percent: 0, expected: 0},
+		{amount: 1 << 62, percent: 20, expected: 922337203685477581},
+		{amount: math.MinInt64, percent: 100, expected: math.MinInt64},
+	}
+
+	for _, tt := range tests {
+		if result := tt.amount.Percent(tt.percent); result != tt.expected {
+			t.Errorf("%v.Percent(%d) = %v; expected %v", tt.amount, tt.percent, result, tt.expected)
+		}
+	}
+}
+
+func TestNewMoneySigns(t *testing.T) {
+	// Kopecks are added to the rubles whatever their sign
+	tests := []struct {
+		rubles, kopecks int64
+		expected        string
+	}{
+		{rubles: 10, kopecks: 50, expected: "10.50"},
+		{rubles: -10, kopecks: -50, expected: "-10.50"},
+		{rubles: -10, kopecks: 50, expected: "-9.50"},
+		{rubles: 0, kopecks: -5, expected: "-0.05"},
+	}
+
+	for _, tt := range tests {
+		if result := NewMoney(tt.rubles, tt.kopecks).String(); result != tt.expected {
+			t.Errorf("NewMoney(%d, %d) = %s; expected %s", tt.rubles, tt.kopecks, result, tt.expected)
+		}
+	}
+}
@@ -1,14 +1,37 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"errors"
+	"fmt"
+	"strings"
 
-// Position represents a position in an act with cost information
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Position represents a work item of an act: what was done, how much of it
+// and what it cost
 type Position struct {
 	ID                              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	CurrentPeriodCost               *float64           `json:"currentPeriodCost,omitempty" bson:"currentPeriodCost,omitempty"`
-	CurrentPeriodCostInspection     *float64           `json:"currentPeriodCostInspection,omitempty" bson:"currentPeriodCostInspection,omitempty"`
-	CurrentPeriodCostConsiderations *float64           `json:"currentPeriodCostConsiderations,omitempty" bson:"currentPeriodCostConsiderations,omitempty"`
-	AccumulatedCost                 *float64           `json:"accumulatedCost,omitempty" bson:"accumulatedCost,omitempty"`
+	Code                            string             `json:"code,omitempty" bson:"code,omitempty"`
+	Name                            string             `json:"name,omitempty" bson:"name,omitempty"`
+	Unit                            string             `json:"unit,omitempty" bson:"unit,omitempty"`
+	Quantity                        *Quantity          `json:"quantity,omitempty" bson:"quantity,omitempty"`
+	UnitPrice                       *Money             `json:"unitPrice,omitempty" bson:"unitPrice,omitempty"`
+	EstimateLine                    string             `json:"estimateLine,omitempty" bson:"estimateLine,omitempty"`
+	CurrentPeriodCost               *Money             `json:"currentPeriodCost,omitempty" bson:"currentPeriodCost,omitempty"`
+	CurrentPeriodCostInspection     *Money             `json:"currentPeriodCostInspection,omitempty" bson:"currentPeriodCostInspection,omitempty"`
+	CurrentPeriodCostConsiderations *Money             `json:"currentPeriodCostConsiderations,omitempty" bson:"currentPeriodCostConsiderations,omitempty"`
+	AccumulatedCost                 *Money             `json:"accumulatedCost,omitempty" bson:"accumulatedCost,omitempty"`
+	VATRate                         VATRate            `json:"vatRate,omitempty" bson:"vatRate,omitempty"`
+}
+
+// EffectiveVATRate returns the VAT rate of the position, or the given
+// default rate when the position has none
+func (p *Position) EffectiveVATRate(defaultRate VATRate) VATRate {
+	if p.VATRate == "" {
+		return defaultRate
+	}
+	return p.VATRate
 }
 
 // HasCurrentPeriodCost checks if position has any current period cost
@@ -22,3 +45,59 @@ func (p *Position) HasCurrentPeriodCost() bool {
 func (p *Position) HasAccumulatedCost() bool {
 	return p.AccumulatedCost != nil
 }
+
+// DerivedCost returns the quantity times the unit price, false when either
+// of them is missing and an error when the cost is out of range
+func (p *Position) DerivedCost() (Money, bool, error) {
+	if p.Quantity == nil || p.UnitPrice == nil {
+		return 0, false, nil
+	}
+	cost, ok := p.Quantity.Cost(*p.UnitPrice)
+	if !ok {
+		return 0, true, fmt.Errorf("%w: quantity %s times unit price %s is out of range", ErrInvalidMoney, p.Quantity, p.UnitPrice)
+	}
+	return cost, true, nil
+}
+
+// DeriveCost sets the current period cost to the quantity times the unit
+// price when the cost is not given explicitly
+func (p *Position) DeriveCost() error {
+	if p.CurrentPeriodCost != nil {
+		return nil
+	}
+	cost, ok, err := p.DerivedCost()
+	if err != nil {
+		return err
+	}
+	if ok {
+		p.CurrentPeriodCost = &cost
+	}
+	return nil
+}
+
+// Validate checks that the fields of the position agree with each other:
+// the VAT rate is known, the unit price is not negative, a quantity has a
+// unit, and an explicit cost equals the quantity times the unit price,
+// which is in range
+func (p *Position) Validate() error {
+	var problems []string
+	if p.VATRate != "" && !p.VATRate.IsValid() {
+		problems = append(problems, fmt.Sprintf("unknown VAT rate %q, expected one of 20, 10, 0, none", p.VATRate))
+	}
+	if p.UnitPrice != nil && *p.UnitPrice < 0 {
+		problems = append(problems, fmt.Sprintf("unit price %s is negative", p.UnitPrice))
+	}
+	if p.Quantity != nil && p.Unit == "" {
+		problems = append(problems, "quantity has no unit")
+	}
+	if cost, ok, err := p.DerivedCost(); err != nil {
+		problems = append(problems, fmt.Sprintf("quantity %s times unit price %s is out of range", p.Quantity, p.UnitPrice))
+	} else if ok && p.CurrentPeriodCost != nil && *p.CurrentPeriodCost != cost {
+		problems = append(problems, fmt.Sprintf("current period cost %s does not equal quantity %s times unit price %s = %s",
+			p.CurrentPeriodCost, p.Quantity, p.UnitPrice, cost))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
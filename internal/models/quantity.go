@@ -0,0 +1,158 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// QuantityScale is the number of decimal places quantities are kept with
+const QuantityScale = 3
+
+// quantityUnit is the number of thousandths in a unit of measure
+const quantityUnit = 1000
+
+// Quantity is an exact amount of work in its unit of measure, kept as a
+// whole number of thousandths, e.g. 12.345 m3. Like Money it is written as
+// a JSON number and stored as a BSON Decimal128.
+type Quantity int64
+
+// ErrInvalidQuantity is returned when a text is not a decimal quantity
+var ErrInvalidQuantity = errors.New("invalid quantity")
+
+// ParseQuantity parses a decimal quantity such as "12.5" or "1e3" exactly,
+// rounding it to thousandths half away from zero
+func ParseQuantity(text string) (Quantity, error) {
+	text = strings.TrimSpace(text)
+	rat, ok := new(big.Rat).SetString(text)
+	if !ok || strings.ContainsAny(text, "/") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidQuantity, text)
+	}
+	units, ok := roundRat(rat, quantityUnit)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidQuantity, text)
+	}
+	return Quantity(units), nil
+}
+
+// Units returns the quantity in thousandths
+func (q Quantity) Units() int64 {
+	return int64(q)
+}
+
+// Scale returns the number of decimal places of the quantity
+func (q Quantity) Scale() int {
+	return QuantityScale
+}
+
+// Decimals returns the number of decimal places the quantity needs, e.g. 1
+// for 12.500
+func (q Quantity) Decimals() int {
+	decimals := QuantityScale
+	for units := int64(q); decimals > 0 && units%10 == 0; units /= 10 {
+		decimals--
+	}
+	return decimals
+}
+
+// Float64 returns the quantity as a float, for Excel cells
+func (q Quantity) Float64() float64 {
+	return float64(q) / quantityUnit
+}
+
+// IsZero reports whether the quantity is zero
+func (q Quantity) IsZero() bool {
+	return q == 0
+}
+
+// Cost returns the cost of the quantity at the unit price, rounded to
+// kopecks half away from zero, false when the cost is out of range
+func (q Quantity) Cost(price Money) (Money, bool) {
+	product := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(int64(q)), big.NewInt(int64(price))),
+		big.NewInt(quantityUnit*moneyUnit),
+	)
+	units, ok := roundRat(product, moneyUnit)
+	return Money(units), ok
+}
+
+// String returns the quantity without trailing zeros, e.g. "12.5"
+func (q Quantity) String() string {
+	sign := ""
+	units := int64(q)
+	abs := uint64(units)
+	if units < 0 {
+		sign = "-"
+		abs = uint64(-units)
+	}
+
+	whole, fraction := abs/quantityUnit, abs%quantityUnit
+	if fraction == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	return strings.TrimRight(fmt.Sprintf("%s%d.%03d", sign, whole, fraction), "0")
+}
+
+// MarshalJSON writes the quantity as a JSON number
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON reads the quantity from a JSON number or a string, parsing
+// the text exactly instead of going through a float
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	text := string(data)
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	value, err := ParseQuantity(text)
+	if err != nil {
+		return err
+	}
+	*q = value
+	return nil
+}
+
+// MarshalBSONValue stores the quantity as a Decimal128
+func (q Quantity) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	value, err := primitive.ParseDecimal128(q.String())
+	if err != nil {
+		return 0, nil, err
+	}
+	return bsontype.Decimal128, bsoncore.AppendDecimal128(nil, value), nil
+}
+
+// UnmarshalBSONValue reads the quantity from a Decimal128, a double, an
+// integer or a string
+func (q *Quantity) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	text, err := bsonDecimalText(t, data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidQuantity, err)
+	}
+	if text == "" {
+		*q = 0
+		return nil
+	}
+
+	parsed, err := ParseQuantity(text)
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
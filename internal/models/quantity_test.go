@@ -0,0 +1,161 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Quantity
+		text     string
+		wantErr  bool
+	}{
+		{input: "12.345", expected: 12345, text: "12.345"},
+		{input: "12.5", expected: 12500, text: "12.5"},
+		{input: "3", expected: 3000, text: "3"},
+		{input: "0.0005", expected: 1, text: "0.001"},
+		{input: "-1.0005", expected: -1001, text: "-1.001"},
+		{input: "2/3", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseQuantity(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseQuantity(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if result != tt.expected || result.String() != tt.text {
+			t.Errorf("ParseQuantity(%q) = %d (%s); expected %d (%s)", tt.input, result, result, tt.expected, tt.text)
+		}
+	}
+}
+
+func TestQuantityCost(t *testing.T) {
+	tests := []struct {
+		quantity Quantity
+		price    Money
+		expected Money
+	}{
+		{quantity: 12500, price: NewMoney(100, 0), expected: NewMoney(1250, 0)},
+		{quantity: 1, price: NewMoney(5, 0), expected: 1},       // 0.005 -> 0.01
+		{quantity: 333, price: NewMoney(1, 0), expected: 33},    // 0.333 -> 0.33
+		{quantity: -1, price: NewMoney(5, 0), expected: -1},     // -0.005 -> -0.01
+		{quantity: 2345, price: NewMoney(0, 99), expected: 232}, // 2.32155 -> 2.32
+	}
+
+	for _, tt := range tests {
+		if result, ok := tt.quantity.Cost(tt.price); !ok || result != tt.expected {
+			t.Errorf("%v.Cost(%v) = %v, %v; expected %v", tt.quantity, tt.price, result, ok, tt.expected)
+		}
+	}
+
+	// 9 223 372 036 854 775.807 × 100.00 does not fit in kopecks
+	if result, ok := Quantity(math.MaxInt64).Cost(NewMoney(100, 0)); ok {
+		t.Errorf("overflowing Cost() = %v, expected it reported", result)
+	}
+}
+
+func TestQuantityEncoding(t *testing.T) {
+	var position Position
+	if err := json.Unmarshal([]byte(`{"quantity": 12.345, "unitPrice": "10"}`), &position); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if position.Quantity == nil || *position.Quantity != 12345 {
+		t.Fatalf("quantity = %v, expected 12.345", position.Quantity)
+	}
+
+	data, err := bson.Marshal(position)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var raw bson.M
+	if err = bson.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decimal, ok := raw["quantity"].(primitive.Decimal128); !ok || decimal.String() != "12.345" {
+		t.Errorf("stored value = %#v, expected Decimal128 12.345", raw["quantity"])
+	}
+
+	var stored Position
+	if err = bson.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if stored.Quantity == nil || *stored.Quantity != *position.Quantity {
+		t.Errorf("quantity = %v, expected %v", stored.Quantity, position.Quantity)
+	}
+}
+
+func TestPositionValidate(t *testing.T) {
+	quantity, price := Quantity(2500), NewMoney(10, 0)
+	cost, wrongCost, negative := NewMoney(25, 0), NewMoney(26, 0), NewMoney(-1, 0)
+	huge, hugePrice := Quantity(math.MaxInt64), NewMoney(100, 0)
+
+	tests := []struct {
+		name     string
+		position Position
+		problem  string
+	}{
+		{name: "costs only", position: Position{CurrentPeriodCost: &cost}},
+		{name: "cost matches", position: Position{Unit: "м", Quantity: &quantity, UnitPrice: &price, CurrentPeriodCost: &cost}},
+		{name: "cost differs", position: Position{Unit: "м", Quantity: &quantity, UnitPrice: &price, CurrentPeriodCost: &wrongCost},
+			problem: "does not equal quantity 2.5 times unit price 10.00 = 25.00"},
+		{name: "quantity without unit", position: Position{Quantity: &quantity}, problem: "quantity has no unit"},
+		{name: "negative price", position: Position{UnitPrice: &negative}, problem: "unit price -1.00 is negative"},
+		{name: "unknown VAT rate", position: Position{VATRate: "18"}, problem: `unknown VAT rate "18"`},
+		{name: "cost out of range", position: Position{Unit: "м", Quantity: &huge, UnitPrice: &hugePrice},
+			problem: "times unit price 100.00 is out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.position.Validate()
+			if tt.problem == "" && err != nil {
+				t.Errorf("Validate() error = %v, expected none", err)
+			}
+			if tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)) {
+				t.Errorf("Validate() error = %v, expected %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestPositionDeriveCost(t *testing.T) {
+	quantity, price, explicit := Quantity(2500), NewMoney(10, 0), NewMoney(1, 0)
+
+	derived := Position{Quantity: &quantity, UnitPrice: &price}
+	if err := derived.DeriveCost(); err != nil {
+		t.Fatalf("DeriveCost() error = %v", err)
+	}
+	if derived.CurrentPeriodCost == nil || *derived.CurrentPeriodCost != NewMoney(25, 0) {
+		t.Errorf("derived cost = %v, expected 25.00", derived.CurrentPeriodCost)
+	}
+
+	given := Position{Quantity: &quantity, UnitPrice: &price, CurrentPeriodCost: &explicit}
+	_ = given.DeriveCost()
+	if *given.CurrentPeriodCost != explicit {
+		t.Errorf("explicit cost = %v, expected it kept", given.CurrentPeriodCost)
+	}
+
+	partial := Position{Quantity: &quantity}
+	_ = partial.DeriveCost()
+	if partial.CurrentPeriodCost != nil {
+		t.Errorf("cost without price = %v, expected nil", partial.CurrentPeriodCost)
+	}
+
+	huge, hugePrice := Quantity(math.MaxInt64), NewMoney(100, 0)
+	overflow := Position{Quantity: &huge, UnitPrice: &hugePrice}
+	if err := overflow.DeriveCost(); !errors.Is(err, ErrInvalidMoney) || overflow.CurrentPeriodCost != nil {
+		t.Errorf("DeriveCost() error = %v, cost = %v, expected ErrInvalidMoney and no cost", err, overflow.CurrentPeriodCost)
+	}
+}
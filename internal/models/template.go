@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Template represents a single uploaded version of an Excel template.
+// Versions of a template share the TemplateID, and one of them is active.
+type Template struct {
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TemplateID       string             `json:"templateId" bson:"templateId"`
+	Version          int                `json:"version" bson:"version"`
+	FileName         string             `json:"fileName" bson:"fileName"`
+	FilePath         string             `json:"-" bson:"filePath"`
+	MissingKeyPolicy string             `json:"missingKeyPolicy,omitempty" bson:"missingKeyPolicy,omitempty"`
+	Locale           string             `json:"locale,omitempty" bson:"locale,omitempty"`
+	Active           bool               `json:"active" bson:"active"`
+	ActivatedAt      *time.Time         `json:"activatedAt,omitempty" bson:"activatedAt,omitempty"`
+	CreatedAt        time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,53 @@
+package models
+
+// VATRate is the VAT (НДС) rate of a position
+type VATRate string
+
+// VAT rates of positions
+const (
+	VATRate20   VATRate = "20"
+	VATRate10   VATRate = "10"
+	VATRate0    VATRate = "0"
+	VATRateNone VATRate = "none" // без НДС
+)
+
+// VATRates lists the rates in the order totals are broken down by
+var VATRates = []VATRate{VATRate20, VATRate10, VATRate0, VATRateNone}
+
+// IsValid reports whether the rate is one of the known rates
+func (r VATRate) IsValid() bool {
+	for _, rate := range VATRates {
+		if r == rate {
+			return true
+		}
+	}
+	return false
+}
+
+// Percent returns the rate in percent, 0 for positions without VAT
+func (r VATRate) Percent() int64 {
+	switch r {
+	case VATRate20:
+		return 20
+	case VATRate10:
+		return 10
+	default:
+		return 0
+	}
+}
+
+// Label returns the rate as printed in documents, e.g. "20%" or "без НДС"
+func (r VATRate) Label() string {
+	if r == VATRateNone {
+		return "без НДС"
+	}
+	return string(r) + "%"
+}
+
+// VATAmount holds the amounts of the positions with one VAT rate
+type VATAmount struct {
+	Rate  VATRate `json:"rate" bson:"rate"`
+	Net   Money   `json:"net" bson:"net"`
+	VAT   Money   `json:"vat" bson:"vat"`
+	Gross Money   `json:"gross" bson:"gross"`
+}
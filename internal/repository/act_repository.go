@@ -3,19 +3,77 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrActNotFound is returned when no act has the requested ID
+var ErrActNotFound = errors.New("act not found")
+
+// ErrActConflict is returned when an act was changed by someone else since
+// it was read
+var ErrActConflict = errors.New("act was changed concurrently")
+
 // ActRepository defines the interface for act data operations
 type ActRepository interface {
 	Create(ctx context.Context, act *models.Act) (string, error)
 	FindByID(ctx context.Context, id string) (*models.Act, error)
 	Update(ctx context.Context, id string, act *models.Act) error
+	Replace(ctx context.Context, id string, act *models.Act) error
+	SumContractCosts(ctx context.Context, filter ContractCostFilter) (*ContractCosts, error)
+	FindIDs(ctx context.Context, filter ActFilter) ([]string, error)
+}
+
+// ActFilter selects acts by contract and creation time, empty fields do
+// not filter
+type ActFilter struct {
+	ContractID     string
+	ContractNumber string
+	CreatedFrom    time.Time
+	CreatedTo      time.Time // excluded
+}
+
+// ContractCostFilter selects the approved and signed acts of a contract,
+// by contract ID or else by the contractNumber text field, created up to
+// a time. ExcludeID leaves out the act the costs are summed for, and years
+// are counted in Location, UTC when it is nil.
+type ContractCostFilter struct {
+	ContractID     string
+	ContractNumber string
+	CreatedBy      time.Time // included
+	ExcludeID      primitive.ObjectID
+	Location       *time.Location
+}
+
+// ContractCosts are the current period costs of the acts selected by a
+// ContractCostFilter, summed by the database
+type ContractCosts struct {
+	Acts   int                // number of acts
+	ByRate []ActRateCost      // by act and VAT rate
+	ByLine []EstimateLineCost // by estimate line and year
+}
+
+// ActRateCost is the cost of the positions of one act with one VAT rate,
+// an empty rate for positions without one
+type ActRateCost struct {
+	ActID   primitive.ObjectID `bson:"actId"`
+	Year    int                `bson:"year"`
+	VATRate models.VATRate     `bson:"vatRate"`
+	Cost    models.Money       `bson:"cost"`
+}
+
+// EstimateLineCost is the cost of an estimate line in the acts of a year
+type EstimateLineCost struct {
+	EstimateLine string       `bson:"estimateLine"`
+	Year         int          `bson:"year"`
+	Cost         models.Money `bson:"cost"`
 }
 
 // actRepository implements ActRepository
@@ -26,6 +84,11 @@ type actRepository struct {
 // NewActRepository creates a new ActRepository
 func NewActRepository(mongoClient *MongoDBClient) ActRepository {
 	collection := mongoClient.GetCollection(mongoClient.Config.MongoDBCollection)
+	mongoClient.EnsureIndexes(collection, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "contractId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "bigAct.textFields.contractNumber", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}},
+	})
 	return &actRepository{
 		collection: collection,
 	}
@@ -72,7 +135,7 @@ func (r *actRepository) FindByID(ctx context.Context, id string) (*models.Act, e
 		if err == mongo.ErrNoDocuments {
 			utils.LogError("Act not found with ID: %s", id)
 			utils.LogMethodError("ActRepository.FindByID", err)
-			return nil, errors.New("act not found")
+			return nil, ErrActNotFound
 		}
 		utils.LogMethodError("ActRepository.FindByID", err)
 		return nil, err
@@ -83,7 +146,8 @@ func (r *actRepository) FindByID(ctx context.Context, id string) (*models.Act, e
 	return &act, nil
 }
 
-// Update updates an existing act in the database
+// Update updates an existing act in the database when it is still at the
+// revision it was read with, and moves it to the next revision
 func (r *actRepository) Update(ctx context.Context, id string, act *models.Act) error {
 	utils.LogMethodInit("ActRepository.Update")
 
@@ -94,25 +158,210 @@ func (r *actRepository) Update(ctx context.Context, id string, act *models.Act)
 		return errors.New("invalid ID format")
 	}
 
-	update := bson.M{
-		"$set": act,
-	}
-
 	utils.LogMongoTransaction("UPDATE", "Updating act with ID: "+id)
-	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	err = r.writeRevision(ctx, objectID, act, func(filter bson.M) (*mongo.UpdateResult, error) {
+		return r.collection.UpdateOne(ctx, filter, bson.M{"$set": act})
+	})
 	if err != nil {
 		utils.LogMethodError("ActRepository.Update", err)
 		return err
 	}
 
-	if result.MatchedCount == 0 {
-		err := errors.New("act not found")
-		utils.LogError("Act not found with ID: %s", id)
-		utils.LogMethodError("ActRepository.Update", err)
+	utils.LogInfo("Successfully updated act with ID: %s", id)
+	utils.LogMethodSuccess("ActRepository.Update")
+	return nil
+}
+
+// Replace replaces the stored act, so that fields left empty are removed,
+// when it is still at the revision it was read with
+func (r *actRepository) Replace(ctx context.Context, id string, act *models.Act) error {
+	utils.LogMethodInit("ActRepository.Replace")
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		utils.LogError("Invalid ObjectID format: %v", err)
+		utils.LogMethodError("ActRepository.Replace", err)
+		return errors.New("invalid ID format")
+	}
+
+	utils.LogMongoTransaction("UPDATE", "Replacing act with ID: "+id)
+	err = r.writeRevision(ctx, objectID, act, func(filter bson.M) (*mongo.UpdateResult, error) {
+		return r.collection.ReplaceOne(ctx, filter, act)
+	})
+	if err != nil {
+		utils.LogMethodError("ActRepository.Replace", err)
 		return err
 	}
 
-	utils.LogInfo("Successfully updated act with ID: %s", id)
-	utils.LogMethodSuccess("ActRepository.Update")
+	utils.LogInfo("Successfully replaced act with ID: %s", id)
+	utils.LogMethodSuccess("ActRepository.Replace")
 	return nil
 }
+
+// writeRevision runs the write of the act filtered on its revision and
+// moves the act to the next revision. When nothing matched, the act is
+// either gone or at another revision.
+func (r *actRepository) writeRevision(ctx context.Context, objectID primitive.ObjectID, act *models.Act, write func(filter bson.M) (*mongo.UpdateResult, error)) error {
+	filter := bson.M{"_id": objectID, "revision": act.Revision}
+	if act.Revision == 0 {
+		// Acts stored before revisions were kept have none
+		filter["revision"] = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	act.Revision++
+	result, err := write(filter)
+	if err == nil && result.MatchedCount == 1 {
+		return nil
+	}
+	act.Revision--
+	if err != nil {
+		return err
+	}
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		utils.LogError("Act not found with ID: %s", objectID.Hex())
+		return ErrActNotFound
+	}
+	utils.LogError("Act %s is no longer at revision %d", objectID.Hex(), act.Revision)
+	return ErrActConflict
+}
+
+// SumContractCosts sums the current period costs of the positions of the
+// acts of a contract in the database, so that the acts are not loaded.
+// Costs are summed by act and VAT rate, for the VAT is rounded per act, and
+// by estimate line and year of creation in the time zone of the filter.
+func (r *actRepository) SumContractCosts(ctx context.Context, filter ContractCostFilter) (*ContractCosts, error) {
+	utils.LogMethodInit("ActRepository.SumContractCosts")
+
+	query := bson.M{
+		"status":    bson.M{"$in": models.FinalStatuses},
+		"createdAt": bson.M{"$lte": filter.CreatedBy},
+	}
+	if filter.ContractID != "" {
+		query["contractId"] = filter.ContractID
+	} else {
+		query["bigAct.textFields.contractNumber"] = filter.ContractNumber
+	}
+	if !filter.ExcludeID.IsZero() {
+		query["_id"] = bson.M{"$ne": filter.ExcludeID}
+	}
+
+	// Every facet goes through the positions with a current period cost
+	positions := func(stages ...interface{}) bson.A {
+		return append(bson.A{
+			bson.M{"$unwind": "$positions"},
+			bson.M{"$match": bson.M{"positions.currentPeriodCost": bson.M{"$ne": nil}}},
+		}, stages...)
+	}
+	timezone := "UTC"
+	if filter.Location != nil {
+		timezone = filter.Location.String()
+	}
+	year := bson.M{"$year": bson.M{"date": "$createdAt", "timezone": timezone}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: query}},
+		{{Key: "$facet", Value: bson.M{
+			"acts": bson.A{bson.M{"$count": "count"}},
+			"byRate": positions(
+				bson.M{"$group": bson.M{
+					"_id":  bson.M{"act": "$_id", "year": year, "vatRate": "$positions.vatRate"},
+					"cost": bson.M{"$sum": "$positions.currentPeriodCost"},
+				}},
+				bson.M{"$project": bson.M{"_id": 0, "actId": "$_id.act", "year": "$_id.year", "vatRate": "$_id.vatRate", "cost": 1}},
+			),
+			"byLine": positions(
+				bson.M{"$match": bson.M{"positions.estimateLine": bson.M{"$nin": bson.A{nil, ""}}}},
+				bson.M{"$group": bson.M{
+					"_id":  bson.M{"estimateLine": "$positions.estimateLine", "year": year},
+					"cost": bson.M{"$sum": "$positions.currentPeriodCost"},
+				}},
+				bson.M{"$project": bson.M{"_id": 0, "estimateLine": "$_id.estimateLine", "year": "$_id.year", "cost": 1}},
+			),
+		}}},
+	}
+
+	utils.LogMongoTransaction("AGGREGATE", fmt.Sprintf("Summing costs of contract %q %q", filter.ContractID, filter.ContractNumber))
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		utils.LogMethodError("ActRepository.SumContractCosts", err)
+		return nil, err
+	}
+
+	var results []struct {
+		Acts []struct {
+			Count int `bson:"count"`
+		} `bson:"acts"`
+		ByRate []ActRateCost      `bson:"byRate"`
+		ByLine []EstimateLineCost `bson:"byLine"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		utils.LogMethodError("ActRepository.SumContractCosts", err)
+		return nil, err
+	}
+
+	costs := &ContractCosts{}
+	if len(results) > 0 {
+		costs.ByRate, costs.ByLine = results[0].ByRate, results[0].ByLine
+		if len(results[0].Acts) > 0 {
+			costs.Acts = results[0].Acts[0].Count
+		}
+	}
+
+	utils.LogInfo("Summed the costs of %d acts", costs.Acts)
+	utils.LogMethodSuccess("ActRepository.SumContractCosts")
+	return costs, nil
+}
+
+// FindIDs retrieves the IDs of the acts matching the filter, oldest first
+func (r *actRepository) FindIDs(ctx context.Context, filter ActFilter) ([]string, error) {
+	utils.LogMethodInit("ActRepository.FindIDs")
+
+	query := bson.M{}
+	if filter.ContractID != "" {
+		query["contractId"] = filter.ContractID
+	}
+	if filter.ContractNumber != "" {
+		query["bigAct.textFields.contractNumber"] = filter.ContractNumber
+	}
+	createdAt := bson.M{}
+	if !filter.CreatedFrom.IsZero() {
+		createdAt["$gte"] = filter.CreatedFrom
+	}
+	if !filter.CreatedTo.IsZero() {
+		createdAt["$lt"] = filter.CreatedTo
+	}
+	if len(createdAt) > 0 {
+		query["createdAt"] = createdAt
+	}
+
+	utils.LogMongoTransaction("SELECT", "Finding act IDs by filter")
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		utils.LogMethodError("ActRepository.FindIDs", err)
+		return nil, err
+	}
+
+	var results []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		utils.LogMethodError("ActRepository.FindIDs", err)
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.ID.Hex())
+	}
+
+	utils.LogInfo("Found %d acts matching the filter", len(ids))
+	utils.LogMethodSuccess("ActRepository.FindIDs")
+	return ids, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrContractNotFound is returned when no contract has the requested ID
+var ErrContractNotFound = errors.New("contract not found")
+
+// ErrContractNumberExists is returned when a contract number is stored
+// twice, which the unique index on the number prevents
+var ErrContractNumberExists = errors.New("contract number already exists")
+
+// ContractRepository defines the interface for contract data operations
+type ContractRepository interface {
+	Create(ctx context.Context, contract *models.Contract) (string, error)
+	FindByID(ctx context.Context, id string) (*models.Contract, error)
+	FindByNumber(ctx context.Context, number string) (*models.Contract, error)
+	FindAll(ctx context.Context) ([]models.Contract, error)
+}
+
+// contractRepository implements ContractRepository
+type contractRepository struct {
+	collection *mongo.Collection
+}
+
+// NewContractRepository creates a new ContractRepository
+func NewContractRepository(mongoClient *MongoDBClient) ContractRepository {
+	collection := mongoClient.GetCollection(mongoClient.Config.MongoDBContractsCollection)
+	mongoClient.EnsureIndexes(collection, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "number", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	return &contractRepository{
+		collection: collection,
+	}
+}
+
+// Create inserts a new contract into the database
+func (r *contractRepository) Create(ctx context.Context, contract *models.Contract) (string, error) {
+	utils.LogMethodInit("ContractRepository.Create")
+
+	utils.LogMongoTransaction("INSERT", "Inserting contract "+contract.Number)
+	result, err := r.collection.InsertOne(ctx, contract)
+	if err != nil {
+		utils.LogMethodError("ContractRepository.Create", err)
+		if mongo.IsDuplicateKeyError(err) {
+			return "", fmt.Errorf("%w: %s", ErrContractNumberExists, contract.Number)
+		}
+		return "", err
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		err := errors.New("failed to convert inserted ID to ObjectID")
+		utils.LogMethodError("ContractRepository.Create", err)
+		return "", err
+	}
+
+	utils.LogInfo("Successfully created contract with ID: %s", insertedID.Hex())
+	utils.LogMethodSuccess("ContractRepository.Create")
+	return insertedID.Hex(), nil
+}
+
+// FindByID retrieves a contract by its ID
+func (r *contractRepository) FindByID(ctx context.Context, id string) (*models.Contract, error) {
+	utils.LogMethodInit("ContractRepository.FindByID")
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		utils.LogError("Invalid ObjectID format: %v", err)
+		utils.LogMethodError("ContractRepository.FindByID", err)
+		return nil, ErrContractNotFound
+	}
+
+	utils.LogMongoTransaction("SELECT", "Finding contract by ID: "+id)
+	return r.findOne(ctx, bson.M{"_id": objectID}, "ContractRepository.FindByID")
+}
+
+// FindByNumber retrieves a contract by its number
+func (r *contractRepository) FindByNumber(ctx context.Context, number string) (*models.Contract, error) {
+	utils.LogMethodInit("ContractRepository.FindByNumber")
+
+	utils.LogMongoTransaction("SELECT", "Finding contract by number: "+number)
+	return r.findOne(ctx, bson.M{"number": number}, "ContractRepository.FindByNumber")
+}
+
+// findOne decodes the contract matching the filter
+func (r *contractRepository) findOne(ctx context.Context, filter bson.M, method string) (*models.Contract, error) {
+	var contract models.Contract
+	err := r.collection.FindOne(ctx, filter).Decode(&contract)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.LogMethodError(method, ErrContractNotFound)
+			return nil, ErrContractNotFound
+		}
+		utils.LogMethodError(method, err)
+		return nil, err
+	}
+
+	utils.LogMethodSuccess(method)
+	return &contract, nil
+}
+
+// FindAll retrieves all contracts ordered by number
+func (r *contractRepository) FindAll(ctx context.Context) ([]models.Contract, error) {
+	utils.LogMethodInit("ContractRepository.FindAll")
+
+	utils.LogMongoTransaction("SELECT", "Listing contracts")
+	opts := options.Find().SetSort(bson.D{{Key: "number", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		utils.LogMethodError("ContractRepository.FindAll", err)
+		return nil, err
+	}
+
+	contracts := []models.Contract{}
+	if err = cursor.All(ctx, &contracts); err != nil {
+		utils.LogMethodError("ContractRepository.FindAll", err)
+		return nil, err
+	}
+
+	utils.LogMethodSuccess("ContractRepository.FindAll")
+	return contracts, nil
+}
@@ -76,3 +76,20 @@ func (m *MongoDBClient) Disconnect() error {
 func (m *MongoDBClient) GetCollection(name string) *mongo.Collection {
 	return m.Database.Collection(name)
 }
+
+// EnsureIndexes creates the indexes of a collection, indexes that already
+// exist are left as they are
+func (m *MongoDBClient) EnsureIndexes(collection *mongo.Collection, indexes []mongo.IndexModel) {
+	utils.LogMethodInit("MongoDBClient.EnsureIndexes")
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.Config.MongoDBTimeout)
+	defer cancel()
+
+	utils.LogMongoTransaction("CREATE_INDEXES", "Ensuring indexes of collection "+collection.Name())
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		utils.LogMethodError("MongoDBClient.EnsureIndexes", err)
+		log.Fatalf("Failed to create indexes of collection %s: %v", collection.Name(), err)
+	}
+
+	utils.LogMethodSuccess("MongoDBClient.EnsureIndexes")
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrTemplateNotFound is returned when no template matches the requested ID and version
+var ErrTemplateNotFound = errors.New("template not found")
+
+// ErrTemplateVersionExists is returned when a template version is stored twice,
+// e.g. by concurrent uploads of the same template
+var ErrTemplateVersionExists = errors.New("template version already exists")
+
+// TemplateRepository defines the interface for template data operations
+type TemplateRepository interface {
+	Create(ctx context.Context, template *models.Template) (string, error)
+	FindByTemplateID(ctx context.Context, templateID string, version int) (*models.Template, error)
+	FindAll(ctx context.Context) ([]models.Template, error)
+	LatestVersion(ctx context.Context, templateID string) (int, error)
+	Activate(ctx context.Context, templateID string, version int) error
+	Delete(ctx context.Context, id string) error
+}
+
+// templateRepository implements TemplateRepository
+type templateRepository struct {
+	collection *mongo.Collection
+}
+
+// NewTemplateRepository creates a new TemplateRepository
+func NewTemplateRepository(mongoClient *MongoDBClient) TemplateRepository {
+	collection := mongoClient.GetCollection(mongoClient.Config.MongoDBTemplatesCollection)
+	mongoClient.EnsureIndexes(collection, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "templateId", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	return &templateRepository{
+		collection: collection,
+	}
+}
+
+// Create inserts a new template version into the database
+func (r *templateRepository) Create(ctx context.Context, template *models.Template) (string, error) {
+	utils.LogMethodInit("TemplateRepository.Create")
+
+	utils.LogMongoTransaction("INSERT", fmt.Sprintf("Inserting template %s version %d", template.TemplateID, template.Version))
+	result, err := r.collection.InsertOne(ctx, template)
+	if err != nil {
+		utils.LogMethodError("TemplateRepository.Create", err)
+		if mongo.IsDuplicateKeyError(err) {
+			return "", fmt.Errorf("%w: %s version %d", ErrTemplateVersionExists, template.TemplateID, template.Version)
+		}
+		return "", err
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		err := errors.New("failed to convert inserted ID to ObjectID")
+		utils.LogMethodError("TemplateRepository.Create", err)
+		return "", err
+	}
+
+	utils.LogInfo("Successfully created template with ID: %s", insertedID.Hex())
+	utils.LogMethodSuccess("TemplateRepository.Create")
+	return insertedID.Hex(), nil
+}
+
+// FindByTemplateID retrieves a template version. Version 0 selects the
+// active version of the template, the one activated last while an
+// activation is deactivating the previous version.
+func (r *templateRepository) FindByTemplateID(ctx context.Context, templateID string, version int) (*models.Template, error) {
+	utils.LogMethodInit("TemplateRepository.FindByTemplateID")
+
+	filter := bson.M{"templateId": templateID}
+	if version > 0 {
+		filter["version"] = version
+	} else {
+		filter["active"] = true
+	}
+
+	utils.LogMongoTransaction("SELECT", fmt.Sprintf("Finding template %s version %d", templateID, version))
+	opts := options.FindOne().SetSort(bson.D{{Key: "activatedAt", Value: -1}})
+	var template models.Template
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&template)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.LogError("Template not found: %s version %d", templateID, version)
+			utils.LogMethodError("TemplateRepository.FindByTemplateID", err)
+			return nil, ErrTemplateNotFound
+		}
+		utils.LogMethodError("TemplateRepository.FindByTemplateID", err)
+		return nil, err
+	}
+
+	utils.LogMethodSuccess("TemplateRepository.FindByTemplateID")
+	return &template, nil
+}
+
+// FindAll retrieves all template versions ordered by template ID and version
+func (r *templateRepository) FindAll(ctx context.Context) ([]models.Template, error) {
+	utils.LogMethodInit("TemplateRepository.FindAll")
+
+	utils.LogMongoTransaction("SELECT", "Listing templates")
+	opts := options.Find().SetSort(bson.D{{Key: "templateId", Value: 1}, {Key: "version", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		utils.LogMethodError("TemplateRepository.FindAll", err)
+		return nil, err
+	}
+
+	templates := []models.Template{}
+	if err = cursor.All(ctx, &templates); err != nil {
+		utils.LogMethodError("TemplateRepository.FindAll", err)
+		return nil, err
+	}
+
+	utils.LogMethodSuccess("TemplateRepository.FindAll")
+	return templates, nil
+}
+
+// LatestVersion returns the highest version of a template, 0 if it has none
+func (r *templateRepository) LatestVersion(ctx context.Context, templateID string) (int, error) {
+	utils.LogMethodInit("TemplateRepository.LatestVersion")
+
+	utils.LogMongoTransaction("SELECT", "Finding latest version of template "+templateID)
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	var template models.Template
+	err := r.collection.FindOne(ctx, bson.M{"templateId": templateID}, opts).Decode(&template)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.LogMethodSuccess("TemplateRepository.LatestVersion")
+			return 0, nil
+		}
+		utils.LogMethodError("TemplateRepository.LatestVersion", err)
+		return 0, err
+	}
+
+	utils.LogMethodSuccess("TemplateRepository.LatestVersion")
+	return template.Version, nil
+}
+
+// Activate makes a template version the active one. The version becomes
+// active with a single write that also stamps its activation time, readers
+// take the version activated last, so they switch over at once. The other
+// versions are deactivated afterwards.
+func (r *templateRepository) Activate(ctx context.Context, templateID string, version int) error {
+	utils.LogMethodInit("TemplateRepository.Activate")
+
+	activatedAt := time.Now()
+	utils.LogMongoTransaction("UPDATE", fmt.Sprintf("Activating template %s version %d", templateID, version))
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"templateId": templateID, "version": version},
+		bson.M{"$set": bson.M{"active": true, "activatedAt": activatedAt}},
+	)
+	if err != nil {
+		utils.LogMethodError("TemplateRepository.Activate", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		utils.LogError("Template not found: %s version %d", templateID, version)
+		utils.LogMethodError("TemplateRepository.Activate", ErrTemplateNotFound)
+		return ErrTemplateNotFound
+	}
+
+	// Versions activated later by a concurrent request are left active
+	utils.LogMongoTransaction("UPDATE", "Deactivating other versions of template "+templateID)
+	_, err = r.collection.UpdateMany(ctx,
+		bson.M{
+			"templateId": templateID,
+			"version":    bson.M{"$ne": version},
+			"$or": bson.A{
+				bson.M{"activatedAt": bson.M{"$lte": activatedAt}},
+				bson.M{"activatedAt": bson.M{"$exists": false}},
+			},
+		},
+		bson.M{"$set": bson.M{"active": false}},
+	)
+	if err != nil {
+		utils.LogMethodError("TemplateRepository.Activate", err)
+		return err
+	}
+
+	utils.LogInfo("Activated template %s version %d", templateID, version)
+	utils.LogMethodSuccess("TemplateRepository.Activate")
+	return nil
+}
+
+// Delete removes a template version by its document ID
+func (r *templateRepository) Delete(ctx context.Context, id string) error {
+	utils.LogMethodInit("TemplateRepository.Delete")
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		utils.LogMethodError("TemplateRepository.Delete", err)
+		return fmt.Errorf("invalid template ID: %w", err)
+	}
+
+	utils.LogMongoTransaction("DELETE", "Deleting template "+id)
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		utils.LogMethodError("TemplateRepository.Delete", err)
+		return err
+	}
+	if result.DeletedCount == 0 {
+		utils.LogMethodError("TemplateRepository.Delete", ErrTemplateNotFound)
+		return ErrTemplateNotFound
+	}
+
+	utils.LogMethodSuccess("TemplateRepository.Delete")
+	return nil
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidBatch is returned when the acts of a batch generation are not
+// selected in a valid way
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// errArchiveWrite is returned when the archive cannot be written, unlike
+// errors of a single act it stops the batch
+var errArchiveWrite = errors.New("failed to write archive")
+
+// Statuses of the acts of a batch in its manifest
+const (
+	BatchStatusGenerated = "generated"
+	BatchStatusFailed    = "failed"
+)
+
+// batchManifestName is the name of the manifest in the archive of a batch
+const batchManifestName = "manifest.json"
+
+// BatchRequest selects the acts of a batch generation, either by ID or by
+// contract and creation period
+type BatchRequest struct {
+	IDs            []string
+	ContractID     string
+	ContractNumber string
+
+	// From and To limit the creation time of the acts, To excluded; zero
+	// values do not limit it
+	From time.Time
+	To   time.Time
+}
+
+// BatchEntry is the status of an act in the manifest of a batch
+type BatchEntry struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	File   string `json:"file,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchManifest describes the archive of a batch generation
+type BatchManifest struct {
+	CreatedAt time.Time    `json:"createdAt"`
+	Total     int          `json:"total"`
+	Generated int          `json:"generated"`
+	Failed    int          `json:"failed"`
+	Acts      []BatchEntry `json:"acts"`
+}
+
+// FindBatchActs returns the IDs of the acts a batch request selects. IDs
+// given explicitly keep their order, duplicates are dropped; acts found by
+// contract or period are ordered by creation time.
+func (s *actService) FindBatchActs(ctx context.Context, req BatchRequest) ([]string, error) {
+	utils.LogMethodInit("ActService.FindBatchActs")
+
+	hasFilter := req.ContractID != "" || req.ContractNumber != "" || !req.From.IsZero() || !req.To.IsZero()
+	switch {
+	case len(req.IDs) > 0 && hasFilter:
+		err := fmt.Errorf("%w: ids cannot be combined with contractId, contractNumber, from or to", ErrInvalidBatch)
+		utils.LogMethodError("ActService.FindBatchActs", err)
+		return nil, err
+	case len(req.IDs) == 0 && !hasFilter:
+		err := fmt.Errorf("%w: ids, contractId, contractNumber or a period is required", ErrInvalidBatch)
+		utils.LogMethodError("ActService.FindBatchActs", err)
+		return nil, err
+	case !req.From.IsZero() && !req.To.IsZero() && !req.From.Before(req.To):
+		err := fmt.Errorf("%w: the period ends before it starts", ErrInvalidBatch)
+		utils.LogMethodError("ActService.FindBatchActs", err)
+		return nil, err
+	}
+
+	if len(req.IDs) > 0 {
+		ids := make([]string, 0, len(req.IDs))
+		seen := make(map[string]bool)
+		for _, id := range req.IDs {
+			if _, err := primitive.ObjectIDFromHex(id); err != nil {
+				err = fmt.Errorf("%w: invalid act ID %q", ErrInvalidBatch, id)
+				utils.LogMethodError("ActService.FindBatchActs", err)
+				return nil, err
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if err := s.checkBatchSize(len(ids)); err != nil {
+			utils.LogMethodError("ActService.FindBatchActs", err)
+			return nil, err
+		}
+		utils.LogMethodSuccess("ActService.FindBatchActs")
+		return ids, nil
+	}
+
+	ids, err := s.repo.FindIDs(ctx, repository.ActFilter{
+		ContractID:     req.ContractID,
+		ContractNumber: req.ContractNumber,
+		CreatedFrom:    req.From,
+		CreatedTo:      req.To,
+	})
+	if err != nil {
+		utils.LogMethodError("ActService.FindBatchActs", err)
+		return nil, fmt.Errorf("failed to find acts: %w", err)
+	}
+
+	utils.LogInfo("Batch selects %d acts", len(ids))
+	if err = s.checkBatchSize(len(ids)); err != nil {
+		utils.LogMethodError("ActService.FindBatchActs", err)
+		return nil, err
+	}
+	utils.LogMethodSuccess("ActService.FindBatchActs")
+	return ids, nil
+}
+
+// checkBatchSize rejects a batch of more acts than MaxBatchSize allows
+func (s *actService) checkBatchSize(acts int) error {
+	if s.config.MaxBatchSize > 0 && acts > s.config.MaxBatchSize {
+		return fmt.Errorf("%w: %d acts are selected, at most %d are allowed", ErrInvalidBatch, acts, s.config.MaxBatchSize)
+	}
+	return nil
+}
+
+// GenerateBatch generates the acts one by one and streams a ZIP archive of
+// the files to the writer, followed by a manifest with the status of every
+// act. An act that fails is recorded in the manifest and does not stop the
+// batch; only a failure to write the archive is returned.
+func (s *actService) GenerateBatch(ctx context.Context, ids []string, opts GenerateOptions, w io.Writer) (*BatchManifest, error) {
+	utils.LogMethodInit("ActService.GenerateBatch")
+	utils.LogInfo("Generating batch of %d acts", len(ids))
+
+	manifest, err := writeBatchArchive(ctx, w, ids, func(id string) (string, error) {
+		downloadLink, err := s.GenerateAct(ctx, id, opts)
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(s.config.GeneratedPath, filepath.Base(downloadLink))
+		if _, err = os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		// The stored file was removed after it was found, e.g. by the cleanup
+		utils.LogInfo("Generated file of act %s is missing, generating it again", id)
+		act, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			return "", fmt.Errorf("act not found: %w", err)
+		}
+		if downloadLink, err = s.processAndGenerateAct(ctx, act, opts); err != nil {
+			return "", err
+		}
+		return filepath.Join(s.config.GeneratedPath, filepath.Base(downloadLink)), nil
+	})
+	if err != nil {
+		utils.LogMethodError("ActService.GenerateBatch", err)
+		return nil, err
+	}
+
+	utils.LogInfo("Generated batch: %d acts generated, %d failed", manifest.Generated, manifest.Failed)
+	utils.LogMethodSuccess("ActService.GenerateBatch")
+	return manifest, nil
+}
+
+// writeBatchArchive writes the file generated for every act into a ZIP
+// archive and adds the manifest last. Each file is flushed to the client
+// as soon as it is added when the writer supports it.
+func writeBatchArchive(ctx context.Context, w io.Writer, ids []string, generate func(id string) (string, error)) (*BatchManifest, error) {
+	archive := zip.NewWriter(w)
+	manifest := &BatchManifest{CreatedAt: time.Now(), Total: len(ids), Acts: make([]BatchEntry, 0, len(ids))}
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("batch was interrupted: %w", err)
+		}
+
+		entry := BatchEntry{ID: id, Status: BatchStatusGenerated}
+		path, err := generate(id)
+		if err == nil {
+			entry.File = filepath.Base(path)
+			err = addArchiveFile(archive, path, entry.File)
+			if errors.Is(err, errArchiveWrite) {
+				return nil, err
+			}
+		}
+		if err != nil {
+			utils.LogError("Failed to generate act %s in batch: %v", id, err)
+			entry = BatchEntry{ID: id, Status: BatchStatusFailed, Error: batchErrorMessage(err)}
+			manifest.Failed++
+		} else {
+			manifest.Generated++
+		}
+		manifest.Acts = append(manifest.Acts, entry)
+
+		if err = archive.Flush(); err != nil {
+			return nil, fmt.Errorf("%w: %v", errArchiveWrite, err)
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	manifestFile, err := archive.Create(batchManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errArchiveWrite, err)
+	}
+	encoder := json.NewEncoder(manifestFile)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", errArchiveWrite, err)
+	}
+	if err = archive.Close(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errArchiveWrite, err)
+	}
+	return manifest, nil
+}
+
+// batchErrorMessage describes why an act of a batch failed in the manifest,
+// which is given to the client; other errors are only logged
+func batchErrorMessage(err error) string {
+	var missingErr *MissingPlaceholdersError
+	switch {
+	case errors.Is(err, repository.ErrActNotFound):
+		return "act not found"
+	case errors.As(err, &missingErr):
+		return "template has " + missingErr.Error()
+	case errors.Is(err, ErrPDFUnsupportedText):
+		return "act has characters the PDF font can not print"
+	default:
+		return "failed to generate act"
+	}
+}
+
+// addArchiveFile copies a generated file into the archive
+func addArchiveFile(archive *zip.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open generated file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open generated file: %w", err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("failed to open generated file: %w", err)
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errArchiveWrite, err)
+	}
+	if _, err = io.Copy(writer, file); err != nil {
+		return fmt.Errorf("%w: %v", errArchiveWrite, err)
+	}
+	return nil
+}
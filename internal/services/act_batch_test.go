@@ -0,0 +1,127 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+)
+
+func TestWriteBatchArchive(t *testing.T) {
+	dir := t.TempDir()
+	generate := func(id string) (string, error) {
+		path := filepath.Join(dir, "act_"+id+".xlsx")
+		switch id {
+		case "broken":
+			return "", fmt.Errorf("act not found: %w", repository.ErrActNotFound)
+		case "removed":
+			return path, nil
+		}
+		return path, os.WriteFile(path, []byte("content of "+id), 0644)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := writeBatchArchive(context.Background(), &buf, []string{"a", "broken", "removed", "b"}, generate)
+	if err != nil {
+		t.Fatalf("writeBatchArchive() error = %v", err)
+	}
+	if manifest.Total != 4 || manifest.Generated != 2 || manifest.Failed != 2 {
+		t.Errorf("manifest counts = %d/%d/%d, expected 4/2/2", manifest.Total, manifest.Generated, manifest.Failed)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("archive does not open: %v", err)
+	}
+	files := make(map[string]string)
+	var names []string
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		files[file.Name] = string(content)
+		names = append(names, file.Name)
+	}
+
+	if expected := []string{"act_a.xlsx", "act_b.xlsx", batchManifestName}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("archive files = %v, expected %v", names, expected)
+	}
+	if files["act_b.xlsx"] != "content of b" {
+		t.Errorf("act_b.xlsx = %q", files["act_b.xlsx"])
+	}
+
+	var written BatchManifest
+	if err = json.Unmarshal([]byte(files[batchManifestName]), &written); err != nil {
+		t.Fatalf("manifest does not parse: %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, entry := range written.Acts {
+		statuses[entry.ID] = entry.Status + " " + entry.File
+	}
+	expected := map[string]string{
+		"a":       "generated act_a.xlsx",
+		"broken":  "failed ",
+		"removed": "failed ",
+		"b":       "generated act_b.xlsx",
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("manifest statuses = %v, expected %v", statuses, expected)
+	}
+	if written.Acts[1].Error != "act not found" {
+		t.Errorf("error of broken act = %q, expected act not found", written.Acts[1].Error)
+	}
+	// Internal errors, here with the path of the file, are not given out
+	if written.Acts[2].Error != "failed to generate act" {
+		t.Errorf("error of removed act = %q, expected failed to generate act", written.Acts[2].Error)
+	}
+}
+
+func TestWriteBatchArchiveStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	generated := 0
+	_, err := writeBatchArchive(ctx, io.Discard, []string{"a", "b"}, func(id string) (string, error) {
+		generated++
+		cancel()
+		return "", errors.New("not generated")
+	})
+	if !errors.Is(err, context.Canceled) || generated != 1 {
+		t.Errorf("writeBatchArchive() error = %v after %d acts, expected cancellation after 1", err, generated)
+	}
+}
+
+func TestFindBatchActs(t *testing.T) {
+	service := &actService{config: &config.Config{MaxBatchSize: 2}}
+	id := "6523f0a1b2c3d4e5f6a7b8c9"
+
+	ids, err := service.FindBatchActs(context.Background(), BatchRequest{IDs: []string{id, id}})
+	if err != nil || !reflect.DeepEqual(ids, []string{id}) {
+		t.Errorf("FindBatchActs() = %v, %v; expected the ID once", ids, err)
+	}
+
+	invalid := []BatchRequest{
+		{},
+		{IDs: []string{"not-an-id"}},
+		{IDs: []string{id}, ContractNumber: "42"},
+		{IDs: []string{id, "6523f0a1b2c3d4e5f6a7b8ca", "6523f0a1b2c3d4e5f6a7b8cb"}},
+		{From: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, req := range invalid {
+		if _, err = service.FindBatchActs(context.Background(), req); !errors.Is(err, ErrInvalidBatch) {
+			t.Errorf("FindBatchActs(%+v) error = %v, expected ErrInvalidBatch", req, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+// GenerateCombined generates one workbook with a sheet for every act and a
+// summary sheet with their totals. All acts are rendered with the template
+// of the first act, or with the requested form. Totals are calculated for
+// the workbook only, the acts and their links are not updated.
+func (s *actService) GenerateCombined(ctx context.Context, ids []string, opts GenerateOptions) (string, error) {
+	utils.LogMethodInit("ActService.GenerateCombined")
+	utils.LogInfo("Generating combined workbook of %d acts", len(ids))
+
+	if err := s.checkBatchSize(len(ids)); err != nil {
+		utils.LogMethodError("ActService.GenerateCombined", err)
+		return "", err
+	}
+
+	var template *models.Template
+	acts := make([]CombinedAct, 0, len(ids))
+	for _, id := range ids {
+		act, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			utils.LogMethodError("ActService.GenerateCombined", err)
+			return "", fmt.Errorf("act %s not found: %w", id, err)
+		}
+		if act.BigAct == nil {
+			err = fmt.Errorf("act %s does not have BigAct data", id)
+			utils.LogMethodError("ActService.GenerateCombined", err)
+			return "", err
+		}
+
+		// The sheets are cloned from one template, so every act must use it
+		actTemplate, err := s.resolveTemplate(ctx, act, opts.Form)
+		if err != nil {
+			utils.LogMethodError("ActService.GenerateCombined", err)
+			return "", err
+		}
+		if template == nil {
+			template = actTemplate
+		} else if actTemplate.FilePath != template.FilePath {
+			err = fmt.Errorf("%w: act %s uses another template than the first act, select acts of one template or a form", ErrInvalidBatch, id)
+			utils.LogMethodError("ActService.GenerateCombined", err)
+			return "", err
+		}
+		renderOpts, err := s.prepareRender(ctx, act, template, opts)
+		if err != nil {
+			utils.LogMethodError("ActService.GenerateCombined", err)
+			return "", err
+		}
+		acts = append(acts, CombinedAct{Act: act, Options: renderOpts})
+	}
+	if template == nil {
+		err := fmt.Errorf("%w: no acts to combine", ErrInvalidBatch)
+		utils.LogMethodError("ActService.GenerateCombined", err)
+		return "", err
+	}
+
+	// Generate filename
+	timestamp := time.Now().Unix()
+	filename := fmt.Sprintf("acts_combined_%d.xlsx", timestamp)
+	if opts.Form != "" {
+		filename = fmt.Sprintf("acts_combined_%s_%d.xlsx", opts.Form, timestamp)
+	}
+	outputPath := fmt.Sprintf("%s/%s", s.config.GeneratedPath, filename)
+
+	sheetNames, err := s.excelService.GenerateCombined(acts, template.FilePath, outputPath)
+	if err != nil {
+		utils.LogMethodError("ActService.GenerateCombined", err)
+		return "", fmt.Errorf("failed to generate Excel: %w", err)
+	}
+	downloadLink := fmt.Sprintf("/api/act/download/%s", filename)
+
+	// Render the workbook to PDF next to it
+	if opts.Format == FormatPDF {
+		pdfFilename := strings.TrimSuffix(filename, ".xlsx") + ".pdf"
+		// Each act sheet keeps the watermark of its act
+		pdfOpts := PDFOptions{SheetWatermarks: make(map[string]string, len(acts))}
+		for idx, item := range acts {
+			pdfOpts.SheetWatermarks[sheetNames[idx]] = item.Options.Watermark
+		}
+		err := s.excelService.ExportPDF(outputPath, fmt.Sprintf("%s/%s", s.config.GeneratedPath, pdfFilename), pdfOpts)
+		if err != nil {
+			utils.LogMethodError("ActService.GenerateCombined", err)
+			return "", fmt.Errorf("failed to export PDF: %w", err)
+		}
+		downloadLink = fmt.Sprintf("/api/act/download/%s", pdfFilename)
+	}
+
+	utils.LogInfo("Successfully generated combined workbook with download link: %s", downloadLink)
+	utils.LogMethodSuccess("ActService.GenerateCombined")
+	return downloadLink, nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// storedActRepository finds the acts it holds by ID
+type storedActRepository struct {
+	repository.ActRepository
+	acts map[string]*models.Act
+}
+
+// FindByID returns the act stored under the ID
+func (r *storedActRepository) FindByID(_ context.Context, id string) (*models.Act, error) {
+	act, ok := r.acts[id]
+	if !ok {
+		return nil, repository.ErrActNotFound
+	}
+	return act, nil
+}
+
+// actTemplateService resolves every act to a template of its own template ID
+type actTemplateService struct {
+	TemplateService
+}
+
+// ResolveTemplate returns a template named after the template ID of the act
+func (s *actTemplateService) ResolveTemplate(_ context.Context, act *models.Act) (*models.Template, error) {
+	return &models.Template{TemplateID: act.TemplateID, FilePath: act.TemplateID + ".xlsx"}, nil
+}
+
+func TestGenerateCombinedRejectsMixedTemplates(t *testing.T) {
+	repo := &storedActRepository{acts: map[string]*models.Act{}}
+	var ids []string
+	for _, templateID := range []string{"customer-a", "customer-a", "customer-b"} {
+		act := testAct(100)
+		act.ID = primitive.NewObjectID()
+		act.TemplateID = templateID
+		repo.acts[act.ID.Hex()] = act
+		ids = append(ids, act.ID.Hex())
+	}
+
+	cfg := testConfig()
+	cfg.GeneratedPath = t.TempDir()
+	service := &actService{
+		repo:            repo,
+		excelService:    NewExcelService(cfg),
+		templateService: &actTemplateService{},
+		assetService:    NewAssetService(cfg),
+		config:          cfg,
+	}
+
+	_, err := service.GenerateCombined(context.Background(), ids, GenerateOptions{})
+	if !errors.Is(err, ErrInvalidBatch) {
+		t.Errorf("GenerateCombined() error = %v, expected ErrInvalidBatch for acts of two templates", err)
+	}
+}
+
+func TestGenerateCombinedLimitsActs(t *testing.T) {
+	service := &actService{config: &config.Config{MaxBatchSize: 1}}
+	ids := []string{primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()}
+
+	if _, err := service.GenerateCombined(context.Background(), ids, GenerateOptions{}); !errors.Is(err, ErrInvalidBatch) {
+		t.Errorf("GenerateCombined() error = %v, expected ErrInvalidBatch for too many acts", err)
+	}
+}
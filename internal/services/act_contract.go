@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ContractTotals are the cumulative amounts of the acts of a contract, net,
+// VAT and with VAT, since the start of construction and since the start of
+// the year. Balance is the contract amount left after the total with VAT,
+// nil for contracts without an amount.
+type ContractTotals struct {
+	SinceConstructionStart        models.Money  `json:"sinceConstructionStart"`
+	SinceConstructionStartVAT     models.Money  `json:"sinceConstructionStartVat"`
+	SinceConstructionStartWithVAT models.Money  `json:"sinceConstructionStartWithVat"`
+	SinceYearStart                models.Money  `json:"sinceYearStart"`
+	SinceYearStartVAT             models.Money  `json:"sinceYearStartVat"`
+	SinceYearStartWithVAT         models.Money  `json:"sinceYearStartWithVat"`
+	Balance                       *models.Money `json:"balance,omitempty"`
+	Acts                          int           `json:"acts"`
+}
+
+// ContractSummary is a contract with its totals to date
+type ContractSummary struct {
+	Contract *models.Contract `json:"contract"`
+	Totals   ContractTotals   `json:"totals"`
+}
+
+// data returns the totals as template data
+func (t ContractTotals) data() map[string]interface{} {
+	return map[string]interface{}{
+		"sinceConstructionStart":        t.SinceConstructionStart,
+		"sinceConstructionStartVat":     t.SinceConstructionStartVAT,
+		"sinceConstructionStartWithVat": t.SinceConstructionStartWithVAT,
+		"sinceYearStart":                t.SinceYearStart,
+		"sinceYearStartVat":             t.SinceYearStartVAT,
+		"sinceYearStartWithVat":         t.SinceYearStartWithVAT,
+		"contractBalance":               optionalValue(t.Balance),
+	}
+}
+
+// ContractTotals sums the approved and signed acts of a contract to date
+func (s *actService) ContractTotals(ctx context.Context, contractID string) (*ContractSummary, error) {
+	utils.LogMethodInit("ActService.ContractTotals")
+
+	contract, err := s.contractService.GetContract(ctx, contractID)
+	if err != nil {
+		utils.LogMethodError("ActService.ContractTotals", err)
+		return nil, err
+	}
+	// An act without totals issued now counts every approved and signed act
+	asOf := &models.Act{CreatedAt: time.Now()}
+	costs, err := s.repo.SumContractCosts(ctx, repository.ContractCostFilter{
+		ContractID: contractID,
+		CreatedBy:  asOf.CreatedAt,
+		Location:   s.timeZone(),
+	})
+	if err != nil {
+		utils.LogMethodError("ActService.ContractTotals", err)
+		return nil, fmt.Errorf("failed to sum acts of contract: %w", err)
+	}
+	totals := s.sumContractTotals(asOf, costs, contract)
+
+	utils.LogMethodSuccess("ActService.ContractTotals")
+	return &ContractSummary{Contract: contract, Totals: totals}, nil
+}
+
+// loadContract finds the contract of an act and the costs of the approved
+// and signed acts issued under it before the act, nil for acts without a
+// contract
+func (s *actService) loadContract(ctx context.Context, act *models.Act) (*models.Contract, *repository.ContractCosts, error) {
+	if act.ContractID == "" {
+		return nil, nil, nil
+	}
+
+	contract, err := s.contractService.GetContract(ctx, act.ContractID)
+	if err != nil {
+		return nil, nil, err
+	}
+	costs, err := s.repo.SumContractCosts(ctx, repository.ContractCostFilter{
+		ContractID: act.ContractID,
+		CreatedBy:  act.CreatedAt,
+		ExcludeID:  act.ID,
+		Location:   s.timeZone(),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sum acts of contract: %w", err)
+	}
+	return contract, costs, nil
+}
+
+// contractData builds the template data of an act issued under a contract:
+// the contract fields the act does not set itself, the contract amount and
+// the cumulative totals
+func (s *actService) contractData(act *models.Act, contract *models.Contract, costs *repository.ContractCosts) map[string]interface{} {
+	data := s.sumContractTotals(act, costs, contract).data()
+	data["contractAmount"] = contract.Amount
+
+	fields := map[string]interface{}{
+		"contractNumber": contract.Number,
+		"customer":       contract.Customer,
+		"contractor":     contract.Contractor,
+		"objectName":     contract.ObjectName,
+	}
+	if !contract.Date.IsZero() {
+		fields["contractDate"] = contract.Date
+	}
+	for key, value := range fields {
+		if _, ok := act.BigAct.TextFields[key]; ok || value == "" {
+			continue
+		}
+		data[key] = value
+	}
+	return data
+}
+
+// contractNumberCosts sums the acts counted in the cumulative totals of
+// form KS-3 for acts without a contract. Acts of the same contract are found
+// by the contractNumber text field; without it only the act itself is counted.
+func (s *actService) contractNumberCosts(ctx context.Context, act *models.Act) (*repository.ContractCosts, error) {
+	contractNumber, ok := act.BigAct.TextFields["contractNumber"].(string)
+	if !ok || contractNumber == "" {
+		return nil, nil
+	}
+	return s.repo.SumContractCosts(ctx, repository.ContractCostFilter{
+		ContractNumber: contractNumber,
+		CreatedBy:      act.CreatedAt,
+		ExcludeID:      act.ID,
+		Location:       s.timeZone(),
+	})
+}
+
+// contractTotals returns the cumulative totals of the act as template data
+func (s *actService) contractTotals(act *models.Act, costs *repository.ContractCosts) map[string]interface{} {
+	return s.sumContractTotals(act, costs, nil).data()
+}
+
+// sumContractTotals sums the totals of the act and the costs of the
+// approved and signed acts of its contract issued before it, since the
+// start of construction and since the start of the year of the act; an act
+// without BigAct data is not counted itself. The costs of other acts are
+// summed from their positions, so acts that were never generated are
+// counted too, and their VAT is calculated per act like their own totals.
+func (s *actService) sumContractTotals(act *models.Act, costs *repository.ContractCosts, contract *models.Contract) ContractTotals {
+	var totals ContractTotals
+	year := act.CreatedAt.In(s.timeZone()).Year()
+	add := func(costYear int, net, vat models.Money) {
+		totals.SinceConstructionStart = totals.SinceConstructionStart.Add(net)
+		totals.SinceConstructionStartVAT = totals.SinceConstructionStartVAT.Add(vat)
+		if costYear == year {
+			totals.SinceYearStart = totals.SinceYearStart.Add(net)
+			totals.SinceYearStartVAT = totals.SinceYearStartVAT.Add(vat)
+		}
+	}
+
+	if act.BigAct != nil {
+		totals.Acts++
+		add(year, act.BigAct.TotalCost, act.BigAct.VATTotal)
+	}
+	if costs != nil {
+		totals.Acts += costs.Acts
+
+		// Positions without a rate are taxed with the default rate
+		type actRate struct {
+			actID primitive.ObjectID
+			rate  models.VATRate
+		}
+		net := make(map[actRate]models.Money)
+		years := make(map[primitive.ObjectID]int)
+		for _, cost := range costs.ByRate {
+			position := models.Position{VATRate: cost.VATRate}
+			key := actRate{actID: cost.ActID, rate: position.EffectiveVATRate(s.config.DefaultVATRate)}
+			net[key] = net[key].Add(cost.Cost)
+			years[cost.ActID] = cost.Year
+		}
+		for key, amount := range net {
+			add(years[key.actID], amount, amount.Percent(key.rate.Percent()))
+		}
+	}
+
+	totals.SinceConstructionStartWithVAT = totals.SinceConstructionStart.Add(totals.SinceConstructionStartVAT)
+	totals.SinceYearStartWithVAT = totals.SinceYearStart.Add(totals.SinceYearStartVAT)
+	if contract != nil && !contract.Amount.IsZero() {
+		balance := contract.Amount.Sub(totals.SinceConstructionStartWithVAT)
+		totals.Balance = &balance
+	}
+	return totals
+}
+
+// positionTotals returns the cumulative costs of the positions of the act
+// shown by form KS-3: the current period cost of the position plus the
+// current period costs of its estimate line in the acts of the contract
+// issued before, since the start of construction and since the start of the
+// year of the act. Positions without an estimate line count only their own cost.
+func (s *actService) positionTotals(act *models.Act, costs *repository.ContractCosts) []map[string]interface{} {
+	sinceStart, sinceYear := s.estimateLineCosts(act, costs)
+
+	data := make([]map[string]interface{}, len(act.Positions))
+	for i, pos := range act.Positions {
+		var own models.Money
+		if pos.CurrentPeriodCost != nil {
+			own = *pos.CurrentPeriodCost
+		}
+		data[i] = map[string]interface{}{
+			"sinceConstructionStart": own,
+			"sinceYearStart":         own,
+		}
+		if pos.EstimateLine != "" {
+			data[i]["sinceConstructionStart"] = own.Add(sinceStart[pos.EstimateLine])
+			data[i]["sinceYearStart"] = own.Add(sinceYear[pos.EstimateLine])
+		}
+	}
+	return data
+}
+
+// accumulatePositions sets the accumulated cost of every position with an
+// estimate line: its current period cost plus the current period costs of
+// the same estimate line in the acts of the contract issued before
+func (s *actService) accumulatePositions(act *models.Act, costs *repository.ContractCosts) {
+	previous, _ := s.estimateLineCosts(act, costs)
+
+	for i := range act.Positions {
+		pos := &act.Positions[i]
+		if pos.EstimateLine == "" {
+			continue
+		}
+		accumulated := previous[pos.EstimateLine]
+		if pos.CurrentPeriodCost != nil {
+			accumulated = accumulated.Add(*pos.CurrentPeriodCost)
+		}
+		pos.AccumulatedCost = &accumulated
+	}
+}
+
+// timeZone returns the business time zone the years of acts are counted in
+func (s *actService) timeZone() *time.Location {
+	if s.config.TimeZone == nil {
+		return time.UTC
+	}
+	return s.config.TimeZone
+}
+
+// estimateLineCosts returns the costs of the estimate lines in the acts of
+// the contract issued before the act, since the start of construction and
+// since the start of the year of the act
+func (s *actService) estimateLineCosts(act *models.Act, costs *repository.ContractCosts) (sinceStart, sinceYear map[string]models.Money) {
+	sinceStart = make(map[string]models.Money)
+	sinceYear = make(map[string]models.Money)
+	if costs == nil {
+		return sinceStart, sinceYear
+	}
+	year := act.CreatedAt.In(s.timeZone()).Year()
+	for _, cost := range costs.ByLine {
+		sinceStart[cost.EstimateLine] = sinceStart[cost.EstimateLine].Add(cost.Cost)
+		if cost.Year == year {
+			sinceYear[cost.EstimateLine] = sinceYear[cost.EstimateLine].Add(cost.Cost)
+		}
+	}
+	return sinceStart, sinceYear
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+)
+
+func TestSumContractTotalsBalance(t *testing.T) {
+	service := &actService{config: testConfig()}
+	contract := &models.Contract{Number: "42", Amount: models.NewMoney(1000, 0)}
+	contractActs := []models.Act{
+		testContractAct(time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC), "1", 10000),
+		testContractAct(time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), "1", 20000),
+	}
+
+	// To date every act is counted, 300.00 + 60.00 VAT of the 1000.00
+	asOf := &models.Act{CreatedAt: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)}
+	costs := testContractCosts(t, asOf, contractActs)
+	totals := service.sumContractTotals(asOf, costs, contract)
+	if totals.Acts != 2 || totals.SinceConstructionStartWithVAT != models.NewMoney(360, 0) || totals.SinceYearStart != models.NewMoney(200, 0) {
+		t.Errorf("totals = %+v, expected 2 acts, 360.00 with VAT and 200.00 since year start", totals)
+	}
+	if totals.Balance == nil || *totals.Balance != models.NewMoney(640, 0) {
+		t.Errorf("balance = %v, expected 640.00", totals.Balance)
+	}
+
+	// Contracts without an amount have no balance
+	if totals = service.sumContractTotals(asOf, costs, &models.Contract{}); totals.Balance != nil {
+		t.Errorf("balance = %v, expected nil", *totals.Balance)
+	}
+}
+
+func TestSumContractTotalsRoundsVATPerAct(t *testing.T) {
+	service := &actService{config: testConfig()}
+
+	// Positions without a rate are taxed at the default rate together with
+	// those of that rate, 0.06 net gives 0.01 VAT, not 0.01 per position
+	act := testContractAct(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), "1", 3)
+	act.Positions = append(act.Positions, testPosition(3, models.VATRate20), testPosition(500, models.VATRateNone))
+	other := testContractAct(time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), "", 3)
+	draft := testContractAct(time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), "1", 100000)
+	draft.Status = models.ActStatusDraft
+
+	asOf := &models.Act{CreatedAt: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)}
+	totals := service.sumContractTotals(asOf, testContractCosts(t, asOf, []models.Act{act, other, draft}), nil)
+	if totals.Acts != 2 || totals.SinceConstructionStart != 509 || totals.SinceConstructionStartVAT != 2 {
+		t.Errorf("totals = %+v, expected 2 acts, 5.09 net and 0.02 VAT", totals)
+	}
+}
+
+func TestSumContractTotalsYearInTimeZone(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip(err)
+	}
+	cfg := testConfig()
+	cfg.TimeZone = moscow
+	service := &actService{config: cfg}
+
+	// 01:00 on 1 January in Moscow is still 31 December in UTC
+	contractActs := []models.Act{
+		testContractAct(time.Date(2026, time.January, 1, 1, 0, 0, 0, moscow), "1.1", 10000),
+		testContractAct(time.Date(2025, time.December, 31, 23, 0, 0, 0, moscow), "1.1", 5000),
+	}
+	act := testContractAct(time.Date(2026, time.February, 1, 0, 0, 0, 0, moscow), "1.1", 0)
+	repo := &contractActRepository{acts: contractActs}
+	costs, err := repo.SumContractCosts(context.Background(), repository.ContractCostFilter{
+		ContractID: "contract",
+		CreatedBy:  act.CreatedAt,
+		Location:   service.timeZone(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totals := service.sumContractTotals(&act, costs, nil)
+	if totals.SinceConstructionStart != models.NewMoney(150, 0) || totals.SinceYearStart != models.NewMoney(100, 0) {
+		t.Errorf("totals = %+v, expected 150.00 since construction start and 100.00 since year start", totals)
+	}
+	if _, sinceYear := service.estimateLineCosts(&act, costs); sinceYear["1.1"] != models.NewMoney(100, 0) {
+		t.Errorf("estimate line cost since year start = %v, expected 100.00", sinceYear["1.1"])
+	}
+}
+
+func TestAccumulatePositions(t *testing.T) {
+	service := &actService{config: testConfig()}
+	contractActs := []models.Act{
+		testContractAct(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), "1.1", 10000),
+		testContractAct(time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), "1.2", 5000),
+		testContractAct(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC), "1.1", 99999),
+		testContractAct(time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), "1.1", 88888),
+	}
+	contractActs[3].Status = models.ActStatusCancelled
+
+	act := testContractAct(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), "1.1", 2500)
+	sent := models.NewMoney(1, 0)
+	act.Positions = append(act.Positions, models.Position{AccumulatedCost: &sent})
+	service.accumulatePositions(&act, testContractCosts(t, &act, contractActs))
+
+	// Only the earlier signed act of the same estimate line is added
+	if cost := act.Positions[0].AccumulatedCost; cost == nil || *cost != models.NewMoney(125, 0) {
+		t.Errorf("accumulated cost = %v, expected 125.00", cost)
+	}
+	if cost := act.Positions[1].AccumulatedCost; *cost != sent {
+		t.Errorf("accumulated cost without estimate line = %v, expected the sent %v", cost, sent)
+	}
+}
+
+func TestContractData(t *testing.T) {
+	service := &actService{config: testConfig()}
+	contract := &models.Contract{
+		Number:   "42",
+		Date:     time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC),
+		Customer: "Contract customer",
+	}
+	act := testAct(100, 200)
+	delete(act.BigAct.TextFields, "contractNumber")
+	delete(act.BigAct.TextFields, "contractDate")
+
+	data := service.contractData(act, contract, nil)
+	if data["contractNumber"] != "42" || data["contractDate"] != contract.Date {
+		t.Errorf("contract fields = %v, %v; expected those of the contract", data["contractNumber"], data["contractDate"])
+	}
+	if _, ok := data["customer"]; ok {
+		t.Errorf("customer = %v, expected the text field of the act to be kept", data["customer"])
+	}
+	if _, ok := data["contractBalance"]; !ok || data["contractBalance"] != nil {
+		t.Errorf("contractBalance = %v, expected an empty value", data["contractBalance"])
+	}
+}
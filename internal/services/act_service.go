@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -13,29 +17,86 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrInvalidAct is returned when an act is rejected on creation or update
+var ErrInvalidAct = errors.New("invalid act")
+
+// ErrActLocked is returned when a signed or cancelled act is edited
+var ErrActLocked = errors.New("act is locked")
+
 // ActService defines the interface for act business logic
 type ActService interface {
 	CreateAct(ctx context.Context, act *models.Act) (string, error)
-	GenerateAct(ctx context.Context, actID string) (string, error)
+	UpdateAct(ctx context.Context, actID string, act *models.Act) error
+	TransitionAct(ctx context.Context, actID string, req TransitionRequest) (*models.Act, error)
+	GenerateAct(ctx context.Context, actID string, opts GenerateOptions) (string, error)
+	VerifyAct(ctx context.Context, actID string) (*models.Act, error)
+	ImportAct(ctx context.Context, opts ImportOptions) (*ImportResult, error)
+	FindBatchActs(ctx context.Context, req BatchRequest) ([]string, error)
+	GenerateBatch(ctx context.Context, ids []string, opts GenerateOptions, w io.Writer) (*BatchManifest, error)
+	GenerateCombined(ctx context.Context, ids []string, opts GenerateOptions) (string, error)
+	ContractTotals(ctx context.Context, contractID string) (*ContractSummary, error)
+}
+
+// GenerateOptions controls a generation of an act
+type GenerateOptions struct {
+	// MissingKeyPolicy overrides the policy of the template when it is set
+	MissingKeyPolicy MissingKeyPolicy
+
+	// Form generates the act as a built-in standard form instead of with
+	// its template when it is set
+	Form FormType
+
+	// Format is the format of the returned file, xlsx when it is empty. PDF
+	// files are rendered from the generated workbook.
+	Format OutputFormat
+}
+
+// ImportOptions describes a filled workbook imported as an act
+type ImportOptions struct {
+	// TemplateID and TemplateVersion select the template the workbook was
+	// filled from, the default template when the ID is empty and the active
+	// version when the version is 0
+	TemplateID      string
+	TemplateVersion int
+
+	// Content is the filled workbook
+	Content []byte
+
+	// Save creates the act when every cell of the workbook was read
+	Save bool
+}
+
+// ImportResult is an act read from a filled workbook. ID is set when the
+// act was created.
+type ImportResult struct {
+	ID     string        `json:"id,omitempty"`
+	Act    *models.Act   `json:"act"`
+	Issues []ImportIssue `json:"issues"`
 }
 
 // actService implements ActService
 type actService struct {
-	repo         repository.ActRepository
-	excelService ExcelService
-	config       *config.Config
+	repo            repository.ActRepository
+	excelService    ExcelService
+	templateService TemplateService
+	assetService    AssetService
+	contractService ContractService
+	config          *config.Config
 }
 
 // NewActService creates a new ActService
-func NewActService(repo repository.ActRepository, excelService ExcelService, cfg *config.Config) ActService {
+func NewActService(repo repository.ActRepository, excelService ExcelService, templateService TemplateService, assetService AssetService, contractService ContractService, cfg *config.Config) ActService {
 	return &actService{
-		repo:         repo,
-		excelService: excelService,
-		config:       cfg,
+		repo:            repo,
+		excelService:    excelService,
+		templateService: templateService,
+		assetService:    assetService,
+		contractService: contractService,
+		config:          cfg,
 	}
 }
 
-// CreateAct creates a new act in the database
+// CreateAct creates a new act in the database. New acts are drafts.
 func (s *actService) CreateAct(ctx context.Context, act *models.Act) (string, error) {
 	utils.LogMethodInit("ActService.CreateAct")
 
@@ -44,11 +105,13 @@ func (s *actService) CreateAct(ctx context.Context, act *models.Act) (string, er
 	act.CreatedAt = now
 	act.UpdatedAt = now
 
-	// Generate IDs for positions if not set
-	for i := range act.Positions {
-		if act.Positions[i].ID.IsZero() {
-			act.Positions[i].ID = primitive.NewObjectID()
-		}
+	// The status only changes by transitions
+	act.Status = models.ActStatusDraft
+	act.StatusHistory = nil
+
+	if err := s.prepareAct(ctx, act); err != nil {
+		utils.LogMethodError("ActService.CreateAct", err)
+		return "", err
 	}
 
 	// Save to database
@@ -63,8 +126,94 @@ func (s *actService) CreateAct(ctx context.Context, act *models.Act) (string, er
 	return id, nil
 }
 
-// GenerateAct generates an Excel file for an act
-func (s *actService) GenerateAct(ctx context.Context, actID string) (string, error) {
+// UpdateAct replaces the content of an act: its data, positions, template,
+// contract and locale. The status, its history and the creation time are
+// kept. Signed and cancelled acts can not be edited.
+func (s *actService) UpdateAct(ctx context.Context, actID string, act *models.Act) error {
+	utils.LogMethodInit("ActService.UpdateAct")
+
+	stored, err := s.repo.FindByID(ctx, actID)
+	if err != nil {
+		utils.LogMethodError("ActService.UpdateAct", err)
+		return err
+	}
+	if status := stored.CurrentStatus(); status.IsLocked() {
+		err = fmt.Errorf("%w: %s acts can not be edited", ErrActLocked, status)
+		utils.LogMethodError("ActService.UpdateAct", err)
+		return err
+	}
+
+	if err = s.prepareAct(ctx, act); err != nil {
+		utils.LogMethodError("ActService.UpdateAct", err)
+		return err
+	}
+
+	act.ID = stored.ID
+	act.Revision = stored.Revision
+	act.Status = stored.Status
+	act.StatusHistory = stored.StatusHistory
+	act.CreatedAt = stored.CreatedAt
+	act.UpdatedAt = time.Now()
+
+	// The generated file no longer matches the act
+	if act.BigAct != nil {
+		act.BigAct.Changed = true
+	}
+
+	if err = s.repo.Replace(ctx, actID, act); err != nil {
+		utils.LogMethodError("ActService.UpdateAct", err)
+		return fmt.Errorf("failed to update act: %w", err)
+	}
+
+	utils.LogInfo("Successfully updated act with ID: %s", actID)
+	utils.LogMethodSuccess("ActService.UpdateAct")
+	return nil
+}
+
+// prepareAct validates an act before it is stored, derives the costs of its
+// positions and generates the missing position IDs
+func (s *actService) prepareAct(ctx context.Context, act *models.Act) error {
+	if _, err := utils.LookupLocale(act.Locale); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAct, err)
+	}
+
+	for i := range act.Positions {
+		if err := act.Positions[i].Validate(); err != nil {
+			return fmt.Errorf("%w: position %d: %v", ErrInvalidAct, i+1, err)
+		}
+		// The cost of a position may be given by its quantity and unit price
+		if err := act.Positions[i].DeriveCost(); err != nil {
+			return fmt.Errorf("%w: position %d: %v", ErrInvalidAct, i+1, err)
+		}
+	}
+
+	// Make sure the referenced template exists
+	if act.TemplateID != "" {
+		if _, err := s.templateService.ResolveTemplate(ctx, act); err != nil {
+			return err
+		}
+	}
+
+	// Make sure the referenced contract exists
+	if act.ContractID != "" {
+		if _, err := s.contractService.GetContract(ctx, act.ContractID); err != nil {
+			return err
+		}
+	}
+
+	// Generate IDs for positions if not set
+	for i := range act.Positions {
+		if act.Positions[i].ID.IsZero() {
+			act.Positions[i].ID = primitive.NewObjectID()
+		}
+	}
+	return nil
+}
+
+// GenerateAct generates an Excel file for an act. The missing key policy
+// overrides the policy of the template; when it, a form or the PDF format is
+// set, the file is always generated again.
+func (s *actService) GenerateAct(ctx context.Context, actID string, opts GenerateOptions) (string, error) {
 	utils.LogMethodInit("ActService.GenerateAct")
 	utils.LogInfo("Generating act for ID: %s", actID)
 
@@ -83,83 +232,151 @@ func (s *actService) GenerateAct(ctx context.Context, actID string) (string, err
 	}
 
 	// Check if bigActChanged is true
-	if act.BigAct.Changed {
+	if act.BigAct.Changed || opts.MissingKeyPolicy != "" || opts.Form != "" || opts.Format == FormatPDF {
 		// Process the act and generate new file
-		return s.processAndGenerateAct(ctx, act)
+		return s.processAndGenerateAct(ctx, act, opts)
 	}
 
-	// Return existing link if not changed
-	if act.BigAct.BigActLink != "" {
+	// Return existing link if not changed and the file is still there
+	if act.BigAct.BigActLink != "" && s.generatedFileExists(act.BigAct.BigActLink) {
 		utils.LogInfo("Returning existing BigActLink: %s", act.BigAct.BigActLink)
 		utils.LogMethodSuccess("ActService.GenerateAct")
 		return act.BigAct.BigActLink, nil
 	}
 
 	// If no link exists but changed is false, generate anyway
-	return s.processAndGenerateAct(ctx, act)
+	return s.processAndGenerateAct(ctx, act, opts)
 }
 
-// processAndGenerateAct processes the act and generates the Excel file
-func (s *actService) processAndGenerateAct(ctx context.Context, act *models.Act) (string, error) {
-	utils.LogInfo("Processing and generating act: %s", act.ID.Hex())
+// generatedFileExists checks that the file of a download link was not removed
+func (s *actService) generatedFileExists(downloadLink string) bool {
+	_, err := os.Stat(filepath.Join(s.config.GeneratedPath, filepath.Base(downloadLink)))
+	return err == nil
+}
 
-	// Find positions with current period costs
-	positionsWithCurrent := s.findPositionsWithCurrentPeriod(act.Positions)
+// VerifyAct finds an act by the ID printed on it, e.g. in its QR code
+func (s *actService) VerifyAct(ctx context.Context, actID string) (*models.Act, error) {
+	utils.LogMethodInit("ActService.VerifyAct")
 
-	var selectedPositions []models.Position
+	// A malformed ID cannot name an act
+	if _, err := primitive.ObjectIDFromHex(actID); err != nil {
+		err = fmt.Errorf("act not found: %w: invalid ID %q", repository.ErrActNotFound, actID)
+		utils.LogMethodError("ActService.VerifyAct", err)
+		return nil, err
+	}
 
-	if len(positionsWithCurrent) > 0 {
-		// Use positions with current period costs
-		selectedPositions = positionsWithCurrent
-		utils.LogDebug("Using %d positions with current period costs", len(positionsWithCurrent))
-	} else {
-		// Fallback to positions with accumulated cost
-		positionsWithAccumulated := s.findPositionsWithAccumulated(act.Positions)
-		selectedPositions = positionsWithAccumulated
-		utils.LogDebug("Using %d positions with accumulated costs", len(positionsWithAccumulated))
+	act, err := s.repo.FindByID(ctx, actID)
+	if err != nil {
+		utils.LogMethodError("ActService.VerifyAct", err)
+		return nil, fmt.Errorf("act not found: %w", err)
 	}
 
-	// Calculate totals
-	totalCost, totalInspection, totalConsiderations := s.calculateTotals(selectedPositions)
+	utils.LogMethodSuccess("ActService.VerifyAct")
+	return act, nil
+}
 
-	// Update BigAct with totals
-	act.BigAct.TotalCost = totalCost
-	act.BigAct.TotalCostInspection = totalInspection
-	act.BigAct.TotalCostConsiderations = totalConsiderations
+// ImportAct reads a filled workbook back into an act using the placeholders
+// of its template. With Save the act is created unless some cells could
+// not be read.
+func (s *actService) ImportAct(ctx context.Context, opts ImportOptions) (*ImportResult, error) {
+	utils.LogMethodInit("ActService.ImportAct")
 
-	// Concatenate position IDs
-	act.BigAct.PositionIDs = s.concatenatePositionIDs(selectedPositions)
+	stub := &models.Act{TemplateID: opts.TemplateID, TemplateVersion: opts.TemplateVersion}
+	template, err := s.templateService.ResolveTemplate(ctx, stub)
+	if err != nil {
+		utils.LogMethodError("ActService.ImportAct", err)
+		return nil, err
+	}
 
-	// Update act in database
-	act.UpdatedAt = time.Now()
-	err := s.repo.Update(ctx, act.ID.Hex(), act)
+	act, issues, err := s.excelService.ImportAct(template.FilePath, opts.Content, s.locale(stub, template))
+	if err != nil {
+		utils.LogMethodError("ActService.ImportAct", err)
+		return nil, err
+	}
+	act.TemplateID = opts.TemplateID
+	act.TemplateVersion = template.Version
+
+	result := &ImportResult{Act: act, Issues: issues}
+	if opts.Save && len(issues) == 0 {
+		result.ID, err = s.CreateAct(ctx, act)
+		if err != nil {
+			utils.LogMethodError("ActService.ImportAct", err)
+			return nil, err
+		}
+	}
+
+	utils.LogInfo("Imported act from template %q with %d issues", opts.TemplateID, len(issues))
+	utils.LogMethodSuccess("ActService.ImportAct")
+	return result, nil
+}
+
+// processAndGenerateAct processes the act and generates the Excel file
+func (s *actService) processAndGenerateAct(ctx context.Context, act *models.Act, genOpts GenerateOptions) (string, error) {
+	utils.LogInfo("Processing and generating act: %s", act.ID.Hex())
+
+	// Resolve the template the act renders with
+	template, err := s.resolveTemplate(ctx, act, genOpts.Form)
 	if err != nil {
 		utils.LogMethodError("ActService.processAndGenerateAct", err)
-		return "", fmt.Errorf("failed to update act: %w", err)
+		return "", err
+	}
+	opts, err := s.prepareRender(ctx, act, template, genOpts)
+	if err != nil {
+		utils.LogMethodError("ActService.processAndGenerateAct", err)
+		return "", err
 	}
 
 	// Generate filename
 	timestamp := time.Now().Unix()
 	filename := fmt.Sprintf("act_%s_%d.xlsx", act.ID.Hex(), timestamp)
+	if genOpts.Form != "" {
+		filename = fmt.Sprintf("act_%s_%s_%d.xlsx", act.ID.Hex(), genOpts.Form, timestamp)
+	}
 	outputPath := fmt.Sprintf("%s/%s", s.config.GeneratedPath, filename)
 
-	// Generate Excel file
-	err = s.excelService.GenerateAct(act, outputPath)
+	// Generate Excel file before anything is stored, so that an act whose
+	// generation fails, e.g. on missing keys, keeps its stored totals
+	err = s.excelService.GenerateAct(act, template.FilePath, outputPath, opts)
 	if err != nil {
 		utils.LogMethodError("ActService.processAndGenerateAct", err)
 		return "", fmt.Errorf("failed to generate Excel: %w", err)
 	}
 
-	// Update BigActLink
 	downloadLink := fmt.Sprintf("/api/act/download/%s", filename)
-	act.BigAct.BigActLink = downloadLink
-	act.BigAct.Changed = false // Reset changed flag
+	if genOpts.Form == "" {
+		// Update BigActLink, forms are generated on request and the link
+		// keeps pointing to the act. Locked acts are not changed anymore,
+		// so their file is served from now on.
+		act.BigAct.BigActLink = downloadLink
+		act.BigAct.Changed = false // Reset changed flag
+	}
 
-	// Update act again with the link
-	err = s.repo.Update(ctx, act.ID.Hex(), act)
-	if err != nil {
-		utils.LogError("Error updating act with BigActLink: %v", err)
-		// Don't return error here, file is already generated
+	// Update act in database with the totals and the link, signed and
+	// cancelled acts keep the totals they were locked with
+	locked := act.CurrentStatus().IsLocked()
+	if !locked || genOpts.Form == "" {
+		if !locked {
+			act.UpdatedAt = time.Now()
+		}
+		if err = s.repo.Update(ctx, act.ID.Hex(), act); err != nil {
+			utils.LogMethodError("ActService.processAndGenerateAct", err)
+			if removeErr := os.Remove(outputPath); removeErr != nil {
+				utils.LogError("Error removing generated file %s: %v", outputPath, removeErr)
+			}
+			return "", fmt.Errorf("failed to update act: %w", err)
+		}
+	}
+
+	// Render the workbook to PDF next to it
+	if genOpts.Format == FormatPDF {
+		pdfFilename := strings.TrimSuffix(filename, ".xlsx") + ".pdf"
+		pdfOpts := PDFOptions{Watermark: opts.Watermark}
+		err = s.excelService.ExportPDF(outputPath, fmt.Sprintf("%s/%s", s.config.GeneratedPath, pdfFilename), pdfOpts)
+		if err != nil {
+			utils.LogMethodError("ActService.processAndGenerateAct", err)
+			return "", fmt.Errorf("failed to export PDF: %w", err)
+		}
+		downloadLink = fmt.Sprintf("/api/act/download/%s", pdfFilename)
 	}
 
 	utils.LogInfo("Successfully generated act with download link: %s", downloadLink)
@@ -167,6 +384,170 @@ func (s *actService) processAndGenerateAct(ctx context.Context, act *models.Act)
 	return downloadLink, nil
 }
 
+// calculateActTotals sets the totals, the VAT and the position IDs of the
+// act from its selected positions
+func (s *actService) calculateActTotals(act *models.Act) {
+	selectedPositions := s.selectPositions(act.Positions)
+
+	// Calculate totals
+	totalCost, totalInspection, totalConsiderations := s.calculateTotals(selectedPositions)
+
+	// Update BigAct with totals
+	act.BigAct.TotalCost = totalCost
+	act.BigAct.TotalCostInspection = totalInspection
+	act.BigAct.TotalCostConsiderations = totalConsiderations
+
+	// Calculate VAT on top of the net total
+	act.BigAct.VATBreakdown, act.BigAct.VATTotal = s.calculateVAT(selectedPositions)
+	act.BigAct.TotalWithVAT = totalCost.Add(act.BigAct.VATTotal)
+
+	// Concatenate position IDs
+	act.BigAct.PositionIDs = s.concatenatePositionIDs(selectedPositions)
+}
+
+// prepareRender calculates the totals of the act and builds the options it
+// is rendered with by its template. Acts issued under a contract get the
+// accumulated costs of their positions and the contract data. Signed and
+// cancelled acts render the totals they were locked with.
+func (s *actService) prepareRender(ctx context.Context, act *models.Act, template *models.Template, genOpts GenerateOptions) (RenderOptions, error) {
+	contract, costs, err := s.loadContract(ctx, act)
+	if err != nil {
+		return RenderOptions{}, fmt.Errorf("failed to load contract: %w", err)
+	}
+	if !act.CurrentStatus().IsLocked() {
+		if contract != nil {
+			s.accumulatePositions(act, costs)
+		}
+		s.calculateActTotals(act)
+	}
+
+	opts := RenderOptions{
+		MissingKeyPolicy: s.missingKeyPolicy(template, genOpts.MissingKeyPolicy),
+		Locale:           s.locale(act, template),
+		LoadAsset:        s.assetService.Load,
+		Watermark:        s.watermark(act),
+	}
+
+	switch {
+	case contract != nil:
+		opts.Data = s.contractData(act, contract, costs)
+	case genOpts.Form == FormKS3:
+		// Form KS-3 shows the totals of the contract since its start and since the start of the year
+		costs, err = s.contractNumberCosts(ctx, act)
+		if err != nil {
+			return opts, fmt.Errorf("failed to calculate contract totals: %w", err)
+		}
+		opts.Data = s.contractTotals(act, costs)
+	}
+	if genOpts.Form == FormKS3 {
+		opts.PositionData = s.positionTotals(act, costs)
+	}
+	return opts, nil
+}
+
+// resolveTemplate returns the template an act is generated with: the
+// built-in template of the form when one is requested, else the template
+// of the act
+func (s *actService) resolveTemplate(ctx context.Context, act *models.Act, form FormType) (*models.Template, error) {
+	if form == "" {
+		return s.templateService.ResolveTemplate(ctx, act)
+	}
+
+	path, err := formTemplatePath(s.config.FormsDir, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare form %s: %w", form, err)
+	}
+	return &models.Template{
+		TemplateID: string(form),
+		FileName:   filepath.Base(path),
+		FilePath:   path,
+	}, nil
+}
+
+// missingKeyPolicy picks the missing key policy of a generation: the one
+// requested, else the one of the template, else the configured default
+func (s *actService) missingKeyPolicy(template *models.Template, requested MissingKeyPolicy) MissingKeyPolicy {
+	if requested != "" {
+		return requested
+	}
+	if template.MissingKeyPolicy != "" {
+		return MissingKeyPolicy(template.MissingKeyPolicy)
+	}
+
+	policy, err := ParseMissingKeyPolicy(s.config.MissingKeyPolicy)
+	if err != nil {
+		utils.LogError("Invalid MISSING_KEY_POLICY, keeping placeholders: %v", err)
+		return MissingKeyKeep
+	}
+	return policy
+}
+
+// locale picks the locale numbers and dates of an act are written in: the
+// one of the act, else the one of the template, else the configured default
+func (s *actService) locale(act *models.Act, template *models.Template) *utils.Locale {
+	for _, name := range []string{act.Locale, template.Locale} {
+		if name == "" {
+			continue
+		}
+		if locale, err := utils.LookupLocale(name); err == nil {
+			return locale
+		}
+		utils.LogError("Unknown locale %q of act %s, skipping it", name, act.ID.Hex())
+	}
+
+	locale, err := utils.LookupLocale(s.config.Locale)
+	if err != nil {
+		utils.LogError("Invalid LOCALE, using the default locale: %v", err)
+		return utils.DefaultLocale
+	}
+	return locale
+}
+
+// calculateVAT breaks the current period costs of the positions down by VAT
+// rate. Costs are net amounts; VAT is calculated once per rate on the sum of
+// its positions and rounded to kopecks half away from zero, so the breakdown
+// adds up to the totals exactly.
+func (s *actService) calculateVAT(positions []models.Position) ([]models.VATAmount, models.Money) {
+	netByRate := make(map[models.VATRate]models.Money)
+	for _, pos := range positions {
+		if pos.CurrentPeriodCost == nil {
+			continue
+		}
+		rate := pos.EffectiveVATRate(s.config.DefaultVATRate)
+		netByRate[rate] = netByRate[rate].Add(*pos.CurrentPeriodCost)
+	}
+
+	var breakdown []models.VATAmount
+	var vatTotal models.Money
+	for _, rate := range models.VATRates {
+		net, ok := netByRate[rate]
+		if !ok {
+			continue
+		}
+		vat := net.Percent(rate.Percent())
+		breakdown = append(breakdown, models.VATAmount{Rate: rate, Net: net, VAT: vat, Gross: net.Add(vat)})
+		vatTotal = vatTotal.Add(vat)
+	}
+	return breakdown, vatTotal
+}
+
+// selectPositions picks the positions the totals of an act are calculated
+// from: the positions with current period costs, else the positions with
+// accumulated costs
+func (s *actService) selectPositions(positions []models.Position) []models.Position {
+	// Find positions with current period costs
+	positionsWithCurrent := s.findPositionsWithCurrentPeriod(positions)
+	if len(positionsWithCurrent) > 0 {
+		utils.LogDebug("Using %d positions with current period costs", len(positionsWithCurrent))
+		return positionsWithCurrent
+	}
+
+	// Fallback to positions with accumulated cost
+	positionsWithAccumulated := s.findPositionsWithAccumulated(positions)
+	utils.LogDebug("Using %d positions with accumulated costs", len(positionsWithAccumulated))
+	return positionsWithAccumulated
+}
+
 // findPositionsWithCurrentPeriod finds positions with current period costs
 func (s *actService) findPositionsWithCurrentPeriod(positions []models.Position) []models.Position {
 	var result []models.Position
@@ -198,19 +579,20 @@ func (s *actService) concatenatePositionIDs(positions []models.Position) string
 	return strings.Join(ids, ", ")
 }
 
-// calculateTotals calculates total costs from positions
-func (s *actService) calculateTotals(positions []models.Position) (float64, float64, float64) {
-	var totalCost, totalInspection, totalConsiderations float64
+// calculateTotals calculates total costs from positions. Amounts are kept in
+// kopecks, so the sums are exact.
+func (s *actService) calculateTotals(positions []models.Position) (models.Money, models.Money, models.Money) {
+	var totalCost, totalInspection, totalConsiderations models.Money
 
 	for _, pos := range positions {
 		if pos.CurrentPeriodCost != nil {
-			totalCost += *pos.CurrentPeriodCost
+			totalCost = totalCost.Add(*pos.CurrentPeriodCost)
 		}
 		if pos.CurrentPeriodCostInspection != nil {
-			totalInspection += *pos.CurrentPeriodCostInspection
+			totalInspection = totalInspection.Add(*pos.CurrentPeriodCostInspection)
 		}
 		if pos.CurrentPeriodCostConsiderations != nil {
-			totalConsiderations += *pos.CurrentPeriodCostConsiderations
+			totalConsiderations = totalConsiderations.Add(*pos.CurrentPeriodCostConsiderations)
 		}
 	}
 
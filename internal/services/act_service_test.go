@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+)
+
+func TestCalculateVAT(t *testing.T) {
+	service := &actService{config: testConfig()}
+
+	positions := []models.Position{
+		testPosition(10001, ""), // default rate
+		testPosition(20002, models.VATRate20),
+		testPosition(5005, models.VATRate10),
+		testPosition(700, models.VATRateNone),
+		{AccumulatedCost: new(models.Money)}, // no current period cost
+	}
+	breakdown, vatTotal := service.calculateVAT(positions)
+
+	expected := []models.VATAmount{
+		{Rate: models.VATRate20, Net: 30003, VAT: 6001, Gross: 36004},
+		{Rate: models.VATRate10, Net: 5005, VAT: 501, Gross: 5506},
+		{Rate: models.VATRateNone, Net: 700, VAT: 0, Gross: 700},
+	}
+	if len(breakdown) != len(expected) {
+		t.Fatalf("breakdown = %+v, expected %+v", breakdown, expected)
+	}
+	for i := range expected {
+		if breakdown[i] != expected[i] {
+			t.Errorf("breakdown[%d] = %+v, expected %+v", i, breakdown[i], expected[i])
+		}
+	}
+	if vatTotal != 6502 {
+		t.Errorf("vatTotal = %v, expected 65.02", vatTotal)
+	}
+}
+
+func TestVerifyUnknownAct(t *testing.T) {
+	repo := &missingActRepository{}
+	service := &actService{repo: repo, config: testConfig()}
+
+	for _, actID := range []string{"6523f0a1b2c3d4e5f6a7b8c9", "not-an-id"} {
+		if _, err := service.VerifyAct(context.Background(), actID); !errors.Is(err, repository.ErrActNotFound) {
+			t.Errorf("VerifyAct(%q) error = %v, expected ErrActNotFound", actID, err)
+		}
+	}
+	if repo.lookups != 1 {
+		t.Errorf("acts looked up %d times, expected only the well-formed ID", repo.lookups)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+// ErrInvalidTransition is returned when an act can not change to the
+// requested status
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// TransitionRequest changes the status of an act. Actor names who made the
+// change and is recorded with it.
+type TransitionRequest struct {
+	Status  models.ActStatus `json:"status"`
+	Actor   string           `json:"actor"`
+	Comment string           `json:"comment,omitempty"`
+}
+
+// TransitionAct changes the status of an act when its current status
+// allows it and records the change. Acts are locked with the totals of
+// their positions calculated on the transition and their file is generated
+// once more, without the watermark, to be served from then on.
+func (s *actService) TransitionAct(ctx context.Context, actID string, req TransitionRequest) (*models.Act, error) {
+	utils.LogMethodInit("ActService.TransitionAct")
+
+	act, err := s.repo.FindByID(ctx, actID)
+	if err != nil {
+		utils.LogMethodError("ActService.TransitionAct", err)
+		return nil, err
+	}
+
+	from := act.CurrentStatus()
+	if err = s.applyTransition(act, req, time.Now()); err != nil {
+		utils.LogMethodError("ActService.TransitionAct", err)
+		return nil, err
+	}
+
+	if act.Status.IsLocked() && act.BigAct != nil {
+		contract, costs, err := s.loadContract(ctx, act)
+		if err != nil {
+			utils.LogMethodError("ActService.TransitionAct", err)
+			return nil, fmt.Errorf("failed to load contract: %w", err)
+		}
+		if contract != nil {
+			s.accumulatePositions(act, costs)
+		}
+		s.calculateActTotals(act)
+	}
+
+	if err = s.repo.Update(ctx, actID, act); err != nil {
+		utils.LogMethodError("ActService.TransitionAct", err)
+		return nil, fmt.Errorf("failed to update act: %w", err)
+	}
+
+	utils.LogInfo("Act %s changed from %s to %s by %s", actID, from, act.Status, req.Actor)
+
+	if act.Status.IsLocked() && act.BigAct != nil {
+		// The status is changed already, the file stays marked as changed
+		// and is generated on the next request
+		if _, err = s.processAndGenerateAct(ctx, act, GenerateOptions{}); err != nil {
+			utils.LogError("Error generating locked act %s: %v", actID, err)
+		}
+	}
+
+	utils.LogMethodSuccess("ActService.TransitionAct")
+	return act, nil
+}
+
+// applyTransition checks a transition against the current status of the
+// act and records it. The generated file shows the status, so it is
+// generated again.
+func (s *actService) applyTransition(act *models.Act, req TransitionRequest, at time.Time) error {
+	to := models.ActStatus(strings.ToLower(strings.TrimSpace(string(req.Status))))
+	actor := strings.TrimSpace(req.Actor)
+	if !to.IsValid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, req.Status)
+	}
+	if actor == "" {
+		return fmt.Errorf("%w: actor is required", ErrInvalidTransition)
+	}
+
+	from := act.CurrentStatus()
+	if !from.CanTransition(to) {
+		return fmt.Errorf("%w: %s acts can not become %s", ErrInvalidTransition, from, to)
+	}
+
+	act.Status = to
+	act.StatusHistory = append(act.StatusHistory, models.StatusChange{
+		From:    from,
+		To:      to,
+		Actor:   actor,
+		Comment: strings.TrimSpace(req.Comment),
+		At:      at,
+	})
+	act.UpdatedAt = at
+	if act.BigAct != nil {
+		act.BigAct.Changed = true
+	}
+	return nil
+}
+
+// watermark returns the watermark of acts that are not approved yet, none
+// when it is disabled
+func (s *actService) watermark(act *models.Act) string {
+	if s.config.DraftWatermark == "" || strings.EqualFold(s.config.DraftWatermark, "none") {
+		return ""
+	}
+	switch act.CurrentStatus() {
+	case models.ActStatusDraft, models.ActStatusSubmitted:
+		return s.config.DraftWatermark
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+)
+
+func TestApplyTransition(t *testing.T) {
+	service := &actService{config: &config.Config{}}
+	act := testAct(100, 200)
+	at := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		req     TransitionRequest
+		wantErr bool
+	}{
+		{req: TransitionRequest{Status: "approved", Actor: "customer"}, wantErr: true},
+		{req: TransitionRequest{Status: "submitted"}, wantErr: true},
+		{req: TransitionRequest{Status: "archived", Actor: "contractor"}, wantErr: true},
+		{req: TransitionRequest{Status: " Submitted ", Actor: "contractor"}},
+		{req: TransitionRequest{Status: "approved", Actor: "customer", Comment: "Checked on site"}},
+		{req: TransitionRequest{Status: "signed", Actor: "customer"}},
+		{req: TransitionRequest{Status: "cancelled", Actor: "customer"}, wantErr: true},
+	}
+	for _, step := range steps {
+		err := service.applyTransition(act, step.req, at)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("applyTransition(%+v) error = %v, wantErr %v", step.req, err, step.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("applyTransition(%+v) error = %v, expected ErrInvalidTransition", step.req, err)
+		}
+	}
+
+	if act.Status != models.ActStatusSigned || len(act.StatusHistory) != 3 {
+		t.Fatalf("status = %s with %d changes, expected signed with 3", act.Status, len(act.StatusHistory))
+	}
+	expected := models.StatusChange{From: models.ActStatusSubmitted, To: models.ActStatusApproved, Actor: "customer", Comment: "Checked on site", At: at}
+	if act.StatusHistory[1] != expected {
+		t.Errorf("change = %+v, expected %+v", act.StatusHistory[1], expected)
+	}
+	if !act.BigAct.Changed {
+		t.Error("generated file is expected to be generated again")
+	}
+}
+
+func TestWatermarkByStatus(t *testing.T) {
+	service := &actService{config: &config.Config{DraftWatermark: "DRAFT"}}
+	for status, expected := range map[models.ActStatus]string{
+		"":                        "DRAFT",
+		models.ActStatusSubmitted: "DRAFT",
+		models.ActStatusApproved:  "",
+		models.ActStatusSigned:    "",
+		models.ActStatusCancelled: "",
+	} {
+		if result := service.watermark(&models.Act{Status: status}); result != expected {
+			t.Errorf("watermark(%q) = %q, expected %q", status, result, expected)
+		}
+	}
+
+	service.config.DraftWatermark = "none"
+	if result := service.watermark(&models.Act{}); result != "" {
+		t.Errorf("disabled watermark = %q, expected none", result)
+	}
+}
+
+func TestPrepareRenderKeepsLockedTotals(t *testing.T) {
+	service := &actService{config: testConfig(), assetService: NewAssetService(testConfig())}
+	template := &models.Template{}
+
+	// A signed act renders the totals it was locked with, even when its
+	// positions would give others
+	act := testAct(100, 200)
+	act.Status = models.ActStatusSigned
+	act.Positions = append(act.Positions, testPosition(5000, ""))
+	if _, err := service.prepareRender(context.Background(), act, template, GenerateOptions{}); err != nil {
+		t.Fatalf("prepareRender() error = %v", err)
+	}
+	if act.BigAct.TotalCost != models.NewMoney(3, 0) || act.BigAct.TotalWithVAT != models.NewMoney(3, 60) {
+		t.Errorf("totals = %v, %v; expected the locked 3.00, 3.60", act.BigAct.TotalCost, act.BigAct.TotalWithVAT)
+	}
+
+	act.Status = models.ActStatusApproved
+	if _, err := service.prepareRender(context.Background(), act, template, GenerateOptions{}); err != nil {
+		t.Fatalf("prepareRender() error = %v", err)
+	}
+	if act.BigAct.TotalCost != models.NewMoney(53, 0) {
+		t.Errorf("total = %v, expected 53.00 of an act that is not locked", act.BigAct.TotalCost)
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidAsset is returned when an uploaded asset is not a PNG or JPEG image
+var ErrInvalidAsset = errors.New("invalid asset")
+
+// ErrAssetNotFound is returned when no asset is stored under the ID
+var ErrAssetNotFound = errors.New("asset not found")
+
+// Asset IDs are object IDs, so they can be told apart from base64 images
+var assetIDPattern = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+// AssetService defines the interface for stored images such as signatures,
+// stamps and logos used by image placeholders
+type AssetService interface {
+	Upload(fileName string, content []byte) (string, error)
+	Load(id string) ([]byte, error)
+}
+
+// assetService implements AssetService, assets are stored as files
+type assetService struct {
+	config *config.Config
+}
+
+// NewAssetService creates a new AssetService
+func NewAssetService(cfg *config.Config) AssetService {
+	return &assetService{
+		config: cfg,
+	}
+}
+
+// IsAssetID reports whether a template value refers to a stored asset
+func IsAssetID(value string) bool {
+	return assetIDPattern.MatchString(value)
+}
+
+// Upload validates and stores an image and returns its asset ID
+func (s *assetService) Upload(fileName string, content []byte) (string, error) {
+	utils.LogMethodInit("AssetService.Upload")
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		err = fmt.Errorf("%w: failed to decode image %s: %v", ErrInvalidAsset, fileName, err)
+		utils.LogMethodError("AssetService.Upload", err)
+		return "", err
+	}
+	extension, ok := pictureExtensions[format]
+	if !ok {
+		err = fmt.Errorf("%w: unsupported image format %s, expected PNG or JPEG", ErrInvalidAsset, format)
+		utils.LogMethodError("AssetService.Upload", err)
+		return "", err
+	}
+
+	id := primitive.NewObjectID().Hex()
+	filePath := filepath.Join(s.config.AssetsDir, id+extension)
+
+	utils.LogInfo("Saving asset %s to %s", fileName, filePath)
+	if err = os.MkdirAll(s.config.AssetsDir, 0755); err != nil {
+		utils.LogMethodError("AssetService.Upload", err)
+		return "", fmt.Errorf("failed to create assets directory: %w", err)
+	}
+	if err = os.WriteFile(filePath, content, 0644); err != nil {
+		utils.LogMethodError("AssetService.Upload", err)
+		return "", fmt.Errorf("failed to save asset file: %w", err)
+	}
+
+	utils.LogMethodSuccess("AssetService.Upload")
+	return id, nil
+}
+
+// Load reads the image stored under the asset ID
+func (s *assetService) Load(id string) ([]byte, error) {
+	if !IsAssetID(id) {
+		return nil, fmt.Errorf("%w: %s", ErrAssetNotFound, id)
+	}
+
+	for _, extension := range pictureExtensions {
+		content, err := os.ReadFile(filepath.Join(s.config.AssetsDir, id+extension))
+		if err == nil {
+			return content, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read asset %s: %w", id, err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAssetNotFound, id)
+}
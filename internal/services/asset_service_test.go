@@ -0,0 +1,44 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+func TestAssetUploadAndLoad(t *testing.T) {
+	cfg := testConfig()
+	cfg.AssetsDir = t.TempDir()
+	service := NewAssetService(cfg)
+
+	content, err := utils.EncodeQRPNG("signature", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := service.Upload("signature.png", content)
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if !IsAssetID(id) {
+		t.Errorf("Upload() = %q, expected an asset ID", id)
+	}
+
+	loaded, err := service.Load(id)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !bytes.Equal(loaded, content) {
+		t.Error("Load() returned other content than uploaded")
+	}
+
+	if _, err = service.Upload("notes.txt", []byte("not an image")); !errors.Is(err, ErrInvalidAsset) {
+		t.Errorf("Upload() of a text error = %v, expected ErrInvalidAsset", err)
+	}
+	for _, missing := range []string{"6523f0a1b2c3d4e5f6a7b8c9", "../secret", ""} {
+		if _, err = service.Load(missing); !errors.Is(err, ErrAssetNotFound) {
+			t.Errorf("Load(%q) error = %v, expected ErrAssetNotFound", missing, err)
+		}
+	}
+}
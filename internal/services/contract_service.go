@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+// ErrInvalidContract is returned when a contract is rejected on creation
+var ErrInvalidContract = errors.New("invalid contract")
+
+// ContractService defines the interface for contract operations
+type ContractService interface {
+	CreateContract(ctx context.Context, contract *models.Contract) (string, error)
+	GetContract(ctx context.Context, id string) (*models.Contract, error)
+	ListContracts(ctx context.Context) ([]models.Contract, error)
+}
+
+// contractService implements ContractService
+type contractService struct {
+	repo   repository.ContractRepository
+	config *config.Config
+}
+
+// NewContractService creates a new ContractService
+func NewContractService(repo repository.ContractRepository, cfg *config.Config) ContractService {
+	return &contractService{
+		repo:   repo,
+		config: cfg,
+	}
+}
+
+// CreateContract creates a new contract. Contract numbers are unique.
+func (s *contractService) CreateContract(ctx context.Context, contract *models.Contract) (string, error) {
+	utils.LogMethodInit("ContractService.CreateContract")
+
+	contract.Number = strings.TrimSpace(contract.Number)
+	if contract.Number == "" {
+		err := fmt.Errorf("%w: number is required", ErrInvalidContract)
+		utils.LogMethodError("ContractService.CreateContract", err)
+		return "", err
+	}
+	if contract.Amount < 0 {
+		err := fmt.Errorf("%w: amount %s is negative", ErrInvalidContract, contract.Amount)
+		utils.LogMethodError("ContractService.CreateContract", err)
+		return "", err
+	}
+
+	_, err := s.repo.FindByNumber(ctx, contract.Number)
+	switch {
+	case err == nil:
+		err = fmt.Errorf("%w: contract %q already exists", ErrInvalidContract, contract.Number)
+		utils.LogMethodError("ContractService.CreateContract", err)
+		return "", err
+	case !errors.Is(err, repository.ErrContractNotFound):
+		utils.LogMethodError("ContractService.CreateContract", err)
+		return "", fmt.Errorf("failed to check contract number: %w", err)
+	}
+
+	now := time.Now()
+	contract.CreatedAt = now
+	contract.UpdatedAt = now
+
+	// A contract created concurrently with the same number is rejected by
+	// the unique index
+	id, err := s.repo.Create(ctx, contract)
+	if errors.Is(err, repository.ErrContractNumberExists) {
+		err = fmt.Errorf("%w: contract %q already exists", ErrInvalidContract, contract.Number)
+		utils.LogMethodError("ContractService.CreateContract", err)
+		return "", err
+	}
+	if err != nil {
+		utils.LogMethodError("ContractService.CreateContract", err)
+		return "", fmt.Errorf("failed to create contract: %w", err)
+	}
+
+	utils.LogInfo("Successfully created contract %s with ID: %s", contract.Number, id)
+	utils.LogMethodSuccess("ContractService.CreateContract")
+	return id, nil
+}
+
+// GetContract finds a contract by ID
+func (s *contractService) GetContract(ctx context.Context, id string) (*models.Contract, error) {
+	utils.LogMethodInit("ContractService.GetContract")
+
+	contract, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		utils.LogMethodError("ContractService.GetContract", err)
+		return nil, err
+	}
+
+	utils.LogMethodSuccess("ContractService.GetContract")
+	return contract, nil
+}
+
+// ListContracts returns all contracts ordered by number
+func (s *contractService) ListContracts(ctx context.Context) ([]models.Contract, error) {
+	utils.LogMethodInit("ContractService.ListContracts")
+
+	contracts, err := s.repo.FindAll(ctx)
+	if err != nil {
+		utils.LogMethodError("ContractService.ListContracts", err)
+		return nil, fmt.Errorf("failed to list contracts: %w", err)
+	}
+
+	utils.LogMethodSuccess("ContractService.ListContracts")
+	return contracts, nil
+}
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// Pattern to match the opening tag of a repeating block, e.g. {{#positions}}
+// or {{#customer.signers}}
+var blockOpenPattern = regexp.MustCompile(`\{\{#([\w.\[\]]+)\}\}`)
+
+// Pattern to match a cell range reference inside a formula, e.g. C5:$E$7
+var formulaRangePattern = regexp.MustCompile(`(!?)(\$?[A-Z]{1,3}\$?)(\d+):(\$?[A-Z]{1,3}\$?)(\d+)`)
+
+// rowBlock describes a repeating section of template rows. The block starts
+// at the row holding the {{#name}} tag and ends at the row holding the
+// matching {{/name}} tag, both rows included.
+type rowBlock struct {
+	name      string
+	openCell  string
+	closeCell string
+	startRow  int
+	endRow    int
+}
+
+// height returns the number of template rows in the block
+func (b *rowBlock) height() int {
+	return b.endRow - b.startRow + 1
+}
+
+// expandRowBlocks clones every repeating block of the sheet once per item of
+// the list it refers to and fills the cloned rows with the item data
+func (s *excelService) expandRowBlocks(rc *renderContext, sheetName string, scope *templateScope) error {
+	fromRow := 1
+	for {
+		rows, err := rc.file.GetRows(sheetName, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return err
+		}
+
+		block, err := findRowBlock(rows, fromRow)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			return nil
+		}
+
+		utils.LogDebug("Expanding block %s in rows %d-%d of sheet %s", block.name, block.startRow, block.endRow, sheetName)
+		fromRow, err = s.expandRowBlock(rc, sheetName, rows, block, scope)
+		if err != nil {
+			return fmt.Errorf("failed to expand block %s: %w", block.name, err)
+		}
+	}
+}
+
+// findRowBlock finds the first repeating block starting at or below fromRow
+func findRowBlock(rows [][]string, fromRow int) (*rowBlock, error) {
+	for rowIdx := fromRow - 1; rowIdx < len(rows); rowIdx++ {
+		for colIdx, value := range rows[rowIdx] {
+			match := blockOpenPattern.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+
+			block := &rowBlock{
+				name:     value[match[2]:match[3]],
+				startRow: rowIdx + 1,
+			}
+			block.openCell, _ = excelize.CoordinatesToCellName(colIdx+1, rowIdx+1)
+
+			// The closing tag may follow in the same cell, the same row or any row below
+			closeTag := "{{/" + block.name + "}}"
+			for closeRowIdx := rowIdx; closeRowIdx < len(rows); closeRowIdx++ {
+				for closeColIdx, closeValue := range rows[closeRowIdx] {
+					searchFrom := 0
+					if closeRowIdx == rowIdx && closeColIdx < colIdx {
+						continue
+					}
+					if closeRowIdx == rowIdx && closeColIdx == colIdx {
+						searchFrom = match[1]
+					}
+					if strings.Contains(closeValue[searchFrom:], closeTag) {
+						block.endRow = closeRowIdx + 1
+						block.closeCell, _ = excelize.CoordinatesToCellName(closeColIdx+1, closeRowIdx+1)
+						return block, nil
+					}
+				}
+			}
+
+			return nil, fmt.Errorf("block %s opened at %s is not closed", block.name, block.openCell)
+		}
+	}
+	return nil, nil
+}
+
+// expandRowBlock expands a single block and returns the first row after the
+// generated rows, where the search for the next block continues
+func (s *excelService) expandRowBlock(rc *renderContext, sheetName string, rows [][]string, block *rowBlock, scope *templateScope) (int, error) {
+	f := rc.file
+
+	// Remove the block tags from the template rows before they are cloned
+	blockRows := make([][]string, block.height())
+	for i := range blockRows {
+		if block.startRow+i-1 < len(rows) {
+			blockRows[i] = append([]string(nil), rows[block.startRow+i-1]...)
+		}
+	}
+	if err := s.stripBlockTag(f, sheetName, blockRows, block, block.openCell, "{{#"+block.name+"}}"); err != nil {
+		return 0, err
+	}
+	if err := s.stripBlockTag(f, sheetName, blockRows, block, block.closeCell, "{{/"+block.name+"}}"); err != nil {
+		return 0, err
+	}
+
+	items := blockItems(scope, block.name)
+	height := block.height()
+
+	// An empty list removes the block rows entirely
+	if len(items) == 0 {
+		for row := block.endRow; row >= block.startRow; row-- {
+			if err := f.RemoveRow(sheetName, row); err != nil {
+				return 0, err
+			}
+		}
+		return block.startRow, shiftComments(f, sheetName, block.endRow+1, -height)
+	}
+
+	mergeCells, err := blockMergeCells(f, sheetName, block)
+	if err != nil {
+		return 0, err
+	}
+
+	extraRows := (len(items) - 1) * height
+	rangeFormulas := s.findBlockRangeFormulas(f, sheetName, rows, block, extraRows)
+	if err = shiftComments(f, sheetName, block.endRow+1, extraRows); err != nil {
+		return 0, err
+	}
+
+	// Clone the template rows once per additional item. DuplicateRowTo keeps
+	// cell styles, row heights, single-row merges and shifts relative formulas.
+	for itemIdx := 1; itemIdx < len(items); itemIdx++ {
+		offset := itemIdx * height
+		for i := 0; i < height; i++ {
+			if err = f.DuplicateRowTo(sheetName, block.startRow+i, block.startRow+offset+i); err != nil {
+				return 0, err
+			}
+		}
+		for _, mergeCell := range mergeCells {
+			if err = mergeCellWithOffset(f, sheetName, mergeCell, offset); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// Stretch ranges that covered the template rows over all generated rows,
+	// rows removed or inserted below shrink or grow them again
+	for _, cellName := range rangeFormulas {
+		formula, err := f.GetCellFormula(sheetName, cellName)
+		if err != nil {
+			return 0, err
+		}
+		if err = f.SetCellFormula(sheetName, cellName, stretchBlockRanges(formula, block, extraRows)); err != nil {
+			utils.LogError("Error updating formula at %s: %v", cellName, err)
+		}
+	}
+
+	// Rows of nested blocks are left to their own expansion, so their
+	// conditions are evaluated against the items of the nested list
+	nested, err := nestedRowBlocks(blockRows)
+	if err != nil {
+		return 0, err
+	}
+	inNested := make([]bool, height)
+	conditionRows := blockRows
+	if len(nested) > 0 {
+		conditionRows = make([][]string, height)
+		for _, inner := range nested {
+			for row := inner.startRow; row <= inner.endRow; row++ {
+				inNested[row-1] = true
+			}
+		}
+		for i := range blockRows {
+			if !inNested[i] {
+				conditionRows[i] = blockRows[i]
+			}
+		}
+	}
+	hasConditions, _ := blockRowConditions(conditionRows)
+
+	// Fill every copy with its item data, the conditions of the block rows
+	// are evaluated for every item. Copies are filled from the last one up,
+	// so that removing and inserting rows leaves the pending copies in place.
+	generatedRows := 0
+	for itemIdx := len(items) - 1; itemIdx >= 0; itemIdx-- {
+		itemScope := scope.child(blockItemData(items[itemIdx], itemIdx))
+		itemRows, removed := conditionRows, make([]bool, height)
+		if hasConditions {
+			if itemRows, removed, err = s.resolveItemConditions(conditionRows, itemScope); err != nil {
+				return 0, err
+			}
+		}
+
+		firstRow := block.startRow + itemIdx*height
+		for i, row := range itemRows {
+			if removed[i] || inNested[i] {
+				continue
+			}
+			for colIdx, value := range row {
+				cellName, _ := excelize.CoordinatesToCellName(colIdx+1, firstRow+i)
+				switch {
+				case strings.Contains(value, "{{"):
+					s.setCellText(rc, sheetName, cellName, value, itemScope)
+				case value != blockRows[i][colIdx]:
+					// Only condition tags were stripped
+					if err = setCellString(f, sheetName, cellName, value); err != nil {
+						return 0, err
+					}
+				}
+			}
+		}
+
+		// Rows of sections whose condition does not hold for the item are
+		// removed, excelize shrinks the stretched ranges and merged cells
+		itemHeight := height
+		for i := height - 1; i >= 0; i-- {
+			if !removed[i] {
+				continue
+			}
+			if err = f.RemoveRow(sheetName, firstRow+i); err != nil {
+				return 0, err
+			}
+			if err = shiftComments(f, sheetName, firstRow+i+1, -1); err != nil {
+				return 0, err
+			}
+			itemHeight--
+		}
+
+		// Nested blocks iterate over the lists of the item
+		for j := len(nested) - 1; j >= 0; j-- {
+			if removed[nested[j].startRow-1] {
+				continue
+			}
+			removedAbove := 0
+			for i := 0; i < nested[j].startRow-1; i++ {
+				if removed[i] {
+					removedAbove++
+				}
+			}
+			inner, err := nested[j].moveTo(firstRow + nested[j].startRow - 1 - removedAbove)
+			if err != nil {
+				return 0, err
+			}
+			rows, err := f.GetRows(sheetName, excelize.Options{RawCellValue: true})
+			if err != nil {
+				return 0, err
+			}
+			nextRow, err := s.expandRowBlock(rc, sheetName, rows, inner, itemScope)
+			if err != nil {
+				return 0, fmt.Errorf("failed to expand block %s: %w", inner.name, err)
+			}
+			itemHeight += nextRow - inner.endRow - 1
+		}
+		generatedRows += itemHeight
+	}
+
+	return block.startRow + generatedRows, nil
+}
+
+// nestedRowBlocks lists the blocks within the template rows of a block,
+// with their rows numbered from the first row of the block
+func nestedRowBlocks(blockRows [][]string) ([]*rowBlock, error) {
+	var blocks []*rowBlock
+	for fromRow := 1; ; {
+		block, err := findRowBlock(blockRows, fromRow)
+		if err != nil || block == nil {
+			return blocks, err
+		}
+		blocks = append(blocks, block)
+		fromRow = block.endRow + 1
+	}
+}
+
+// hasNestedBlock reports whether the template rows of a block, its own tags
+// included, open another block
+func hasNestedBlock(blockRows [][]string) bool {
+	opened := 0
+	for _, row := range blockRows {
+		for _, value := range row {
+			opened += len(blockOpenPattern.FindAllStringIndex(value, -1))
+		}
+	}
+	return opened > 1
+}
+
+// moveTo returns a copy of the block starting at the given row
+func (b *rowBlock) moveTo(startRow int) (*rowBlock, error) {
+	offset := startRow - b.startRow
+	moved := &rowBlock{name: b.name, startRow: b.startRow + offset, endRow: b.endRow + offset}
+	for _, cell := range []struct{ from, to *string }{{&b.openCell, &moved.openCell}, {&b.closeCell, &moved.closeCell}} {
+		col, row, err := excelize.CellNameToCoordinates(*cell.from)
+		if err != nil {
+			return nil, err
+		}
+		if *cell.to, err = excelize.CoordinatesToCellName(col, row+offset); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// stripBlockTag removes a block tag from its cell, both in the workbook and
+// in the captured template rows
+func (s *excelService) stripBlockTag(f *excelize.File, sheetName string, blockRows [][]string, block *rowBlock, cellName, tag string) error {
+	col, row, err := excelize.CellNameToCoordinates(cellName)
+	if err != nil {
+		return err
+	}
+
+	rowValues := blockRows[row-block.startRow]
+	value := strings.Replace(rowValues[col-1], tag, "", 1)
+	rowValues[col-1] = value
+
+	return setCellString(f, sheetName, cellName, value)
+}
+
+// blockItems returns the list a block iterates over. A single document is
+// treated as a one-item list, and a missing key produces no items.
+func blockItems(scope *templateScope, name string) []interface{} {
+	value, ok := scope.lookup(name)
+	if !ok {
+		return nil
+	}
+	if items, ok := toList(value); ok {
+		return items
+	}
+	if _, ok := toMap(value); ok {
+		return []interface{}{value}
+	}
+	return nil
+}
+
+// blockItemData builds the data of a block item scope. Document items expose
+// their fields directly, other items are available as {{.}}.
+func blockItemData(item interface{}, index int) map[string]interface{} {
+	data := make(map[string]interface{})
+	if fields, ok := toMap(item); ok {
+		for key, value := range fields {
+			data[key] = value
+		}
+	}
+	data["."] = item
+	data["@index"] = index
+	data["@number"] = index + 1
+	return data
+}
+
+// blockMergeCells returns the merged ranges that span several rows of the
+// block. Single-row merges are copied by DuplicateRowTo itself.
+func blockMergeCells(f *excelize.File, sheetName string, block *rowBlock) ([][]int, error) {
+	mergeCells, err := f.GetMergeCells(sheetName, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var result [][]int
+	for _, mergeCell := range mergeCells {
+		startCol, startRow, err := excelize.CellNameToCoordinates(mergeCell.GetStartAxis())
+		if err != nil {
+			return nil, err
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(mergeCell.GetEndAxis())
+		if err != nil {
+			return nil, err
+		}
+		if startRow != endRow && startRow >= block.startRow && endRow <= block.endRow {
+			result = append(result, []int{startCol, startRow, endCol, endRow})
+		}
+	}
+	return result, nil
+}
+
+// mergeCellWithOffset merges a copy of the given range shifted down by offset rows
+func mergeCellWithOffset(f *excelize.File, sheetName string, coordinates []int, offset int) error {
+	topLeft, err := excelize.CoordinatesToCellName(coordinates[0], coordinates[1]+offset)
+	if err != nil {
+		return err
+	}
+	bottomRight, err := excelize.CoordinatesToCellName(coordinates[2], coordinates[3]+offset)
+	if err != nil {
+		return err
+	}
+	return f.MergeCell(sheetName, topLeft, bottomRight)
+}
+
+// findBlockRangeFormulas finds formulas outside the block that aggregate a
+// range lying within the block rows, e.g. =SUM(C5:C5) under a one-row block.
+// It returns the cells these formulas end up in once the block is expanded.
+func (s *excelService) findBlockRangeFormulas(f *excelize.File, sheetName string, rows [][]string, block *rowBlock, extraRows int) []string {
+	var result []string
+	if extraRows == 0 {
+		return result
+	}
+
+	for rowIdx, row := range rows {
+		rowNum := rowIdx + 1
+		if rowNum >= block.startRow && rowNum <= block.endRow {
+			continue
+		}
+		for colIdx := range row {
+			cellName, _ := excelize.CoordinatesToCellName(colIdx+1, rowNum)
+			formula, err := f.GetCellFormula(sheetName, cellName)
+			if err != nil || formula == "" {
+				continue
+			}
+
+			if stretchBlockRanges(formula, block, extraRows) == formula {
+				continue
+			}
+
+			// Cells below the block move down together with the rows after it
+			if rowNum > block.endRow {
+				cellName, _ = excelize.CoordinatesToCellName(colIdx+1, rowNum+extraRows)
+			}
+			result = append(result, cellName)
+		}
+	}
+	return result
+}
+
+// stretchBlockRanges extends every same-sheet range of the formula that lies
+// within the block rows so that it ends at the last generated row
+func stretchBlockRanges(formula string, block *rowBlock, extraRows int) string {
+	return formulaRangePattern.ReplaceAllStringFunc(formula, func(ref string) string {
+		parts := formulaRangePattern.FindStringSubmatch(ref)
+		if parts[1] == "!" {
+			return ref
+		}
+		startRow, _ := strconv.Atoi(parts[3])
+		endRow, _ := strconv.Atoi(parts[5])
+		if startRow < block.startRow || endRow > block.endRow || startRow > endRow {
+			return ref
+		}
+		return fmt.Sprintf("%s%d:%s%d", parts[2], startRow, parts[4], endRow+extraRows)
+	})
+}
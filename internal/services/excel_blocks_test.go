@@ -0,0 +1,259 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// blockTestData holds lists for the blocks of the tests besides the
+// positions of the act
+func blockTestData() map[string]interface{} {
+	return map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{"title": "Earthworks", "items": []interface{}{"Digging", "Backfill"}},
+			map[string]interface{}{"title": "Design", "items": []interface{}{}},
+			map[string]interface{}{"title": "Roofing", "items": []interface{}{"Sheeting"}},
+		},
+		"empty":  []interface{}{},
+		"signer": map[string]interface{}{"name": "Ivanov"},
+	}
+}
+
+func TestExpandRowBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		cells    map[string]interface{}
+		expected [][]string
+	}{
+		{
+			name:     "one row block",
+			cells:    map[string]interface{}{"A1": "{{#positions}}{{@number}}", "B1": "{{currentPeriodCost}}{{/positions}}", "A2": "Total"},
+			expected: [][]string{{"1", "1.00"}, {"2", "2.00"}, {"3", "3.00"}, {"Total"}},
+		},
+		{
+			name:     "block of several rows",
+			cells:    map[string]interface{}{"A1": "{{#positions}}No {{@number}}", "A2": "{{currentPeriodCost}}{{/positions}}", "A3": "Total"},
+			expected: [][]string{{"No 1"}, {"1.00"}, {"No 2"}, {"2.00"}, {"No 3"}, {"3.00"}, {"Total"}},
+		},
+		{
+			name:     "empty list removes the block rows",
+			cells:    map[string]interface{}{"A1": "Head", "A2": "{{#empty}}{{.}}", "A3": "{{/empty}}", "A4": "Total"},
+			expected: [][]string{{"Head"}, {"Total"}},
+		},
+		{
+			name:     "missing list removes the block rows",
+			cells:    map[string]interface{}{"A1": "{{#missing}}{{.}}{{/missing}}", "A2": "Total"},
+			expected: [][]string{{"Total"}},
+		},
+		{
+			name:     "document is a one item list",
+			cells:    map[string]interface{}{"A1": "{{#signer}}{{name}}{{/signer}}", "A2": "Total"},
+			expected: [][]string{{"Ivanov"}, {"Total"}},
+		},
+		{
+			name: "nested blocks iterate over the lists of every item",
+			cells: map[string]interface{}{
+				"A1": "{{#groups}}{{@number}}. {{title}}",
+				"A2": "{{#items}}{{@number}}) {{.}}",
+				"B2": "{{title}}{{/items}}",
+				"A3": "End of {{title}}{{/groups}}",
+				"A4": "Total",
+			},
+			expected: [][]string{
+				{"1. Earthworks"}, {"1) Digging", "Earthworks"}, {"2) Backfill", "Earthworks"}, {"End of Earthworks"},
+				{"2. Design"}, {"End of Design"},
+				{"3. Roofing"}, {"1) Sheeting", "Roofing"}, {"End of Roofing"},
+				{"Total"},
+			},
+		},
+		{
+			name: "nested block sharing the rows of the outer tags",
+			cells: map[string]interface{}{
+				"A1": "{{#groups}}{{#items}}{{.}}",
+				"B1": "{{title}}{{/items}}{{/groups}}",
+				"A2": "Total",
+			},
+			expected: [][]string{{"Digging", "Earthworks"}, {"Backfill", "Earthworks"}, {"Sheeting", "Roofing"}, {"Total"}},
+		},
+		{
+			name: "blocks one after another",
+			cells: map[string]interface{}{
+				"A1": "{{#empty}}{{.}}{{/empty}}",
+				"A2": "{{#groups}}{{title}}{{/groups}}",
+				"A3": "{{#signer}}{{name}}{{/signer}}",
+			},
+			expected: [][]string{{"Earthworks"}, {"Design"}, {"Roofing"}, {"Ivanov"}},
+		},
+	}
+
+	service := NewExcelService(testConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := renderTestTemplate(t, service, tt.cells, testAct(testCosts(3)...), RenderOptions{Data: blockTestData()})
+			if rows := sheetRows(t, f); !reflect.DeepEqual(rows, tt.expected) {
+				t.Errorf("rows = %q, expected %q", rows, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandRowBlockShiftsMergedCells(t *testing.T) {
+	tests := []struct {
+		name     string
+		costs    []int64
+		expected []string
+	}{
+		{name: "three items", costs: testCosts(3), expected: []string{"A1:A2", "A3:A4", "A5:A6", "B1:C1", "B3:C3", "B5:C5", "A8:C8"}},
+		{name: "one item", costs: testCosts(1), expected: []string{"A1:A2", "B1:C1", "A4:C4"}},
+		{name: "no items", expected: []string{"A2:C2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			templatePath := dir + "/template.xlsx"
+			writeTestWorkbook(t, templatePath, map[string]interface{}{
+				"A1": "{{#positions}}{{@number}}",
+				"B1": "{{currentPeriodCost}}",
+				"B2": "{{/positions}}",
+				"A3": "Total",
+				"A4": "Signed",
+			}, func(f *excelize.File, sheet string) error {
+				for _, cells := range [][2]string{{"A1", "A2"}, {"B1", "C1"}, {"A4", "C4"}} {
+					if err := f.MergeCell(sheet, cells[0], cells[1]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+			outputPath := dir + "/act.xlsx"
+			if err := NewExcelService(testConfig()).GenerateAct(testAct(tt.costs...), templatePath, outputPath, RenderOptions{}); err != nil {
+				t.Fatalf("GenerateAct() error = %v", err)
+			}
+			f := openTestWorkbook(t, outputPath)
+
+			mergeCells, err := f.GetMergeCells(f.GetSheetName(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			merged := make(map[string]bool)
+			for _, mergeCell := range mergeCells {
+				merged[mergeCell.GetStartAxis()+":"+mergeCell.GetEndAxis()] = true
+			}
+			if len(merged) != len(tt.expected) {
+				t.Errorf("merged cells = %v, expected %v", merged, tt.expected)
+			}
+			for _, cells := range tt.expected {
+				if !merged[cells] {
+					t.Errorf("merged cells = %v, expected %s", merged, cells)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandRowBlockShiftsFormulas(t *testing.T) {
+	tests := []struct {
+		name     string
+		costs    []int64
+		expected map[string]string
+	}{
+		{
+			name:  "three items",
+			costs: testCosts(3),
+			expected: map[string]string{
+				"C2": "B2*2",
+				"C3": "B3*2",
+				"C4": "B4*2",
+				"B5": "SUM(B2:B4)",
+				"C5": "SUM(C2:C4)+B$1",
+				"B6": "B5*$B$1",
+			},
+		},
+		{
+			name:     "one item",
+			costs:    testCosts(1),
+			expected: map[string]string{"C2": "B2*2", "B3": "SUM(B2:B2)", "C3": "SUM(C2:C2)+B$1", "B4": "B3*$B$1"},
+		},
+	}
+
+	service := NewExcelService(testConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := renderTestTemplate(t, service, map[string]interface{}{
+				"B1": 2,
+				"A2": "{{#positions}}{{@number}}",
+				"B2": "{{currentPeriodCost}}",
+				"C2": "=B2*2",
+				"D2": "{{/positions}}",
+				"B3": "=SUM(B2:B2)",
+				"C3": "=SUM(C2:C2)+B$1",
+				"B4": "=B3*$B$1",
+			}, testAct(tt.costs...), RenderOptions{})
+
+			sheet := f.GetSheetName(0)
+			for cell, expected := range tt.expected {
+				if formula, _ := f.GetCellFormula(sheet, cell); formula != expected {
+					t.Errorf("formula at %s = %q, expected %q", cell, formula, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandRowBlockStretchesRangesOverNestedBlocks(t *testing.T) {
+	service := NewExcelService(testConfig())
+	f := renderTestTemplate(t, service, map[string]interface{}{
+		"A1": "{{#groups}}{{title}}",
+		"A2": "{{#items}}{{.}}",
+		"B2": "1{{/items}}{{/groups}}",
+		"B3": "=SUM(B1:B2)",
+	}, testAct(), RenderOptions{Data: blockTestData()})
+
+	if formula, _ := f.GetCellFormula(f.GetSheetName(0), "B7"); formula != "SUM(B1:B6)" {
+		t.Errorf("formula = %q, expected SUM(B1:B6)", formula)
+	}
+}
+
+func TestBlockIndexesAreNumbers(t *testing.T) {
+	service := NewExcelService(testConfig())
+	f := renderTestTemplate(t, service, map[string]interface{}{
+		"A1": "{{#positions}}{{@number}}",
+		"B1": "{{@index}}{{/positions}}",
+	}, testAct(testCosts(2)...), RenderOptions{})
+	sheet := f.GetSheetName(0)
+
+	for cell, expected := range map[string]string{"A1": "1", "B1": "0", "A2": "2", "B2": "1"} {
+		value, err := f.GetCellValue(sheet, cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cellType, err := f.GetCellType(sheet, cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected || cellType != excelize.CellTypeUnset {
+			t.Errorf("%s = %q of type %v, expected the number %s", cell, value, cellType, expected)
+		}
+	}
+}
+
+func TestHasNestedBlock(t *testing.T) {
+	tests := []struct {
+		rows     [][]string
+		expected bool
+	}{
+		{rows: [][]string{{"{{#positions}}{{name}}{{/positions}}"}}},
+		{rows: [][]string{{"{{#groups}}{{title}}"}, {"{{#items}}{{.}}{{/items}}{{/groups}}"}}, expected: true},
+		{rows: [][]string{{"{{#groups}}{{#items}}{{.}}{{/items}}{{/groups}}"}}, expected: true},
+	}
+
+	for _, tt := range tests {
+		if nested := hasNestedBlock(tt.rows); nested != tt.expected {
+			t.Errorf("hasNestedBlock(%q) = %v, expected %v", tt.rows, nested, tt.expected)
+		}
+	}
+}
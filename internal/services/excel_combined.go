@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// summarySheetName is the name of the sheet listing the acts of a combined workbook
+const summarySheetName = "Сводка"
+
+// Columns of the summary sheet
+var summaryHeaders = []string{"№", "Лист", "Акт", "Дата", "Стоимость", "НДС", "Всего с НДС"}
+
+// CombinedAct is an act rendered into a combined workbook with its own
+// options, e.g. its locale
+type CombinedAct struct {
+	Act     *models.Act
+	Options RenderOptions
+}
+
+// GenerateCombined renders several acts into one workbook: the first sheet
+// of the template is cloned once per act and filled with its data, and a
+// summary sheet in front lists the totals of every act with a link to its
+// sheet. The other template sheets, shapes and defined names are not part
+// of the combined workbook. It returns the names of the act sheets, in the
+// order of the acts.
+func (s *excelService) GenerateCombined(acts []CombinedAct, templatePath, outputPath string) ([]string, error) {
+	utils.LogMethodInit("ExcelService.GenerateCombined")
+	utils.LogExcelInit(outputPath)
+
+	utils.LogInfo("Opening Excel template: %s", templatePath)
+	template, err := s.templates.load(templatePath)
+	if err != nil {
+		utils.LogMethodError("ExcelService.GenerateCombined", err)
+		return nil, fmt.Errorf("failed to open template: %w", err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(template.content))
+	if err != nil {
+		utils.LogMethodError("ExcelService.GenerateCombined", err)
+		return nil, fmt.Errorf("failed to open template: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			utils.LogError("Error closing Excel file: %v", closeErr)
+		}
+	}()
+
+	templateSheets := f.GetSheetList()
+	templateSheet := templateSheets[0]
+	templateIndex, err := f.GetSheetIndex(templateSheet)
+	if err != nil {
+		utils.LogMethodError("ExcelService.GenerateCombined", err)
+		return nil, err
+	}
+	pageLayout, err := f.GetPageLayout(templateSheet)
+	if err != nil {
+		utils.LogMethodError("ExcelService.GenerateCombined", err)
+		return nil, err
+	}
+
+	// The clones share the compiled model of the template sheet
+	view := &compiledTemplate{content: template.content, sheets: make(map[string]*compiledSheet)}
+
+	var missing []TemplatePlaceholder
+	sheetNames := make([]string, len(acts))
+	for idx, item := range acts {
+		utils.LogDebug("Rendering act %s into combined workbook", item.Act.ID.Hex())
+		sheetName := fmt.Sprintf("act %d", idx+1)
+		sheetIndex, err := f.NewSheet(sheetName)
+		if err == nil {
+			err = f.CopySheet(templateIndex, sheetIndex)
+		}
+		if err == nil {
+			// Copying a sheet drops its page setup
+			err = f.SetPageLayout(sheetName, &pageLayout)
+		}
+		if err != nil {
+			utils.LogMethodError("ExcelService.GenerateCombined", err)
+			return nil, fmt.Errorf("failed to clone sheet %s: %w", templateSheet, err)
+		}
+		view.sheets[sheetName] = template.sheets[templateSheet]
+
+		templateData := s.renderData(item.Act, item.Options)
+		rc := newRenderContext(f, view, item.Options)
+		scope := newTemplateScope(templateData, item.Options.Locale)
+		if err = s.processSheet(rc, sheetName, scope); err != nil {
+			utils.LogMethodError("ExcelService.GenerateCombined", err)
+			return nil, fmt.Errorf("failed to render act %s: %w", item.Act.ID.Hex(), err)
+		}
+		// Each act keeps its own missing key policy
+		if item.Options.MissingKeyPolicy == MissingKeyError {
+			missing = append(missing, sortPlaceholders(rc.missing)...)
+		}
+
+		sheetNames[idx] = s.combinedSheetName(f, rc, templateSheet, idx, scope)
+		if err = f.SetSheetName(sheetName, sheetNames[idx]); err != nil {
+			utils.LogMethodError("ExcelService.GenerateCombined", err)
+			return nil, fmt.Errorf("failed to rename sheet %s: %w", sheetName, err)
+		}
+	}
+
+	// Nothing is saved when placeholders of acts with the error policy are missing
+	if len(missing) > 0 {
+		err = &MissingPlaceholdersError{Placeholders: missing}
+		utils.LogMethodError("ExcelService.GenerateCombined", err)
+		return nil, err
+	}
+
+	if err = s.writeSummarySheet(f, acts, sheetNames); err != nil {
+		utils.LogMethodError("ExcelService.GenerateCombined", err)
+		return nil, fmt.Errorf("failed to write summary sheet: %w", err)
+	}
+	for _, sheetName := range templateSheets {
+		if err = f.DeleteSheet(sheetName); err != nil {
+			utils.LogMethodError("ExcelService.GenerateCombined", err)
+			return nil, fmt.Errorf("failed to remove template sheet %s: %w", sheetName, err)
+		}
+	}
+	f.SetActiveSheet(0)
+
+	utils.LogInfo("Saving Excel file to: %s", outputPath)
+	if err = f.SaveAs(outputPath); err != nil {
+		utils.LogMethodError("ExcelService.GenerateCombined", err)
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	utils.LogExcelComplete(outputPath)
+	utils.LogMethodSuccess("ExcelService.GenerateCombined")
+	return sheetNames, nil
+}
+
+// combinedSheetName names the sheet of an act: the template sheet name with
+// its placeholders filled, or the template sheet name with the number of
+// the act. Names taken by other sheets get a number in parentheses.
+func (s *excelService) combinedSheetName(f *excelize.File, rc *renderContext, templateSheet string, idx int, scope *templateScope) string {
+	name := templateSheet + " " + strconv.Itoa(idx+1)
+	if strings.Contains(templateSheet, "{{") {
+		loc := TemplatePlaceholder{Sheet: templateSheet, Part: partSheetName}
+		name = s.replacePlaceholders(rc, loc, templateSheet, scope)
+	}
+	name = sanitizeSheetName(name)
+	if name == "" {
+		name = strconv.Itoa(idx + 1)
+	}
+
+	candidate := name
+	for n := 2; ; n++ {
+		if index, _ := f.GetSheetIndex(candidate); index == -1 && candidate != summarySheetName {
+			return candidate
+		}
+		suffix := " (" + strconv.Itoa(n) + ")"
+		runes := []rune(name)
+		if len(runes)+len([]rune(suffix)) > maxSheetNameLength {
+			runes = runes[:maxSheetNameLength-len([]rune(suffix))]
+		}
+		candidate = string(runes) + suffix
+	}
+}
+
+// writeSummarySheet adds the summary sheet in front of the act sheets: one
+// row per act with its totals and a link to its sheet, and a total row
+func (s *excelService) writeSummarySheet(f *excelize.File, acts []CombinedAct, sheetNames []string) error {
+	if _, err := f.NewSheet(summarySheetName); err != nil {
+		return err
+	}
+	if err := f.MoveSheet(summarySheetName, f.GetSheetName(0)); err != nil {
+		return err
+	}
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true},
+		Border:    summaryBorders(),
+	})
+	if err != nil {
+		return err
+	}
+	textStyle, err := f.NewStyle(&excelize.Style{Border: summaryBorders()})
+	if err != nil {
+		return err
+	}
+	moneyStyle, err := f.NewStyle(&excelize.Style{Border: summaryBorders(), NumFmt: defaultNumberFormat})
+	if err != nil {
+		return err
+	}
+	totalStyle, err := f.NewStyle(&excelize.Style{Border: summaryBorders(), NumFmt: defaultNumberFormat, Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	// Dates are written in the locale of each act
+	dateStyles := make(map[string]int)
+	dateStyle := func(locale *utils.Locale) (int, error) {
+		if locale == nil {
+			locale = utils.DefaultLocale
+		}
+		dateFormat := locale.ExcelDateFormat()
+		if id, ok := dateStyles[dateFormat]; ok {
+			return id, nil
+		}
+		id, err := f.NewStyle(&excelize.Style{Border: summaryBorders(), CustomNumFmt: &dateFormat})
+		dateStyles[dateFormat] = id
+		return id, err
+	}
+
+	if err = f.SetSheetRow(summarySheetName, "A1", &summaryHeaders); err != nil {
+		return err
+	}
+	if err = f.SetCellStyle(summarySheetName, "A1", "G1", headerStyle); err != nil {
+		return err
+	}
+
+	for idx, item := range acts {
+		row := idx + 2
+		cell := func(col string) string { return col + strconv.Itoa(row) }
+
+		var totalCost, vatTotal, totalWithVAT models.Money
+		if item.Act.BigAct != nil {
+			totalCost, vatTotal, totalWithVAT = item.Act.BigAct.TotalCost, item.Act.BigAct.VATTotal, item.Act.BigAct.TotalWithVAT
+		}
+		values := []interface{}{idx + 1, sheetNames[idx], item.Act.ID.Hex(), item.Act.CreatedAt,
+			totalCost.Float64(), vatTotal.Float64(), totalWithVAT.Float64()}
+		if err = f.SetSheetRow(summarySheetName, cell("A"), &values); err != nil {
+			return err
+		}
+
+		rowDateStyle, err := dateStyle(item.Options.Locale)
+		if err != nil {
+			return err
+		}
+		link := "'" + strings.ReplaceAll(sheetNames[idx], "'", "''") + "'!A1"
+		if err = f.SetCellHyperLink(summarySheetName, cell("B"), link, "Location"); err != nil {
+			return err
+		}
+		for _, style := range []struct {
+			from, to string
+			id       int
+		}{{"A", "C", textStyle}, {"D", "D", rowDateStyle}, {"E", "G", moneyStyle}} {
+			if err = f.SetCellStyle(summarySheetName, cell(style.from), cell(style.to), style.id); err != nil {
+				return err
+			}
+		}
+	}
+
+	totalRow := strconv.Itoa(len(acts) + 2)
+	if err = f.SetCellValue(summarySheetName, "A"+totalRow, "Итого"); err != nil {
+		return err
+	}
+	if err = f.MergeCell(summarySheetName, "A"+totalRow, "D"+totalRow); err != nil {
+		return err
+	}
+	for _, col := range []string{"E", "F", "G"} {
+		formula := "SUM(" + col + "2:" + col + strconv.Itoa(len(acts)+1) + ")"
+		if len(acts) == 0 {
+			formula = "0"
+		}
+		if err = f.SetCellFormula(summarySheetName, col+totalRow, formula); err != nil {
+			return err
+		}
+	}
+	if err = f.SetCellStyle(summarySheetName, "A"+totalRow, "G"+totalRow, totalStyle); err != nil {
+		return err
+	}
+
+	for col, width := range map[string]float64{"A": 5, "B": 24, "C": 27, "D": 12, "E": 16, "F": 14, "G": 16} {
+		if err = f.SetColWidth(summarySheetName, col, col, width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summaryBorders returns thin borders on every side of a cell
+func summaryBorders() []excelize.Border {
+	return []excelize.Border{
+		{Type: "left", Color: "000000", Style: 1},
+		{Type: "top", Color: "000000", Style: 1},
+		{Type: "right", Color: "000000", Style: 1},
+		{Type: "bottom", Color: "000000", Style: 1},
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGenerateCombined(t *testing.T) {
+	first, second := testAct(100, 200), testAct(100, 200)
+	first.ID, second.ID = primitive.NewObjectID(), primitive.NewObjectID()
+	second.BigAct.TextFields["customer"] = "Second customer"
+	second.BigAct.TotalCost = models.NewMoney(10, 0)
+	second.BigAct.VATTotal = models.NewMoney(2, 0)
+	second.BigAct.TotalWithVAT = models.NewMoney(12, 0)
+
+	outputPath := filepath.Join(t.TempDir(), "combined.xlsx")
+	service := NewExcelService(testConfig())
+	actSheets, err := service.GenerateCombined([]CombinedAct{{Act: first}, {Act: second}}, "../../templates/act_template.xlsx", outputPath)
+	if err != nil {
+		t.Fatalf("GenerateCombined() error = %v", err)
+	}
+	if expected := []string{"Акт 1", "Акт 2"}; !reflect.DeepEqual(actSheets, expected) {
+		t.Errorf("act sheets = %v, expected %v", actSheets, expected)
+	}
+
+	f := openTestWorkbook(t, outputPath)
+
+	if sheets, expected := f.GetSheetList(), []string{summarySheetName, "Акт 1", "Акт 2"}; !reflect.DeepEqual(sheets, expected) {
+		t.Fatalf("sheets = %v, expected %v", sheets, expected)
+	}
+	if cells, _ := f.SearchSheet("Акт 2", "Second customer"); len(cells) == 0 {
+		t.Error("second act sheet does not hold its customer")
+	}
+	if cells, _ := f.SearchSheet("Акт 1", "Second customer"); len(cells) != 0 {
+		t.Error("first act sheet holds the customer of the second act")
+	}
+
+	rows, err := f.GetRows(summarySheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("summary rows = %d, expected 4", len(rows))
+	}
+	if rows[2][1] != "Акт 2" || rows[2][2] != second.ID.Hex() || rows[2][4] != "10" || rows[2][6] != "12" {
+		t.Errorf("summary row of second act = %v", rows[2])
+	}
+	if link, target, _ := f.GetCellHyperLink(summarySheetName, "B3"); !link || target != "'Акт 2'!A1" {
+		t.Errorf("link of second act = %v %q, expected 'Акт 2'!A1", link, target)
+	}
+	for cell, expected := range map[string]string{"E4": "13", "F4": "2.6", "G4": "15.6"} {
+		if value, err := f.CalcCellValue(summarySheetName, cell, excelize.Options{RawCellValue: true}); err != nil || value != expected {
+			t.Errorf("total %s = %q, %v; expected %s", cell, value, err, expected)
+		}
+	}
+}
+
+func TestGenerateCombinedMissingKeyPolicies(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.xlsx")
+	writeTestWorkbook(t, templatePath, map[string]interface{}{"A1": "{{customer}}", "A2": "{{note}}"}, nil)
+
+	noted, plain := testAct(100), testAct(100)
+	noted.ID, plain.ID = primitive.NewObjectID(), primitive.NewObjectID()
+	noted.BigAct.TextFields["note"] = "Note"
+
+	tests := []struct {
+		name    string
+		acts    []CombinedAct
+		missing int
+	}{
+		{
+			name: "error policy of a complete act",
+			acts: []CombinedAct{{Act: noted, Options: RenderOptions{MissingKeyPolicy: MissingKeyError}}, {Act: plain, Options: RenderOptions{MissingKeyPolicy: MissingKeyKeep}}},
+		},
+		{
+			name:    "error policy of an incomplete act",
+			acts:    []CombinedAct{{Act: noted, Options: RenderOptions{MissingKeyPolicy: MissingKeyKeep}}, {Act: plain, Options: RenderOptions{MissingKeyPolicy: MissingKeyError}}},
+			missing: 1,
+		},
+	}
+
+	service := NewExcelService(testConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.GenerateCombined(tt.acts, templatePath, filepath.Join(t.TempDir(), "combined.xlsx"))
+			var missingErr *MissingPlaceholdersError
+			switch {
+			case tt.missing == 0 && err != nil:
+				t.Errorf("GenerateCombined() error = %v", err)
+			case tt.missing > 0 && !errors.As(err, &missingErr):
+				t.Errorf("GenerateCombined() error = %v, expected missing placeholders", err)
+			case tt.missing > 0 && len(missingErr.Placeholders) != tt.missing:
+				t.Errorf("placeholders = %+v, expected %d", missingErr.Placeholders, tt.missing)
+			}
+		})
+	}
+}
+
+func TestCombinedSheetName(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	f.NewSheet("Act 42")
+	f.NewSheet("Act 42 (2)")
+
+	service := &excelService{config: &config.Config{}}
+	act := testAct(100, 200)
+	scope := newTemplateScope(service.buildTemplateData(act), nil)
+	rc := newRenderContext(f, &compiledTemplate{}, RenderOptions{})
+
+	if name := service.combinedSheetName(f, rc, "Act {{contractNumber}}", 0, scope); name != "Act 42 (3)" {
+		t.Errorf("name = %q, expected Act 42 (3)", name)
+	}
+	if name := service.combinedSheetName(f, rc, "Sheet1", 1, scope); name != "Sheet1 2" {
+		t.Errorf("name = %q, expected Sheet1 2", name)
+	}
+}
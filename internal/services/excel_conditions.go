@@ -0,0 +1,398 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// Pattern to match the tags of a conditional section, e.g. {{#if key}} and {{/if}}
+var conditionTagPattern = regexp.MustCompile(`\{\{(?:#(if|unless|ifcol|unlesscol) +([^}]+?)|/(if|unless|ifcol|unlesscol))\s*\}\}`)
+
+// Pattern to match a sheet condition, e.g. {{#ifsheet key}}
+var sheetConditionPattern = regexp.MustCompile(`\{\{#(ifsheet|unlesssheet) +([^}]+?)\s*\}\}`)
+
+// conditionTag is an opening or closing conditional tag found in a cell
+type conditionTag struct {
+	kind    string
+	key     string
+	closing bool
+	text    string
+	row     int
+	col     int
+	start   int
+	end     int
+}
+
+// cellName returns the name of the cell holding the tag
+func (t *conditionTag) cellName() string {
+	cellName, _ := excelize.CoordinatesToCellName(t.col, t.row)
+	return cellName
+}
+
+// applySheetConditions evaluates the sheet conditions and hides the sheet
+// when one of them does not hold
+func (s *excelService) applySheetConditions(rc *renderContext, sheetName string, scope *templateScope) error {
+	rows, err := rc.file.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+
+	visible := true
+	for rowIdx, row := range rows {
+		for colIdx, value := range row {
+			matches := sheetConditionPattern.FindAllStringSubmatch(value, -1)
+			if matches == nil {
+				continue
+			}
+
+			for _, match := range matches {
+				if !s.evaluateCondition(match[1] == "unlesssheet", match[2], scope) {
+					visible = false
+				}
+			}
+
+			cellName, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+1)
+			err = setCellString(rc.file, sheetName, cellName, sheetConditionPattern.ReplaceAllString(value, ""))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !visible {
+		utils.LogDebug("Hiding sheet %s, sheet condition does not hold", sheetName)
+		return hideSheet(rc.file, sheetName)
+	}
+	return nil
+}
+
+// applyConditions evaluates the conditional sections of the sheet. Rows of
+// an {{#if}} section and columns of an {{#ifcol}} section are removed when
+// the condition does not hold, and the tags are stripped when it does.
+// Both tags in a single cell make an inline section that only affects the
+// text between them. Row sections within repeating blocks are left for the
+// expansion, which evaluates them for every item.
+func (s *excelService) applyConditions(rc *renderContext, sheetName string, scope *templateScope) error {
+	for {
+		rows, err := rc.file.GetRows(sheetName, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return err
+		}
+
+		tags, err := sheetConditionTags(rows)
+		if err != nil {
+			return err
+		}
+		open, closing, err := findConditionSection(tags)
+		if err != nil {
+			return err
+		}
+		if open == nil {
+			return nil
+		}
+
+		holds := s.evaluateCondition(strings.HasPrefix(open.kind, "unless"), open.key, scope)
+		utils.LogDebug("Condition %s %s at %s evaluated to %t", open.kind, open.key, open.cellName(), holds)
+
+		if err = s.applyConditionSection(rc, sheetName, open, closing, holds); err != nil {
+			return fmt.Errorf("failed to apply condition at %s: %w", open.cellName(), err)
+		}
+	}
+}
+
+// applyConditionSection removes or keeps a single conditional section
+func (s *excelService) applyConditionSection(rc *renderContext, sheetName string, open, closing *conditionTag, holds bool) error {
+	f := rc.file
+
+	// Inline section, only the text between the tags is affected
+	if open.isInline(closing) {
+		value, err := f.GetCellValue(sheetName, open.cellName(), excelize.Options{RawCellValue: true})
+		if err != nil {
+			return err
+		}
+		return setCellString(f, sheetName, open.cellName(), applyInlineSection(value, open, closing, holds))
+	}
+
+	if holds {
+		if err := stripCellTag(f, sheetName, open.cellName(), open.text); err != nil {
+			return err
+		}
+		return stripCellTag(f, sheetName, closing.cellName(), closing.text)
+	}
+
+	if strings.HasSuffix(open.kind, "col") {
+		for col := closing.col; col >= open.col; col-- {
+			colName, err := excelize.ColumnNumberToName(col)
+			if err != nil {
+				return err
+			}
+			if err = f.RemoveCol(sheetName, colName); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for row := closing.row; row >= open.row; row-- {
+		if err := f.RemoveRow(sheetName, row); err != nil {
+			return err
+		}
+	}
+	return shiftComments(f, sheetName, closing.row+1, open.row-closing.row-1)
+}
+
+// isInline reports whether the section opened by the tag closes in the same cell
+func (t *conditionTag) isInline(closing *conditionTag) bool {
+	return t.row == closing.row && t.col == closing.col
+}
+
+// isRowCondition reports whether tags of the kind remove rows or text.
+// Column conditions always apply to the whole sheet.
+func isRowCondition(kind string) bool {
+	return kind == "if" || kind == "unless"
+}
+
+// applyInlineSection keeps the text between the tags of an inline section
+// when its condition holds and drops it when it does not
+func applyInlineSection(value string, open, closing *conditionTag, holds bool) string {
+	if holds {
+		return value[:open.start] + value[open.end:closing.start] + value[closing.end:]
+	}
+	return value[:open.start] + value[closing.end:]
+}
+
+// sheetConditionTags lists the conditional tags the sheet level pass
+// evaluates, leaving out the row conditions within repeating blocks
+func sheetConditionTags(rows [][]string) ([]conditionTag, error) {
+	var blocks []*rowBlock
+	for fromRow := 1; ; {
+		block, err := findRowBlock(rows, fromRow)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+		fromRow = block.endRow + 1
+	}
+
+	var tags []conditionTag
+	for _, tag := range scanConditionTags(rows) {
+		inBlock := false
+		for _, block := range blocks {
+			if tag.row >= block.startRow && tag.row <= block.endRow {
+				inBlock = true
+				break
+			}
+		}
+		if !inBlock || !isRowCondition(tag.kind) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// blockRowConditions reports whether the template rows of a block hold row
+// conditions, and whether some of them are sections of whole rows rather
+// than inline sections
+func blockRowConditions(blockRows [][]string) (hasConditions, hasRowSections bool) {
+	var tags []conditionTag
+	for _, tag := range scanConditionTags(blockRows) {
+		if isRowCondition(tag.kind) {
+			tags = append(tags, tag)
+		}
+	}
+	for i := range tags {
+		if tags[i].closing {
+			continue
+		}
+		if open, closing, err := findConditionSection(tags[i:]); err != nil || !open.isInline(closing) {
+			hasRowSections = true
+		}
+	}
+	return len(tags) > 0, hasRowSections
+}
+
+// resolveItemConditions evaluates the row conditions of the template rows
+// of a block for one item. It returns the rows with the tags of holding
+// sections stripped and the text of failing inline sections dropped, and
+// marks the rows of failing sections as removed. Rows are numbered from
+// the first row of the block.
+func (s *excelService) resolveItemConditions(blockRows [][]string, scope *templateScope) ([][]string, []bool, error) {
+	rows := make([][]string, len(blockRows))
+	for i := range blockRows {
+		rows[i] = append([]string(nil), blockRows[i]...)
+	}
+	removed := make([]bool, len(rows))
+
+	for {
+		var tags []conditionTag
+		for _, tag := range scanConditionTags(rows) {
+			if isRowCondition(tag.kind) {
+				tags = append(tags, tag)
+			}
+		}
+		open, closing, err := findConditionSection(tags)
+		if err != nil || open == nil {
+			return rows, removed, err
+		}
+
+		holds := s.evaluateCondition(strings.HasPrefix(open.kind, "unless"), open.key, scope)
+		switch {
+		case open.isInline(closing):
+			cell := &rows[open.row-1][open.col-1]
+			*cell = applyInlineSection(*cell, open, closing, holds)
+		case holds:
+			closingCell := &rows[closing.row-1][closing.col-1]
+			*closingCell = (*closingCell)[:closing.start] + (*closingCell)[closing.end:]
+			openCell := &rows[open.row-1][open.col-1]
+			*openCell = (*openCell)[:open.start] + (*openCell)[open.end:]
+		default:
+			for row := open.row; row <= closing.row; row++ {
+				rows[row-1] = nil
+				removed[row-1] = true
+			}
+		}
+	}
+}
+
+// scanConditionTags lists the conditional tags of the sheet in reading order
+func scanConditionTags(rows [][]string) []conditionTag {
+	var tags []conditionTag
+	for rowIdx, row := range rows {
+		for colIdx, value := range row {
+			for _, match := range conditionTagPattern.FindAllStringSubmatchIndex(value, -1) {
+				tag := conditionTag{
+					text:  value[match[0]:match[1]],
+					row:   rowIdx + 1,
+					col:   colIdx + 1,
+					start: match[0],
+					end:   match[1],
+				}
+				if match[2] >= 0 {
+					tag.kind = value[match[2]:match[3]]
+					tag.key = value[match[4]:match[5]]
+				} else {
+					tag.kind = value[match[6]:match[7]]
+					tag.closing = true
+				}
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// findConditionSection returns the first conditional section together with
+// its matching closing tag, taking nested sections of the same kind into account
+func findConditionSection(tags []conditionTag) (*conditionTag, *conditionTag, error) {
+	if len(tags) == 0 {
+		return nil, nil, nil
+	}
+
+	open := &tags[0]
+	if open.closing {
+		return nil, nil, fmt.Errorf("unexpected {{/%s}} at %s", open.kind, open.cellName())
+	}
+
+	depth := 0
+	for i := 1; i < len(tags); i++ {
+		if tags[i].kind != open.kind {
+			continue
+		}
+		if !tags[i].closing {
+			depth++
+			continue
+		}
+		if depth == 0 {
+			return open, &tags[i], nil
+		}
+		depth--
+	}
+	return nil, nil, fmt.Errorf("condition %s opened at %s is not closed", open.key, open.cellName())
+}
+
+// evaluateCondition checks whether the value of the expression is truthy,
+// inverted for unless conditions
+func (s *excelService) evaluateCondition(negate bool, exprText string, scope *templateScope) bool {
+	value, _ := s.evaluatePlaceholder(exprText, scope)
+	return isTruthy(value) != negate
+}
+
+// isTruthy reports whether a template value counts as true in a condition.
+// Missing values, false, zero numbers, empty strings and empty lists are false.
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case models.Money:
+		return !v.IsZero()
+	case models.Quantity:
+		return !v.IsZero()
+	case float64:
+		return v != 0
+	case float32:
+		return v != 0
+	case int:
+		return v != 0
+	case int32:
+		return v != 0
+	case int64:
+		return v != 0
+	case string:
+		return v != ""
+	case time.Time:
+		return !v.IsZero()
+	}
+
+	if items, ok := toList(value); ok {
+		return len(items) > 0
+	}
+	if fields, ok := toMap(value); ok {
+		return len(fields) > 0
+	}
+	return true
+}
+
+// hideSheet hides a sheet. Excel does not hide the active sheet, so the
+// first other visible sheet is activated beforehand. A workbook needs a
+// visible sheet, so the last one is kept visible.
+func hideSheet(f *excelize.File, sheetName string) error {
+	other := -1
+	for idx, name := range f.GetSheetList() {
+		if name == sheetName {
+			continue
+		}
+		if visible, err := f.GetSheetVisible(name); err == nil && visible {
+			other = idx
+			break
+		}
+	}
+	if other < 0 {
+		utils.LogError("Not hiding sheet %s, it is the last visible sheet", sheetName)
+		return nil
+	}
+
+	if f.GetSheetName(f.GetActiveSheetIndex()) == sheetName {
+		f.SetActiveSheet(other)
+	}
+	return f.SetSheetVisible(sheetName, false)
+}
+
+// stripCellTag removes the first occurrence of a tag from the cell text
+func stripCellTag(f *excelize.File, sheetName, cellName, tag string) error {
+	value, err := f.GetCellValue(sheetName, cellName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+	return setCellString(f, sheetName, cellName, strings.Replace(value, tag, "", 1))
+}
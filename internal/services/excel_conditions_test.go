@@ -0,0 +1,218 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// conditionTestAct creates an act with a unit on the first of its two positions
+func conditionTestAct() *models.Act {
+	act := testAct(100, 200)
+	act.Positions[0].Name = "Excavation"
+	act.Positions[0].Unit = "m3"
+	act.Positions[1].Name = "Survey"
+	act.BigAct.TextFields["note"] = "Urgent"
+	return act
+}
+
+func TestApplyConditions(t *testing.T) {
+	tests := []struct {
+		name     string
+		cells    map[string]interface{}
+		expected [][]string
+	}{
+		{
+			name:     "holding section keeps its rows",
+			cells:    map[string]interface{}{"A1": "{{#if note}}Note:", "A2": "{{note}}{{/if}}", "A3": "End"},
+			expected: [][]string{{"Note:"}, {"Urgent"}, {"End"}},
+		},
+		{
+			name:     "failing section removes its rows",
+			cells:    map[string]interface{}{"A1": "{{#if missing}}Note:", "A2": "{{/if}}", "A3": "End"},
+			expected: [][]string{{"End"}},
+		},
+		{
+			name:     "unless inverts the condition",
+			cells:    map[string]interface{}{"A1": "{{#unless note}}No note{{/unless}}", "A2": "End"},
+			expected: [][]string{nil, {"End"}},
+		},
+		{
+			name:     "nested sections",
+			cells:    map[string]interface{}{"A1": "{{#if note}}Outer", "A2": "{{#if missing}}Inner{{/if}}", "A3": "{{/if}}End"},
+			expected: [][]string{{"Outer"}, nil, {"End"}},
+		},
+		{
+			name:     "failing column section removes its columns",
+			cells:    map[string]interface{}{"A1": "First", "B1": "{{#ifcol missing}}Hidden", "B2": "{{/ifcol}}", "C1": "Last"},
+			expected: [][]string{{"First", "Last"}},
+		},
+		{
+			name: "sections within a block hold per item",
+			cells: map[string]interface{}{
+				"A1": "{{#positions}}{{name}}",
+				"A2": "{{#if unit}}Unit: {{unit}}{{/if}}",
+				"B2": "{{#if unit}}per {{unit}}{{/if}}",
+				"A3": "{{#unless unit}}No unit{{/unless}}{{/positions}}",
+				"A4": "{{#if note}}{{note}}{{/if}}",
+			},
+			expected: [][]string{{"Excavation"}, {"Unit: m3", "per m3"}, nil, {"Survey"}, nil, {"No unit"}, {"Urgent"}},
+		},
+		{
+			name: "row sections within a block remove the rows of their item",
+			cells: map[string]interface{}{
+				"A1": "{{#positions}}{{name}}",
+				"A2": "{{#if unit}}Unit:",
+				"B2": "{{unit}}{{/if}}",
+				"A3": "{{currentPeriodCost}}{{/positions}}",
+				"A4": "Total",
+			},
+			expected: [][]string{{"Excavation"}, {"Unit:", "m3"}, {"1.00"}, {"Survey"}, {"2.00"}, {"Total"}},
+		},
+	}
+
+	service := NewExcelService(testConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := renderTestTemplate(t, service, tt.cells, conditionTestAct(), RenderOptions{})
+			if rows := sheetRows(t, f); !reflect.DeepEqual(rows, tt.expected) {
+				t.Errorf("rows = %q, expected %q", rows, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBlockConditionsShrinkRanges(t *testing.T) {
+	service := NewExcelService(testConfig())
+	f := renderTestTemplate(t, service, map[string]interface{}{
+		"A1": "{{#positions}}{{#if unit}}{{name}}",
+		"B1": "{{currentPeriodCost}}{{/if}}{{/positions}}",
+		"B2": "=SUM(B1:B1)",
+	}, conditionTestAct(), RenderOptions{})
+
+	sheet := f.GetSheetName(0)
+	if rows := sheetRows(t, f); len(rows) != 2 || rows[0][0] != "Excavation" {
+		t.Fatalf("rows = %q, expected the position with a unit and the total", rows)
+	}
+	if formula, _ := f.GetCellFormula(sheet, "B2"); formula != "SUM(B1:B1)" {
+		t.Errorf("formula = %q, expected SUM(B1:B1)", formula)
+	}
+}
+
+func TestStreamedBlockConditions(t *testing.T) {
+	cells := map[string]interface{}{
+		"A1": "{{#positions}}{{name}}{{#if unit}}, {{unit}}{{/if}}{{/positions}}",
+		"A2": "{{#if note}}{{note}}{{/if}}",
+	}
+	expected := [][]string{{"Excavation, m3"}, {"Survey"}, {"Urgent"}}
+
+	for _, threshold := range []int{0, 1} {
+		service := NewExcelService(&config.Config{DefaultVATRate: "20", StreamingThreshold: threshold})
+		f := renderTestTemplate(t, service, cells, conditionTestAct(), RenderOptions{})
+		if rows := sheetRows(t, f); !reflect.DeepEqual(rows, expected) {
+			t.Errorf("threshold %d: rows = %q, expected %q", threshold, rows, expected)
+		}
+	}
+}
+
+func TestBlockRowConditions(t *testing.T) {
+	tests := []struct {
+		rows           [][]string
+		hasConditions  bool
+		hasRowSections bool
+	}{
+		{rows: [][]string{{"{{name}}"}}},
+		{rows: [][]string{{"{{#if unit}}{{unit}}{{/if}}"}}, hasConditions: true},
+		{rows: [][]string{{"{{#if unit}}{{unit}}", "{{/if}}"}}, hasConditions: true, hasRowSections: true},
+		{rows: [][]string{{"{{#if unit}}"}, {"{{/if}}"}}, hasConditions: true, hasRowSections: true},
+		{rows: [][]string{{"{{#ifcol unit}}{{/ifcol}}"}}},
+	}
+	for _, tt := range tests {
+		hasConditions, hasRowSections := blockRowConditions(tt.rows)
+		if hasConditions != tt.hasConditions || hasRowSections != tt.hasRowSections {
+			t.Errorf("blockRowConditions(%q) = %v, %v; expected %v, %v", tt.rows, hasConditions, hasRowSections, tt.hasConditions, tt.hasRowSections)
+		}
+	}
+}
+
+func TestIsTruthy(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected bool
+	}{
+		{value: nil},
+		{value: false},
+		{value: true, expected: true},
+		{value: models.Money(0)},
+		{value: models.Money(1), expected: true},
+		{value: 0.0},
+		{value: 2, expected: true},
+		{value: ""},
+		{value: "text", expected: true},
+		{value: []interface{}{}},
+		{value: []interface{}{1}, expected: true},
+		{value: map[string]interface{}{}},
+		{value: map[string]interface{}{"key": 1}, expected: true},
+	}
+	for _, tt := range tests {
+		if result := isTruthy(tt.value); result != tt.expected {
+			t.Errorf("isTruthy(%#v) = %v, expected %v", tt.value, result, tt.expected)
+		}
+	}
+}
+
+func TestSheetConditionKeepsLastVisibleSheet(t *testing.T) {
+	service := NewExcelService(testConfig())
+	tests := []struct {
+		name   string
+		second string // value of A1 on a second sheet, none when empty
+		hidden bool
+	}{
+		{name: "only sheet", hidden: false},
+		{name: "other visible sheet", second: "Summary", hidden: true},
+		{name: "other sheet hidden too", second: "{{#ifsheet missing}}Summary", hidden: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			templatePath := filepath.Join(dir, "template.xlsx")
+			writeTestWorkbook(t, templatePath, map[string]interface{}{"A1": "{{#ifsheet missing}}Details"}, func(f *excelize.File, _ string) error {
+				if tt.second == "" {
+					return nil
+				}
+				if _, err := f.NewSheet("Second"); err != nil {
+					return err
+				}
+				return f.SetCellValue("Second", "A1", tt.second)
+			})
+
+			outputPath := filepath.Join(dir, "act.xlsx")
+			if err := service.GenerateAct(conditionTestAct(), templatePath, outputPath, RenderOptions{}); err != nil {
+				t.Fatalf("GenerateAct() error = %v", err)
+			}
+			f := openTestWorkbook(t, outputPath)
+
+			first := f.GetSheetName(0)
+			if visible, _ := f.GetSheetVisible(first); visible == tt.hidden {
+				t.Errorf("first sheet visible = %v, expected %v", visible, !tt.hidden)
+			}
+			visible := 0
+			for _, name := range f.GetSheetList() {
+				if v, _ := f.GetSheetVisible(name); v {
+					visible++
+				}
+			}
+			if visible == 0 {
+				t.Error("no visible sheet left")
+			}
+			if active, _ := f.GetSheetVisible(f.GetSheetName(f.GetActiveSheetIndex())); !active {
+				t.Error("the active sheet is hidden")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the JPEG decoder for image placeholders
+	_ "image/png"  // register the PNG decoder for image placeholders
+	"regexp"
+	"strings"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// Pattern to match a picture placeholder, e.g. {{image:signature}} or {{qr:verificationUrl}}
+var picturePlaceholderPattern = regexp.MustCompile(`\{\{\s*(image|qr):\s*([^}]+?)\s*\}\}`)
+
+// Size of a QR code module in pixels, pictures are shrunk to the cell afterwards
+const qrModuleSize = 10
+
+// Picture file extensions by the format reported by the image decoder
+var pictureExtensions = map[string]string{
+	"png":  ".png",
+	"jpeg": ".jpg",
+}
+
+// insertPictures inserts the pictures of the picture placeholders of a cell
+// and returns the cell text without them. Pictures are anchored at the cell
+// and shrunk to fit the merged range the cell belongs to. Image placeholders
+// insert the image of the value, QR placeholders a QR code of the value text.
+func (s *excelService) insertPictures(rc *renderContext, sheetName, cellName, text string, scope *templateScope) string {
+	return picturePlaceholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := picturePlaceholderPattern.FindStringSubmatch(match)
+		loc := TemplatePlaceholder{Sheet: sheetName, Cell: cellName, Part: partCell}
+
+		value, found := s.evaluatePlaceholder(parts[2], scope)
+		if !found || value == nil || value == "" {
+			return s.missingPlaceholder(rc, loc, match)
+		}
+
+		var content []byte
+		var err error
+		if parts[1] == "qr" {
+			content, err = utils.EncodeQRPNG(s.formatValue(value, scope.locale), qrModuleSize)
+		} else {
+			content, err = s.pictureContent(rc, value)
+		}
+		if err == nil {
+			err = addCellPicture(rc.file, sheetName, cellName, content, parts[2])
+		}
+		if err != nil {
+			utils.LogError("Error inserting picture %s at %s: %v", match, cellName, err)
+			return s.missingPlaceholder(rc, loc, match)
+		}
+		return ""
+	})
+}
+
+// pictureContent returns the image bytes of an image placeholder value: an
+// asset ID, a base64 string with or without a data URI prefix, or raw bytes
+func (s *excelService) pictureContent(rc *renderContext, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if IsAssetID(v) {
+			if rc.opts.LoadAsset == nil {
+				return nil, fmt.Errorf("assets are not available")
+			}
+			return rc.opts.LoadAsset(v)
+		}
+
+		// Strip the data URI prefix, e.g. data:image/png;base64,
+		if _, data, ok := strings.Cut(v, ";base64,"); ok && strings.HasPrefix(v, "data:") {
+			v = data
+		}
+		content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v), ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 image: %w", err)
+		}
+		return content, nil
+	default:
+		return nil, fmt.Errorf("unsupported image value of type %T", value)
+	}
+}
+
+// addCellPicture anchors a PNG or JPEG picture at the cell, keeping its
+// aspect ratio and shrinking it to the cell or its merged range
+func addCellPicture(f *excelize.File, sheetName, cellName string, content []byte, altText string) error {
+	_, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	extension, ok := pictureExtensions[format]
+	if !ok {
+		return fmt.Errorf("unsupported image format %s, expected PNG or JPEG", format)
+	}
+
+	return f.AddPictureFromBytes(sheetName, cellName, &excelize.Picture{
+		Extension: extension,
+		File:      content,
+		Format: &excelize.GraphicOptions{
+			AltText:         altText,
+			AutoFit:         true,
+			LockAspectRatio: true,
+			Positioning:     "oneCell",
+		},
+	})
+}
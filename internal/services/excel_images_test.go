@@ -0,0 +1,80 @@
+package services
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestPicturePlaceholders(t *testing.T) {
+	cfg := testConfig()
+	cfg.AssetsDir = t.TempDir()
+	assets := NewAssetService(cfg)
+
+	stamp, err := utils.EncodeQRPNG("stamp", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stampID, err := assets.Upload("stamp.png", stamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	act := testAct(100)
+	act.BigAct.TextFields["stamp"] = stampID
+	act.BigAct.TextFields["logo"] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(stamp)
+	act.BigAct.TextFields["broken"] = "not base64!"
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.xlsx")
+	writeTestWorkbook(t, templatePath, map[string]interface{}{
+		"A1": "{{image:stamp}}",
+		"B1": "Logo {{image:logo}}",
+		"A2": "{{qr:verificationUrl}}",
+		"A3": "{{image:signature}}",
+		"A4": "{{image:broken}}",
+	}, func(f *excelize.File, sheet string) error {
+		return f.MergeCell(sheet, "A2", "B2")
+	})
+
+	outputPath := filepath.Join(dir, "act.xlsx")
+	opts := RenderOptions{LoadAsset: assets.Load}
+	if err = NewExcelService(cfg).GenerateAct(act, templatePath, outputPath, opts); err != nil {
+		t.Fatalf("GenerateAct() error = %v", err)
+	}
+	f := openTestWorkbook(t, outputPath)
+	sheet := f.GetSheetName(0)
+
+	tests := []struct {
+		cell     string
+		text     string
+		pictures int
+	}{
+		{cell: "A1", text: "", pictures: 1},
+		{cell: "B1", text: "Logo ", pictures: 1},
+		{cell: "A2", text: "", pictures: 1},
+		// Pictures that cannot be inserted are missing keys, kept by default
+		{cell: "A3", text: "{{image:signature}}"},
+		{cell: "A4", text: "{{image:broken}}"},
+	}
+
+	for _, tt := range tests {
+		text, err := f.GetCellValue(sheet, tt.cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != tt.text {
+			t.Errorf("%s = %q, expected %q", tt.cell, text, tt.text)
+		}
+		pictures, err := f.GetPictures(sheet, tt.cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pictures) != tt.pictures {
+			t.Errorf("%s has %d pictures, expected %d", tt.cell, len(pictures), tt.pictures)
+		}
+	}
+}
@@ -0,0 +1,697 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Pattern to match a placeholder key that can be read back, e.g. customer.name
+var importKeyPattern = regexp.MustCompile(`^\w+(\.\w+)*$`)
+
+// Template values that are calculated or set by the service and never read back
+var importSkippedKeys = map[string]bool{
+	"createdAt":       true,
+	"updatedAt":       true,
+	"actId":           true,
+	"verificationUrl": true,
+}
+
+// ImportIssue is a cell of an imported workbook that could not be read
+type ImportIssue struct {
+	Sheet       string `json:"sheet,omitempty"`
+	Cell        string `json:"cell,omitempty"`
+	Placeholder string `json:"placeholder,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Message     string `json:"message"`
+}
+
+// importCell is a template cell together with the pattern the filled value
+// is matched against, one capture group per placeholder
+type importCell struct {
+	col     int
+	pattern *regexp.Regexp
+	fields  []importField
+	literal bool
+	single  bool
+}
+
+// importField is a placeholder of a template cell. The expression is nil
+// when the value cannot be read back, e.g. when it goes through a filter.
+type importField struct {
+	text string
+	expr *placeholderExpr
+}
+
+// importValue is the value read for a placeholder. Number holds the raw
+// number when the cell holds nothing but a typed number.
+type importValue struct {
+	text   string
+	number string
+}
+
+// actImport collects the act while a workbook is read
+type actImport struct {
+	act    *models.Act
+	locale *utils.Locale
+	issues []ImportIssue
+
+	// item is the item of a text field list being read
+	item map[string]interface{}
+
+	// seen holds the text of the values read so far, to report cells that
+	// disagree on the same value
+	seen map[string]string
+}
+
+// ImportAct reads a filled workbook back into an act by matching its cells
+// against the placeholders of the template. Rows of repeating blocks are
+// read until an empty row or the row following the block in the template;
+// rows of conditional sections may be missing. Cells that do not parse are
+// reported as issues and left out of the act.
+func (s *excelService) ImportAct(templatePath string, content []byte, locale *utils.Locale) (*models.Act, []ImportIssue, error) {
+	utils.LogMethodInit("ExcelService.ImportAct")
+
+	if locale == nil {
+		locale = utils.DefaultLocale
+	}
+
+	utils.LogInfo("Opening Excel template for import: %s", templatePath)
+	template, err := excelize.OpenFile(templatePath)
+	if err != nil {
+		utils.LogMethodError("ExcelService.ImportAct", err)
+		return nil, nil, fmt.Errorf("failed to open template: %w", err)
+	}
+	defer func() {
+		if closeErr := template.Close(); closeErr != nil {
+			utils.LogError("Error closing Excel file: %v", closeErr)
+		}
+	}()
+
+	filled, err := excelize.OpenReader(bytes.NewReader(content))
+	if err != nil {
+		utils.LogMethodError("ExcelService.ImportAct", err)
+		return nil, nil, fmt.Errorf("%w: failed to open workbook: %v", ErrInvalidAct, err)
+	}
+	defer func() {
+		if closeErr := filled.Close(); closeErr != nil {
+			utils.LogError("Error closing Excel file: %v", closeErr)
+		}
+	}()
+
+	imp := &actImport{
+		act: &models.Act{
+			BigAct:    &models.BigAct{Changed: true, TextFields: map[string]interface{}{}},
+			Positions: []models.Position{},
+		},
+		locale: locale,
+		issues: []ImportIssue{},
+		seen:   make(map[string]string),
+	}
+
+	// Sheets are matched by position, sheets hidden by conditions are kept
+	filledSheets := filled.GetSheetList()
+	for idx, sheetName := range template.GetSheetList() {
+		if idx >= len(filledSheets) {
+			imp.addIssue(sheetName, "", "", "", "sheet is missing in the workbook")
+			continue
+		}
+		if err = imp.readSheet(template, filled, sheetName, filledSheets[idx]); err != nil {
+			utils.LogMethodError("ExcelService.ImportAct", err)
+			return nil, nil, fmt.Errorf("failed to import sheet %s: %w", sheetName, err)
+		}
+	}
+
+	utils.LogInfo("Imported act with %d positions, %d text fields and %d issues",
+		len(imp.act.Positions), len(imp.act.BigAct.TextFields), len(imp.issues))
+	utils.LogMethodSuccess("ExcelService.ImportAct")
+	return imp.act, imp.issues, nil
+}
+
+// readSheet reads the values of a filled sheet, following the rows of the
+// template sheet and keeping track of the rows added by blocks and removed
+// by conditions
+func (imp *actImport) readSheet(template, filled *excelize.File, templateSheet, filledSheet string) error {
+	// Placeholders in the sheet name are read from the name of the filled sheet
+	if nameCell := newImportCell(0, templateSheet); len(nameCell.fields) > 0 {
+		imp.readCell(nameCell, 0, importValue{text: filledSheet}, filledSheet, "")
+	}
+
+	templateRows, err := template.GetRows(templateSheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+	filledRows, err := filled.GetRows(filledSheet)
+	if err != nil {
+		return err
+	}
+
+	blocks := make(map[int]*rowBlock)
+	for fromRow := 1; fromRow <= len(templateRows); {
+		block, err := findRowBlock(templateRows, fromRow)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			break
+		}
+		blocks[block.startRow] = block
+		fromRow = block.endRow + 1
+	}
+
+	conditional := conditionalRows(templateRows)
+	sheet := &importSheet{file: filled, name: filledSheet, rows: filledRows}
+
+	cursor := 1
+	for row := 1; row <= len(templateRows); row++ {
+		if block, ok := blocks[row]; ok {
+			cursor = imp.readBlock(sheet, templateRows, block, cursor)
+			row = block.endRow
+			continue
+		}
+
+		cells := importRowCells(templateRows[row-1])
+		if rowHasLiteral(cells) {
+			found := sheet.findRow(cells, cursor)
+			switch {
+			case found > 0:
+				cursor = found
+			case conditional[row]:
+				// The row was removed with its conditional section
+				continue
+			default:
+				// The text of the row was changed, its values are not read
+				message := fmt.Sprintf("row %d of the template is not found in the workbook", row)
+				for _, cell := range cells {
+					for _, field := range cell.fields {
+						imp.addIssue(filledSheet, "", field.text, "", message)
+					}
+				}
+				continue
+			}
+		}
+
+		for _, cell := range cells {
+			imp.readCell(cell, cursor, sheet.value(cell, cursor), filledSheet, "")
+		}
+		cursor++
+	}
+	return nil
+}
+
+// readBlock reads the items of a repeating block starting at the cursor and
+// returns the first row after them
+func (imp *actImport) readBlock(sheet *importSheet, templateRows [][]string, block *rowBlock, cursor int) int {
+	blockRows := make([][]importCell, block.height())
+	for i := range blockRows {
+		if block.startRow+i-1 < len(templateRows) {
+			blockRows[i] = importRowCells(templateRows[block.startRow+i-1])
+		}
+	}
+
+	// The first row with text after the block marks its end
+	var stopCells []importCell
+	for row := block.endRow + 1; row <= len(templateRows); row++ {
+		if cells := importRowCells(templateRows[row-1]); rowHasLiteral(cells) {
+			stopCells = cells
+			break
+		}
+	}
+
+	for ; cursor+block.height()-1 <= len(sheet.rows); cursor += block.height() {
+		if sheet.rowsEmpty(cursor, block.height()) || (stopCells != nil && sheet.matchRow(stopCells, cursor)) {
+			break
+		}
+		matches := true
+		for i, cells := range blockRows {
+			if rowHasLiteral(cells) && !sheet.matchRow(cells, cursor+i) {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			break
+		}
+
+		imp.startBlockItem(block.name)
+		for i, cells := range blockRows {
+			for _, cell := range cells {
+				imp.readCell(cell, cursor+i, sheet.value(cell, cursor+i), sheet.name, block.name)
+			}
+		}
+		imp.finishBlockItem(block.name)
+	}
+	return cursor
+}
+
+// startBlockItem starts an item of a block: a position, or an item of a
+// list in the text fields. The VAT breakdown is calculated and not read back.
+func (imp *actImport) startBlockItem(name string) {
+	imp.item = nil
+	switch {
+	case name == "positions":
+		imp.act.Positions = append(imp.act.Positions, models.Position{})
+	case name != "vatBreakdown" && importKeyPattern.MatchString(name) && !strings.Contains(name, "."):
+		imp.item = map[string]interface{}{}
+	}
+}
+
+// finishBlockItem adds the item of a text field list once it is read
+func (imp *actImport) finishBlockItem(name string) {
+	if imp.item == nil {
+		return
+	}
+	list, _ := imp.act.BigAct.TextFields[name].([]interface{})
+	imp.act.BigAct.TextFields[name] = append(list, imp.item)
+	imp.item = nil
+}
+
+// readCell matches the filled value against the template cell and sets the
+// values of its placeholders. Inside a block the values belong to the
+// current item of the block.
+func (imp *actImport) readCell(cell importCell, row int, value importValue, sheetName, block string) {
+	if len(cell.fields) == 0 {
+		return
+	}
+	cellName := ""
+	if row > 0 {
+		cellName, _ = excelize.CoordinatesToCellName(cell.col, row)
+	}
+
+	values := []importValue{value}
+	if !cell.single {
+		match := cell.pattern.FindStringSubmatch(value.text)
+		if match == nil {
+			imp.addIssue(sheetName, cellName, cell.fields[0].text, value.text, "value does not match the template text")
+			return
+		}
+		values = values[:0]
+		for _, text := range match[1:] {
+			values = append(values, importValue{text: strings.TrimSpace(text)})
+		}
+	}
+
+	for i, field := range cell.fields {
+		if field.expr == nil || i >= len(values) {
+			continue
+		}
+		if err := imp.setValue(block, field.expr.key, values[i]); err != nil {
+			imp.addIssue(sheetName, cellName, field.text, values[i].text, err.Error())
+		}
+	}
+}
+
+// setValue sets a value read for a placeholder key
+func (imp *actImport) setValue(block, key string, value importValue) error {
+	switch {
+	case block == "positions":
+		return imp.setPositionValue(&imp.act.Positions[len(imp.act.Positions)-1], key, value)
+	case block != "":
+		if imp.item != nil && value.text != "" {
+			return setTextField(imp.item, key, value.text)
+		}
+		return nil
+	}
+
+	if importSkippedKeys[key] || value.text == "" {
+		return nil
+	}
+	if previous, ok := imp.seen[key]; ok && previous != value.text {
+		return fmt.Errorf("value differs from %q read for the same placeholder", previous)
+	}
+	imp.seen[key] = value.text
+
+	bigAct := imp.act.BigAct
+	var target *models.Money
+	switch key {
+	case "totalCost":
+		target = &bigAct.TotalCost
+	case "totalCostInspection":
+		target = &bigAct.TotalCostInspection
+	case "totalCostConsiderations":
+		target = &bigAct.TotalCostConsiderations
+	case "vatTotal":
+		target = &bigAct.VATTotal
+	case "totalWithVat":
+		target = &bigAct.TotalWithVAT
+	case "positionIds":
+		bigAct.PositionIDs = value.text
+		return nil
+	default:
+		return setTextField(bigAct.TextFields, key, value.text)
+	}
+
+	amount, err := imp.parseMoney(value)
+	if err != nil {
+		return err
+	}
+	*target = amount
+	return nil
+}
+
+// setPositionValue sets a field of a position read from a {{#positions}} block
+func (imp *actImport) setPositionValue(pos *models.Position, key string, value importValue) error {
+	var target **models.Money
+	switch key {
+	case "id":
+		if value.text == "" {
+			return nil
+		}
+		id, err := primitive.ObjectIDFromHex(value.text)
+		if err != nil {
+			return fmt.Errorf("invalid position ID")
+		}
+		pos.ID = id
+		return nil
+	case "vatRate":
+		rate, ok := parseVATLabel(value.text)
+		if !ok {
+			return fmt.Errorf("unknown VAT rate, expected one of 20%%, 10%%, 0%%, без НДС")
+		}
+		pos.VATRate = rate
+		return nil
+	case "code":
+		pos.Code = value.text
+		return nil
+	case "name":
+		pos.Name = value.text
+		return nil
+	case "unit":
+		pos.Unit = value.text
+		return nil
+	case "estimateLine":
+		pos.EstimateLine = value.text
+		return nil
+	case "quantity":
+		if value.text == "" {
+			return nil
+		}
+		quantity, err := imp.parseQuantity(value)
+		if err != nil {
+			return err
+		}
+		pos.Quantity = &quantity
+		return nil
+	case "unitPrice":
+		target = &pos.UnitPrice
+	case "currentPeriodCost":
+		target = &pos.CurrentPeriodCost
+	case "currentPeriodCostInspection":
+		target = &pos.CurrentPeriodCostInspection
+	case "currentPeriodCostConsiderations":
+		target = &pos.CurrentPeriodCostConsiderations
+	case "accumulatedCost":
+		target = &pos.AccumulatedCost
+	default:
+		return nil
+	}
+
+	if value.text == "" {
+		return nil
+	}
+	amount, err := imp.parseMoney(value)
+	if err != nil {
+		return err
+	}
+	*target = &amount
+	return nil
+}
+
+// parseMoney reads an amount from a typed number or from text written with
+// the locale separators
+func (imp *actImport) parseMoney(value importValue) (models.Money, error) {
+	if value.number != "" {
+		return models.ParseMoney(value.number)
+	}
+	amount, err := models.ParseMoney(imp.locale.NormalizeNumber(value.text))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", models.ErrInvalidMoney, value.text)
+	}
+	return amount, nil
+}
+
+// parseQuantity reads a quantity from a typed number or from text written
+// with the locale separators
+func (imp *actImport) parseQuantity(value importValue) (models.Quantity, error) {
+	if value.number != "" {
+		return models.ParseQuantity(value.number)
+	}
+	quantity, err := models.ParseQuantity(imp.locale.NormalizeNumber(value.text))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", models.ErrInvalidQuantity, value.text)
+	}
+	return quantity, nil
+}
+
+// addIssue records a cell that could not be read
+func (imp *actImport) addIssue(sheet, cell, placeholder, value, message string) {
+	imp.issues = append(imp.issues, ImportIssue{
+		Sheet:       sheet,
+		Cell:        cell,
+		Placeholder: placeholder,
+		Value:       value,
+		Message:     message,
+	})
+}
+
+// parseVATLabel reads a VAT rate written as a label, e.g. "20%" or "без НДС",
+// or as the rate itself
+func parseVATLabel(text string) (models.VATRate, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", true
+	}
+	for _, rate := range models.VATRates {
+		if strings.EqualFold(text, rate.Label()) || text == string(rate) {
+			return rate, true
+		}
+	}
+	return "", false
+}
+
+// setTextField sets a text field, creating the nested maps of a dotted key.
+// A key cannot be both a value and the parent of other keys, e.g. customer
+// and customer.inn, so that conflict is returned instead of dropping either.
+func setTextField(fields map[string]interface{}, key, value string) error {
+	parts := strings.Split(key, ".")
+	for i, part := range parts[:len(parts)-1] {
+		existing, found := fields[part]
+		if !found {
+			nested := map[string]interface{}{}
+			fields[part] = nested
+			fields = nested
+			continue
+		}
+		nested, ok := existing.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%q is read as a value and cannot hold %q", strings.Join(parts[:i+1], "."), key)
+		}
+		fields = nested
+	}
+
+	last := parts[len(parts)-1]
+	if _, ok := fields[last].(map[string]interface{}); ok {
+		return fmt.Errorf("%q holds nested fields and cannot be read as a value", key)
+	}
+	fields[last] = value
+	return nil
+}
+
+// newImportCell builds the pattern of a template cell. Block tags are
+// dropped, conditional sections opened and closed inside the cell become
+// optional and pictures match nothing. Sections spanning several cells
+// remove whole rows or columns, so their tags are dropped too.
+func newImportCell(col int, text string) importCell {
+	cell := importCell{col: col}
+	matches := placeholderPattern.FindAllStringSubmatchIndex(text, -1)
+
+	// Pair the conditional tags of the cell
+	paired := make(map[int]bool)
+	var open []int
+	for idx, match := range matches {
+		inner := strings.TrimSpace(text[match[2]:match[3]])
+		switch {
+		case strings.HasPrefix(inner, "#"):
+			kind, _, _ := strings.Cut(inner[1:], " ")
+			if conditionKinds[kind] && !strings.HasSuffix(kind, "sheet") {
+				open = append(open, idx)
+			}
+		case strings.HasPrefix(inner, "/") && conditionKinds[strings.TrimSpace(inner[1:])] && len(open) > 0:
+			paired[open[len(open)-1]] = true
+			paired[idx] = true
+			open = open[:len(open)-1]
+		}
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString(`^\s*`)
+	depth := 0
+	last := 0
+	writeLiteral := func(literal string) {
+		pattern.WriteString(regexp.QuoteMeta(literal))
+		if depth == 0 && strings.TrimSpace(literal) != "" {
+			cell.literal = true
+		}
+	}
+	for idx, match := range matches {
+		writeLiteral(text[last:match[0]])
+		last = match[1]
+
+		tag := text[match[0]:match[1]]
+		inner := strings.TrimSpace(text[match[2]:match[3]])
+		switch {
+		case paired[idx] && strings.HasPrefix(inner, "#"):
+			pattern.WriteString(`(?:`)
+			depth++
+		case paired[idx]:
+			pattern.WriteString(`)?`)
+			depth--
+		case picturePlaceholderPattern.MatchString(tag), strings.HasPrefix(inner, "#"), strings.HasPrefix(inner, "/"):
+		default:
+			field := importField{text: tag}
+			if expr, err := parsePlaceholder(inner); err == nil && len(expr.filters) == 0 &&
+				importKeyPattern.MatchString(expr.key) {
+				field.expr = expr
+			}
+			cell.fields = append(cell.fields, field)
+			pattern.WriteString(`(.*?)`)
+		}
+	}
+	writeLiteral(text[last:])
+	pattern.WriteString(`\s*$`)
+
+	cell.pattern = regexp.MustCompile(`(?s)` + pattern.String())
+	cell.single = len(cell.fields) == 1 && !cell.literal && len(paired) == 0
+	return cell
+}
+
+// importRowCells returns the cells of a template row that hold text
+func importRowCells(row []string) []importCell {
+	var cells []importCell
+	for colIdx, value := range row {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		cell := newImportCell(colIdx+1, value)
+		if cell.literal || len(cell.fields) > 0 {
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// rowHasLiteral reports whether a row has text besides placeholders, which
+// allows finding it in the filled sheet
+func rowHasLiteral(cells []importCell) bool {
+	for _, cell := range cells {
+		if cell.literal {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalRows returns the template rows inside conditional sections
+// spanning several rows, which are missing when the condition did not hold
+func conditionalRows(rows [][]string) map[int]bool {
+	result := make(map[int]bool)
+	var open []conditionTag
+	for _, tag := range scanConditionTags(rows) {
+		if strings.HasSuffix(tag.kind, "col") {
+			continue
+		}
+		if !tag.closing {
+			open = append(open, tag)
+			continue
+		}
+		if len(open) == 0 {
+			continue
+		}
+		start := open[len(open)-1]
+		open = open[:len(open)-1]
+		if start.row == tag.row && start.col == tag.col {
+			continue
+		}
+		for row := start.row; row <= tag.row; row++ {
+			result[row] = true
+		}
+	}
+	return result
+}
+
+// importSheet is a sheet of the filled workbook
+type importSheet struct {
+	file *excelize.File
+	name string
+	rows [][]string
+}
+
+// text returns the formatted text of a cell
+func (sh *importSheet) text(col, row int) string {
+	if row < 1 || row > len(sh.rows) || col < 1 || col > len(sh.rows[row-1]) {
+		return ""
+	}
+	return sh.rows[row-1][col-1]
+}
+
+// value returns the value of a cell for a template cell, with the raw number
+// when the template cell holds a single placeholder and the cell a number
+func (sh *importSheet) value(cell importCell, row int) importValue {
+	value := importValue{text: strings.TrimSpace(sh.text(cell.col, row))}
+	if !cell.single || value.text == "" {
+		return value
+	}
+
+	cellName, err := excelize.CoordinatesToCellName(cell.col, row)
+	if err != nil {
+		return value
+	}
+	if cellType, err := sh.file.GetCellType(sh.name, cellName); err == nil &&
+		(cellType == excelize.CellTypeNumber || cellType == excelize.CellTypeUnset) {
+		if raw, err := sh.file.GetCellValue(sh.name, cellName, excelize.Options{RawCellValue: true}); err == nil {
+			if _, err = models.ParseMoney(raw); err == nil {
+				value.number = raw
+			}
+		}
+	}
+	return value
+}
+
+// matchRow reports whether the cells with text of a template row match a
+// row of the sheet
+func (sh *importSheet) matchRow(cells []importCell, row int) bool {
+	for _, cell := range cells {
+		if cell.literal && !cell.pattern.MatchString(strings.TrimSpace(sh.text(cell.col, row))) {
+			return false
+		}
+	}
+	return true
+}
+
+// findRow finds the first row at or below fromRow matching a template row
+func (sh *importSheet) findRow(cells []importCell, fromRow int) int {
+	for row := fromRow; row <= len(sh.rows); row++ {
+		if sh.matchRow(cells, row) {
+			return row
+		}
+	}
+	return 0
+}
+
+// rowsEmpty reports whether the rows hold no text
+func (sh *importSheet) rowsEmpty(fromRow, count int) bool {
+	for row := fromRow; row < fromRow+count && row <= len(sh.rows); row++ {
+		for _, text := range sh.rows[row-1] {
+			if strings.TrimSpace(text) != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// generateImportWorkbook renders the act with the default template and
+// lets the test edit the result before it is imported
+func generateImportWorkbook(t *testing.T, act *models.Act, locale *utils.Locale, edit func(f *excelize.File, sheet string)) []byte {
+	t.Helper()
+
+	outputPath := filepath.Join(t.TempDir(), "act.xlsx")
+	service := NewExcelService(testConfig())
+	if err := service.GenerateAct(act, "../../templates/act_template.xlsx", outputPath, RenderOptions{Locale: locale}); err != nil {
+		t.Fatalf("GenerateAct() error = %v", err)
+	}
+
+	if edit != nil {
+		f, err := excelize.OpenFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		edit(f, f.GetSheetName(0))
+		if err = f.Save(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestImportActRoundTrip(t *testing.T) {
+	act := testAct(100, 200)
+	for i := range act.Positions {
+		act.Positions[i].ID = primitive.NewObjectID()
+	}
+	act.Positions[1].VATRate = models.VATRate10
+	act.BigAct.TotalCost = models.NewMoney(1234, 50)
+	act.BigAct.PositionIDs = "a,b"
+
+	service := NewExcelService(testConfig())
+	for _, locale := range []*utils.Locale{utils.DefaultLocale, utils.RussianLocale} {
+		t.Run(locale.Name, func(t *testing.T) {
+			content := generateImportWorkbook(t, act, locale, nil)
+			imported, issues, err := service.ImportAct("../../templates/act_template.xlsx", content, locale)
+			if err != nil {
+				t.Fatalf("ImportAct() error = %v", err)
+			}
+			if len(issues) != 0 {
+				t.Fatalf("issues = %+v, expected none", issues)
+			}
+
+			for _, key := range []string{"customer", "contractor", "objectName", "contractNumber", "contractDate"} {
+				if imported.BigAct.TextFields[key] != act.BigAct.TextFields[key] {
+					t.Errorf("textFields[%s] = %v, expected %v", key, imported.BigAct.TextFields[key], act.BigAct.TextFields[key])
+				}
+			}
+			if imported.BigAct.TotalCost != act.BigAct.TotalCost || imported.BigAct.VATTotal != act.BigAct.VATTotal ||
+				imported.BigAct.TotalWithVAT != act.BigAct.TotalWithVAT || imported.BigAct.TotalCostInspection != 0 {
+				t.Errorf("totals = %+v, expected those of %+v", imported.BigAct, act.BigAct)
+			}
+			if imported.BigAct.PositionIDs != "a,b" {
+				t.Errorf("positionIds = %q, expected a,b", imported.BigAct.PositionIDs)
+			}
+
+			if len(imported.Positions) != len(act.Positions) {
+				t.Fatalf("positions = %d, expected %d", len(imported.Positions), len(act.Positions))
+			}
+			for i, pos := range imported.Positions {
+				if pos.ID != act.Positions[i].ID {
+					t.Errorf("position %d ID = %s, expected %s", i, pos.ID.Hex(), act.Positions[i].ID.Hex())
+				}
+				if pos.CurrentPeriodCost == nil || *pos.CurrentPeriodCost != *act.Positions[i].CurrentPeriodCost {
+					t.Errorf("position %d cost = %v, expected %v", i, pos.CurrentPeriodCost, *act.Positions[i].CurrentPeriodCost)
+				}
+			}
+		})
+	}
+}
+
+func TestImportActReportsCells(t *testing.T) {
+	act := testAct(100, 200)
+	content := generateImportWorkbook(t, act, nil, func(f *excelize.File, sheet string) {
+		// A position cost typed as text, an extra position and a changed label
+		cells, _ := f.SearchSheet(sheet, "^2\\. ", true)
+		row := 0
+		if len(cells) == 1 {
+			_, row, _ = excelize.CellNameToCoordinates(cells[0])
+		}
+		f.SetCellValue(sheet, "B"+strconv.Itoa(row), "two rubles")
+		f.InsertRows(sheet, row+1, 1)
+		f.SetCellValue(sheet, "A"+strconv.Itoa(row+1), "3. "+primitive.NewObjectID().Hex())
+		f.SetCellValue(sheet, "B"+strconv.Itoa(row+1), 7.5)
+		f.SetCellValue(sheet, "A15", "ID:") // the inspection row above is removed
+	})
+
+	service := NewExcelService(testConfig())
+	imported, issues, err := service.ImportAct("../../templates/act_template.xlsx", content, nil)
+	if err != nil {
+		t.Fatalf("ImportAct() error = %v", err)
+	}
+
+	if len(imported.Positions) != 3 {
+		t.Fatalf("positions = %d, expected 3", len(imported.Positions))
+	}
+	if cost := imported.Positions[2].CurrentPeriodCost; cost == nil || *cost != models.NewMoney(7, 50) {
+		t.Errorf("added position cost = %v, expected 7.50", cost)
+	}
+	if imported.Positions[1].CurrentPeriodCost != nil {
+		t.Errorf("unparsed cost = %v, expected nil", imported.Positions[1].CurrentPeriodCost)
+	}
+
+	expected := []ImportIssue{
+		{Sheet: "Акт", Placeholder: "{{positionIds}}", Message: "row 16 of the template is not found in the workbook"},
+		{Sheet: "Акт", Cell: "B19", Placeholder: "{{currentPeriodCost}}", Value: "two rubles", Message: `invalid money amount: "two rubles"`},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("issues = %+v, expected %+v", issues, expected)
+	}
+	for i := range expected {
+		if issues[i] != expected[i] {
+			t.Errorf("issue %d = %+v, expected %+v", i, issues[i], expected[i])
+		}
+	}
+}
+
+func TestSetTextField(t *testing.T) {
+	fields := map[string]interface{}{}
+	for _, key := range []string{"customer.name", "customer.bank.bik", "title"} {
+		if err := setTextField(fields, key, key); err != nil {
+			t.Fatalf("setTextField(%q) error = %v", key, err)
+		}
+	}
+	expected := map[string]interface{}{
+		"customer": map[string]interface{}{
+			"name": "customer.name",
+			"bank": map[string]interface{}{"bik": "customer.bank.bik"},
+		},
+		"title": "title",
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("fields = %v, expected %v", fields, expected)
+	}
+
+	// Neither a value nor nested fields are replaced by the other
+	for _, key := range []string{"title.length", "customer.name.first", "customer", "customer.bank"} {
+		if err := setTextField(fields, key, "x"); err == nil {
+			t.Errorf("setTextField(%q) succeeded, expected a conflict", key)
+		}
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("fields = %v after conflicts, expected %v", fields, expected)
+	}
+}
+
+// resolvedTemplateService resolves every act to the same template version
+type resolvedTemplateService struct {
+	TemplateService
+	template *models.Template
+}
+
+// ResolveTemplate returns the template
+func (s *resolvedTemplateService) ResolveTemplate(_ context.Context, _ *models.Act) (*models.Template, error) {
+	return s.template, nil
+}
+
+func TestImportActRecordsResolvedVersion(t *testing.T) {
+	template := &models.Template{TemplateID: "customer", Version: 3, FilePath: "../../templates/act_template.xlsx"}
+	service := &actService{
+		excelService:    NewExcelService(testConfig()),
+		templateService: &resolvedTemplateService{template: template},
+		config:          testConfig(),
+	}
+
+	// No version is requested, the act records the active one it was read with
+	result, err := service.ImportAct(context.Background(), ImportOptions{
+		TemplateID: "customer",
+		Content:    generateImportWorkbook(t, testAct(100), utils.DefaultLocale, nil),
+	})
+	if err != nil {
+		t.Fatalf("ImportAct() error = %v", err)
+	}
+	if result.Act.TemplateID != "customer" || result.Act.TemplateVersion != 3 {
+		t.Errorf("template = %q version %d, expected customer version 3", result.Act.TemplateID, result.Act.TemplateVersion)
+	}
+}
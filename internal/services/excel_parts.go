@@ -0,0 +1,316 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// Patterns to match a DrawingML paragraph and the text of its runs, used by
+// shapes and text boxes
+var (
+	drawingParagraphPattern = regexp.MustCompile(`(?s)<a:p(?:\s[^>]*)?>.*?</a:p>`)
+	drawingTextPattern      = regexp.MustCompile(`(<a:t(?:\s[^>]*)?>)([^<]*)(</a:t>)`)
+)
+
+// Characters that are not allowed in sheet names
+var invalidSheetNameChars = strings.NewReplacer(":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "_", "]", "_")
+
+// Maximum length of a sheet name allowed by Excel
+const maxSheetNameLength = 31
+
+// processDrawingText replaces placeholders in the text of shapes and text
+// boxes. It edits the raw drawing parts of the package, so it has to run
+// before any operation that makes excelize load and rewrite the drawings,
+// such as inserting or removing rows.
+func (s *excelService) processDrawingText(rc *renderContext, scope *templateScope) {
+	rc.file.Pkg.Range(func(key, value interface{}) bool {
+		name, ok := key.(string)
+		if !ok || !strings.HasPrefix(name, "xl/drawings/drawing") || !strings.HasSuffix(name, ".xml") {
+			return true
+		}
+		content, ok := value.([]byte)
+		if !ok || !bytes.Contains(content, []byte("{{")) {
+			return true
+		}
+
+		utils.LogDebug("Processing shape text in %s", name)
+		content = drawingParagraphPattern.ReplaceAllFunc(content, mergeDrawingRuns)
+		content = drawingTextPattern.ReplaceAllFunc(content, func(run []byte) []byte {
+			parts := drawingTextPattern.FindSubmatch(run)
+			text := html.UnescapeString(string(parts[2]))
+			if !strings.Contains(text, "{{") {
+				return run
+			}
+
+			loc := TemplatePlaceholder{Cell: name, Part: partShape}
+			var escaped bytes.Buffer
+			if err := xml.EscapeText(&escaped, []byte(s.replacePlaceholders(rc, loc, text, scope))); err != nil {
+				utils.LogError("Error escaping shape text in %s: %v", name, err)
+				return run
+			}
+			return append(append(append([]byte{}, parts[1]...), escaped.Bytes()...), parts[3]...)
+		})
+		rc.file.Pkg.Store(name, content)
+		return true
+	})
+}
+
+// mergeDrawingRuns rewrites a DrawingML paragraph so that no placeholder
+// spans several runs, as editors split text into runs wherever the
+// formatting or the spell checking changes. The text of the runs a
+// placeholder spans is moved to the run it starts in, so the replaced
+// value gets the formatting of that run.
+func mergeDrawingRuns(paragraph []byte) []byte {
+	runs := drawingTextPattern.FindAllSubmatchIndex(paragraph, -1)
+	if len(runs) < 2 || !bytes.Contains(paragraph, []byte("{{")) {
+		return paragraph
+	}
+
+	texts := make([]string, len(runs))
+	starts := make([]int, len(runs))
+	var joined strings.Builder
+	for i, run := range runs {
+		texts[i] = html.UnescapeString(string(paragraph[run[4]:run[5]]))
+		starts[i] = joined.Len()
+		joined.WriteString(texts[i])
+	}
+	// runAt returns the run holding the character at the offset
+	runAt := func(offset int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+	}
+
+	// Runs spanned by placeholders, overlapping spans joined
+	var spans [][2]int
+	for _, match := range placeholderPattern.FindAllStringIndex(joined.String(), -1) {
+		first, last := runAt(match[0]), runAt(match[1]-1)
+		if first == last {
+			continue
+		}
+		if n := len(spans); n > 0 && first <= spans[n-1][1] {
+			spans[n-1][1] = max(spans[n-1][1], last)
+			continue
+		}
+		spans = append(spans, [2]int{first, last})
+	}
+	if len(spans) == 0 {
+		return paragraph
+	}
+	for _, span := range spans {
+		for i := span[0] + 1; i <= span[1]; i++ {
+			texts[span[0]] += texts[i]
+			texts[i] = ""
+		}
+	}
+
+	var merged bytes.Buffer
+	last := 0
+	for i, run := range runs {
+		merged.Write(paragraph[last:run[4]])
+		if err := xml.EscapeText(&merged, []byte(texts[i])); err != nil {
+			return paragraph
+		}
+		last = run[5]
+	}
+	merged.Write(paragraph[last:])
+	return merged.Bytes()
+}
+
+// processHyperlinks replaces placeholders in the targets of the hyperlinks
+// of the given cells
+func (s *excelService) processHyperlinks(rc *renderContext, sheetName string, cells []string, scope *templateScope) error {
+	for _, cellName := range cells {
+		hasLink, link, err := rc.file.GetCellHyperLink(sheetName, cellName)
+		if err != nil {
+			return err
+		}
+		if !hasLink || !strings.Contains(link, "{{") {
+			continue
+		}
+
+		// Links to other places of the workbook are stored as locations
+		linkType := "Location"
+		if strings.Contains(link, "://") || strings.HasPrefix(link, "mailto:") {
+			linkType = "External"
+		}
+
+		loc := TemplatePlaceholder{Sheet: sheetName, Cell: cellName, Part: partHyperlink}
+		err = rc.file.SetCellHyperLink(sheetName, cellName, s.replacePlaceholders(rc, loc, link, scope), linkType)
+		if err != nil {
+			utils.LogError("Error setting hyperlink at %s: %v", cellName, err)
+		}
+	}
+	return nil
+}
+
+// processHeaderFooter replaces placeholders in the page headers and footers
+func (s *excelService) processHeaderFooter(rc *renderContext, sheetName string, scope *templateScope) error {
+	opts, err := rc.file.GetHeaderFooter(sheetName)
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		return nil
+	}
+
+	loc := TemplatePlaceholder{Sheet: sheetName, Part: partHeader}
+	changed := false
+	for _, text := range []*string{
+		&opts.OddHeader, &opts.OddFooter,
+		&opts.EvenHeader, &opts.EvenFooter,
+		&opts.FirstHeader, &opts.FirstFooter,
+	} {
+		if !strings.Contains(*text, "{{") {
+			continue
+		}
+		// & starts a formatting code in headers and footers, so it is doubled in values
+		*text = s.replaceEscaped(rc, loc, *text, scope, func(value string) string {
+			return strings.ReplaceAll(value, "&", "&&")
+		})
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return rc.file.SetHeaderFooter(sheetName, opts)
+}
+
+// processComments replaces placeholders in cell comments. Comments are
+// recreated, since excelize can not edit them in place.
+func (s *excelService) processComments(rc *renderContext, sheetName string, scope *templateScope) error {
+	comments, err := rc.file.GetComments(sheetName)
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range comments {
+		loc := TemplatePlaceholder{Sheet: sheetName, Cell: comment.Cell, Part: partComment}
+		changed := strings.Contains(comment.Text, "{{")
+		comment.Text = s.replacePlaceholders(rc, loc, comment.Text, scope)
+		for i := range comment.Paragraph {
+			if strings.Contains(comment.Paragraph[i].Text, "{{") {
+				comment.Paragraph[i].Text = s.replacePlaceholders(rc, loc, comment.Paragraph[i].Text, scope)
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if err = rc.file.DeleteComment(sheetName, comment.Cell); err != nil {
+			return err
+		}
+		if err = rc.file.AddComment(sheetName, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shiftComments moves the comments at or below fromRow by offset rows,
+// since excelize leaves comments in place when rows are inserted or
+// removed. With a negative offset the comments of the removed rows above
+// fromRow are deleted.
+func shiftComments(f *excelize.File, sheetName string, fromRow, offset int) error {
+	comments, err := f.GetComments(sheetName)
+	if err != nil || offset == 0 {
+		return err
+	}
+
+	var moved []excelize.Comment
+	for _, comment := range comments {
+		col, row, err := excelize.CellNameToCoordinates(comment.Cell)
+		if err != nil {
+			return err
+		}
+		if row < fromRow+min(offset, 0) {
+			continue
+		}
+		if err = f.DeleteComment(sheetName, comment.Cell); err != nil {
+			return err
+		}
+		if row < fromRow {
+			continue
+		}
+		if comment.Cell, err = excelize.CoordinatesToCellName(col, row+offset); err != nil {
+			return err
+		}
+		moved = append(moved, comment)
+	}
+
+	// Comments are added after all are deleted, so none takes the cell of
+	// another that has not moved yet
+	for _, comment := range moved {
+		if err = f.AddComment(sheetName, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processDefinedNames replaces placeholders in the formulas and comments of
+// defined names
+func (s *excelService) processDefinedNames(rc *renderContext, scope *templateScope) {
+	for _, definedName := range rc.file.GetDefinedName() {
+		if !strings.Contains(definedName.RefersTo, "{{") && !strings.Contains(definedName.Comment, "{{") {
+			continue
+		}
+
+		// Values usually end up in string constants, so quotes are doubled
+		loc := TemplatePlaceholder{Cell: definedName.Name, Part: partDefinedName}
+		updated := definedName
+		updated.RefersTo = s.replaceEscaped(rc, loc, definedName.RefersTo, scope, func(value string) string {
+			return strings.ReplaceAll(value, `"`, `""`)
+		})
+		updated.Comment = s.replacePlaceholders(rc, loc, definedName.Comment, scope)
+
+		err := rc.file.DeleteDefinedName(&excelize.DefinedName{Name: definedName.Name, Scope: definedName.Scope})
+		if err == nil {
+			err = rc.file.SetDefinedName(&updated)
+		}
+		if err != nil {
+			utils.LogError("Error updating defined name %s: %v", definedName.Name, err)
+		}
+	}
+}
+
+// processSheetNames replaces placeholders in sheet names. Characters that
+// Excel does not allow are replaced and names are cut to 31 characters.
+func (s *excelService) processSheetNames(rc *renderContext, scope *templateScope) {
+	for _, sheetName := range rc.file.GetSheetList() {
+		if !strings.Contains(sheetName, "{{") {
+			continue
+		}
+
+		loc := TemplatePlaceholder{Sheet: sheetName, Part: partSheetName}
+		newName := sanitizeSheetName(s.replacePlaceholders(rc, loc, sheetName, scope))
+		if newName == "" {
+			continue
+		}
+
+		if index, _ := rc.file.GetSheetIndex(newName); index != -1 {
+			utils.LogError("Cannot rename sheet %s, sheet %s already exists", sheetName, newName)
+			continue
+		}
+		if err := rc.file.SetSheetName(sheetName, newName); err != nil {
+			utils.LogError("Error renaming sheet %s: %v", sheetName, err)
+		}
+	}
+}
+
+// sanitizeSheetName replaces the characters Excel does not allow in sheet
+// names and cuts the name to 31 characters
+func sanitizeSheetName(name string) string {
+	name = strings.Trim(invalidSheetNameChars.Replace(name), "'")
+	if runes := []rune(name); len(runes) > maxSheetNameLength {
+		name = string(runes[:maxSheetNameLength])
+	}
+	return name
+}
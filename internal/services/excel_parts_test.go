@@ -0,0 +1,100 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestShiftComments(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	for _, cell := range []string{"A1", "A3", "B4", "A6"} {
+		if err := f.AddComment(sheet, excelize.Comment{Cell: cell, Author: "Author", Text: cell}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rows 3-4 are removed, so their comments go and the one below moves up
+	if err := shiftComments(f, sheet, 5, -2); err != nil {
+		t.Fatalf("shiftComments() error = %v", err)
+	}
+	// Two rows are inserted above row 2
+	if err := shiftComments(f, sheet, 2, 2); err != nil {
+		t.Fatalf("shiftComments() error = %v", err)
+	}
+
+	comments, err := f.GetComments(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := map[string]string{}
+	for _, comment := range comments {
+		cells[comment.Cell] = comment.Text
+	}
+	expected := map[string]string{"A1": "A1", "A6": "A6"}
+	if !reflect.DeepEqual(cells, expected) {
+		t.Errorf("comments = %v, expected %v", cells, expected)
+	}
+}
+
+func TestMergeDrawingRuns(t *testing.T) {
+	tests := []struct {
+		name      string
+		paragraph string
+		expected  string
+	}{
+		{
+			name:      "placeholder in one run",
+			paragraph: `<a:p><a:r><a:t>{{customer}}</a:t></a:r><a:r><a:t> tail</a:t></a:r></a:p>`,
+			expected:  `<a:p><a:r><a:t>{{customer}}</a:t></a:r><a:r><a:t> tail</a:t></a:r></a:p>`,
+		},
+		{
+			name:      "placeholder split across runs",
+			paragraph: `<a:p><a:r><a:rPr b="1"/><a:t>To {{cust</a:t></a:r><a:r><a:t>om</a:t></a:r><a:r><a:t>er}} &amp; co</a:t></a:r></a:p>`,
+			expected:  `<a:p><a:r><a:rPr b="1"/><a:t>To {{customer}} &amp; co</a:t></a:r><a:r><a:t></a:t></a:r><a:r><a:t></a:t></a:r></a:p>`,
+		},
+		{
+			name:      "overlapping spans",
+			paragraph: `<a:p><a:r><a:t>{{a</a:t></a:r><a:r><a:t>}}{{b</a:t></a:r><a:r><a:t>}}</a:t></a:r><a:r><a:t> {{c}}</a:t></a:r></a:p>`,
+			expected:  `<a:p><a:r><a:t>{{a}}{{b}}</a:t></a:r><a:r><a:t></a:t></a:r><a:r><a:t></a:t></a:r><a:r><a:t> {{c}}</a:t></a:r></a:p>`,
+		},
+		{
+			name:      "unclosed placeholder",
+			paragraph: `<a:p><a:r><a:t>{{a</a:t></a:r><a:r><a:t>b</a:t></a:r></a:p>`,
+			expected:  `<a:p><a:r><a:t>{{a</a:t></a:r><a:r><a:t>b</a:t></a:r></a:p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		if merged := string(mergeDrawingRuns([]byte(tt.paragraph))); merged != tt.expected {
+			t.Errorf("%s: mergeDrawingRuns() = %s, expected %s", tt.name, merged, tt.expected)
+		}
+	}
+}
+
+func TestHyperlinkOfEmptyCell(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.xlsx")
+	writeTestWorkbook(t, templatePath, map[string]interface{}{"A1": "{{customer}}"}, func(f *excelize.File, sheet string) error {
+		return f.SetCellHyperLink(sheet, "B2", "https://example.com/contracts/{{contractNumber}}", "External")
+	})
+
+	outputPath := filepath.Join(dir, "act.xlsx")
+	if err := NewExcelService(testConfig()).GenerateAct(testAct(100), templatePath, outputPath, RenderOptions{}); err != nil {
+		t.Fatalf("GenerateAct() error = %v", err)
+	}
+	f := openTestWorkbook(t, outputPath)
+
+	hasLink, link, err := f.GetCellHyperLink(f.GetSheetName(0), "B2")
+	if err != nil || !hasLink {
+		t.Fatalf("GetCellHyperLink() = %v, %v, expected a hyperlink", hasLink, err)
+	}
+	if expected := "https://example.com/contracts/42"; link != expected {
+		t.Errorf("hyperlink = %q, expected %q", link, expected)
+	}
+}
@@ -1,40 +1,123 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
 	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
 	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // ExcelService defines the interface for Excel operations
 type ExcelService interface {
-	GenerateAct(act *models.Act, outputPath string) error
+	GenerateAct(act *models.Act, templatePath, outputPath string, opts RenderOptions) error
+	GenerateCombined(acts []CombinedAct, templatePath, outputPath string) ([]string, error)
+	InspectTemplate(templatePath string, act *models.Act) (*TemplateReport, error)
+	ExportPDF(workbookPath, outputPath string, opts PDFOptions) error
+	ImportAct(templatePath string, content []byte, locale *utils.Locale) (*models.Act, []ImportIssue, error)
+	EvictTemplates(dir, keepPath string)
 }
 
 // excelService implements ExcelService
 type excelService struct {
-	config *config.Config
+	config    *config.Config
+	templates *templateCache
+}
+
+// RenderOptions controls how an act is rendered
+type RenderOptions struct {
+	// MissingKeyPolicy decides what happens to unresolved placeholders,
+	// placeholders are kept when it is empty
+	MissingKeyPolicy MissingKeyPolicy
+
+	// Locale decides how numbers and dates are written, the default locale
+	// is used when it is nil
+	Locale *utils.Locale
+
+	// LoadAsset reads a stored image for image placeholders referring to an asset ID
+	LoadAsset func(id string) ([]byte, error)
+
+	// Data holds values added to the template data of the act, replacing
+	// values with the same keys, e.g. the cumulative totals of form KS-3
+	Data map[string]interface{}
+
+	// PositionData holds values added to the data of the positions, in the
+	// order of the act positions, e.g. the cumulative costs of form KS-3
+	PositionData []map[string]interface{}
+
+	// Watermark is laid over every sheet when it is set, e.g. DRAFT on acts
+	// that are not approved yet
+	Watermark string
+}
+
+// renderContext holds the state of rendering a single workbook
+type renderContext struct {
+	file     *excelize.File
+	template *compiledTemplate
+	opts     RenderOptions
+
+	// valueStyles caches the styles derived from template cell styles for
+	// typed values, keyed by template style ID and value kind
+	valueStyles map[string]int
+
+	// missing collects the placeholders that could not be resolved, once
+	// per location even when a kept placeholder is met again
+	missing     []TemplatePlaceholder
+	missingSeen map[TemplatePlaceholder]bool
+}
+
+// newRenderContext creates a render context for the opened template
+func newRenderContext(f *excelize.File, template *compiledTemplate, opts RenderOptions) *renderContext {
+	return &renderContext{
+		file:        f,
+		template:    template,
+		opts:        opts,
+		valueStyles: make(map[string]int),
+		missingSeen: make(map[TemplatePlaceholder]bool),
+	}
+}
+
+// locale returns the locale of the render
+func (rc *renderContext) locale() *utils.Locale {
+	if rc.opts.Locale == nil {
+		return utils.DefaultLocale
+	}
+	return rc.opts.Locale
+}
+
+// EvictTemplates drops the compiled templates of the directory from the
+// cache except the kept one, e.g. when another template version is activated
+func (s *excelService) EvictTemplates(dir, keepPath string) {
+	s.templates.evict(dir, keepPath)
 }
 
 // NewExcelService creates a new ExcelService
 func NewExcelService(cfg *config.Config) ExcelService {
 	return &excelService{
-		config: cfg,
+		config:    cfg,
+		templates: newTemplateCache(cfg.TemplateCacheSize),
 	}
 }
 
-// GenerateAct generates an Excel file from an act using the template
-func (s *excelService) GenerateAct(act *models.Act, outputPath string) error {
+// GenerateAct generates an Excel file from an act using the given template
+func (s *excelService) GenerateAct(act *models.Act, templatePath, outputPath string, opts RenderOptions) error {
 	utils.LogMethodInit("ExcelService.GenerateAct")
 	utils.LogExcelInit(outputPath)
 
-	// Open the template file
-	utils.LogInfo("Opening Excel template: %s", s.config.TemplatePath)
-	f, err := excelize.OpenFile(s.config.TemplatePath)
+	// Open the template from the compiled copy, the file is read again only when it changes
+	utils.LogInfo("Opening Excel template: %s", templatePath)
+	template, err := s.templates.load(templatePath)
+	if err != nil {
+		utils.LogMethodError("ExcelService.GenerateAct", err)
+		return fmt.Errorf("failed to open template: %w", err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(template.content))
 	if err != nil {
 		utils.LogMethodError("ExcelService.GenerateAct", err)
 		return fmt.Errorf("failed to open template: %w", err)
@@ -47,14 +130,19 @@ func (s *excelService) GenerateAct(act *models.Act, outputPath string) error {
 
 	// Build template data
 	utils.LogDebug("Building template data for act: %s", act.ID.Hex())
-	templateData := s.buildTemplateData(act)
+	templateData := s.renderData(act, opts)
+
+	// Shapes are processed on the raw package before sheets change the drawings
+	rc := newRenderContext(f, template, opts)
+	scope := newTemplateScope(templateData, opts.Locale)
+	s.processDrawingText(rc, scope)
 
 	// Process all sheets
 	sheets := f.GetSheetList()
 	utils.LogInfo("Processing %d sheets in Excel template", len(sheets))
 	for _, sheetName := range sheets {
 		utils.LogDebug("Processing sheet: %s", sheetName)
-		err = s.processSheet(f, sheetName, templateData)
+		err = s.processSheet(rc, sheetName, scope)
 		if err != nil {
 			utils.LogError("Error processing sheet %s: %v", sheetName, err)
 			utils.LogMethodError("ExcelService.GenerateAct", err)
@@ -62,6 +150,17 @@ func (s *excelService) GenerateAct(act *models.Act, outputPath string) error {
 		}
 	}
 
+	// Process workbook parts, sheets are renamed last
+	s.processDefinedNames(rc, scope)
+	s.processSheetNames(rc, scope)
+
+	// With the error policy nothing is saved when placeholders are missing
+	if opts.MissingKeyPolicy == MissingKeyError && len(rc.missing) > 0 {
+		err = &MissingPlaceholdersError{Placeholders: sortPlaceholders(rc.missing)}
+		utils.LogMethodError("ExcelService.GenerateAct", err)
+		return err
+	}
+
 	// Save the file
 	utils.LogInfo("Saving Excel file to: %s", outputPath)
 	err = f.SaveAs(outputPath)
@@ -75,15 +174,67 @@ func (s *excelService) GenerateAct(act *models.Act, outputPath string) error {
 	return nil
 }
 
+// Pattern to match {{key}}
+var placeholderPattern = regexp.MustCompile(`\{\{([^}]+)\}\}`)
+
 // processSheet processes a single sheet, replacing all placeholders
-func (s *excelService) processSheet(f *excelize.File, sheetName string, data map[string]interface{}) error {
-	rows, err := f.GetRows(sheetName)
+func (s *excelService) processSheet(rc *renderContext, sheetName string, scope *templateScope) error {
+	f := rc.file
+	compiled := rc.template.sheets[sheetName]
+	if compiled == nil {
+		return fmt.Errorf("sheet %s is missing from the compiled template", sheetName)
+	}
+
+	// Hyperlinks are kept in sync by excelize when rows are added or removed
+	err := s.processHyperlinks(rc, sheetName, compiled.links, scope)
+	if err != nil {
+		return err
+	}
+
+	// Sheets without blocks and conditions keep the template cell positions,
+	// so only the compiled placeholder cells are filled
+	if compiled.isStatic() {
+		for _, cell := range compiled.cells {
+			s.setCellText(rc, sheetName, cell.name, cell.text, scope)
+		}
+		return s.processSheetParts(rc, sheetName, scope)
+	}
+
+	// Evaluate conditions first, so that removed rows are never expanded
+	if compiled.hasConditions {
+		err = s.applySheetConditions(rc, sheetName, scope)
+		if err != nil {
+			return err
+		}
+		err = s.applyConditions(rc, sheetName, scope)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Large repeating blocks are streamed. The sheet can not be changed once
+	// it is streamed, so the parts outside of cells are processed first.
+	stream, err := s.shouldStream(rc, sheetName, scope)
 	if err != nil {
 		return err
 	}
+	if stream {
+		if err = s.processSheetParts(rc, sheetName, scope); err != nil {
+			return err
+		}
+		return s.streamSheet(rc, sheetName, scope)
+	}
 
-	// Pattern to match {{key}}
-	pattern := regexp.MustCompile(`\{\{([^}]+)\}\}`)
+	// Expand repeating blocks, their rows are filled with item data
+	err = s.expandRowBlocks(rc, sheetName, scope)
+	if err != nil {
+		return err
+	}
+
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return err
+	}
 
 	// Iterate through all rows and columns
 	for rowIdx, row := range rows {
@@ -101,28 +252,120 @@ func (s *excelService) processSheet(f *excelize.File, sheetName string, data map
 			}
 
 			// Find all matches in the cell
-			if pattern.MatchString(cellValue) {
-				newValue := pattern.ReplaceAllStringFunc(cellValue, func(match string) string {
-					// Extract key from {{key}}
-					key := pattern.FindStringSubmatch(match)[1]
-
-					// Get value from data
-					if value, ok := data[key]; ok {
-						return s.formatValue(value)
-					}
-					return match // Keep original if not found
-				})
-
-				// Set the new value
-				err = f.SetCellValue(sheetName, cellName, newValue)
-				if err != nil {
-					utils.LogError("Error setting cell value at %s: %v", cellName, err)
-				}
+			if placeholderPattern.MatchString(cellValue) {
+				s.setCellText(rc, sheetName, cellName, cellValue, scope)
 			}
 		}
 	}
 
-	return nil
+	return s.processSheetParts(rc, sheetName, scope)
+}
+
+// processSheetParts processes the parts of the sheet outside of cells
+func (s *excelService) processSheetParts(rc *renderContext, sheetName string, scope *templateScope) error {
+	if err := s.processHeaderFooter(rc, sheetName, scope); err != nil {
+		return err
+	}
+	if rc.opts.Watermark != "" {
+		if err := addWatermark(rc.file, sheetName, rc.opts.Watermark); err != nil {
+			utils.LogError("Error adding watermark to sheet %s: %v", sheetName, err)
+		}
+	}
+	return s.processComments(rc, sheetName, scope)
+}
+
+// setCellText replaces the placeholders of a template text and writes the
+// result into the cell. A cell holding nothing but a single placeholder
+// receives the typed value, so numbers, dates and booleans stay usable in
+// formulas and keep the number format of the template cell.
+func (s *excelService) setCellText(rc *renderContext, sheetName, cellName, text string, scope *templateScope) {
+	// Pictures are anchored at the cell, the rest of the text stays in it
+	if picturePlaceholderPattern.MatchString(text) {
+		text = s.insertPictures(rc, sheetName, cellName, text, scope)
+		if !placeholderPattern.MatchString(text) {
+			if err := setCellString(rc.file, sheetName, cellName, text); err != nil {
+				utils.LogError("Error setting cell value at %s: %v", cellName, err)
+			}
+			return
+		}
+	}
+
+	if exprText, ok := singlePlaceholderExpr(text); ok {
+		if value, found := s.evaluatePlaceholder(exprText, scope); found {
+			err := s.setCellTypedValue(rc, sheetName, cellName, value)
+			if err != nil {
+				utils.LogError("Error setting typed cell value at %s: %v", cellName, err)
+			}
+			return
+		}
+	}
+
+	loc := TemplatePlaceholder{Sheet: sheetName, Cell: cellName, Part: partCell}
+	newValue := s.replacePlaceholders(rc, loc, text, scope)
+
+	// Set the new value
+	err := rc.file.SetCellValue(sheetName, cellName, newValue)
+	if err != nil {
+		utils.LogError("Error setting cell value at %s: %v", cellName, err)
+	}
+}
+
+// replacePlaceholders replaces every {{key}} in the text with its formatted
+// value. Unresolved placeholders are recorded at the given location.
+func (s *excelService) replacePlaceholders(rc *renderContext, loc TemplatePlaceholder, text string, scope *templateScope) string {
+	return s.replaceEscaped(rc, loc, text, scope, nil)
+}
+
+// replaceEscaped replaces every {{key}} in the text with its formatted value,
+// passed through escape when the target text has special characters
+func (s *excelService) replaceEscaped(rc *renderContext, loc TemplatePlaceholder, text string, scope *templateScope, escape func(string) string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		// Extract the expression from {{key | filter}}
+		exprText := placeholderPattern.FindStringSubmatch(match)[1]
+
+		// Get value from data
+		if value, found := s.evaluatePlaceholder(exprText, scope); found {
+			if escape != nil {
+				return escape(s.formatValue(value, scope.locale))
+			}
+			return s.formatValue(value, scope.locale)
+		}
+		return s.missingPlaceholder(rc, loc, match)
+	})
+}
+
+// missingPlaceholder records an unresolved placeholder and returns the text
+// that replaces it according to the missing key policy
+func (s *excelService) missingPlaceholder(rc *renderContext, loc TemplatePlaceholder, match string) string {
+	loc.Text = match
+	loc.Kind = tagValue
+	if !rc.missingSeen[loc] {
+		rc.missingSeen[loc] = true
+		rc.missing = append(rc.missing, loc)
+	}
+	utils.LogDebug("Unresolved placeholder %s in %s %s!%s", match, loc.Part, loc.Sheet, loc.Cell)
+
+	if rc.opts.MissingKeyPolicy == MissingKeyBlank {
+		return ""
+	}
+	return match // Keep original if not found
+}
+
+// evaluatePlaceholder parses a placeholder expression and evaluates it
+// against the scope. Invalid expressions are logged and left unresolved.
+func (s *excelService) evaluatePlaceholder(exprText string, scope *templateScope) (interface{}, bool) {
+	expr, err := compilePlaceholder(exprText)
+	if err != nil {
+		utils.LogError("Invalid placeholder {{%s}}: %v", exprText, err)
+		return nil, false
+	}
+
+	value, found, err := expr.evaluate(scope)
+	if err != nil {
+		utils.LogError("Error evaluating placeholder {{%s}}: %v", exprText, err)
+		return nil, false
+	}
+	return value, found
 }
 
 // buildTemplateData builds a map of all data that can be used in the template
@@ -135,6 +378,9 @@ func (s *excelService) buildTemplateData(act *models.Act) map[string]interface{}
 		data["totalCost"] = act.BigAct.TotalCost
 		data["totalCostInspection"] = act.BigAct.TotalCostInspection
 		data["totalCostConsiderations"] = act.BigAct.TotalCostConsiderations
+		data["vatTotal"] = act.BigAct.VATTotal
+		data["totalWithVat"] = act.BigAct.TotalWithVAT
+		data["vatBreakdown"] = buildVATData(act.BigAct.VATBreakdown)
 		data["positionIds"] = act.BigAct.PositionIDs
 
 		// Add text fields
@@ -145,29 +391,126 @@ func (s *excelService) buildTemplateData(act *models.Act) map[string]interface{}
 		}
 	}
 
+	// Add positions for repeating blocks
+	positions := make([]interface{}, 0, len(act.Positions))
+	for _, pos := range act.Positions {
+		positions = append(positions, s.buildPositionData(pos))
+	}
+	data["positions"] = positions
+
 	// Add timestamps
-	data["createdAt"] = act.CreatedAt.Format("02.01.2006")
-	data["updatedAt"] = act.UpdatedAt.Format("02.01.2006")
+	data["createdAt"] = act.CreatedAt
+	data["updatedAt"] = act.UpdatedAt
 
-	// Add act ID
+	// Add act ID and the link printed acts are verified with
 	data["actId"] = act.ID.Hex()
+	data["verificationUrl"] = strings.TrimRight(s.config.BaseURL, "/") + "/api/act/verify?id=" + act.ID.Hex()
+
+	// Add the status with a flag per status for conditional content, and
+	// the time of the last change to each status, e.g. signedAt
+	status := act.CurrentStatus()
+	data["status"] = string(status)
+	data["isDraft"] = status == models.ActStatusDraft
+	data["isSubmitted"] = status == models.ActStatusSubmitted
+	data["isApproved"] = status == models.ActStatusApproved
+	data["isSigned"] = status == models.ActStatusSigned
+	data["isCancelled"] = status == models.ActStatusCancelled
+	for _, change := range act.StatusHistory {
+		if change.To != models.ActStatusDraft {
+			data[string(change.To)+"At"] = change.At
+		}
+	}
+
+	return data
+}
+
+// renderData builds the template data of the act with the values of the
+// render options added
+func (s *excelService) renderData(act *models.Act, opts RenderOptions) map[string]interface{} {
+	data := s.buildTemplateData(act)
+	if positions, ok := data["positions"].([]interface{}); ok {
+		for i, extra := range opts.PositionData {
+			if i >= len(positions) {
+				break
+			}
+			for key, value := range extra {
+				positions[i].(map[string]interface{})[key] = value
+			}
+		}
+	}
+	for key, value := range opts.Data {
+		data[key] = value
+	}
+	return data
+}
 
+// buildPositionData builds the data available inside a {{#positions}} block
+func (s *excelService) buildPositionData(pos models.Position) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":                              pos.ID.Hex(),
+		"code":                            pos.Code,
+		"name":                            pos.Name,
+		"unit":                            pos.Unit,
+		"quantity":                        nil,
+		"unitPrice":                       optionalValue(pos.UnitPrice),
+		"estimateLine":                    pos.EstimateLine,
+		"currentPeriodCost":               optionalValue(pos.CurrentPeriodCost),
+		"currentPeriodCostInspection":     optionalValue(pos.CurrentPeriodCostInspection),
+		"currentPeriodCostConsiderations": optionalValue(pos.CurrentPeriodCostConsiderations),
+		"accumulatedCost":                 optionalValue(pos.AccumulatedCost),
+		"vatRate":                         pos.EffectiveVATRate(s.config.DefaultVATRate).Label(),
+	}
+	if pos.Quantity != nil {
+		data["quantity"] = *pos.Quantity
+	}
 	return data
 }
 
-// formatValue formats a value based on its type
-func (s *excelService) formatValue(value interface{}) string {
+// buildVATData builds the items of a {{#vatBreakdown}} block
+func buildVATData(breakdown []models.VATAmount) []interface{} {
+	items := make([]interface{}, 0, len(breakdown))
+	for _, amount := range breakdown {
+		items = append(items, map[string]interface{}{
+			"rate":  amount.Rate.Label(),
+			"net":   amount.Net,
+			"vat":   amount.VAT,
+			"gross": amount.Gross,
+		})
+	}
+	return items
+}
+
+// optionalValue unwraps an optional cost, a missing cost becomes an empty value
+func optionalValue(value *models.Money) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// formatValue formats a value based on its type, numbers and dates as the locale writes them
+func (s *excelService) formatValue(value interface{}, locale *utils.Locale) string {
 	switch v := value.(type) {
+	case models.Money:
+		return locale.FormatDecimal(v, locale.Decimals)
+	case models.Quantity:
+		return locale.FormatDecimal(v, v.Decimals())
 	case float64:
-		return utils.FormatNumber(v)
+		return locale.FormatNumber(v)
 	case float32:
-		return utils.FormatNumber(float64(v))
+		return locale.FormatNumber(float64(v))
 	case int:
-		return utils.FormatNumber(float64(v))
+		return locale.FormatNumberWithDecimals(float64(v), 0)
 	case int64:
-		return utils.FormatNumber(float64(v))
+		return locale.FormatNumberWithDecimals(float64(v), 0)
 	case string:
 		return v
+	case time.Time:
+		return locale.FormatDate(v, "")
+	case primitive.DateTime:
+		return locale.FormatDate(v.Time(), "")
+	case nil:
+		return ""
 	default:
 		return fmt.Sprintf("%v", v)
 	}
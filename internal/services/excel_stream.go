@@ -0,0 +1,497 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// Pattern to match a cell reference inside a formula, e.g. $B$12. References
+// to other sheets and names followed by ( such as LOG10( are skipped by the caller.
+var formulaCellPattern = regexp.MustCompile(`(\$?[A-Z]{1,3}\$?)(\d+)`)
+
+// Default row height of a worksheet in points
+const defaultRowHeight = 15.0
+
+// streamCell is a template cell kept in memory while a sheet is streamed
+type streamCell struct {
+	value    string
+	formula  string
+	styleID  int
+	cellType excelize.CellType
+}
+
+// streamRow is a template row kept in memory while a sheet is streamed
+type streamRow struct {
+	cells  []streamCell
+	height float64
+	hidden bool
+}
+
+// streamLayout maps template rows to the rows of the generated sheet
+type streamLayout struct {
+	blocks []*rowBlock
+	items  [][]interface{}
+}
+
+// shouldStream reports whether a repeating block of the sheet has at least
+// as many items as the streaming threshold. Sheets with hyperlinks,
+// comments, pictures or shapes are never streamed, since those would stay
+// at their template rows, nor are blocks with nested blocks or conditional
+// sections of whole rows, which give their items different heights.
+func (s *excelService) shouldStream(rc *renderContext, sheetName string, scope *templateScope) (bool, error) {
+	if s.config.StreamingThreshold <= 0 {
+		return false, nil
+	}
+
+	rows, err := rc.file.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return false, err
+	}
+
+	for fromRow := 1; ; {
+		block, err := findRowBlock(rows, fromRow)
+		if err != nil || block == nil {
+			return false, err
+		}
+		if len(blockItems(scope, block.name)) >= s.config.StreamingThreshold {
+			if compiled := rc.template.sheets[sheetName]; compiled != nil && compiled.anchored {
+				utils.LogInfo("Sheet %s has hyperlinks, comments, pictures or shapes and is rendered in memory", sheetName)
+				return false, nil
+			}
+			blockRows := rows[block.startRow-1 : min(block.endRow, len(rows))]
+			if hasNestedBlock(blockRows) {
+				utils.LogInfo("Block %s of sheet %s has nested blocks and is rendered in memory", block.name, sheetName)
+				return false, nil
+			}
+			if _, hasRowSections := blockRowConditions(blockRows); hasRowSections {
+				utils.LogInfo("Block %s of sheet %s has conditional rows and is rendered in memory", block.name, sheetName)
+				return false, nil
+			}
+			return true, nil
+		}
+		fromRow = block.endRow + 1
+	}
+}
+
+// streamSheet renders a sheet with a stream writer: the template rows are
+// read into memory once and the generated rows, with repeating blocks
+// expanded, are written in order without keeping them in the workbook. Cell
+// values, styles, formulas, merged cells and row heights are written.
+func (s *excelService) streamSheet(rc *renderContext, sheetName string, scope *templateScope) error {
+	f := rc.file
+
+	template, err := readStreamTemplate(f, sheetName)
+	if err != nil {
+		return err
+	}
+
+	rows, err := f.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+	layout := &streamLayout{}
+	for fromRow := 1; ; {
+		block, err := findRowBlock(rows, fromRow)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			break
+		}
+		layout.blocks = append(layout.blocks, block)
+		layout.items = append(layout.items, blockItems(scope, block.name))
+		stripStreamBlockTag(template, block.openCell, "{{#"+block.name+"}}")
+		stripStreamBlockTag(template, block.closeCell, "{{/"+block.name+"}}")
+		fromRow = block.endRow + 1
+	}
+
+	mergeCells, err := f.GetMergeCells(sheetName)
+	if err != nil {
+		return err
+	}
+
+	utils.LogInfo("Streaming sheet %s with %d repeating blocks", sheetName, len(layout.blocks))
+	sw, err := f.NewStreamWriter(sheetName)
+	if err != nil {
+		return err
+	}
+
+	for templateRow := 1; templateRow <= len(template); templateRow++ {
+		blockIdx := layout.blockAt(templateRow)
+		if blockIdx < 0 {
+			err = s.streamRow(rc, sw, sheetName, template[templateRow-1], layout, templateRow, -1, 0, scope)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Write every item of the block, then continue after the block.
+		// Inline conditions of the block are evaluated for every item.
+		block := layout.blocks[blockIdx]
+		blockRows := template[block.startRow-1 : block.endRow]
+		values := streamValues(blockRows)
+		hasConditions, _ := blockRowConditions(values)
+		for itemIdx, item := range layout.items[blockIdx] {
+			itemScope := scope.child(blockItemData(item, itemIdx))
+			itemRows := blockRows
+			if hasConditions {
+				itemValues, _, err := s.resolveItemConditions(values, itemScope)
+				if err != nil {
+					return err
+				}
+				itemRows = withStreamValues(blockRows, itemValues)
+			}
+			for i, row := range itemRows {
+				err = s.streamRow(rc, sw, sheetName, row, layout, block.startRow+i, blockIdx, itemIdx, itemScope)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		templateRow = block.endRow
+	}
+
+	for _, mergeCell := range mergeCells {
+		if err = layout.streamMergeCell(sw, mergeCell); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
+
+// readStreamTemplate reads the values, formulas, styles and heights of the template rows
+func readStreamTemplate(f *excelize.File, sheetName string) ([]streamRow, error) {
+	rows, err := f.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+
+	// Styled cells may lie beyond the last value, so the sheet dimension counts too
+	maxRow, maxCol := len(rows), 0
+	for _, row := range rows {
+		maxCol = max(maxCol, len(row))
+	}
+	if dimension, err := f.GetSheetDimension(sheetName); err == nil {
+		if _, lastCell, found := strings.Cut(dimension, ":"); found {
+			if col, row, err := excelize.CellNameToCoordinates(lastCell); err == nil {
+				maxRow, maxCol = max(maxRow, row), max(maxCol, col)
+			}
+		}
+	}
+
+	sheetRowHeight := defaultRowHeight
+	if props, err := f.GetSheetProps(sheetName); err == nil && props.DefaultRowHeight != nil {
+		sheetRowHeight = *props.DefaultRowHeight
+	}
+
+	template := make([]streamRow, maxRow)
+	for rowIdx := range template {
+		row := &template[rowIdx]
+		row.cells = make([]streamCell, maxCol)
+
+		if height, err := f.GetRowHeight(sheetName, rowIdx+1); err == nil && height != sheetRowHeight {
+			row.height = height
+		}
+		if visible, err := f.GetRowVisible(sheetName, rowIdx+1); err == nil {
+			row.hidden = !visible
+		}
+
+		for colIdx := range row.cells {
+			cellName, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+1)
+			cell := &row.cells[colIdx]
+			if rowIdx < len(rows) && colIdx < len(rows[rowIdx]) {
+				cell.value = rows[rowIdx][colIdx]
+			}
+			if cell.styleID, err = f.GetCellStyle(sheetName, cellName); err != nil {
+				return nil, err
+			}
+			if cell.formula, err = f.GetCellFormula(sheetName, cellName); err != nil {
+				return nil, err
+			}
+			if cell.value != "" {
+				if cell.cellType, err = f.GetCellType(sheetName, cellName); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return template, nil
+}
+
+// stripStreamBlockTag removes a block tag from the in-memory template cell
+func stripStreamBlockTag(template []streamRow, cellName, tag string) {
+	col, row, err := excelize.CellNameToCoordinates(cellName)
+	if err != nil || row > len(template) || col > len(template[row-1].cells) {
+		return
+	}
+	cell := &template[row-1].cells[col-1]
+	cell.value = strings.Replace(cell.value, tag, "", 1)
+}
+
+// streamValues returns the cell values of the template rows
+func streamValues(rows []streamRow) [][]string {
+	values := make([][]string, len(rows))
+	for i, row := range rows {
+		values[i] = make([]string, len(row.cells))
+		for j, cell := range row.cells {
+			values[i][j] = cell.value
+		}
+	}
+	return values
+}
+
+// withStreamValues returns copies of the template rows holding the values
+func withStreamValues(rows []streamRow, values [][]string) []streamRow {
+	result := make([]streamRow, len(rows))
+	for i, row := range rows {
+		result[i] = row
+		result[i].cells = append([]streamCell(nil), row.cells...)
+		for j := range result[i].cells {
+			result[i].cells[j].value = values[i][j]
+		}
+	}
+	return result
+}
+
+// streamRow renders a template row and writes it to the stream. blockIdx is
+// the block the row belongs to, -1 outside of blocks, itemIdx the item it is
+// rendered for.
+func (s *excelService) streamRow(rc *renderContext, sw *excelize.StreamWriter, sheetName string, row streamRow,
+	layout *streamLayout, templateRow, blockIdx, itemIdx int, scope *templateScope) error {
+	outRow := layout.outputRow(templateRow, blockIdx, itemIdx)
+
+	values := make([]interface{}, len(row.cells))
+	empty := true
+	for colIdx, cell := range row.cells {
+		if cell.formula != "" {
+			values[colIdx] = excelize.Cell{StyleID: cell.styleID, Formula: layout.adjustFormula(cell.formula, blockIdx, itemIdx)}
+			empty = false
+			continue
+		}
+
+		cellName, _ := excelize.CoordinatesToCellName(colIdx+1, outRow)
+		value, err := s.streamCellValue(rc, sheetName, cellName, cell, scope)
+		if err != nil {
+			return err
+		}
+		if value.Value != nil || value.StyleID != 0 {
+			values[colIdx] = value
+			empty = false
+		}
+	}
+
+	if empty && row.height == 0 && !row.hidden {
+		return nil
+	}
+	firstCell, _ := excelize.CoordinatesToCellName(1, outRow)
+	return sw.SetRow(firstCell, values, excelize.RowOpts{Height: row.height, Hidden: row.hidden})
+}
+
+// streamCellValue renders the value of a template cell. Placeholders are
+// replaced as in the regular render path, pictures are anchored at the
+// generated cell.
+func (s *excelService) streamCellValue(rc *renderContext, sheetName, cellName string, cell streamCell, scope *templateScope) (excelize.Cell, error) {
+	result := excelize.Cell{StyleID: cell.styleID}
+	text := cell.value
+
+	if !placeholderPattern.MatchString(text) {
+		if text == "" {
+			return result, nil
+		}
+		result.Value = rawCellValue(text, cell.cellType)
+		return result, nil
+	}
+
+	if picturePlaceholderPattern.MatchString(text) {
+		text = s.insertPictures(rc, sheetName, cellName, text, scope)
+	}
+
+	if exprText, ok := singlePlaceholderExpr(text); ok {
+		if value, found := s.evaluatePlaceholder(exprText, scope); found {
+			value, styleID, err := s.typedValue(rc, cell.styleID, value)
+			if err != nil {
+				return result, err
+			}
+			result.Value, result.StyleID = value, styleID
+			return result, nil
+		}
+	}
+
+	loc := TemplatePlaceholder{Sheet: sheetName, Cell: cellName, Part: partCell}
+	if text = s.replacePlaceholders(rc, loc, text, scope); text != "" {
+		result.Value = text
+	}
+	return result, nil
+}
+
+// blockAt returns the index of the block holding the template row, -1 if none
+func (l *streamLayout) blockAt(templateRow int) int {
+	for idx, block := range l.blocks {
+		if templateRow >= block.startRow && templateRow <= block.endRow {
+			return idx
+		}
+	}
+	return -1
+}
+
+// rowShift returns how many rows the blocks above the template row add or remove
+func (l *streamLayout) rowShift(templateRow int) int {
+	shift := 0
+	for idx, block := range l.blocks {
+		if block.endRow < templateRow {
+			shift += (len(l.items[idx]) - 1) * block.height()
+		}
+	}
+	return shift
+}
+
+// outputRow maps a template row to the generated row. Rows of a block are
+// mapped to the copy for the given item.
+func (l *streamLayout) outputRow(templateRow, blockIdx, itemIdx int) int {
+	row := templateRow + l.rowShift(templateRow)
+	if blockIdx >= 0 {
+		row += itemIdx * l.blocks[blockIdx].height()
+	}
+	return row
+}
+
+// adjustFormula moves the cell references of a template formula to the
+// generated rows. References into the block of the row point to the same
+// item, ranges covering exactly the template rows of a block are stretched
+// over all generated rows of the block.
+func (l *streamLayout) adjustFormula(formula string, blockIdx, itemIdx int) string {
+	// Stretch ranges first, so that their ends are not moved on their own
+	var ranges []string
+	formula = formulaRangePattern.ReplaceAllStringFunc(formula, func(match string) string {
+		parts := formulaRangePattern.FindStringSubmatch(match)
+		if parts[1] == "!" {
+			return match
+		}
+		startRow, _ := strconv.Atoi(parts[3])
+		endRow, _ := strconv.Atoi(parts[5])
+
+		for idx, block := range l.blocks {
+			if idx == blockIdx || startRow < block.startRow || endRow > block.endRow {
+				continue
+			}
+			if len(l.items[idx]) == 0 {
+				break
+			}
+			first := l.outputRow(startRow, idx, 0)
+			last := l.outputRow(endRow, idx, len(l.items[idx])-1)
+			ranges = append(ranges, fmt.Sprintf("%s%d:%s%d", parts[2], first, parts[4], last))
+			return fmt.Sprintf("\x00%d\x00", len(ranges)-1)
+		}
+		return match
+	})
+
+	formula = replaceFormulaRefs(formula, func(col string, row int) string {
+		target := -1
+		if idx := l.blockAt(row); idx == blockIdx {
+			target = idx
+		}
+		if target >= 0 {
+			return fmt.Sprintf("%s%d", col, l.outputRow(row, target, itemIdx))
+		}
+		return fmt.Sprintf("%s%d", col, l.outputRow(row, -1, 0))
+	})
+
+	for idx, rng := range ranges {
+		formula = strings.Replace(formula, fmt.Sprintf("\x00%d\x00", idx), rng, 1)
+	}
+	return formula
+}
+
+// replaceFormulaRefs replaces the cell references of a formula outside of
+// string literals, skipping references to other sheets and function names
+func replaceFormulaRefs(formula string, replace func(col string, row int) string) string {
+	var result strings.Builder
+	inString := false
+	last, skippedEnd := 0, -1
+
+	for _, loc := range formulaCellPattern.FindAllStringSubmatchIndex(formula, -1) {
+		start, end := loc[0], loc[1]
+		inString = inString != (strings.Count(formula[last:start], `"`)%2 == 1)
+		result.WriteString(formula[last:start])
+		last = end
+
+		prev, next := byte(0), byte(0)
+		if start > 0 {
+			prev = formula[start-1]
+		}
+		if end < len(formula) {
+			next = formula[end]
+		}
+		// The end of a range on another sheet follows the skipped start, e.g. Sheet2!A1:A5
+		otherSheet := prev == '!' || (prev == ':' && start-1 == skippedEnd)
+		if inString || otherSheet || isNameChar(prev) || isNameChar(next) || next == '(' {
+			if otherSheet {
+				skippedEnd = end
+			}
+			result.WriteString(formula[start:end])
+			continue
+		}
+
+		row, _ := strconv.Atoi(formula[loc[4]:loc[5]])
+		result.WriteString(replace(formula[loc[2]:loc[3]], row))
+	}
+	result.WriteString(formula[last:])
+	return result.String()
+}
+
+// streamMergeCell writes a template merged range for the generated rows.
+// Ranges inside a block are repeated for every item, ranges crossing a
+// block boundary are dropped.
+func (l *streamLayout) streamMergeCell(sw *excelize.StreamWriter, mergeCell excelize.MergeCell) error {
+	startCol, startRow, err := excelize.CellNameToCoordinates(mergeCell.GetStartAxis())
+	if err != nil {
+		return err
+	}
+	endCol, endRow, err := excelize.CellNameToCoordinates(mergeCell.GetEndAxis())
+	if err != nil {
+		return err
+	}
+
+	blockIdx := l.blockAt(startRow)
+	if blockIdx != l.blockAt(endRow) {
+		utils.LogError("Merged range %s crosses a repeating block and is dropped", mergeCell[0])
+		return nil
+	}
+
+	count := 1
+	if blockIdx >= 0 {
+		count = len(l.items[blockIdx])
+	}
+	for itemIdx := 0; itemIdx < count; itemIdx++ {
+		topLeft, _ := excelize.CoordinatesToCellName(startCol, l.outputRow(startRow, blockIdx, itemIdx))
+		bottomRight, _ := excelize.CoordinatesToCellName(endCol, l.outputRow(endRow, blockIdx, itemIdx))
+		if err = sw.MergeCell(topLeft, bottomRight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawCellValue converts a raw template cell value back to its type
+func rawCellValue(text string, cellType excelize.CellType) interface{} {
+	switch cellType {
+	case excelize.CellTypeBool:
+		return text == "1"
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			return number
+		}
+	}
+	return text
+}
+
+// isNameChar reports whether a character may be part of a name next to a cell reference
+func isNameChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
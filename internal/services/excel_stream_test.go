@@ -0,0 +1,204 @@
+package services
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestStreamSheetMatchesInMemoryRendering(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := writeStreamTemplate(t, dir)
+	act := testAct(testCosts(3)...)
+
+	render := func(threshold int, name string) *excelize.File {
+		service := NewExcelService(&config.Config{StreamingThreshold: threshold})
+		outputPath := filepath.Join(dir, name)
+		if err := service.GenerateAct(act, templatePath, outputPath, RenderOptions{}); err != nil {
+			t.Fatalf("GenerateAct() error = %v", err)
+		}
+		return openTestWorkbook(t, outputPath)
+	}
+	inMemory := render(0, "in_memory.xlsx")
+	streamed := render(1, "streamed.xlsx")
+	sheet := streamed.GetSheetName(0)
+
+	for _, cell := range []string{"A1", "A2", "B2", "A3", "B3", "A5", "B5", "A6", "B6", "A7", "A8"} {
+		want, _ := inMemory.GetCellValue(sheet, cell)
+		got, err := streamed.GetCellValue(sheet, cell)
+		if err != nil || got != want {
+			t.Errorf("cell %s = %q, want %q", cell, got, want)
+		}
+	}
+
+	formula, _ := streamed.GetCellFormula(sheet, "B6")
+	if want := "SUM(B3:B5)*$B$2+B3"; formula != want {
+		t.Errorf("formula = %q, want %q", formula, want)
+	}
+
+	mergeCells, err := streamed.GetMergeCells(sheet)
+	if err != nil || len(mergeCells) != 1 || mergeCells[0].GetStartAxis() != "A7" || mergeCells[0].GetEndAxis() != "C7" {
+		t.Errorf("merged cells = %v, want A7:C7", mergeCells)
+	}
+	if height, _ := streamed.GetRowHeight(sheet, 7); height != 30 {
+		t.Errorf("row height = %v, want 30", height)
+	}
+
+	cellType, _ := streamed.GetCellType(sheet, "B4")
+	if cellType == excelize.CellTypeSharedString || cellType == excelize.CellTypeInlineString {
+		t.Errorf("cost cell type = %v, want a number", cellType)
+	}
+}
+
+func TestStreamSheetSkipsAnchoredObjects(t *testing.T) {
+	dir := t.TempDir()
+	qr, err := utils.EncodeQRPNG("https://example.com/act", qrModuleSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		edit func(f *excelize.File, sheet string) error
+	}{
+		{name: "hyperlink", edit: func(f *excelize.File, sheet string) error {
+			return f.SetCellHyperLink(sheet, "A4", "https://example.com", "External")
+		}},
+		{name: "comment", edit: func(f *excelize.File, sheet string) error {
+			return f.AddComment(sheet, excelize.Comment{Cell: "A4", Author: "Author", Text: "Total of the act"})
+		}},
+		{name: "picture", edit: func(f *excelize.File, sheet string) error {
+			return f.AddPictureFromBytes(sheet, "C4", &excelize.Picture{Extension: ".png", File: qr})
+		}},
+		{name: "shape", edit: func(f *excelize.File, sheet string) error {
+			return f.AddShape(sheet, &excelize.Shape{Cell: "C4", Type: "rect", Width: 80, Height: 20})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templatePath := filepath.Join(dir, tt.name+".xlsx")
+			writeTestWorkbook(t, templatePath, map[string]interface{}{
+				"A3": "{{#positions}}{{@number}}",
+				"B3": "{{currentPeriodCost}}{{/positions}}",
+				"A4": "Total",
+			}, tt.edit)
+
+			compiled, err := newTemplateCache(0).load(templatePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sheet := compiled.sheets["Sheet1"]; !sheet.anchored {
+				t.Errorf("sheet with a %s is not anchored", tt.name)
+			}
+
+			// The sheet is rendered in memory, so the object moves with its row
+			service := NewExcelService(&config.Config{StreamingThreshold: 1})
+			outputPath := filepath.Join(dir, tt.name+"_act.xlsx")
+			if err = service.GenerateAct(testAct(testCosts(3)...), templatePath, outputPath, RenderOptions{}); err != nil {
+				t.Fatalf("GenerateAct() error = %v", err)
+			}
+			f := openTestWorkbook(t, outputPath)
+			switch tt.name {
+			case "hyperlink":
+				if hasLink, _, _ := f.GetCellHyperLink("Sheet1", "A6"); !hasLink {
+					t.Error("hyperlink did not move to A6")
+				}
+			case "comment":
+				comments, err := f.GetComments("Sheet1")
+				if err != nil || len(comments) != 1 || comments[0].Cell != "A6" || comments[0].Text != "Total of the act" {
+					t.Errorf("comments = %+v, want one at A6", comments)
+				}
+			case "picture":
+				if cells, _ := f.GetPictureCells("Sheet1"); len(cells) != 1 || cells[0] != "C6" {
+					t.Errorf("pictures = %v, want one at C6", cells)
+				}
+			}
+		})
+	}
+
+	// The plain stream template has none of them
+	compiled, err := newTemplateCache(0).load(writeStreamTemplate(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compiled.sheets["Sheet1"].anchored {
+		t.Error("plain sheet is anchored")
+	}
+}
+
+// BenchmarkGenerateActStreaming renders acts of up to 100k positions with
+// the stream writer, and up to 10k in memory for comparison, as inserting
+// rows makes larger acts too slow there. Allocations grow with the act
+// either way, since every position is turned into template data; peak-MB
+// reports the largest heap seen while rendering, which the stream writer
+// keeps lower by flushing generated rows to a temporary file instead of
+// keeping them in the workbook.
+func BenchmarkGenerateActStreaming(b *testing.B) {
+	dir := b.TempDir()
+	templatePath := writeStreamTemplate(b, dir)
+
+	for _, positions := range []int{1000, 10000, 100000} {
+		act := testAct(testCosts(positions)...)
+		for _, mode := range []struct {
+			name      string
+			threshold int
+		}{
+			{name: "in-memory", threshold: 0},
+			{name: "streamed", threshold: 1},
+		} {
+			if mode.threshold == 0 && positions > 10000 {
+				continue
+			}
+			service := NewExcelService(&config.Config{StreamingThreshold: mode.threshold})
+			b.Run(strconv.Itoa(positions)+"/"+mode.name, func(b *testing.B) {
+				b.ReportAllocs()
+				var peak uint64
+				for i := 0; i < b.N; i++ {
+					heap := measurePeakHeap(func() {
+						if err := service.GenerateAct(act, templatePath, filepath.Join(dir, "act.xlsx"), RenderOptions{}); err != nil {
+							b.Fatal(err)
+						}
+					})
+					peak = max(peak, heap)
+				}
+				b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+			})
+		}
+	}
+}
+
+// measurePeakHeap runs the function and samples the heap in use while it
+// runs, returning the largest amount seen above the heap before the call
+func measurePeakHeap(run func()) uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	base, peak := stats.HeapInuse, stats.HeapInuse
+
+	done := make(chan struct{})
+	sampled := make(chan uint64)
+	go func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			peak = max(peak, stats.HeapInuse)
+			select {
+			case <-done:
+				sampled <- peak
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	run()
+	close(done)
+	return <-sampled - base
+}
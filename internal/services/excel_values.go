@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Pattern to match a cell holding a single placeholder and nothing else
+var singlePlaceholderPattern = regexp.MustCompile(`^\s*\{\{([^}]+)\}\}\s*$`)
+
+// Value kinds that need a number format on the cell
+const (
+	numberValueKind  = "number"
+	integerValueKind = "integer"
+	dateValueKind    = "date"
+)
+
+// Number format applied to typed numbers when the template cell has the
+// General format, matching the text produced by formatValue. Dates get the
+// short date format of the locale.
+const (
+	defaultNumberFormat  = 4 // #,##0.00
+	defaultIntegerFormat = 3 // #,##0
+)
+
+// singlePlaceholderExpr returns the placeholder expression if the text is a
+// single placeholder
+func singlePlaceholderExpr(text string) (string, bool) {
+	match := singlePlaceholderPattern.FindStringSubmatch(text)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// setCellTypedValue writes a value into the cell as a number, date, bool or
+// string, keeping the number format of the template cell
+func (s *excelService) setCellTypedValue(rc *renderContext, sheetName, cellName string, value interface{}) error {
+	styleID, err := rc.file.GetCellStyle(sheetName, cellName)
+	if err != nil {
+		return err
+	}
+
+	value, valueStyle, err := s.typedValue(rc, styleID, value)
+	if err != nil {
+		return err
+	}
+
+	err = rc.file.SetCellValue(sheetName, cellName, value)
+	if err != nil {
+		return err
+	}
+
+	// Setting a date resets the number format, so the style is always restored
+	if _, isDate := value.(time.Time); !isDate && valueStyle == styleID {
+		return nil
+	}
+	return rc.file.SetCellStyle(sheetName, cellName, cellName, valueStyle)
+}
+
+// typedValue converts a placeholder value into a value excelize writes as
+// a number, date, bool or string and returns the style the cell needs for it
+func (s *excelService) typedValue(rc *renderContext, styleID int, value interface{}) (interface{}, int, error) {
+	var kind string
+	switch v := value.(type) {
+	case float64, float32:
+		kind = numberValueKind
+	case int, int32, int64:
+		kind = integerValueKind
+	case models.Money:
+		value = v.Float64()
+		kind = numberValueKind
+	case models.Quantity:
+		value = v.Float64()
+		kind = numberValueKind
+	case time.Time:
+		kind = dateValueKind
+	case primitive.DateTime:
+		value = v.Time()
+		kind = dateValueKind
+	case bool, string, nil:
+	default:
+		value = s.formatValue(v, rc.locale())
+	}
+	if kind == "" {
+		return value, styleID, nil
+	}
+
+	valueStyle, err := s.valueStyle(rc, styleID, kind)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, valueStyle, nil
+}
+
+// valueStyle returns the style for a typed value written into a cell with
+// the given template style. Styles with a number format are kept as is,
+// General styles get the default format of the value kind.
+func (s *excelService) valueStyle(rc *renderContext, styleID int, kind string) (int, error) {
+	cacheKey := fmt.Sprintf("%d:%s", styleID, kind)
+	if cached, ok := rc.valueStyles[cacheKey]; ok {
+		return cached, nil
+	}
+
+	style, err := rc.file.GetStyle(styleID)
+	if err != nil {
+		return 0, err
+	}
+
+	result := styleID
+	if style.NumFmt == 0 && style.CustomNumFmt == nil {
+		switch kind {
+		case numberValueKind:
+			style.NumFmt = defaultNumberFormat
+		case integerValueKind:
+			style.NumFmt = defaultIntegerFormat
+		case dateValueKind:
+			dateFormat := rc.locale().ExcelDateFormat()
+			style.CustomNumFmt = &dateFormat
+		}
+		result, err = rc.file.NewStyle(style)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	rc.valueStyles[cacheKey] = result
+	return result, nil
+}
+
+// setCellString writes a text into the cell, clearing the cell value for an
+// empty text while keeping its style
+func setCellString(f *excelize.File, sheetName, cellName, value string) error {
+	if value == "" {
+		return f.SetCellValue(sheetName, cellName, nil)
+	}
+	return f.SetCellValue(sheetName, cellName, value)
+}
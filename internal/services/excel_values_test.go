@@ -0,0 +1,170 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTypedCellValues(t *testing.T) {
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.xlsx")
+	writeTestWorkbook(t, templatePath, map[string]interface{}{
+		"A1": "{{totalCost}}",
+		"A2": "{{totalCost}}",
+		"A3": "{{isDraft}}",
+		"A4": "Total: {{totalCost}}",
+		"A5": "{{customer}}",
+		"A6": "{{positions[0].currentPeriodCost}}",
+	}, func(f *excelize.File, sheet string) error {
+		// A2 has its own number format, #,##0
+		style, err := f.NewStyle(&excelize.Style{NumFmt: 3})
+		if err != nil {
+			return err
+		}
+		return f.SetCellStyle(sheet, "A2", "A2", style)
+	})
+
+	act := testAct(100)
+	act.BigAct.TotalCost = models.NewMoney(1234, 50)
+	outputPath := filepath.Join(dir, "act.xlsx")
+	if err := NewExcelService(testConfig()).GenerateAct(act, templatePath, outputPath, RenderOptions{}); err != nil {
+		t.Fatalf("GenerateAct() error = %v", err)
+	}
+	f := openTestWorkbook(t, outputPath)
+	sheet := f.GetSheetName(0)
+
+	tests := []struct {
+		cell      string
+		raw       string
+		formatted string
+		cellType  excelize.CellType
+		numFmt    int
+	}{
+		{cell: "A1", raw: "1234.5", formatted: "1,234.50", numFmt: defaultNumberFormat},
+		{cell: "A2", raw: "1234.5", formatted: "1,235", numFmt: 3},
+		{cell: "A3", raw: "1", formatted: "TRUE", cellType: excelize.CellTypeBool},
+		{cell: "A4", raw: "Total: 1,234.50", formatted: "Total: 1,234.50", cellType: excelize.CellTypeSharedString},
+		{cell: "A5", raw: "Customer", formatted: "Customer", cellType: excelize.CellTypeSharedString},
+		{cell: "A6", raw: "1", formatted: "1.00", numFmt: defaultNumberFormat},
+	}
+
+	for _, tt := range tests {
+		raw, err := f.GetCellValue(sheet, tt.cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted, err := f.GetCellValue(sheet, tt.cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw != tt.raw || formatted != tt.formatted {
+			t.Errorf("%s = %q shown as %q, expected %q shown as %q", tt.cell, raw, formatted, tt.raw, tt.formatted)
+		}
+
+		cellType, err := f.GetCellType(sheet, tt.cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cellType != tt.cellType {
+			t.Errorf("%s type = %v, expected %v", tt.cell, cellType, tt.cellType)
+		}
+		if tt.numFmt == 0 {
+			continue
+		}
+		styleID, err := f.GetCellStyle(sheet, tt.cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		style, err := f.GetStyle(styleID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if style.NumFmt != tt.numFmt {
+			t.Errorf("%s number format = %d, expected %d", tt.cell, style.NumFmt, tt.numFmt)
+		}
+	}
+}
+
+func TestTypedValue(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	rc := newRenderContext(f, nil, RenderOptions{})
+	service := NewExcelService(testConfig()).(*excelService)
+	date := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+		styled   bool
+	}{
+		{name: "int", value: 3, expected: 3, styled: true},
+		{name: "money", value: models.NewMoney(12, 5), expected: 12.05, styled: true},
+		{name: "quantity", value: models.Quantity(1500), expected: 1.5, styled: true},
+		{name: "time", value: date, expected: date, styled: true},
+		{name: "BSON date", value: primitive.NewDateTimeFromTime(date), expected: date.Local(), styled: true},
+		{name: "bool", value: true, expected: true},
+		{name: "string", value: "text", expected: "text"},
+		{name: "nil", value: nil, expected: nil},
+		{name: "other values as text", value: []string{"a", "b"}, expected: service.formatValue([]string{"a", "b"}, utils.DefaultLocale)},
+	}
+
+	for _, tt := range tests {
+		value, styleID, err := service.typedValue(rc, 0, tt.value)
+		if err != nil {
+			t.Fatalf("%s: typedValue() error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("%s: value = %#v, expected %#v", tt.name, value, tt.expected)
+		}
+		if (styleID != 0) != tt.styled {
+			t.Errorf("%s: style = %d, expected a number format %v", tt.name, styleID, tt.styled)
+		}
+	}
+}
+
+func TestTypedDateFollowsLocale(t *testing.T) {
+	service := NewExcelService(testConfig())
+	act := testAct(100)
+	act.CreatedAt = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		locale   *utils.Locale
+		expected string
+	}{
+		{locale: utils.DefaultLocale, expected: "17.10.2026"},
+		{locale: utils.RussianLocale, expected: "17.10.2026"},
+		{locale: utils.EnglishLocale, expected: "10/17/2026"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale.Name, func(t *testing.T) {
+			f := renderTestTemplate(t, service, map[string]interface{}{"A1": "{{createdAt}}"}, act, RenderOptions{Locale: tt.locale})
+			sheet := f.GetSheetName(0)
+
+			value, err := f.GetCellValue(sheet, "A1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.expected {
+				t.Errorf("A1 = %q, expected %q", value, tt.expected)
+			}
+			// The cell holds the date serial, not a text
+			raw, err := f.GetCellValue(sheet, "A1", excelize.Options{RawCellValue: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = strconv.ParseFloat(raw, 64); err != nil {
+				t.Errorf("A1 holds %q, expected a date serial", raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import "github.com/xuri/excelize/v2"
+
+// Look of the watermark text box
+const (
+	watermarkFontSize = 72
+	watermarkColor    = "C0C0C0"
+	watermarkWidth    = 640
+	watermarkHeight   = 120
+)
+
+// addWatermark lays a transparent text box with the watermark over the
+// top of the sheet. The PDF export does not read shapes, it is given the
+// watermark with PDFOptions instead.
+func addWatermark(f *excelize.File, sheetName, text string) error {
+	lineWidth := 0.0
+	return f.AddShape(sheetName, &excelize.Shape{
+		Cell:   "B2",
+		Type:   "rect",
+		Width:  watermarkWidth,
+		Height: watermarkHeight,
+		Format: excelize.GraphicOptions{AltText: text},
+		Fill:   excelize.Fill{Color: []string{"FFFFFF"}, Transparency: 100},
+		Line:   excelize.ShapeLine{Color: "FFFFFF", Width: &lineWidth},
+		Paragraph: []excelize.RichTextRun{{
+			Text: text,
+			Font: &excelize.Font{Bold: true, Size: watermarkFontSize, Color: watermarkColor},
+		}},
+	})
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestGenerateActWithWatermark(t *testing.T) {
+	dir := t.TempDir()
+	templatePath, err := formTemplatePath(dir, FormKS2)
+	if err != nil {
+		t.Fatalf("formTemplatePath() error = %v", err)
+	}
+
+	fontPath := testPDFFontPath(t)
+	service := NewExcelService(&config.Config{PDFFontPath: fontPath})
+	// The template has a defined name of its own called Watermark
+	f := openTestWorkbook(t, templatePath)
+	if err = f.SetDefinedName(&excelize.DefinedName{Name: "Watermark", RefersTo: "Sheet1!$A$1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.SaveAs(templatePath); err != nil {
+		t.Fatal(err)
+	}
+
+	workbookPath := filepath.Join(dir, "act.xlsx")
+	opts := RenderOptions{MissingKeyPolicy: MissingKeyError, Watermark: `DRAFT "1"`}
+	if err = service.GenerateAct(testAct(100, 200), templatePath, workbookPath, opts); err != nil {
+		t.Fatalf("GenerateAct() error = %v", err)
+	}
+
+	// The watermark leaves no defined names in the workbook
+	f = openTestWorkbook(t, workbookPath)
+	names := f.GetDefinedName()
+	if len(names) != 1 || names[0].RefersTo != "Sheet1!$A$1" {
+		t.Errorf("defined names = %+v, expected only the one of the template", names)
+	}
+
+	// The PDF export draws the watermark it is given
+	pdfPath := filepath.Join(dir, "act.pdf")
+	if err = service.ExportPDF(workbookPath, pdfPath, PDFOptions{Watermark: opts.Watermark}); err != nil {
+		t.Fatalf("ExportPDF() error = %v", err)
+	}
+	content, err := os.ReadFile(pdfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The form is Cyrillic, so the text is written as glyphs of the font
+	face, err := loadTrueTypeFace(fontPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := strings.Join(pdfStreams(t, content), "\n")
+	if !strings.Contains(page, newTrueTypeFont(face).encode(`DRAFT "1"`)+" Tj ET Q") {
+		t.Errorf("page content does not contain the watermark")
+	}
+}
+
+func TestPDFSheetWatermark(t *testing.T) {
+	opts := PDFOptions{Watermark: "DRAFT", SheetWatermarks: map[string]string{"Акт 1": "", "Акт 2": "SUBMITTED"}}
+	for sheet, expected := range map[string]string{"Акт 1": "", "Акт 2": "SUBMITTED", "Сводка": "DRAFT"} {
+		if text := opts.sheetWatermark(sheet); text != expected {
+			t.Errorf("watermark of %s = %q, expected %q", sheet, text, expected)
+		}
+	}
+}
+
+func TestStatusTemplateData(t *testing.T) {
+	signedAt := time.Date(2026, time.March, 5, 0, 0, 0, 0, time.UTC)
+	act := testAct(100, 200)
+	act.Status = models.ActStatusSigned
+	act.StatusHistory = []models.StatusChange{
+		{From: models.ActStatusDraft, To: models.ActStatusSubmitted, Actor: "contractor"},
+		{From: models.ActStatusSubmitted, To: models.ActStatusDraft, Actor: "customer"},
+		{From: models.ActStatusDraft, To: models.ActStatusSubmitted, Actor: "contractor"},
+		{From: models.ActStatusSubmitted, To: models.ActStatusApproved, Actor: "customer"},
+		{From: models.ActStatusApproved, To: models.ActStatusSigned, Actor: "customer", At: signedAt},
+	}
+
+	service := NewExcelService(&config.Config{}).(*excelService)
+	data := service.buildTemplateData(act)
+	if data["status"] != "signed" || data["isSigned"] != true || data["isDraft"] != false || data["signedAt"] != signedAt {
+		t.Errorf("status data = %v, %v, %v, %v; expected a signed act", data["status"], data["isSigned"], data["isDraft"], data["signedAt"])
+	}
+	if _, ok := data["draftAt"]; ok {
+		t.Error("draftAt is not expected in the data")
+	}
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// FormType selects a built-in standard form an act is generated as
+type FormType string
+
+// Built-in forms
+const (
+	// FormKS2 is the unified form KS-2, акт о приёмке выполненных работ
+	FormKS2 FormType = "ks2"
+	// FormKS3 is the unified form KS-3, справка о стоимости выполненных работ и затрат
+	FormKS3 FormType = "ks3"
+)
+
+// ParseFormType validates a form name. An empty name is returned as an
+// empty form, meaning that the act template is used.
+func ParseFormType(name string) (FormType, error) {
+	switch form := FormType(strings.ToLower(strings.TrimSpace(name))); form {
+	case "", FormKS2, FormKS3:
+		return form, nil
+	default:
+		return "", fmt.Errorf("unknown form %q, expected ks2 or ks3", name)
+	}
+}
+
+// formTemplates holds the paths of the form templates written by this
+// process. Forms are written once per process, so a new version of the
+// service replaces the files of the previous one.
+var formTemplates = struct {
+	sync.Mutex
+	written map[string]bool
+}{
+	written: make(map[string]bool),
+}
+
+// formTemplatePath returns the path of the built-in template of the form,
+// writing it into the directory on first use
+func formTemplatePath(dir string, form FormType) (string, error) {
+	formTemplates.Lock()
+	defer formTemplates.Unlock()
+
+	path := filepath.Join(dir, string(form)+".xlsx")
+	if formTemplates.written[path] {
+		return path, nil
+	}
+
+	var build func(f *excelize.File) error
+	switch form {
+	case FormKS2:
+		build = buildKS2Template
+	case FormKS3:
+		build = buildKS3Template
+	default:
+		return "", fmt.Errorf("unknown form %q", form)
+	}
+
+	f := excelize.NewFile()
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			utils.LogError("Error closing Excel file: %v", closeErr)
+		}
+	}()
+	if err := build(f); err != nil {
+		return "", fmt.Errorf("failed to build %s template: %w", form, err)
+	}
+
+	// The template is written under a temporary name and moved in place, so
+	// other instances sharing the directory never read a partial file
+	utils.LogInfo("Writing %s form template to %s", form, path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return "", err
+	}
+	tempPath, err := writeTempFile(dir, buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	if err = os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	formTemplates.written[path] = true
+	return path, nil
+}
+
+// formSheet writes the cells of a form template and keeps the first error
+type formSheet struct {
+	f      *excelize.File
+	name   string
+	styles formStyles
+	err    error
+}
+
+// formStyles are the cell styles used by the form templates
+type formStyles struct {
+	title    int
+	caption  int
+	label    int
+	value    int
+	header   int
+	cell     int
+	money    int
+	totalRow int
+	quantity int
+}
+
+// newFormSheet renames the default sheet of the file and creates the form styles
+func newFormSheet(f *excelize.File, name string, widths map[string]float64) (*formSheet, error) {
+	if err := f.SetSheetName(f.GetSheetName(0), name); err != nil {
+		return nil, err
+	}
+	for col, width := range widths {
+		if err := f.SetColWidth(name, col, col, width); err != nil {
+			return nil, err
+		}
+	}
+
+	border := []excelize.Border{
+		{Type: "left", Color: "000000", Style: 1},
+		{Type: "top", Color: "000000", Style: 1},
+		{Type: "right", Color: "000000", Style: 1},
+		{Type: "bottom", Color: "000000", Style: 1},
+	}
+	moneyFormat := 4 // #,##0.00
+	quantityFormat := "#,##0.000"
+	definitions := []*excelize.Style{
+		{Font: &excelize.Font{Bold: true, Size: 12}, Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true}},
+		{Font: &excelize.Font{Size: 7}, Alignment: &excelize.Alignment{Horizontal: "right"}},
+		{Font: &excelize.Font{Size: 9}, Alignment: &excelize.Alignment{Vertical: "center"}},
+		{Font: &excelize.Font{Size: 9}, Alignment: &excelize.Alignment{Vertical: "center", WrapText: true},
+			Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}}},
+		{Font: &excelize.Font{Size: 8}, Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true}, Border: border},
+		{Font: &excelize.Font{Size: 9}, Alignment: &excelize.Alignment{Vertical: "top", WrapText: true}, Border: border},
+		{Font: &excelize.Font{Size: 9}, Alignment: &excelize.Alignment{Horizontal: "right", Vertical: "top"}, Border: border, NumFmt: moneyFormat},
+		{Font: &excelize.Font{Size: 9, Bold: true}, Alignment: &excelize.Alignment{Horizontal: "right", Vertical: "top"}, Border: border, NumFmt: moneyFormat},
+		{Font: &excelize.Font{Size: 9}, Alignment: &excelize.Alignment{Horizontal: "right", Vertical: "top"}, Border: border, CustomNumFmt: &quantityFormat},
+	}
+
+	ids := make([]int, len(definitions))
+	for i, definition := range definitions {
+		id, err := f.NewStyle(definition)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+
+	return &formSheet{
+		f:    f,
+		name: name,
+		styles: formStyles{
+			title: ids[0], caption: ids[1], label: ids[2], value: ids[3],
+			header: ids[4], cell: ids[5], money: ids[6], totalRow: ids[7],
+			quantity: ids[8],
+		},
+	}, nil
+}
+
+// set writes a value with a style, merging the cell up to mergeTo when it is set
+func (fs *formSheet) set(cell, mergeTo string, value interface{}, style int) {
+	if fs.err != nil {
+		return
+	}
+	if fs.err = fs.f.SetCellValue(fs.name, cell, value); fs.err != nil {
+		return
+	}
+	last := cell
+	if mergeTo != "" {
+		last = mergeTo
+		if fs.err = fs.f.MergeCell(fs.name, cell, mergeTo); fs.err != nil {
+			return
+		}
+	}
+	fs.err = fs.f.SetCellStyle(fs.name, cell, last, style)
+}
+
+// formula writes a formula with a style
+func (fs *formSheet) formula(cell, formula string, style int) {
+	if fs.err != nil {
+		return
+	}
+	if fs.err = fs.f.SetCellFormula(fs.name, cell, formula); fs.err != nil {
+		return
+	}
+	fs.err = fs.f.SetCellStyle(fs.name, cell, cell, style)
+}
+
+// row sets the height of a row
+func (fs *formSheet) row(row int, height float64) {
+	if fs.err == nil {
+		fs.err = fs.f.SetRowHeight(fs.name, row, height)
+	}
+}
+
+// parties writes the header of the form with the parties of the contract,
+// starting at the given row, and returns the next free row
+func (fs *formSheet) parties(row int, lastCol string) int {
+	st := fs.styles
+	for _, party := range [][2]string{
+		{"Инвестор", `{{investor | default:""}}`},
+		{"Заказчик (Генподрядчик)", "{{customer}}"},
+		{"Подрядчик (Субподрядчик)", "{{contractor}}"},
+		{"Стройка", `{{constructionName | default:""}}`},
+		{"Объект", "{{objectName}}"},
+	} {
+		fs.set(fmt.Sprintf("A%d", row), fmt.Sprintf("B%d", row), party[0], st.label)
+		fs.set(fmt.Sprintf("C%d", row), fmt.Sprintf("%s%d", lastCol, row), party[1], st.value)
+		row++
+	}
+	fs.set(fmt.Sprintf("A%d", row), fmt.Sprintf("B%d", row), "Договор подряда (контракт)", st.label)
+	fs.set(fmt.Sprintf("C%d", row), fmt.Sprintf("%s%d", lastCol, row), "№ {{contractNumber}} от {{contractDate}}", st.value)
+	return row + 2
+}
+
+// document writes the number, date and reporting period table of the form
+// and its title, and returns the next free row
+func (fs *formSheet) document(row int, title, lastCol string) int {
+	st := fs.styles
+	fs.set(fmt.Sprintf("D%d", row), "", "Номер документа", st.header)
+	fs.set(fmt.Sprintf("E%d", row), "", "Дата составления", st.header)
+	fs.set(fmt.Sprintf("F%d", row), "", "Отчетный период с", st.header)
+	fs.set(fmt.Sprintf("G%d", row), "", "по", st.header)
+	fs.set(fmt.Sprintf("D%d", row+1), "", `{{documentNumber | default:""}}`, st.cell)
+	fs.set(fmt.Sprintf("E%d", row+1), "", `{{createdAt | date}}`, st.cell)
+	fs.set(fmt.Sprintf("F%d", row+1), "", `{{periodFrom | default:""}}`, st.cell)
+	fs.set(fmt.Sprintf("G%d", row+1), "", `{{periodTo | default:""}}`, st.cell)
+
+	fs.set(fmt.Sprintf("A%d", row+3), fmt.Sprintf("%s%d", lastCol, row+3), title, st.title)
+	fs.row(row+3, 24)
+	return row + 5
+}
+
+// signatures writes the signature lines of the form
+func (fs *formSheet) signatures(row int, lines [][2]string) {
+	st := fs.styles
+	for _, line := range lines {
+		fs.set(fmt.Sprintf("A%d", row), fmt.Sprintf("B%d", row), line[0], st.label)
+		fs.set(fmt.Sprintf("C%d", row), fmt.Sprintf("D%d", row), "", st.value)
+		fs.set(fmt.Sprintf("E%d", row), fmt.Sprintf("G%d", row), line[1], st.value)
+		row += 2
+	}
+}
+
+// buildKS2Template builds the template of the unified form KS-2. Positions
+// make up the table rows, totals and VAT follow it.
+func buildKS2Template(f *excelize.File) error {
+	fs, err := newFormSheet(f, "КС-2", map[string]float64{
+		"A": 8, "B": 12, "C": 40, "D": 14, "E": 12, "F": 12, "G": 14, "H": 16,
+	})
+	if err != nil {
+		return err
+	}
+	st := fs.styles
+
+	fs.set("A1", "H1", "Унифицированная форма № КС-2", st.caption)
+	fs.set("A2", "H2", "Утверждена постановлением Госкомстата России от 11.11.99 № 100", st.caption)
+	row := fs.parties(4, "H")
+	row = fs.document(row, "АКТ О ПРИЕМКЕ ВЫПОЛНЕННЫХ РАБОТ", "H")
+
+	// Table header, the last three columns share the "performed" caption
+	headers := []string{
+		"Номер по порядку", "Номер позиции по смете", "Наименование работ",
+		"Номер единичной расценки", "Единица измерения",
+	}
+	for i, header := range headers {
+		col := string(rune('A' + i))
+		fs.set(fmt.Sprintf("%s%d", col, row), fmt.Sprintf("%s%d", col, row+1), header, st.header)
+	}
+	fs.set(fmt.Sprintf("F%d", row), fmt.Sprintf("H%d", row), "Выполнено работ", st.header)
+	fs.set(fmt.Sprintf("F%d", row+1), "", "количество", st.header)
+	fs.set(fmt.Sprintf("G%d", row+1), "", "цена за единицу, руб.", st.header)
+	fs.set(fmt.Sprintf("H%d", row+1), "", "стоимость, руб.", st.header)
+	fs.row(row+1, 36)
+	for i := 0; i < 8; i++ {
+		fs.set(fmt.Sprintf("%s%d", string(rune('A'+i)), row+2), "", i+1, st.header)
+	}
+
+	// One row per position
+	itemRow := row + 3
+	fs.set(fmt.Sprintf("A%d", itemRow), "", "{{#positions}}{{@number}}", st.cell)
+	fs.set(fmt.Sprintf("B%d", itemRow), "", `{{estimateLine | default:""}}`, st.cell)
+	fs.set(fmt.Sprintf("C%d", itemRow), "", `{{name | default:""}}`, st.cell)
+	fs.set(fmt.Sprintf("D%d", itemRow), "", `{{code | default:""}}`, st.cell)
+	fs.set(fmt.Sprintf("E%d", itemRow), "", `{{unit | default:""}}`, st.cell)
+	fs.set(fmt.Sprintf("F%d", itemRow), "", `{{quantity | default:""}}`, st.quantity)
+	fs.set(fmt.Sprintf("G%d", itemRow), "", `{{unitPrice | default:""}}`, st.money)
+	fs.set(fmt.Sprintf("H%d", itemRow), "", "{{currentPeriodCost}}{{/positions}}", st.money)
+
+	// Totals, VAT per rate and the total with VAT
+	fs.set(fmt.Sprintf("A%d", itemRow+1), fmt.Sprintf("G%d", itemRow+1), "Итого", st.label)
+	fs.formula(fmt.Sprintf("H%d", itemRow+1), fmt.Sprintf("SUM(H%d:H%d)", itemRow, itemRow), st.totalRow)
+	fs.set(fmt.Sprintf("A%d", itemRow+2), fmt.Sprintf("G%d", itemRow+2), "{{#vatBreakdown}}НДС {{rate}}", st.label)
+	fs.set(fmt.Sprintf("H%d", itemRow+2), "", "{{vat}}{{/vatBreakdown}}", st.money)
+	fs.set(fmt.Sprintf("A%d", itemRow+3), fmt.Sprintf("G%d", itemRow+3), "Всего с учетом НДС", st.label)
+	fs.set(fmt.Sprintf("H%d", itemRow+3), "", "{{totalWithVat}}", st.totalRow)
+
+	fs.signatures(itemRow+5, [][2]string{
+		{"Сдал", `{{contractorSigner | default:""}}`},
+		{"Принял", `{{customerSigner | default:""}}`},
+	})
+	return fs.err
+}
+
+// buildKS3Template builds the template of the unified form KS-3. The cost
+// columns hold the amounts since the start of construction, since the start
+// of the year and for the reporting period.
+func buildKS3Template(f *excelize.File) error {
+	fs, err := newFormSheet(f, "КС-3", map[string]float64{
+		"A": 8, "B": 16, "C": 40, "D": 10, "E": 18, "F": 18, "G": 18,
+	})
+	if err != nil {
+		return err
+	}
+	st := fs.styles
+
+	fs.set("A1", "G1", "Унифицированная форма № КС-3", st.caption)
+	fs.set("A2", "G2", "Утверждена постановлением Госкомстата России от 11.11.99 № 100", st.caption)
+	row := fs.parties(4, "G")
+	row = fs.document(row, "СПРАВКА О СТОИМОСТИ ВЫПОЛНЕННЫХ РАБОТ И ЗАТРАТ", "G")
+
+	fs.set(fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row+1), "Номер по порядку", st.header)
+	fs.set(fmt.Sprintf("B%d", row), fmt.Sprintf("C%d", row+1),
+		"Наименование пусковых комплексов, этапов, объектов, видов выполненных работ, оборудования, затрат", st.header)
+	fs.set(fmt.Sprintf("D%d", row), fmt.Sprintf("D%d", row+1), "Код", st.header)
+	fs.set(fmt.Sprintf("E%d", row), fmt.Sprintf("G%d", row), "Стоимость выполненных работ и затрат, руб.", st.header)
+	fs.set(fmt.Sprintf("E%d", row+1), "", "с начала проведения работ", st.header)
+	fs.set(fmt.Sprintf("F%d", row+1), "", "с начала года", st.header)
+	fs.set(fmt.Sprintf("G%d", row+1), "", "в том числе за отчетный период", st.header)
+	fs.row(row+1, 36)
+	fs.set(fmt.Sprintf("A%d", row+2), "", 1, st.header)
+	fs.set(fmt.Sprintf("B%d", row+2), fmt.Sprintf("C%d", row+2), 2, st.header)
+	for i, col := range []string{"D", "E", "F", "G"} {
+		fs.set(fmt.Sprintf("%s%d", col, row+2), "", i+3, st.header)
+	}
+
+	// Total of the works, then the positions of the reporting period
+	totalRow := row + 3
+	fs.set(fmt.Sprintf("A%d", totalRow), "", "", st.cell)
+	fs.set(fmt.Sprintf("B%d", totalRow), fmt.Sprintf("C%d", totalRow), "Всего работ и затрат, включаемых в стоимость работ", st.cell)
+	fs.set(fmt.Sprintf("D%d", totalRow), "", "", st.cell)
+	fs.set(fmt.Sprintf("E%d", totalRow), "", "{{sinceConstructionStart}}", st.totalRow)
+	fs.set(fmt.Sprintf("F%d", totalRow), "", "{{sinceYearStart}}", st.totalRow)
+	fs.set(fmt.Sprintf("G%d", totalRow), "", "{{totalCost}}", st.totalRow)
+
+	fs.set(fmt.Sprintf("A%d", totalRow+1), "", "", st.cell)
+	fs.set(fmt.Sprintf("B%d", totalRow+1), fmt.Sprintf("G%d", totalRow+1), "в том числе:", st.cell)
+
+	itemRow := totalRow + 2
+	fs.set(fmt.Sprintf("A%d", itemRow), "", "{{#positions}}{{@number}}", st.cell)
+	fs.set(fmt.Sprintf("B%d", itemRow), fmt.Sprintf("C%d", itemRow), `{{name | default:""}}`, st.cell)
+	fs.set(fmt.Sprintf("D%d", itemRow), "", `{{code | default:""}}`, st.cell)
+	fs.set(fmt.Sprintf("E%d", itemRow), "", "{{sinceConstructionStart}}", st.money)
+	fs.set(fmt.Sprintf("F%d", itemRow), "", "{{sinceYearStart}}", st.money)
+	fs.set(fmt.Sprintf("G%d", itemRow), "", "{{currentPeriodCost}}{{/positions}}", st.money)
+
+	// Totals, VAT and the totals with VAT for every column
+	for i, line := range [][4]string{
+		{"Итого", "{{sinceConstructionStart}}", "{{sinceYearStart}}", "{{totalCost}}"},
+		{"Сумма НДС", "{{sinceConstructionStartVat}}", "{{sinceYearStartVat}}", "{{vatTotal}}"},
+		{"Всего с учетом НДС", "{{sinceConstructionStartWithVat}}", "{{sinceYearStartWithVat}}", "{{totalWithVat}}"},
+	} {
+		lineRow := itemRow + 1 + i
+		fs.set(fmt.Sprintf("A%d", lineRow), fmt.Sprintf("D%d", lineRow), line[0], st.label)
+		fs.set(fmt.Sprintf("E%d", lineRow), "", line[1], st.totalRow)
+		fs.set(fmt.Sprintf("F%d", lineRow), "", line[2], st.totalRow)
+		fs.set(fmt.Sprintf("G%d", lineRow), "", line[3], st.totalRow)
+	}
+
+	fs.signatures(itemRow+5, [][2]string{
+		{"Заказчик (Генподрядчик)", `{{customerSigner | default:""}}`},
+		{"Подрядчик (Субподрядчик)", `{{contractorSigner | default:""}}`},
+	})
+	return fs.err
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// renderForm generates the act as the form and opens the result
+func renderForm(t *testing.T, form FormType, act *models.Act, data map[string]interface{}) *excelize.File {
+	t.Helper()
+	return renderFormWith(t, form, act, RenderOptions{Data: data})
+}
+
+// renderFormWith generates the act as the form with the render options,
+// failing on missing keys, and opens the result
+func renderFormWith(t *testing.T, form FormType, act *models.Act, opts RenderOptions) *excelize.File {
+	t.Helper()
+
+	dir := t.TempDir()
+	templatePath, err := formTemplatePath(dir, form)
+	if err != nil {
+		t.Fatalf("formTemplatePath() error = %v", err)
+	}
+
+	outputPath := filepath.Join(dir, "act.xlsx")
+	service := NewExcelService(testConfig())
+	opts.MissingKeyPolicy = MissingKeyError
+	if err = service.GenerateAct(act, templatePath, outputPath, opts); err != nil {
+		t.Fatalf("GenerateAct() error = %v", err)
+	}
+
+	return openTestWorkbook(t, outputPath)
+}
+
+// findCell returns the first cell of the sheet holding the text
+func findCell(t *testing.T, f *excelize.File, text string) string {
+	t.Helper()
+
+	sheet := f.GetSheetName(0)
+	cells, err := f.SearchSheet(sheet, text)
+	if err != nil || len(cells) == 0 {
+		t.Fatalf("cell %q not found: %v", text, err)
+	}
+	return cells[0]
+}
+
+// cellRight returns the value of the cell the given number of columns to the right
+func cellRight(t *testing.T, f *excelize.File, cell string, offset int) string {
+	t.Helper()
+
+	col, row, err := excelize.CellNameToCoordinates(cell)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := excelize.CoordinatesToCellName(col+offset, row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := f.GetCellValue(f.GetSheetName(0), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestKS2Form(t *testing.T) {
+	f := renderForm(t, FormKS2, testAct(100, 200), nil)
+
+	if sheet := f.GetSheetName(0); sheet != "КС-2" {
+		t.Errorf("sheet = %q, expected КС-2", sheet)
+	}
+	if cell := findCell(t, f, "№ 42 от 01.02.2026"); cell != "C9" {
+		t.Errorf("contract cell = %s, expected C9", cell)
+	}
+
+	// Both positions are expanded and the total sums them
+	total := findCell(t, f, "Итого")
+	formula, err := f.GetCellFormula(f.GetSheetName(0), "H"+total[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SUM(H19:H20)"; formula != expected {
+		t.Errorf("total formula = %q, expected %q", formula, expected)
+	}
+	if value := cellRight(t, f, findCell(t, f, "НДС 20%"), 7); value != "0.60" {
+		t.Errorf("VAT = %q, expected 0.60", value)
+	}
+	if value := cellRight(t, f, findCell(t, f, "Всего с учетом НДС"), 7); value != "3.60" {
+		t.Errorf("total with VAT = %q, expected 3.60", value)
+	}
+}
+
+func TestKS2FormLineItems(t *testing.T) {
+	act := testAct(100, 200)
+	quantity, price := models.Quantity(12345), models.NewMoney(100, 0)
+	act.Positions[0].Code = "ФЕР01-01-001"
+	act.Positions[0].Name = "Разработка грунта"
+	act.Positions[0].Unit = "м3"
+	act.Positions[0].Quantity = &quantity
+	act.Positions[0].UnitPrice = &price
+	act.Positions[0].EstimateLine = "1.1"
+
+	f := renderForm(t, FormKS2, act, nil)
+	row := findCell(t, f, "Разработка грунта")[1:]
+	sheet := f.GetSheetName(0)
+	for col, expected := range map[string]string{"B": "1.1", "D": "ФЕР01-01-001", "E": "м3", "F": "12.345", "G": "100.00"} {
+		if value, _ := f.GetCellValue(sheet, col+row); value != expected {
+			t.Errorf("%s%s = %q, expected %q", col, row, value, expected)
+		}
+	}
+}
+
+func TestKS3FormContractTotals(t *testing.T) {
+	act := testAct(100, 200)
+	act.ID = primitive.NewObjectID()
+	act.CreatedAt = time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	// One act of the previous year, one of this year and one created later,
+	// all signed, and a draft that is not counted
+	earlier := func(year int, kopecks int64) models.Act {
+		return testContractAct(time.Date(year, time.June, 1, 0, 0, 0, 0, time.UTC), "", kopecks)
+	}
+	contractActs := []models.Act{earlier(2025, 10000), earlier(2026, 500), earlier(2027, 99999), earlier(2026, 777), *act}
+	contractActs[1].CreatedAt = time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC)
+	contractActs[3].Status = models.ActStatusDraft
+	contractActs[3].CreatedAt = time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	service := &actService{config: testConfig()}
+	f := renderForm(t, FormKS3, act, service.contractTotals(act, testContractCosts(t, act, contractActs)))
+
+	// Since construction start: 100.00 + 5.00 + 3.00, since year start: 5.00 + 3.00
+	tests := []struct {
+		label    string
+		expected [3]string
+	}{
+		{label: "Итого", expected: [3]string{"108.00", "8.00", "3.00"}},
+		{label: "Сумма НДС", expected: [3]string{"21.60", "1.60", "0.60"}},
+		{label: "Всего с учетом НДС", expected: [3]string{"129.60", "9.60", "3.60"}},
+	}
+	for _, tt := range tests {
+		cell := findCell(t, f, tt.label)
+		for i, expected := range tt.expected {
+			if value := cellRight(t, f, cell, 4+i); value != expected {
+				t.Errorf("%s column %d = %q, expected %q", tt.label, i+1, value, expected)
+			}
+		}
+	}
+}
+
+func TestKS3FormPositionTotals(t *testing.T) {
+	act := testAct(100, 200)
+	act.ID = primitive.NewObjectID()
+	act.CreatedAt = time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	act.Positions[0].Name = "Excavation"
+	act.Positions[0].EstimateLine = "1.1"
+	act.Positions[1].Name = "Survey"
+
+	// Signed acts of the estimate line from the previous year and this year,
+	// a draft and an act of another line that are not counted
+	contractActs := []models.Act{
+		testContractAct(time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC), "1.1", 10000),
+		testContractAct(time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC), "1.1", 500),
+		testContractAct(time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), "1.1", 777),
+		testContractAct(time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), "2.1", 300),
+	}
+	contractActs[2].Status = models.ActStatusDraft
+
+	service := &actService{config: testConfig()}
+	costs := testContractCosts(t, act, contractActs)
+	f := renderFormWith(t, FormKS3, act, RenderOptions{
+		Data:         service.contractTotals(act, costs),
+		PositionData: service.positionTotals(act, costs),
+	})
+
+	tests := []struct {
+		name     string
+		expected [3]string
+	}{
+		{name: "Excavation", expected: [3]string{"106.00", "6.00", "1.00"}},
+		{name: "Survey", expected: [3]string{"2.00", "2.00", "2.00"}},
+	}
+	for _, tt := range tests {
+		cell := findCell(t, f, tt.name)
+		for i, expected := range tt.expected {
+			if value := cellRight(t, f, cell, 3+i); value != expected {
+				t.Errorf("%s column %d = %q, expected %q", tt.name, i+1, value, expected)
+			}
+		}
+	}
+}
+
+func TestFormTemplatePathWritesOnce(t *testing.T) {
+	dir := t.TempDir()
+	path, err := formTemplatePath(dir, FormKS2)
+	if err != nil {
+		t.Fatalf("formTemplatePath() error = %v", err)
+	}
+	openTestWorkbook(t, path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "ks2.xlsx" {
+		t.Errorf("directory holds %d files, expected only ks2.xlsx", len(entries))
+	}
+}
+
+func TestParseFormType(t *testing.T) {
+	if form, err := ParseFormType(" KS3 "); err != nil || form != FormKS3 {
+		t.Errorf("ParseFormType(\" KS3 \") = %q, %v, expected ks3", form, err)
+	}
+	if form, err := ParseFormType(""); err != nil || form != "" {
+		t.Errorf("ParseFormType(\"\") = %q, %v, expected no form", form, err)
+	}
+	if _, err := ParseFormType("ks4"); err == nil {
+		t.Error("ParseFormType(\"ks4\") expected an error")
+	}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testConfig is the configuration of services under test
+func testConfig() *config.Config {
+	return &config.Config{DefaultVATRate: "20"}
+}
+
+// testPosition creates a position with a current period cost in kopecks
+func testPosition(kopecks int64, rate models.VATRate) models.Position {
+	cost := models.Money(kopecks)
+	return models.Position{CurrentPeriodCost: &cost, VATRate: rate}
+}
+
+// testCosts returns the costs in kopecks of n positions costing 1.00,
+// 2.00, 3.00 and so on
+func testCosts(n int) []int64 {
+	costs := make([]int64, n)
+	for i := range costs {
+		costs[i] = int64(i+1) * 100
+	}
+	return costs
+}
+
+// testAct creates an act with the header text fields of the forms and a
+// position per cost in kopecks, with its totals calculated at the default
+// VAT rate
+func testAct(costs ...int64) *models.Act {
+	act := &models.Act{
+		BigAct: &models.BigAct{TextFields: map[string]interface{}{
+			"customer":       "Customer",
+			"contractor":     "Contractor",
+			"objectName":     "Object",
+			"contractNumber": "42",
+			"contractDate":   "01.02.2026",
+		}},
+		Positions: make([]models.Position, 0, len(costs)),
+	}
+	for _, kopecks := range costs {
+		act.Positions = append(act.Positions, testPosition(kopecks, ""))
+	}
+
+	service := &actService{config: testConfig()}
+	service.calculateActTotals(act)
+	act.BigAct.PositionIDs = ""
+	return act
+}
+
+// testContractAct creates a signed act of the contract with one position of
+// the estimate line
+func testContractAct(createdAt time.Time, estimateLine string, kopecks int64) models.Act {
+	position := testPosition(kopecks, "")
+	position.EstimateLine = estimateLine
+	return models.Act{
+		ID:         primitive.NewObjectID(),
+		ContractID: "contract",
+		Status:     models.ActStatusSigned,
+		CreatedAt:  createdAt,
+		BigAct:     &models.BigAct{},
+		Positions:  []models.Position{position},
+	}
+}
+
+// contractActRepository holds acts in memory and sums their costs like the
+// aggregation of the act repository does
+type contractActRepository struct {
+	repository.ActRepository
+	acts []models.Act
+}
+
+// SumContractCosts sums the costs of the approved and signed acts matching
+// the filter by act and VAT rate and by estimate line and year in the
+// time zone of the filter
+func (r *contractActRepository) SumContractCosts(_ context.Context, filter repository.ContractCostFilter) (*repository.ContractCosts, error) {
+	type rateKey struct {
+		actID primitive.ObjectID
+		rate  models.VATRate
+	}
+	type lineKey struct {
+		line string
+		year int
+	}
+	byRate := make(map[rateKey]repository.ActRateCost)
+	byLine := make(map[lineKey]repository.EstimateLineCost)
+
+	location := filter.Location
+	if location == nil {
+		location = time.UTC
+	}
+	costs := &repository.ContractCosts{}
+	for _, act := range r.acts {
+		var number interface{}
+		if act.BigAct != nil {
+			number = act.BigAct.TextFields["contractNumber"]
+		}
+		switch {
+		case !act.CurrentStatus().IsFinal(), act.CreatedAt.After(filter.CreatedBy), act.ID == filter.ExcludeID:
+			continue
+		case filter.ContractID != "" && act.ContractID != filter.ContractID:
+			continue
+		case filter.ContractID == "" && number != filter.ContractNumber:
+			continue
+		}
+
+		costs.Acts++
+		year := act.CreatedAt.In(location).Year()
+		for _, pos := range act.Positions {
+			if pos.CurrentPeriodCost == nil {
+				continue
+			}
+			rate := byRate[rateKey{act.ID, pos.VATRate}]
+			rate.ActID, rate.Year, rate.VATRate = act.ID, year, pos.VATRate
+			rate.Cost = rate.Cost.Add(*pos.CurrentPeriodCost)
+			byRate[rateKey{act.ID, pos.VATRate}] = rate
+			if pos.EstimateLine != "" {
+				line := byLine[lineKey{pos.EstimateLine, year}]
+				line.EstimateLine, line.Year = pos.EstimateLine, year
+				line.Cost = line.Cost.Add(*pos.CurrentPeriodCost)
+				byLine[lineKey{pos.EstimateLine, year}] = line
+			}
+		}
+	}
+	for _, cost := range byRate {
+		costs.ByRate = append(costs.ByRate, cost)
+	}
+	for _, cost := range byLine {
+		costs.ByLine = append(costs.ByLine, cost)
+	}
+	return costs, nil
+}
+
+// missingActRepository finds no act
+type missingActRepository struct {
+	repository.ActRepository
+	lookups int
+}
+
+// FindByID reports that the act does not exist
+func (r *missingActRepository) FindByID(_ context.Context, _ string) (*models.Act, error) {
+	r.lookups++
+	return nil, repository.ErrActNotFound
+}
+
+// testContractCosts sums the costs of the acts of the test contract issued
+// before the act
+func testContractCosts(t *testing.T, act *models.Act, acts []models.Act) *repository.ContractCosts {
+	t.Helper()
+
+	repo := &contractActRepository{acts: acts}
+	costs, err := repo.SumContractCosts(context.Background(), repository.ContractCostFilter{
+		ContractID: "contract",
+		CreatedBy:  act.CreatedAt,
+		ExcludeID:  act.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return costs
+}
+
+// writeTestWorkbook saves a one-sheet workbook with the given cell values,
+// values starting with = are written as formulas. The edit function, when
+// given, changes the workbook before it is saved.
+func writeTestWorkbook(t testing.TB, path string, cells map[string]interface{}, edit func(f *excelize.File, sheet string) error) {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	for cell, value := range cells {
+		var err error
+		if text, ok := value.(string); ok && strings.HasPrefix(text, "=") {
+			err = f.SetCellFormula(sheet, cell, text[1:])
+		} else {
+			err = f.SetCellValue(sheet, cell, value)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if edit != nil {
+		if err := edit(f, sheet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openTestWorkbook opens a generated workbook for the duration of the test
+func openTestWorkbook(t testing.TB, path string) *excelize.File {
+	t.Helper()
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// writeStreamTemplate creates a template with a header, a position block,
+// a total row with a stretched range and a merged signature row
+func writeStreamTemplate(t testing.TB, dir string) string {
+	path := filepath.Join(dir, "template.xlsx")
+	writeTestWorkbook(t, path, map[string]interface{}{
+		"A1": "Act {{actId}}",
+		"A2": "No",
+		"B2": "Cost",
+		"A3": "{{#positions}}{{@number}}",
+		"B3": "{{currentPeriodCost}}{{/positions}}",
+		"A4": "Total",
+		"B4": "=SUM(B3:B3)*$B$2+B3",
+		"A5": "Signed: {{contractor}}",
+	}, func(f *excelize.File, sheet string) error {
+		if err := f.MergeCell(sheet, "A5", "C5"); err != nil {
+			return err
+		}
+		return f.SetRowHeight(sheet, 5, 30)
+	})
+	return path
+}
+
+// writePDFWorkbook creates a workbook with a merged bordered title, a
+// wrapped text, a number, a sum without a cached result and a picture
+func writePDFWorkbook(t testing.TB, path string) {
+	writeTestWorkbook(t, path, map[string]interface{}{
+		"A1": "Act (draft) 1",
+		"A2": "A long description of the works that does not fit on one line",
+		"B3": 1234.5,
+		"B4": 10,
+		"B5": "=SUM(B3:B4)",
+	}, func(f *excelize.File, sheet string) error {
+		border := []excelize.Border{
+			{Type: "left", Color: "000000", Style: 1},
+			{Type: "top", Color: "000000", Style: 2},
+			{Type: "right", Color: "000000", Style: 1},
+			{Type: "bottom", Color: "000000", Style: 1},
+		}
+		title, err := f.NewStyle(&excelize.Style{
+			Font:      &excelize.Font{Bold: true, Size: 14},
+			Alignment: &excelize.Alignment{Horizontal: "center"},
+			Border:    border,
+			Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEEFF"}},
+		})
+		if err != nil {
+			return err
+		}
+		wrapped, err := f.NewStyle(&excelize.Style{Alignment: &excelize.Alignment{WrapText: true, Vertical: "top"}})
+		if err != nil {
+			return err
+		}
+		qr, err := utils.EncodeQRPNG("https://example.com/act", qrModuleSize)
+		if err != nil {
+			return err
+		}
+		landscape := "landscape"
+		steps := []error{
+			f.MergeCell(sheet, "A1", "C1"),
+			f.SetCellStyle(sheet, "A1", "C1", title),
+			f.SetCellStyle(sheet, "A2", "A2", wrapped),
+			f.SetRowHeight(sheet, 2, 60),
+			f.SetColWidth(sheet, "A", "A", 20),
+			f.AddPictureFromBytes(sheet, "D2", &excelize.Picture{Extension: ".png", File: qr, Format: &excelize.GraphicOptions{AutoFit: true}}),
+			f.SetPageLayout(sheet, &excelize.PageLayoutOptions{Orientation: &landscape}),
+		}
+		for _, err := range steps {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// renderTestTemplate saves a one-sheet template with the given cell values,
+// generates the act with it and opens the result
+func renderTestTemplate(t testing.TB, service ExcelService, cells map[string]interface{}, act *models.Act, opts RenderOptions) *excelize.File {
+	t.Helper()
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "template.xlsx")
+	writeTestWorkbook(t, templatePath, cells, nil)
+
+	outputPath := filepath.Join(dir, "act.xlsx")
+	if err := service.GenerateAct(act, templatePath, outputPath, opts); err != nil {
+		t.Fatalf("GenerateAct() error = %v", err)
+	}
+	return openTestWorkbook(t, outputPath)
+}
+
+// sheetRows returns the values of the first sheet, trailing empty cells
+// and rows left out and empty rows as nil
+func sheetRows(t testing.TB, f *excelize.File) [][]string {
+	t.Helper()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range rows {
+		if len(rows[i]) == 0 {
+			rows[i] = nil
+		}
+	}
+	for len(rows) > 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
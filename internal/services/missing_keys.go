@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// MissingKeyPolicy decides what happens to placeholders whose key is missing
+type MissingKeyPolicy string
+
+// Missing key policies
+const (
+	// MissingKeyKeep leaves the placeholder text in the document
+	MissingKeyKeep MissingKeyPolicy = "keep"
+	// MissingKeyBlank replaces the placeholder with an empty string
+	MissingKeyBlank MissingKeyPolicy = "blank"
+	// MissingKeyError fails the generation and reports every missing key
+	MissingKeyError MissingKeyPolicy = "error"
+)
+
+// ParseMissingKeyPolicy validates a policy name. An empty name is returned
+// as an empty policy, meaning that the policy is not set.
+func ParseMissingKeyPolicy(name string) (MissingKeyPolicy, error) {
+	switch policy := MissingKeyPolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "", MissingKeyKeep, MissingKeyBlank, MissingKeyError:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown missing key policy %q, expected keep, blank or error", name)
+	}
+}
+
+// MissingPlaceholdersError is returned by generation with the error policy.
+// It lists every placeholder that could not be resolved and where it was found.
+type MissingPlaceholdersError struct {
+	Placeholders []TemplatePlaceholder
+}
+
+// Error implements the error interface
+func (e *MissingPlaceholdersError) Error() string {
+	keys := make([]string, 0, len(e.Placeholders))
+	for _, placeholder := range e.Placeholders {
+		location := placeholder.Part
+		if placeholder.Cell != "" {
+			location = placeholder.Cell
+		}
+		if placeholder.Sheet != "" {
+			location = placeholder.Sheet + "!" + location
+		}
+		keys = append(keys, fmt.Sprintf("%s at %s", placeholder.Text, location))
+	}
+	return fmt.Sprintf("%d unresolved placeholders: %s", len(e.Placeholders), strings.Join(keys, ", "))
+}
+
+// sortPlaceholders orders placeholders by sheet, in the order the sheets were
+// processed, and then by their cell in reading order
+func sortPlaceholders(placeholders []TemplatePlaceholder) []TemplatePlaceholder {
+	sheetRank := make(map[string]int)
+	for _, placeholder := range placeholders {
+		if _, ok := sheetRank[placeholder.Sheet]; !ok {
+			sheetRank[placeholder.Sheet] = len(sheetRank)
+		}
+	}
+
+	sorted := append([]TemplatePlaceholder(nil), placeholders...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sheetRank[sorted[i].Sheet] != sheetRank[sorted[j].Sheet] {
+			return sheetRank[sorted[i].Sheet] < sheetRank[sorted[j].Sheet]
+		}
+		colI, rowI, _ := excelize.CellNameToCoordinates(sorted[i].Cell)
+		colJ, rowJ, _ := excelize.CellNameToCoordinates(sorted[j].Cell)
+		if rowI != rowJ {
+			return rowI < rowJ
+		}
+		return colI < colJ
+	})
+	return sorted
+}
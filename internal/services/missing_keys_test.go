@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+)
+
+// recordingActRepository counts the act updates, other calls are not expected
+type recordingActRepository struct {
+	repository.ActRepository
+	updates int
+}
+
+// Update records the update of an act
+func (r *recordingActRepository) Update(_ context.Context, _ string, _ *models.Act) error {
+	r.updates++
+	return nil
+}
+
+func TestParseMissingKeyPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected MissingKeyPolicy
+		err      bool
+	}{
+		{name: "", expected: ""},
+		{name: "keep", expected: MissingKeyKeep},
+		{name: " Blank ", expected: MissingKeyBlank},
+		{name: "ERROR", expected: MissingKeyError},
+		{name: "ignore", err: true},
+	}
+
+	for _, tt := range tests {
+		policy, err := ParseMissingKeyPolicy(tt.name)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseMissingKeyPolicy(%q) error = %v, expected error %v", tt.name, err, tt.err)
+			continue
+		}
+		if policy != tt.expected {
+			t.Errorf("ParseMissingKeyPolicy(%q) = %q, expected %q", tt.name, policy, tt.expected)
+		}
+	}
+}
+
+func TestMissingKeyPolicies(t *testing.T) {
+	cells := map[string]interface{}{
+		"A1": "{{customer}}",
+		"A2": "Note: {{note}}",
+		"A3": "{{signer.name | upper}}",
+		"A4": "{{#positions}}{{extra}}{{/positions}}",
+		"A5": "{{note | default:\"none\"}}",
+	}
+
+	tests := []struct {
+		policy   MissingKeyPolicy
+		expected [][]string
+	}{
+		{
+			policy:   "",
+			expected: [][]string{{"Customer"}, {"Note: {{note}}"}, {"{{signer.name | upper}}"}, {"{{extra}}"}, {"{{extra}}"}, {"none"}},
+		},
+		{
+			policy:   MissingKeyKeep,
+			expected: [][]string{{"Customer"}, {"Note: {{note}}"}, {"{{signer.name | upper}}"}, {"{{extra}}"}, {"{{extra}}"}, {"none"}},
+		},
+		{
+			policy:   MissingKeyBlank,
+			expected: [][]string{{"Customer"}, {"Note: "}, nil, nil, nil, {"none"}},
+		},
+	}
+
+	service := NewExcelService(testConfig())
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			f := renderTestTemplate(t, service, cells, testAct(100, 200), RenderOptions{MissingKeyPolicy: tt.policy})
+			if rows := sheetRows(t, f); !reflect.DeepEqual(rows, tt.expected) {
+				t.Errorf("rows = %q, expected %q", rows, tt.expected)
+			}
+		})
+	}
+
+	t.Run(string(MissingKeyError), func(t *testing.T) {
+		dir := t.TempDir()
+		templatePath := filepath.Join(dir, "template.xlsx")
+		writeTestWorkbook(t, templatePath, cells, nil)
+
+		outputPath := filepath.Join(dir, "act.xlsx")
+		err := service.GenerateAct(testAct(100, 200), templatePath, outputPath, RenderOptions{MissingKeyPolicy: MissingKeyError})
+
+		var missingErr *MissingPlaceholdersError
+		if !errors.As(err, &missingErr) {
+			t.Fatalf("GenerateAct() error = %v, expected missing placeholders", err)
+		}
+		var found []string
+		for _, placeholder := range missingErr.Placeholders {
+			found = append(found, placeholder.Cell+" "+placeholder.Text)
+		}
+		expected := []string{"A2 {{note}}", "A3 {{signer.name | upper}}", "A4 {{extra}}", "A5 {{extra}}"}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("placeholders = %q, expected %q", found, expected)
+		}
+		if !strings.Contains(err.Error(), "{{note}} at Sheet1!A2") {
+			t.Errorf("error = %q, expected the location of {{note}}", err)
+		}
+		if _, statErr := os.Stat(outputPath); !os.IsNotExist(statErr) {
+			t.Errorf("output file exists after a failed generation: %v", statErr)
+		}
+	})
+}
+
+func TestMissingKeyPolicyPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		requested  MissingKeyPolicy
+		template   string
+		configured string
+		expected   MissingKeyPolicy
+	}{
+		{name: "requested wins", requested: MissingKeyBlank, template: "error", configured: "keep", expected: MissingKeyBlank},
+		{name: "template over configuration", template: "error", configured: "blank", expected: MissingKeyError},
+		{name: "configuration", configured: "blank", expected: MissingKeyBlank},
+		{name: "nothing set", expected: ""},
+		{name: "invalid configuration keeps placeholders", configured: "silent", expected: MissingKeyKeep},
+	}
+
+	for _, tt := range tests {
+		service := &actService{config: &config.Config{MissingKeyPolicy: tt.configured}}
+		policy := service.missingKeyPolicy(&models.Template{MissingKeyPolicy: tt.template}, tt.requested)
+		if policy != tt.expected {
+			t.Errorf("%s: policy = %q, expected %q", tt.name, policy, tt.expected)
+		}
+	}
+}
+
+func TestGenerateActStoresNothingOnMissingKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(act *models.Act)
+		updates int
+		err     bool
+	}{
+		{name: "complete act is stored", edit: func(*models.Act) {}, updates: 1},
+		{name: "act with missing keys is not stored", edit: func(act *models.Act) { delete(act.BigAct.TextFields, "customer") }, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := testConfig()
+			cfg.GeneratedPath = dir
+			cfg.FormsDir = dir
+			repo := &recordingActRepository{}
+			service := &actService{
+				repo:         repo,
+				excelService: NewExcelService(cfg),
+				assetService: NewAssetService(cfg),
+				config:       cfg,
+			}
+
+			act := testAct(100, 200)
+			tt.edit(act)
+			_, err := service.processAndGenerateAct(context.Background(), act, GenerateOptions{Form: FormKS2, MissingKeyPolicy: MissingKeyError})
+			if (err != nil) != tt.err {
+				t.Fatalf("processAndGenerateAct() error = %v, expected error %v", err, tt.err)
+			}
+			if repo.updates != tt.updates {
+				t.Errorf("updates = %d, expected %d", repo.updates, tt.updates)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// pdfWriter writes the numbered objects of a PDF file and its cross-reference table
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// newObject reserves an object number
+func (w *pdfWriter) newObject() int {
+	w.offsets = append(w.offsets, 0)
+	return len(w.offsets)
+}
+
+// writeObject writes a reserved object
+func (w *pdfWriter) writeObject(ref int, body string) {
+	w.offsets[ref-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", ref, body)
+}
+
+// writeStream writes a reserved object as a compressed stream with the
+// extra dictionary entries
+func (w *pdfWriter) writeStream(ref int, entries string, data []byte) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	w.writeRawStream(ref, entries+" /Filter /FlateDecode", compressed.Bytes())
+}
+
+// writeRawStream writes a reserved object as a stream of already encoded data
+func (w *pdfWriter) writeRawStream(ref int, entries string, data []byte) {
+	w.offsets[ref-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< /Length %d %s >>\nstream\n", ref, len(data), strings.TrimSpace(entries))
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// pdfDocument is a PDF document made of pages drawn with a set of fonts and images
+type pdfDocument struct {
+	fonts  []pdfFont
+	images []*pdfImage
+	pages  []*pdfPage
+}
+
+// pdfPage is a page of a document, drawn from the top left corner in points
+type pdfPage struct {
+	width   float64
+	height  float64
+	content bytes.Buffer
+}
+
+// addFont adds a font to the document and returns its resource name
+func (d *pdfDocument) addFont(font pdfFont) string {
+	for i, existing := range d.fonts {
+		if existing == font {
+			return "F" + strconv.Itoa(i+1)
+		}
+	}
+	d.fonts = append(d.fonts, font)
+	return "F" + strconv.Itoa(len(d.fonts))
+}
+
+// addImage adds an image to the document and returns its resource name
+func (d *pdfDocument) addImage(img *pdfImage) string {
+	d.images = append(d.images, img)
+	return "Im" + strconv.Itoa(len(d.images))
+}
+
+// addPage appends an empty page of the given size
+func (d *pdfDocument) addPage(width, height float64) *pdfPage {
+	page := &pdfPage{width: width, height: height}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// save writes the document to a file
+func (d *pdfDocument) save(path string) error {
+	w := &pdfWriter{}
+	w.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	catalog, pages := w.newObject(), w.newObject()
+
+	// All pages share the resources of the document
+	var resources strings.Builder
+	resources.WriteString("<< /ProcSet [/PDF /Text /ImageB /ImageC] /Font <<")
+	for i, font := range d.fonts {
+		fmt.Fprintf(&resources, " /F%d %d 0 R", i+1, font.write(w))
+	}
+	resources.WriteString(" >> /XObject <<")
+	for i, img := range d.images {
+		fmt.Fprintf(&resources, " /Im%d %d 0 R", i+1, img.write(w))
+	}
+	resources.WriteString(" >> >>")
+	resourcesRef := w.newObject()
+	w.writeObject(resourcesRef, resources.String())
+
+	kids := make([]string, 0, len(d.pages))
+	for _, page := range d.pages {
+		content := w.newObject()
+		w.writeStream(content, "", page.content.Bytes())
+		ref := w.newObject()
+		w.writeObject(ref, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %d 0 R /Contents %d 0 R >>",
+			pages, pdfNumber(page.width), pdfNumber(page.height), resourcesRef, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", ref))
+	}
+	w.writeObject(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	w.writeObject(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, catalog, xref)
+
+	return os.WriteFile(path, w.buf.Bytes(), 0644)
+}
+
+// pdfNumber formats a number with at most three decimal places
+func pdfNumber(value float64) string {
+	text := strconv.FormatFloat(value, 'f', 3, 64)
+	text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	if text == "-0" || text == "" {
+		return "0"
+	}
+	return text
+}
+
+// pdfColor is an RGB color with components from 0 to 1
+type pdfColor struct {
+	r, g, b float64
+}
+
+// parsePDFColor reads a color written as RRGGBB or AARRGGBB, with or
+// without a leading #
+func parsePDFColor(text string) (pdfColor, bool) {
+	text = strings.TrimPrefix(text, "#")
+	if len(text) == 8 {
+		text = text[2:]
+	}
+	value, err := strconv.ParseUint(text, 16, 32)
+	if len(text) != 6 || err != nil {
+		return pdfColor{}, false
+	}
+	return pdfColor{
+		r: float64(value>>16&0xff) / 255,
+		g: float64(value>>8&0xff) / 255,
+		b: float64(value&0xff) / 255,
+	}, true
+}
+
+// operands returns the color as operands of the rg and RG operators
+func (c pdfColor) operands() string {
+	return pdfNumber(c.r) + " " + pdfNumber(c.g) + " " + pdfNumber(c.b)
+}
+
+// y converts a distance from the top of the page to a PDF coordinate
+func (p *pdfPage) y(top float64) float64 {
+	return p.height - top
+}
+
+// fillRect fills a rectangle
+func (p *pdfPage) fillRect(x, top, width, height float64, c pdfColor) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n", c.operands(),
+		pdfNumber(x), pdfNumber(p.y(top+height)), pdfNumber(width), pdfNumber(height))
+}
+
+// line strokes a line, dashed when a dash pattern is given
+func (p *pdfPage) line(x1, top1, x2, top2, width float64, c pdfColor, dash string) {
+	fmt.Fprintf(&p.content, "%s RG %s w [%s] 0 d %s %s m %s %s l S\n", c.operands(), pdfNumber(width), dash,
+		pdfNumber(x1), pdfNumber(p.y(top1)), pdfNumber(x2), pdfNumber(p.y(top2)))
+}
+
+// clip restricts the drawing until unclip to a rectangle
+func (p *pdfPage) clip(x, top, width, height float64) {
+	fmt.Fprintf(&p.content, "q %s %s %s %s re W n\n",
+		pdfNumber(x), pdfNumber(p.y(top+height)), pdfNumber(width), pdfNumber(height))
+}
+
+// unclip restores the drawing area of the last clip
+func (p *pdfPage) unclip() {
+	p.content.WriteString("Q\n")
+}
+
+// pdfTextStyle is the look of a text run
+type pdfTextStyle struct {
+	font     string
+	size     float64
+	color    pdfColor
+	fakeBold bool
+	italic   bool
+}
+
+// text writes encoded text with its baseline starting at the point. Bold and
+// italic are simulated for fonts without such faces.
+func (p *pdfPage) text(x, baseline float64, encoded string, style pdfTextStyle) {
+	p.content.WriteString("BT ")
+	fmt.Fprintf(&p.content, "/%s %s Tf %s rg ", style.font, pdfNumber(style.size), style.color.operands())
+	if style.fakeBold {
+		fmt.Fprintf(&p.content, "2 Tr %s w %s RG ", pdfNumber(style.size*0.03), style.color.operands())
+	}
+	skew := 0.0
+	if style.italic {
+		skew = 0.2
+	}
+	fmt.Fprintf(&p.content, "1 0 %s 1 %s %s Tm %s Tj ET\n", pdfNumber(skew), pdfNumber(x), pdfNumber(p.y(baseline)), encoded)
+	if style.fakeBold {
+		p.content.WriteString("0 Tr\n")
+	}
+}
+
+// rotatedText writes encoded text rotated counterclockwise by the angle in
+// radians around a point given in PDF coordinates. The baseline starts at
+// the offset from the point along the rotated axes.
+func (p *pdfPage) rotatedText(x, y, angle, dx, dy float64, encoded string, style pdfTextStyle) {
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	fmt.Fprintf(&p.content, "q %s %s %s %s %s %s cm BT /%s %s Tf %s rg ",
+		pdfNumber(cos), pdfNumber(sin), pdfNumber(-sin), pdfNumber(cos), pdfNumber(x), pdfNumber(y),
+		style.font, pdfNumber(style.size), style.color.operands())
+	if style.fakeBold {
+		fmt.Fprintf(&p.content, "2 Tr %s w %s RG ", pdfNumber(style.size*0.03), style.color.operands())
+	}
+	fmt.Fprintf(&p.content, "%s %s Td %s Tj ET Q\n", pdfNumber(dx), pdfNumber(dy), encoded)
+}
+
+// drawImage draws an image into a rectangle
+func (p *pdfPage) drawImage(name string, x, top, width, height float64) {
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		pdfNumber(width), pdfNumber(height), pdfNumber(x), pdfNumber(p.y(top+height)), name)
+}
+
+// pdfImage is an image XObject
+type pdfImage struct {
+	width      int
+	height     int
+	colorSpace string
+	filter     string
+	data       []byte
+	alpha      *pdfImage
+}
+
+// newPDFImage creates an image from PNG or JPEG data. JPEG data is embedded
+// as is, other images are stored as compressed pixels with an alpha mask
+// when they have transparency.
+func newPDFImage(content []byte) (*pdfImage, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	if format == "jpeg" {
+		colorSpace := "DeviceRGB"
+		switch config.ColorModel {
+		case color.GrayModel:
+			colorSpace = "DeviceGray"
+		case color.CMYKModel:
+			// CMYK JPEG files are written inverted, re-encode them as RGB
+			img, err := jpeg.Decode(bytes.NewReader(content))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode image: %w", err)
+			}
+			return pixelImage(img), nil
+		}
+		return &pdfImage{width: config.Width, height: config.Height, colorSpace: colorSpace, filter: "DCTDecode", data: content}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return pixelImage(img), nil
+}
+
+// pixelImage stores the pixels of a decoded image
+func pixelImage(img image.Image) *pdfImage {
+	bounds := img.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			if c.A != 0xff {
+				opaque = false
+			}
+		}
+	}
+
+	result := &pdfImage{width: bounds.Dx(), height: bounds.Dy(), colorSpace: "DeviceRGB", data: rgb}
+	if !opaque {
+		result.alpha = &pdfImage{width: bounds.Dx(), height: bounds.Dy(), colorSpace: "DeviceGray", data: alpha}
+	}
+	return result
+}
+
+// write writes the image objects and returns the object number of the image
+func (img *pdfImage) write(w *pdfWriter) int {
+	entries := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8",
+		img.width, img.height, img.colorSpace)
+	if img.alpha != nil {
+		entries += fmt.Sprintf(" /SMask %d 0 R", img.alpha.write(w))
+	}
+
+	ref := w.newObject()
+	if img.filter != "" {
+		w.writeRawStream(ref, entries+" /Filter /"+img.filter, img.data)
+	} else {
+		w.writeStream(ref, entries, img.data)
+	}
+	return ref
+}
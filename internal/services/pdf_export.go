@@ -0,0 +1,1143 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// ErrPDFUnsupportedText is returned when a workbook has text the PDF font
+// has no glyphs for, which would be printed as question marks
+var ErrPDFUnsupportedText = errors.New("text is not supported by the PDF font")
+
+// OutputFormat is the file format an act is generated in
+type OutputFormat string
+
+// Output formats
+const (
+	FormatXLSX OutputFormat = "xlsx"
+	FormatPDF  OutputFormat = "pdf"
+)
+
+// ParseOutputFormat validates an output format name. An empty name is
+// returned as the xlsx format.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch format := OutputFormat(strings.ToLower(strings.TrimSpace(name))); format {
+	case "", FormatXLSX:
+		return FormatXLSX, nil
+	case FormatPDF:
+		return FormatPDF, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected xlsx or pdf", name)
+	}
+}
+
+// pdfPaperSizes maps the paper sizes of the page setup to points
+var pdfPaperSizes = map[int][2]float64{
+	1:  {612, 792},  // Letter
+	5:  {612, 1008}, // Legal
+	8:  {842, 1191}, // A3
+	9:  {595, 842},  // A4
+	11: {420, 595},  // A5
+}
+
+const (
+	// pdfDefaultFontSize is the size of text in cells without a font size
+	pdfDefaultFontSize = 11
+	// pdfCellPadding is the space between the text and the cell edges
+	pdfCellPadding = 2
+	// pdfLineSpacing is the height of a line of text relative to the font size
+	pdfLineSpacing = 1.2
+)
+
+// Defined names of the print area and the print titles of a sheet
+const (
+	printAreaName   = "_xlnm.Print_Area"
+	printTitlesName = "_xlnm.Print_Titles"
+)
+
+// pdfWatermarkColor is the color of watermarks, light enough to read the
+// cells through
+var pdfWatermarkColor = pdfColor{r: 0.8, g: 0.8, b: 0.8}
+
+// pdfFontSet holds the fonts of the regular, bold, italic and bold italic
+// text of a document, with the faces that are simulated and the characters
+// none of them could write
+type pdfFontSet struct {
+	regular, bold, italic, boldItalic pdfFont
+	fakeBold, fakeItalic              bool
+	missing                           map[rune]bool
+}
+
+// choose returns the font of a text style and whether bold and italic are simulated
+func (fs *pdfFontSet) choose(bold, italic bool) (pdfFont, bool, bool) {
+	switch {
+	case bold && italic:
+		return fs.boldItalic, fs.fakeBold, fs.fakeItalic
+	case bold:
+		return fs.bold, fs.fakeBold, false
+	case italic:
+		return fs.italic, false, fs.fakeItalic
+	default:
+		return fs.regular, false, false
+	}
+}
+
+// pdfFonts creates the fonts of a document: the configured TrueType fonts,
+// else Helvetica, which covers Latin-1 only
+func (s *excelService) pdfFonts() (*pdfFontSet, error) {
+	missing := make(map[rune]bool)
+	if s.config.PDFFontPath == "" {
+		return &pdfFontSet{
+			regular:    &standardFont{name: "Helvetica", widths: &helveticaWidths, missing: missing},
+			bold:       &standardFont{name: "Helvetica-Bold", widths: &helveticaBoldWidths, missing: missing},
+			italic:     &standardFont{name: "Helvetica-Oblique", widths: &helveticaWidths, missing: missing},
+			boldItalic: &standardFont{name: "Helvetica-BoldOblique", widths: &helveticaBoldWidths, missing: missing},
+			missing:    missing,
+		}, nil
+	}
+
+	face, err := loadTrueTypeFace(s.config.PDFFontPath)
+	if err != nil {
+		return nil, err
+	}
+	regular := newTrueTypeFont(face)
+	regular.missing = missing
+	fonts := &pdfFontSet{regular: regular, bold: regular, italic: regular, boldItalic: regular, fakeBold: true, fakeItalic: true, missing: missing}
+
+	if s.config.PDFBoldFontPath != "" {
+		boldFace, err := loadTrueTypeFace(s.config.PDFBoldFontPath)
+		if err != nil {
+			return nil, err
+		}
+		bold := newTrueTypeFont(boldFace)
+		bold.missing = missing
+		fonts.bold, fonts.boldItalic, fonts.fakeBold = bold, bold, false
+	}
+	return fonts, nil
+}
+
+// PDFOptions controls how a workbook is exported to PDF
+type PDFOptions struct {
+	// Watermark is drawn across every page when it is set, like the
+	// watermark the workbook was rendered with
+	Watermark string
+
+	// SheetWatermarks replaces Watermark on the sheets it names, e.g. on
+	// the act sheets of a combined workbook
+	SheetWatermarks map[string]string
+}
+
+// sheetWatermark returns the watermark drawn on the pages of a sheet
+func (o PDFOptions) sheetWatermark(sheet string) string {
+	if text, ok := o.SheetWatermarks[sheet]; ok {
+		return text
+	}
+	return o.Watermark
+}
+
+// ExportPDF renders a generated workbook to a PDF file. Every visible sheet
+// is laid out with its column widths, row heights, merged cells, fonts,
+// fills, borders, pictures and page setup; sheets wider than the page are
+// scaled down to fit its width. The print area, repeated title rows, manual
+// row breaks and the text of headers and footers are applied. Text the font
+// has no glyphs for fails the export with ErrPDFUnsupportedText instead of
+// being printed as question marks. Watermarks of the options are drawn
+// across every page of their sheets.
+func (s *excelService) ExportPDF(workbookPath, outputPath string, opts PDFOptions) error {
+	utils.LogMethodInit("ExcelService.ExportPDF")
+	utils.LogInfo("Exporting %s to PDF: %s", workbookPath, outputPath)
+
+	f, err := excelize.OpenFile(workbookPath)
+	if err != nil {
+		utils.LogMethodError("ExcelService.ExportPDF", err)
+		return fmt.Errorf("failed to open workbook: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			utils.LogError("Error closing Excel file: %v", closeErr)
+		}
+	}()
+
+	fonts, err := s.pdfFonts()
+	if err != nil {
+		utils.LogMethodError("ExcelService.ExportPDF", err)
+		return fmt.Errorf("failed to load PDF fonts: %w", err)
+	}
+
+	doc := &pdfDocument{}
+	for _, sheetName := range f.GetSheetList() {
+		if visible, _ := f.GetSheetVisible(sheetName); !visible {
+			continue
+		}
+		layout, err := readSheetLayout(f, sheetName)
+		if err != nil {
+			utils.LogMethodError("ExcelService.ExportPDF", err)
+			return fmt.Errorf("failed to read sheet %s: %w", sheetName, err)
+		}
+		layout.watermark = opts.sheetWatermark(sheetName)
+		if err = layout.render(doc, fonts); err != nil {
+			utils.LogMethodError("ExcelService.ExportPDF", err)
+			return fmt.Errorf("failed to render sheet %s: %w", sheetName, err)
+		}
+	}
+	if len(fonts.missing) > 0 {
+		err = fmt.Errorf("%w: no glyphs for %s, set PDF_FONT_PATH to a font that has them", ErrPDFUnsupportedText, missingCharacters(fonts.missing))
+		utils.LogMethodError("ExcelService.ExportPDF", err)
+		return err
+	}
+	if len(doc.pages) == 0 {
+		size := pdfPaperSizes[9]
+		doc.addPage(size[0], size[1])
+	}
+
+	if err = doc.save(outputPath); err != nil {
+		utils.LogMethodError("ExcelService.ExportPDF", err)
+		return fmt.Errorf("failed to save PDF: %w", err)
+	}
+
+	utils.LogInfo("Exported %d PDF pages to %s", len(doc.pages), outputPath)
+	utils.LogMethodSuccess("ExcelService.ExportPDF")
+	return nil
+}
+
+// missingCharacters lists the first characters of the set in order
+func missingCharacters(missing map[rune]bool) string {
+	runes := make([]rune, 0, len(missing))
+	for r := range missing {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	const shown = 10
+	quoted := make([]string, 0, shown)
+	for i, r := range runes {
+		if i == shown {
+			quoted = append(quoted, fmt.Sprintf("and %d more", len(runes)-shown))
+			break
+		}
+		quoted = append(quoted, strconv.QuoteRune(r))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// cellKey identifies a cell by its column and row, both 1-based
+type cellKey struct {
+	col, row int
+}
+
+// sheetLayout is what of a sheet is drawn in a PDF document
+type sheetLayout struct {
+	file  *excelize.File
+	sheet string
+
+	cols, rows int
+	colWidths  []float64 // points by column, 0 for hidden columns
+	rowHeights []float64 // points by row, 0 for hidden rows
+	values     [][]string
+	merges     map[cellKey]cellKey // top left cell to bottom right cell
+	covered    map[cellKey]bool    // cells hidden by a merged range
+	mergeTop   []int               // first row of a merged range continuing in the row
+	pictures   map[cellKey][][]byte
+	styles     map[int]*excelize.Style
+	watermark  string // drawn across every page
+
+	titleRows    [2]int       // first and last row repeated on every page, 0 without titles
+	rowBreaks    map[int]bool // rows followed by a manual page break
+	headerFooter *excelize.HeaderFooterOptions
+}
+
+// readSheetLayout reads the cells, sizes, merged ranges and pictures of a sheet
+func readSheetLayout(f *excelize.File, sheet string) (*sheetLayout, error) {
+	layout := &sheetLayout{
+		file:      f,
+		sheet:     sheet,
+		merges:    make(map[cellKey]cellKey),
+		covered:   make(map[cellKey]bool),
+		pictures:  make(map[cellKey][][]byte),
+		styles:    make(map[int]*excelize.Style),
+	}
+
+	var err error
+	if layout.values, err = f.GetRows(sheet); err != nil {
+		return nil, err
+	}
+	layout.rows = len(layout.values)
+	for _, row := range layout.values {
+		layout.cols = max(layout.cols, len(row))
+	}
+
+	// Styled cells without values, e.g. empty bordered table cells, are
+	// within the dimension of the sheet
+	if dimension, err := f.GetSheetDimension(sheet); err == nil {
+		if _, last, ok := strings.Cut(dimension, ":"); ok {
+			if col, row, err := excelize.CellNameToCoordinates(last); err == nil {
+				layout.cols, layout.rows = max(layout.cols, col), max(layout.rows, row)
+			}
+		}
+	}
+
+	mergeCells, err := f.GetMergeCells(sheet, true)
+	if err != nil {
+		return nil, err
+	}
+	var mergeRanges [][2]cellKey
+	for _, mergeCell := range mergeCells {
+		startCol, startRow, err := excelize.CellNameToCoordinates(mergeCell.GetStartAxis())
+		if err != nil {
+			return nil, err
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(mergeCell.GetEndAxis())
+		if err != nil {
+			return nil, err
+		}
+		mergeRanges = append(mergeRanges, [2]cellKey{{startCol, startRow}, {endCol, endRow}})
+		layout.merges[cellKey{startCol, startRow}] = cellKey{endCol, endRow}
+		for row := startRow; row <= endRow; row++ {
+			for col := startCol; col <= endCol; col++ {
+				if col != startCol || row != startRow {
+					layout.covered[cellKey{col, row}] = true
+				}
+			}
+		}
+		layout.cols, layout.rows = max(layout.cols, endCol), max(layout.rows, endRow)
+	}
+
+	pictureCells, err := f.GetPictureCells(sheet)
+	if err != nil {
+		return nil, err
+	}
+	for _, cell := range pictureCells {
+		col, row, err := excelize.CellNameToCoordinates(cell)
+		if err != nil {
+			return nil, err
+		}
+		pictures, err := f.GetPictures(sheet, cell)
+		if err != nil {
+			return nil, err
+		}
+		for _, picture := range pictures {
+			layout.pictures[cellKey{col, row}] = append(layout.pictures[cellKey{col, row}], picture.File)
+		}
+		layout.cols, layout.rows = max(layout.cols, col), max(layout.rows, row)
+	}
+
+	layout.trim()
+
+	// The print area limits the rows and columns, those before it are
+	// hidden below. Only the first range of the area is printed.
+	var area sheetRange
+	if areas := definedRanges(f, sheet, printAreaName); len(areas) > 0 {
+		area = areas[0]
+		if area.endRow > 0 {
+			layout.rows = area.endRow
+		}
+		if area.endCol > 0 {
+			layout.cols = area.endCol
+		}
+	}
+	for _, titles := range definedRanges(f, sheet, printTitlesName) {
+		// Only title rows are repeated, title columns are not
+		if titles.startCol == 0 && titles.endCol == 0 && titles.startRow > 0 && titles.startRow <= layout.rows {
+			layout.titleRows = [2]int{titles.startRow, min(titles.endRow, layout.rows)}
+		}
+	}
+	if layout.rowBreaks, err = sheetRowBreaks(f, sheet); err != nil {
+		return nil, err
+	}
+	if layout.headerFooter, err = f.GetHeaderFooter(sheet); err != nil {
+		return nil, err
+	}
+
+	// Column widths and row heights, converted like Excel does to pixels
+	// and then to points
+	layout.colWidths = make([]float64, layout.cols+1)
+	for col := 1; col <= layout.cols; col++ {
+		name, err := excelize.ColumnNumberToName(col)
+		if err != nil {
+			return nil, err
+		}
+		if visible, _ := f.GetColVisible(sheet, name); !visible || col < area.startCol {
+			continue
+		}
+		width, err := f.GetColWidth(sheet, name)
+		if err != nil {
+			return nil, err
+		}
+		layout.colWidths[col] = float64(int(width*7+5)) * 0.75
+	}
+	layout.rowHeights = make([]float64, layout.rows+1)
+	for row := 1; row <= layout.rows; row++ {
+		if visible, _ := f.GetRowVisible(sheet, row); !visible || row < area.startRow && !layout.isTitleRow(row) {
+			continue
+		}
+		if layout.rowHeights[row], err = f.GetRowHeight(sheet, row); err != nil {
+			return nil, err
+		}
+	}
+
+	layout.mergeTop = make([]int, layout.rows+2)
+	for _, mergeRange := range mergeRanges {
+		for row := mergeRange[0].row + 1; row <= mergeRange[1].row && row <= layout.rows; row++ {
+			if top := layout.mergeTop[row]; top == 0 || mergeRange[0].row < top {
+				layout.mergeTop[row] = mergeRange[0].row
+			}
+		}
+	}
+	return layout, nil
+}
+
+// sheetRange is a range of a sheet, 0 for the columns of a range of whole
+// rows and for the rows of a range of whole columns
+type sheetRange struct {
+	startCol, startRow, endCol, endRow int
+}
+
+// sheetRangePattern matches one end of a range: a column, a row or a cell
+var sheetRangePattern = regexp.MustCompile(`^([A-Z]{0,3})([0-9]*)$`)
+
+// parseSheetRange parses a range reference such as Sheet1!$A$1:$D$20,
+// 'Act 1'!$1:$3 or $A:$B
+func parseSheetRange(ref string) (sheetRange, bool) {
+	if i := strings.LastIndex(ref, "!"); i >= 0 {
+		ref = ref[i+1:]
+	}
+	ref = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(ref), "$", ""))
+	start, end, found := strings.Cut(ref, ":")
+	if !found {
+		end = start
+	}
+
+	var r sheetRange
+	for i, part := range []string{start, end} {
+		match := sheetRangePattern.FindStringSubmatch(part)
+		if match == nil || part == "" {
+			return sheetRange{}, false
+		}
+		col, row := 0, 0
+		if match[1] != "" {
+			col, _ = excelize.ColumnNameToNumber(match[1])
+		}
+		if match[2] != "" {
+			row, _ = strconv.Atoi(match[2])
+		}
+		if i == 0 {
+			r.startCol, r.startRow = col, row
+		} else {
+			r.endCol, r.endRow = col, row
+		}
+	}
+	return r, true
+}
+
+// definedRanges returns the ranges of a defined name of the sheet
+func definedRanges(f *excelize.File, sheet, name string) []sheetRange {
+	var ranges []sheetRange
+	for _, definedName := range f.GetDefinedName() {
+		if !strings.EqualFold(definedName.Name, name) || definedName.Scope != sheet {
+			continue
+		}
+		refs, err := splitUnquoted(strings.TrimPrefix(definedName.RefersTo, "="), ',')
+		if err != nil {
+			utils.LogError("Skipping defined name %s of sheet %s: %v", name, sheet, err)
+			continue
+		}
+		for _, ref := range refs {
+			if r, ok := parseSheetRange(ref); ok {
+				ranges = append(ranges, r)
+			}
+		}
+	}
+	return ranges
+}
+
+// sheetRowBreaks returns the rows followed by a manual page break, which
+// excelize does not expose, from the part of the sheet
+func sheetRowBreaks(f *excelize.File, sheet string) (map[int]bool, error) {
+	sheetPath, err := sheetPartPath(f, sheet)
+	if err != nil || sheetPath == "" {
+		return nil, err
+	}
+	var worksheet struct {
+		Breaks []struct {
+			ID     int  `xml:"id,attr"`
+			Manual bool `xml:"man,attr"`
+		} `xml:"rowBreaks>brk"`
+	}
+	if err = readPackagePart(f, sheetPath, &worksheet); err != nil {
+		return nil, err
+	}
+
+	breaks := make(map[int]bool)
+	for _, brk := range worksheet.Breaks {
+		if brk.Manual && brk.ID > 0 {
+			breaks[brk.ID] = true
+		}
+	}
+	return breaks, nil
+}
+
+// isTitleRow reports whether the row is repeated on every page
+func (l *sheetLayout) isTitleRow(row int) bool {
+	return l.titleRows[0] > 0 && row >= l.titleRows[0] && row <= l.titleRows[1]
+}
+
+// repeatsTitles reports whether a page starting at the row repeats the
+// title rows above its own rows
+func (l *sheetLayout) repeatsTitles(firstRow int) bool {
+	return l.titleRows[0] > 0 && firstRow > l.titleRows[1]
+}
+
+// trim drops the trailing rows and columns that have nothing to draw
+func (l *sheetLayout) trim() {
+	drawn := func(col, row int) bool {
+		key := cellKey{col, row}
+		if l.value(col, row) != "" || l.covered[key] || len(l.pictures[key]) > 0 {
+			return true
+		}
+		if _, ok := l.merges[key]; ok {
+			return true
+		}
+		style := l.style(col, row)
+		return style != nil && (len(style.Border) > 0 || len(style.Fill.Color) > 0)
+	}
+
+	lastRow, lastCol := 0, 0
+	for row := 1; row <= l.rows; row++ {
+		for col := 1; col <= l.cols; col++ {
+			if drawn(col, row) {
+				lastRow, lastCol = row, max(lastCol, col)
+			}
+		}
+	}
+	l.rows, l.cols = lastRow, lastCol
+}
+
+// value returns the text of a cell as it is displayed. Formulas without a
+// cached result, such as the stretched sums of repeating blocks, are
+// calculated.
+func (l *sheetLayout) value(col, row int) string {
+	if row <= len(l.values) && col <= len(l.values[row-1]) {
+		if text := l.values[row-1][col-1]; text != "" {
+			return text
+		}
+	}
+
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return ""
+	}
+	if formula, _ := l.file.GetCellFormula(l.sheet, cell); formula == "" {
+		return ""
+	}
+	result, err := l.file.CalcCellValue(l.sheet, cell)
+	if err != nil {
+		utils.LogDebug("Could not calculate %s!%s for PDF: %v", l.sheet, cell, err)
+		return ""
+	}
+	if row <= len(l.values) {
+		for len(l.values[row-1]) < col {
+			l.values[row-1] = append(l.values[row-1], "")
+		}
+		l.values[row-1][col-1] = result
+	}
+	return result
+}
+
+// style returns the style of a cell, nil when it can not be read
+func (l *sheetLayout) style(col, row int) *excelize.Style {
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return nil
+	}
+	styleID, err := l.file.GetCellStyle(l.sheet, cell)
+	if err != nil {
+		return nil
+	}
+	if style, ok := l.styles[styleID]; ok {
+		return style
+	}
+	style, err := l.file.GetStyle(styleID)
+	if err != nil {
+		style = nil
+	}
+	l.styles[styleID] = style
+	return style
+}
+
+// isNumber reports whether a cell holds a number, which is aligned right by default
+func (l *sheetLayout) isNumber(col, row int) bool {
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return false
+	}
+	cellType, err := l.file.GetCellType(l.sheet, cell)
+	if err != nil {
+		return false
+	}
+	switch cellType {
+	case excelize.CellTypeNumber, excelize.CellTypeDate, excelize.CellTypeUnset:
+		raw, err := l.file.GetCellValue(l.sheet, cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return false
+		}
+		if raw == "" {
+			// Formula cells saved without a cached result are calculated
+			raw = l.value(col, row)
+		}
+		_, err = strconv.ParseFloat(raw, 64)
+		return err == nil
+	case excelize.CellTypeFormula:
+		_, err = strconv.ParseFloat(l.value(col, row), 64)
+		return err == nil
+	default:
+		return false
+	}
+}
+
+// pageSetup returns the page size, the printable area and the scale of the sheet
+func (l *sheetLayout) pageSetup() (pageWidth, pageHeight, left, top, scale float64) {
+	size := pdfPaperSizes[9]
+	pageLayout, err := l.file.GetPageLayout(l.sheet)
+	if err == nil && pageLayout.Size != nil {
+		if paper, ok := pdfPaperSizes[*pageLayout.Size]; ok {
+			size = paper
+		}
+	}
+	pageWidth, pageHeight = size[0], size[1]
+	if err == nil && pageLayout.Orientation != nil && *pageLayout.Orientation == "landscape" {
+		pageWidth, pageHeight = pageHeight, pageWidth
+	}
+
+	margins, _ := l.file.GetPageMargins(l.sheet)
+	left, right := pageMargin(margins.Left, 0.7), pageMargin(margins.Right, 0.7)
+	top, bottom := pageMargin(margins.Top, 0.75), pageMargin(margins.Bottom, 0.75)
+
+	var contentWidth, contentHeight float64
+	for _, width := range l.colWidths {
+		contentWidth += width
+	}
+	for _, height := range l.rowHeights {
+		contentHeight += height
+	}
+
+	scale = 1
+	if err == nil && pageLayout.AdjustTo != nil && *pageLayout.AdjustTo >= 10 {
+		scale = float64(*pageLayout.AdjustTo) / 100
+	}
+	if printable := pageWidth - left - right; contentWidth*scale > printable && contentWidth > 0 {
+		scale = printable / contentWidth
+	}
+	fitToPage := err == nil && pageLayout.FitToHeight != nil && *pageLayout.FitToHeight == 1
+	if printable := pageHeight - top - bottom; fitToPage && contentHeight*scale > printable && contentHeight > 0 {
+		scale = printable / contentHeight
+	}
+	if margins.Horizontally != nil && *margins.Horizontally {
+		left += (pageWidth - left - right - contentWidth*scale) / 2
+	}
+	return pageWidth, pageHeight, left, top, scale
+}
+
+// pageMargin converts a margin of the page setup from inches to points
+func pageMargin(value *float64, fallback float64) float64 {
+	if value == nil {
+		return fallback * 72
+	}
+	return *value * 72
+}
+
+// paginate splits the rows into pages of the printable height and at the
+// manual page breaks, keeping merged ranges on one page when they fit.
+// Pages below the title rows leave room to repeat them.
+func (l *sheetLayout) paginate(printable float64) [][2]int {
+	var titleHeight float64
+	if l.titleRows[0] > 0 {
+		for row := l.titleRows[0]; row <= l.titleRows[1]; row++ {
+			titleHeight += l.rowHeights[row]
+		}
+	}
+
+	var pages [][2]int
+	first, used := 1, 0.0
+	for row := 1; row <= l.rows; row++ {
+		height := l.rowHeights[row]
+		available := printable
+		if l.repeatsTitles(first) {
+			available -= titleHeight
+		}
+		if row > first && l.rowBreaks[row-1] {
+			pages = append(pages, [2]int{first, row - 1})
+			first, used = row, 0
+		} else if used+height > available && row > first {
+			next := row
+			if top := l.mergeTop[row]; top > first && top < row {
+				next = top
+			}
+			pages = append(pages, [2]int{first, next - 1})
+			first, used = next, 0
+			for r := next; r < row; r++ {
+				used += l.rowHeights[r]
+			}
+		}
+		used += height
+	}
+	if first <= l.rows {
+		pages = append(pages, [2]int{first, l.rows})
+	}
+	return pages
+}
+
+// render draws the sheet on new pages of the document
+func (l *sheetLayout) render(doc *pdfDocument, fonts *pdfFontSet) error {
+	if l.rows == 0 || l.cols == 0 {
+		return nil
+	}
+
+	pageWidth, pageHeight, left, top, scale := l.pageSetup()
+	margins, _ := l.file.GetPageMargins(l.sheet)
+	bottom := pageMargin(margins.Bottom, 0.75)
+
+	colX := make([]float64, l.cols+2)
+	colX[1] = left
+	for col := 1; col <= l.cols; col++ {
+		colX[col+1] = colX[col] + l.colWidths[col]*scale
+	}
+
+	pages := l.paginate((pageHeight - top - bottom) / scale)
+	for number, pageRows := range pages {
+		page := doc.addPage(pageWidth, pageHeight)
+
+		// The rows of the page, below the title rows when they are repeated
+		var rows []int
+		if l.repeatsTitles(pageRows[0]) {
+			for row := l.titleRows[0]; row <= l.titleRows[1]; row++ {
+				rows = append(rows, row)
+			}
+		}
+		for row := pageRows[0]; row <= pageRows[1]; row++ {
+			rows = append(rows, row)
+		}
+		rowY := make([]float64, len(rows)+1)
+		rowY[0] = top
+		for i, row := range rows {
+			rowY[i+1] = rowY[i] + l.rowHeights[row]*scale
+		}
+
+		// Fills are drawn first and borders last, so that text and
+		// pictures never cover the borders of neighbouring cells
+		fills, texts, borders := &pdfPage{height: pageHeight}, &pdfPage{height: pageHeight}, &pdfPage{height: pageHeight}
+		for i, row := range rows {
+			if l.rowHeights[row] == 0 {
+				continue
+			}
+			for col := 1; col <= l.cols; col++ {
+				key := cellKey{col, row}
+				if l.colWidths[col] == 0 || l.covered[key] {
+					continue
+				}
+
+				// The box of the cell or of its merged range on this page
+				x, width := colX[col], l.colWidths[col]*scale
+				boxTop, height := rowY[i], l.rowHeights[row]*scale
+				if end, ok := l.merges[key]; ok {
+					width = colX[min(end.col, l.cols)+1] - x
+					last := i
+					for last+1 < len(rows) && rows[last+1] == rows[last]+1 && rows[last+1] <= end.row {
+						last++
+					}
+					height = rowY[last+1] - boxTop
+				}
+				box := pdfBox{x: x, top: boxTop, width: width, height: height}
+
+				style := l.style(col, row)
+				if style != nil {
+					drawFill(fills, box, style.Fill)
+					drawBorders(borders, box, style.Border, scale)
+				}
+				if text := l.value(col, row); text != "" {
+					l.drawText(doc, texts, fonts, key, box, colX, text, style, scale)
+				}
+				for _, content := range l.pictures[key] {
+					img, err := newPDFImage(content)
+					if err != nil {
+						utils.LogError("Skipping picture at %s row %d col %d in PDF: %v", l.sheet, row, col, err)
+						continue
+					}
+					drawPicture(texts, doc.addImage(img), img, box, scale)
+				}
+			}
+		}
+
+		page.content.Write(fills.content.Bytes())
+		if l.watermark != "" {
+			drawWatermark(doc, page, fonts, l.watermark)
+		}
+		page.content.Write(texts.content.Bytes())
+		page.content.Write(borders.content.Bytes())
+		l.drawHeaderFooter(doc, page, fonts, number+1, len(pages), scale)
+	}
+	return nil
+}
+
+// headerSection is the left, centre or right part of a header or footer
+type headerSection struct {
+	text         string
+	size         float64
+	bold, italic bool
+}
+
+// headerFields are the values of the codes of headers and footers
+type headerFields struct {
+	page, pages int
+	sheet, file string
+	now         time.Time
+}
+
+// parseHeaderFooter splits a header or footer into its left, centre and
+// right sections, replacing the codes of the page number, the page count,
+// the date, the time and the sheet and file names. Font codes set the size,
+// bold and italic of the whole section; colors, underlines and pictures
+// are ignored.
+func parseHeaderFooter(text string, fields headerFields) [3]headerSection {
+	var sections [3]headerSection
+	current := 1 // text before a section code is centred
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		section := &sections[current]
+		if runes[i] != '&' || i+1 == len(runes) {
+			section.text += string(runes[i])
+			continue
+		}
+		i++
+		switch code := runes[i]; {
+		case code == '&':
+			section.text += "&"
+		case code == 'L':
+			current = 0
+		case code == 'C':
+			current = 1
+		case code == 'R':
+			current = 2
+		case code == 'P':
+			section.text += strconv.Itoa(fields.page)
+		case code == 'N':
+			section.text += strconv.Itoa(fields.pages)
+		case code == 'D':
+			section.text += fields.now.Format(utils.DefaultLocale.DateLayout)
+		case code == 'T':
+			section.text += fields.now.Format("15:04")
+		case code == 'A':
+			section.text += fields.sheet
+		case code == 'F':
+			section.text += fields.file
+		case code == 'B':
+			section.bold = !section.bold
+		case code == 'I':
+			section.italic = !section.italic
+		case code == '"':
+			// &"Font name,Style"
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if _, style, ok := strings.Cut(string(runes[i+1:min(end, len(runes))]), ","); ok {
+				section.bold = strings.Contains(style, "Bold")
+				section.italic = strings.Contains(style, "Italic") || strings.Contains(style, "Oblique")
+			}
+			i = end
+		case code == 'K':
+			// &Krrggbb or a theme color of the same length
+			i = min(i+6, len(runes)-1)
+		case unicode.IsDigit(code):
+			end := i
+			for end < len(runes) && unicode.IsDigit(runes[end]) {
+				end++
+			}
+			size, _ := strconv.Atoi(string(runes[i:end]))
+			section.size = float64(size)
+			i = end - 1
+		}
+	}
+	return sections
+}
+
+// drawHeaderFooter writes the header and the footer of a page in their
+// margins, with the sections aligned to the left and right page margins
+func (l *sheetLayout) drawHeaderFooter(doc *pdfDocument, page *pdfPage, fonts *pdfFontSet, number, pages int, scale float64) {
+	opts := l.headerFooter
+	if opts == nil {
+		return
+	}
+	header, footer := opts.OddHeader, opts.OddFooter
+	switch {
+	case opts.DifferentFirst && number == 1:
+		header, footer = opts.FirstHeader, opts.FirstFooter
+	case opts.DifferentOddEven && number%2 == 0:
+		header, footer = opts.EvenHeader, opts.EvenFooter
+	}
+	if opts.ScaleWithDoc != nil && !*opts.ScaleWithDoc {
+		scale = 1
+	}
+
+	margins, _ := l.file.GetPageMargins(l.sheet)
+	left, right := pageMargin(margins.Left, 0.7), page.width-pageMargin(margins.Right, 0.7)
+	fields := headerFields{page: number, pages: pages, sheet: l.sheet, file: filepath.Base(l.file.Path), now: time.Now()}
+
+	for _, part := range []struct {
+		text     string
+		edge     float64
+		isFooter bool
+	}{
+		{text: header, edge: pageMargin(margins.Header, 0.3)},
+		{text: footer, edge: page.height - pageMargin(margins.Footer, 0.3), isFooter: true},
+	} {
+		if part.text == "" {
+			continue
+		}
+		for align, section := range parseHeaderFooter(part.text, fields) {
+			if section.text == "" {
+				continue
+			}
+			size := section.size
+			if size == 0 {
+				size = pdfDefaultFontSize
+			}
+			size *= scale
+			pdfFont, fakeBold, fakeItalic := fonts.choose(section.bold, section.italic)
+			style := pdfTextStyle{font: doc.addFont(pdfFont), size: size, fakeBold: fakeBold, italic: fakeItalic}
+
+			// Headers grow down from the top edge and footers up from the bottom one
+			lines := strings.Split(section.text, "\n")
+			lineHeight := size * pdfLineSpacing
+			baseline := part.edge + pdfFont.ascent()*size
+			if part.isFooter {
+				baseline = part.edge - pdfFont.descent()*size - float64(len(lines)-1)*lineHeight
+			}
+			for i, line := range lines {
+				width := pdfFont.width(line, size)
+				x := left
+				switch align {
+				case 1:
+					x = (left + right - width) / 2
+				case 2:
+					x = right - width
+				}
+				page.text(x, baseline+float64(i)*lineHeight, pdfFont.encode(line), style)
+			}
+		}
+	}
+}
+
+// pdfBox is the rectangle of a cell on a page
+type pdfBox struct {
+	x, top, width, height float64
+}
+
+// drawFill fills the box with the solid or gradient color of the cell
+func drawFill(page *pdfPage, box pdfBox, fill excelize.Fill) {
+	if len(fill.Color) == 0 || fill.Type == "pattern" && fill.Pattern == 0 {
+		return
+	}
+	if c, ok := parsePDFColor(fill.Color[0]); ok {
+		page.fillRect(box.x, box.top, box.width, box.height, c)
+	}
+}
+
+// pdfBorderStyles maps the border styles of cells to line widths and dash patterns
+var pdfBorderStyles = map[int]struct {
+	width float64
+	dash  string
+}{
+	1: {0.5, ""}, 2: {1, ""}, 3: {0.5, "3 2"}, 4: {0.5, "1 1"}, 5: {1.5, ""},
+	6: {1.5, ""}, 7: {0.25, ""}, 8: {1, "3 2"}, 9: {0.5, "4 2 1 2"}, 10: {1, "4 2 1 2"},
+	11: {0.5, "4 2 1 2 1 2"}, 12: {1, "4 2 1 2 1 2"}, 13: {1, "4 2 1 2"},
+}
+
+// drawBorders strokes the borders of the box
+func drawBorders(page *pdfPage, box pdfBox, borders []excelize.Border, scale float64) {
+	for _, border := range borders {
+		lineStyle, ok := pdfBorderStyles[border.Style]
+		if !ok {
+			continue
+		}
+		c, ok := parsePDFColor(border.Color)
+		if !ok {
+			c = pdfColor{}
+		}
+		right, bottom := box.x+box.width, box.top+box.height
+		width := lineStyle.width * max(scale, 0.5)
+		switch border.Type {
+		case "left":
+			page.line(box.x, box.top, box.x, bottom, width, c, lineStyle.dash)
+		case "right":
+			page.line(right, box.top, right, bottom, width, c, lineStyle.dash)
+		case "top":
+			page.line(box.x, box.top, right, box.top, width, c, lineStyle.dash)
+		case "bottom":
+			page.line(box.x, bottom, right, bottom, width, c, lineStyle.dash)
+		}
+	}
+}
+
+// drawPicture draws a picture at the top left corner of the box, shrunk to
+// fit it with the aspect ratio kept, like pictures are placed in cells
+func drawPicture(page *pdfPage, name string, img *pdfImage, box pdfBox, scale float64) {
+	width, height := float64(img.width)*0.75*scale, float64(img.height)*0.75*scale
+	if fit := min(box.width/width, box.height/height); fit < 1 {
+		width, height = width*fit, height*fit
+	}
+	page.drawImage(name, box.x, box.top, width, height)
+}
+
+// drawText writes the text of a cell with its font and alignment. Text that
+// is not wrapped overflows into the empty cells to the right, like in Excel.
+func (l *sheetLayout) drawText(doc *pdfDocument, page *pdfPage, fonts *pdfFontSet, key cellKey, box pdfBox, colX []float64, text string, style *excelize.Style, scale float64) {
+	var (
+		font      = excelize.Font{}
+		alignment = excelize.Alignment{}
+	)
+	if style != nil {
+		if style.Font != nil {
+			font = *style.Font
+		}
+		if style.Alignment != nil {
+			alignment = *style.Alignment
+		}
+	}
+
+	size := font.Size
+	if size == 0 {
+		size = pdfDefaultFontSize
+	}
+	size *= scale
+	pdfFont, fakeBold, fakeItalic := fonts.choose(font.Bold, font.Italic)
+	textStyle := pdfTextStyle{font: doc.addFont(pdfFont), size: size, fakeBold: fakeBold, italic: fakeItalic}
+	if c, ok := parsePDFColor(font.Color); ok {
+		textStyle.color = c
+	}
+
+	padding := pdfCellPadding * scale
+	available := box.width - 2*padding
+	horizontal := alignment.Horizontal
+	if horizontal == "" || horizontal == "general" {
+		horizontal = "left"
+		if l.isNumber(key.col, key.row) {
+			horizontal = "right"
+		}
+	}
+
+	var lines []string
+	clip := box
+	if alignment.WrapText {
+		lines = wrapPDFText(pdfFont, text, size, available)
+	} else {
+		lines = []string{strings.ReplaceAll(text, "\n", " ")}
+		width := pdfFont.width(lines[0], size)
+		switch {
+		case alignment.ShrinkToFit && width > available && width > 0:
+			size *= available / width
+			textStyle.size = size
+		case horizontal == "left" && width > available:
+			// Overflow into the following empty cells
+			_, merged := l.merges[key]
+			for col := key.col + 1; !merged && col <= l.cols && clip.x+clip.width < box.x+width+2*padding; col++ {
+				next := cellKey{col, key.row}
+				if _, isMerge := l.merges[next]; isMerge || l.covered[next] || l.value(col, key.row) != "" {
+					break
+				}
+				clip.width = colX[col+1] - clip.x
+			}
+		}
+	}
+
+	lineHeight := size * pdfLineSpacing
+	textHeight := lineHeight * float64(len(lines))
+	var blockTop float64
+	switch alignment.Vertical {
+	case "top":
+		blockTop = box.top + padding/2
+	case "center", "justify", "distributed":
+		blockTop = box.top + (box.height-textHeight)/2
+	default:
+		blockTop = box.top + box.height - textHeight - padding/2
+	}
+
+	page.clip(clip.x, clip.top, clip.width, clip.height)
+	for i, line := range lines {
+		width := pdfFont.width(line, size)
+		x := box.x + padding
+		switch horizontal {
+		case "center", "centerContinuous":
+			x = box.x + (box.width-width)/2
+		case "right":
+			x = box.x + box.width - padding - width
+		}
+		baseline := blockTop + float64(i)*lineHeight + (lineHeight+(pdfFont.ascent()-pdfFont.descent())*size)/2
+		page.text(x, baseline, pdfFont.encode(line), textStyle)
+		if font.Underline != "" && font.Underline != "none" {
+			page.line(x, baseline+size*0.1, x+width, baseline+size*0.1, size*0.05, textStyle.color, "")
+		}
+	}
+	page.unclip()
+}
+
+// drawWatermark draws the text in light grey diagonally across the middle
+// of the page, below the text of the cells
+func drawWatermark(doc *pdfDocument, page *pdfPage, fonts *pdfFontSet, text string) {
+	pdfFont, fakeBold, _ := fonts.choose(true, false)
+	width, height := page.width, page.height
+	diagonal := math.Hypot(width, height)
+
+	// The text spans at most two thirds of the diagonal
+	size := float64(watermarkFontSize)
+	if textWidth := pdfFont.width(text, size); textWidth > diagonal*2/3 {
+		size *= diagonal * 2 / 3 / textWidth
+	}
+	style := pdfTextStyle{font: doc.addFont(pdfFont), size: size, fakeBold: fakeBold, color: pdfWatermarkColor}
+	// The text is centred on the middle of the page along the diagonal
+	dx := -pdfFont.width(text, size) / 2
+	dy := -(pdfFont.ascent() + pdfFont.descent()) * size / 2
+	page.rotatedText(width/2, height/2, math.Atan2(height, width), dx, dy, pdfFont.encode(text), style)
+}
+
+// wrapPDFText breaks text into lines of at most the given width at spaces,
+// breaking words that do not fit on a line of their own
+func wrapPDFText(font pdfFont, text string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if font.width(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = ""
+			for _, r := range word {
+				if line != "" && font.width(line+string(r), size) > width {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/xuri/excelize/v2"
+)
+
+// pdfStreams checks the cross-reference table of a PDF file and returns
+// its decompressed content streams
+func pdfStreams(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if startxref == nil {
+		t.Fatal("startxref not found")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to the xref table", xref)
+	}
+	for i, entry := range regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1) {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if prefix := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(data[offset:], []byte(prefix)) {
+			t.Fatalf("xref entry %d points to %q", i+1, data[offset:offset+10])
+		}
+	}
+
+	var streams []string
+	for _, match := range regexp.MustCompile(`(?s)/Length (\d+) ([^>]*)>>\nstream\n`).FindAllSubmatchIndex(data, -1) {
+		length, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		content := data[match[1] : match[1]+length]
+		if bytes.Contains(data[match[4]:match[5]], []byte("/FlateDecode")) && !bytes.Contains(data[match[4]:match[5]], []byte("/Image")) {
+			reader, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				t.Fatalf("stream is not compressed: %v", err)
+			}
+			if content, err = io.ReadAll(reader); err != nil {
+				t.Fatal(err)
+			}
+		}
+		streams = append(streams, string(content))
+	}
+	return streams
+}
+
+func TestExportPDF(t *testing.T) {
+	dir := t.TempDir()
+	workbookPath := filepath.Join(dir, "act.xlsx")
+	writePDFWorkbook(t, workbookPath)
+
+	service := NewExcelService(&config.Config{})
+	pdfPath := filepath.Join(dir, "act.pdf")
+	if err := service.ExportPDF(workbookPath, pdfPath, PDFOptions{}); err != nil {
+		t.Fatalf("ExportPDF() error = %v", err)
+	}
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A single landscape A4 page with the standard fonts and the picture
+	for _, expected := range []string{"/MediaBox [0 0 842 595]", "/Count 1", "/BaseFont /Helvetica-Bold", "/Subtype /Image"} {
+		if !bytes.Contains(data, []byte(expected)) {
+			t.Errorf("PDF does not contain %q", expected)
+		}
+	}
+
+	page := strings.Join(pdfStreams(t, data), "\n")
+	for _, expected := range []string{
+		`(Act \(draft\) 1) Tj`,       // merged title, escaped
+		"(A long description of) Tj", // wrapped at the column width
+		"(1234.5) Tj",                // number
+		"(1244.5) Tj",                // calculated sum
+		"0.867 0.933 1 rg",           // fill
+		"/Im1 Do",                    // picture
+		"1 w [] 0 d",                 // medium top border
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("page content does not contain %q", expected)
+		}
+	}
+}
+
+// testPDFFontPath returns the path of DejaVu Sans on Debian or Alpine and
+// skips the test when it is not installed
+func testPDFFontPath(t *testing.T) string {
+	t.Helper()
+
+	for _, fontPath := range []string{
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+		"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	} {
+		if _, err := os.Stat(fontPath); err == nil {
+			return fontPath
+		}
+	}
+	t.Skip("DejaVu Sans is not installed")
+	return ""
+}
+
+func TestExportPDFEmbedsTrueTypeSubset(t *testing.T) {
+	fontPath := testPDFFontPath(t)
+
+	dir := t.TempDir()
+	f := excelize.NewFile()
+	if err := f.SetCellValue("Sheet1", "A1", "Акт № 1"); err != nil {
+		t.Fatal(err)
+	}
+	workbookPath := filepath.Join(dir, "act.xlsx")
+	if err := f.SaveAs(workbookPath); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	service := NewExcelService(&config.Config{PDFFontPath: fontPath})
+	pdfPath := filepath.Join(dir, "act.pdf")
+	if err := service.ExportPDF(workbookPath, pdfPath, PDFOptions{}); err != nil {
+		t.Fatalf("ExportPDF() error = %v", err)
+	}
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := pdfStreams(t, data)
+	face, err := loadTrueTypeFace(fontPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subset *trueTypeFace
+	for _, stream := range streams {
+		if strings.HasPrefix(stream, "\x00\x01\x00\x00") {
+			if subset, err = parseTrueType([]byte(stream)); err != nil {
+				t.Fatalf("embedded font does not parse: %v", err)
+			}
+		}
+	}
+	if subset == nil {
+		t.Fatal("embedded font not found")
+	}
+
+	// The glyphs of the text are kept, others are dropped
+	for _, r := range "Акт№1" {
+		if len(subset.glyph(int(face.cmap[r]))) == 0 {
+			t.Errorf("glyph of %q is missing from the subset", r)
+		}
+	}
+	if len(subset.glyph(int(face.cmap['Z']))) != 0 {
+		t.Error("glyph of 'Z' is kept in the subset")
+	}
+	toUnicode := fmt.Sprintf("<%04X> <0410>", face.cmap['А'])
+	if !strings.Contains(strings.Join(streams, "\n"), toUnicode) {
+		t.Errorf("ToUnicode map does not contain %q", toUnicode)
+	}
+}
+
+func TestExportPDFRejectsUnsupportedText(t *testing.T) {
+	dir := t.TempDir()
+	workbookPath := filepath.Join(dir, "act.xlsx")
+	writeTestWorkbook(t, workbookPath, map[string]interface{}{"A1": "Act", "A2": "Акт № 1"}, nil)
+
+	service := NewExcelService(&config.Config{})
+	pdfPath := filepath.Join(dir, "act.pdf")
+	err := service.ExportPDF(workbookPath, pdfPath, PDFOptions{})
+	if !errors.Is(err, ErrPDFUnsupportedText) {
+		t.Fatalf("ExportPDF() error = %v, expected ErrPDFUnsupportedText", err)
+	}
+	if !strings.Contains(err.Error(), "'А'") {
+		t.Errorf("error = %q, expected the missing characters", err)
+	}
+	if _, statErr := os.Stat(pdfPath); !os.IsNotExist(statErr) {
+		t.Errorf("PDF file exists after a failed export: %v", statErr)
+	}
+}
+
+func TestExportPDFPrintSetup(t *testing.T) {
+	cells := map[string]interface{}{"A1": "Title", "A2": "Skipped"}
+	for row := 3; row <= 45; row++ {
+		cells[fmt.Sprintf("A%d", row)] = fmt.Sprintf("Row %d", row)
+		cells[fmt.Sprintf("C%d", row)] = "Outside"
+	}
+
+	dir := t.TempDir()
+	workbookPath := filepath.Join(dir, "act.xlsx")
+	writeTestWorkbook(t, workbookPath, cells, func(f *excelize.File, sheet string) error {
+		for _, err := range []error{
+			f.SetDefinedName(&excelize.DefinedName{Name: printAreaName, RefersTo: sheet + "!$A$3:$B$40", Scope: sheet}),
+			f.SetDefinedName(&excelize.DefinedName{Name: printTitlesName, RefersTo: sheet + "!$1:$1", Scope: sheet}),
+			f.InsertPageBreak(sheet, "A11"),
+			f.SetHeaderFooter(sheet, &excelize.HeaderFooterOptions{
+				OddHeader: `&L&"-,Bold"Act&RPage &P of &N`,
+				OddFooter: "&C&A",
+			}),
+		} {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	service := NewExcelService(&config.Config{})
+	pdfPath := filepath.Join(dir, "act.pdf")
+	if err := service.ExportPDF(workbookPath, pdfPath, PDFOptions{}); err != nil {
+		t.Fatalf("ExportPDF() error = %v", err)
+	}
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("/Count 2")) {
+		t.Fatal("PDF does not have two pages")
+	}
+
+	var pages []string
+	for _, stream := range pdfStreams(t, data) {
+		if strings.Contains(stream, " Tj") {
+			pages = append(pages, stream)
+		}
+	}
+	if len(pages) != 2 {
+		t.Fatalf("found %d page contents, expected 2", len(pages))
+	}
+
+	expected := [][]string{
+		{"(Title) Tj", "(Row 3) Tj", "(Row 10) Tj", "(Act) Tj", "(Page 1 of 2) Tj", "(Sheet1) Tj"},
+		{"(Title) Tj", "(Row 11) Tj", "(Row 40) Tj", "(Page 2 of 2) Tj", "(Sheet1) Tj"},
+	}
+	unexpected := [][]string{
+		{"(Skipped) Tj", "(Row 11) Tj", "(Outside) Tj"},
+		{"(Row 10) Tj", "(Row 41) Tj", "(Outside) Tj"},
+	}
+	for i, page := range pages {
+		for _, text := range expected[i] {
+			if !strings.Contains(page, text) {
+				t.Errorf("page %d does not contain %q", i+1, text)
+			}
+		}
+		for _, text := range unexpected[i] {
+			if strings.Contains(page, text) {
+				t.Errorf("page %d contains %q", i+1, text)
+			}
+		}
+	}
+}
+
+func TestParseSheetRange(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected sheetRange
+		invalid  bool
+	}{
+		{ref: "Sheet1!$A$1:$D$20", expected: sheetRange{startCol: 1, startRow: 1, endCol: 4, endRow: 20}},
+		{ref: "'Act 1'!$1:$3", expected: sheetRange{startRow: 1, endRow: 3}},
+		{ref: "$B:$C", expected: sheetRange{startCol: 2, endCol: 3}},
+		{ref: "C5", expected: sheetRange{startCol: 3, startRow: 5, endCol: 3, endRow: 5}},
+		{ref: "Sheet1!#REF!", invalid: true},
+		{ref: "", invalid: true},
+	}
+
+	for _, tt := range tests {
+		r, ok := parseSheetRange(tt.ref)
+		if ok == tt.invalid {
+			t.Errorf("parseSheetRange(%q) ok = %v, expected %v", tt.ref, ok, !tt.invalid)
+			continue
+		}
+		if ok && r != tt.expected {
+			t.Errorf("parseSheetRange(%q) = %+v, expected %+v", tt.ref, r, tt.expected)
+		}
+	}
+}
+
+func TestParseHeaderFooter(t *testing.T) {
+	fields := headerFields{page: 2, pages: 5, sheet: "Act", file: "act.xlsx", now: time.Date(2026, time.October, 17, 9, 30, 0, 0, time.UTC)}
+
+	tests := []struct {
+		text     string
+		expected [3]headerSection
+	}{
+		{text: "Centred", expected: [3]headerSection{1: {text: "Centred"}}},
+		{
+			text:     "&LPage &P of &N&C&A&R&F",
+			expected: [3]headerSection{{text: "Page 2 of 5"}, {text: "Act"}, {text: "act.xlsx"}},
+		},
+		{text: "&L&D &T", expected: [3]headerSection{{text: "17.10.2026 09:30"}}},
+		{
+			text:     `&L&"Arial,Bold Italic"&14Title&R&B&KFF0000R&&D`,
+			expected: [3]headerSection{{text: "Title", size: 14, bold: true, italic: true}, {}, {text: "R&D", bold: true}},
+		},
+		{text: "&C&U&GLogo&", expected: [3]headerSection{1: {text: "Logo&"}}},
+	}
+
+	for _, tt := range tests {
+		if sections := parseHeaderFooter(tt.text, fields); sections != tt.expected {
+			t.Errorf("parseHeaderFooter(%q) = %+v, expected %+v", tt.text, sections, tt.expected)
+		}
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	if format, err := ParseOutputFormat(""); err != nil || format != FormatXLSX {
+		t.Errorf("ParseOutputFormat(\"\") = %q, %v, expected xlsx", format, err)
+	}
+	if format, err := ParseOutputFormat("PDF"); err != nil || format != FormatPDF {
+		t.Errorf("ParseOutputFormat(\"PDF\") = %q, %v, expected pdf", format, err)
+	}
+	if _, err := ParseOutputFormat("docx"); err == nil {
+		t.Error("ParseOutputFormat(\"docx\") expected an error")
+	}
+}
@@ -0,0 +1,674 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// pdfFont is a font text is written with in a PDF document
+type pdfFont interface {
+	// width returns the width of the text in points at the font size
+	width(text string, size float64) float64
+	// encode returns the text as a PDF string operand of the Tj operator
+	encode(text string) string
+	// ascent and descent return the extent of the font above and below the
+	// baseline as a fraction of the font size
+	ascent() float64
+	descent() float64
+	// write writes the font objects and returns the object number of the font
+	write(w *pdfWriter) int
+}
+
+// standardFont is one of the base 14 fonts every PDF reader provides. Text
+// is written in WinAnsi encoding, so it covers Latin-1 only.
+type standardFont struct {
+	name   string
+	widths *[95]int
+	// missing collects the characters written as question marks
+	missing map[rune]bool
+}
+
+// helveticaWidths and helveticaBoldWidths are the glyph widths of the ASCII
+// characters from space to tilde, in thousandths of the font size
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// winAnsiSpecials maps the characters of WinAnsi encoding outside of Latin-1
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// winAnsiByte returns the WinAnsi code of the character, '?' when it has none
+func winAnsiByte(r rune) byte {
+	switch {
+	case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+		return byte(r)
+	case winAnsiSpecials[r] != 0:
+		return winAnsiSpecials[r]
+	default:
+		return '?'
+	}
+}
+
+func (f *standardFont) width(text string, size float64) float64 {
+	total := 0
+	for _, r := range text {
+		code := winAnsiByte(r)
+		if code >= 0x20 && code < 0x7f {
+			total += f.widths[code-0x20]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+func (f *standardFont) encode(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		code := winAnsiByte(r)
+		if code == '?' {
+			recordMissing(f.missing, r)
+		}
+		switch code {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(code)
+		default:
+			b.WriteByte(code)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func (f *standardFont) ascent() float64  { return 0.718 }
+func (f *standardFont) descent() float64 { return 0.207 }
+
+func (f *standardFont) write(w *pdfWriter) int {
+	ref := w.newObject()
+	w.writeObject(ref, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.name))
+	return ref
+}
+
+// trueTypeFace is a parsed TrueType font file
+type trueTypeFace struct {
+	name       string
+	tables     map[string][]byte
+	unitsPerEm float64
+	ascender   int16
+	descender  int16
+	bbox       [4]int16
+	advances   []uint16
+	cmap       map[rune]uint16
+	longLoca   bool
+	numGlyphs  int
+}
+
+// trueTypeFaces caches parsed font files by path
+var trueTypeFaces sync.Map
+
+// loadTrueTypeFace reads and parses a TrueType font file once per process
+func loadTrueTypeFace(path string) (*trueTypeFace, error) {
+	if face, ok := trueTypeFaces.Load(path); ok {
+		return face.(*trueTypeFace), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	face, err := parseTrueType(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s: %w", path, err)
+	}
+	trueTypeFaces.Store(path, face)
+	return face, nil
+}
+
+// errTrueType is returned for font files that can not be embedded
+var errTrueType = errors.New("unsupported or malformed TrueType font")
+
+// parseTrueType reads the tables of a TrueType font needed to measure,
+// subset and embed it
+func parseTrueType(data []byte) (*trueTypeFace, error) {
+	if len(data) < 12 {
+		return nil, errTrueType
+	}
+	switch binary.BigEndian.Uint32(data) {
+	case 0x00010000, 0x74727565: // 1.0 and 'true'
+	default:
+		return nil, fmt.Errorf("%w: only TrueType outlines are supported", errTrueType)
+	}
+
+	face := &trueTypeFace{tables: make(map[string][]byte)}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		entry := 12 + i*16
+		if entry+16 > len(data) {
+			return nil, errTrueType
+		}
+		tag := string(data[entry : entry+4])
+		offset := int(binary.BigEndian.Uint32(data[entry+8:]))
+		length := int(binary.BigEndian.Uint32(data[entry+12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, errTrueType
+		}
+		face.tables[tag] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "cmap", "loca", "glyf"} {
+		if face.tables[tag] == nil {
+			return nil, fmt.Errorf("%w: table %s is missing", errTrueType, tag)
+		}
+	}
+
+	head, hhea, maxp := face.tables["head"], face.tables["hhea"], face.tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, errTrueType
+	}
+	face.unitsPerEm = float64(binary.BigEndian.Uint16(head[18:]))
+	for i := range face.bbox {
+		face.bbox[i] = int16(binary.BigEndian.Uint16(head[36+2*i:]))
+	}
+	face.longLoca = binary.BigEndian.Uint16(head[50:]) == 1
+	face.ascender = int16(binary.BigEndian.Uint16(hhea[4:]))
+	face.descender = int16(binary.BigEndian.Uint16(hhea[6:]))
+	face.numGlyphs = int(binary.BigEndian.Uint16(maxp[4:]))
+	if face.unitsPerEm == 0 {
+		return nil, errTrueType
+	}
+
+	// Advance widths, glyphs past the last metric share its advance
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := face.tables["hmtx"]
+	if numMetrics == 0 || len(hmtx) < numMetrics*4 {
+		return nil, errTrueType
+	}
+	face.advances = make([]uint16, face.numGlyphs)
+	for gid := range face.advances {
+		metric := gid
+		if metric >= numMetrics {
+			metric = numMetrics - 1
+		}
+		face.advances[gid] = binary.BigEndian.Uint16(hmtx[metric*4:])
+	}
+
+	var err error
+	if face.cmap, err = parseCmap(face.tables["cmap"]); err != nil {
+		return nil, err
+	}
+	face.name = trueTypeName(face.tables["name"])
+	return face, nil
+}
+
+// parseCmap reads the Unicode character to glyph mapping of a font,
+// preferring the full repertoire subtable over the BMP one
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, errTrueType
+	}
+
+	best, bestRank := -1, 0
+	numSubtables := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < numSubtables; i++ {
+		record := 4 + i*8
+		if record+8 > len(cmap) {
+			return nil, errTrueType
+		}
+		platform := binary.BigEndian.Uint16(cmap[record:])
+		encoding := binary.BigEndian.Uint16(cmap[record+2:])
+		offset := int(binary.BigEndian.Uint32(cmap[record+4:]))
+		rank := 0
+		switch {
+		case platform == 3 && encoding == 10:
+			rank = 4
+		case platform == 0 && encoding >= 4:
+			rank = 3
+		case platform == 3 && encoding == 1:
+			rank = 2
+		case platform == 0:
+			rank = 1
+		}
+		if rank > bestRank && offset+4 <= len(cmap) {
+			best, bestRank = offset, rank
+		}
+	}
+	if best < 0 {
+		return nil, fmt.Errorf("%w: no Unicode character map", errTrueType)
+	}
+
+	table := cmap[best:]
+	mapping := make(map[rune]uint16)
+	switch binary.BigEndian.Uint16(table) {
+	case 4:
+		if len(table) < 14 {
+			return nil, errTrueType
+		}
+		segCount := int(binary.BigEndian.Uint16(table[6:])) / 2
+		ends, starts := 14, 16+segCount*2
+		deltas, rangeOffsets := starts+segCount*2, starts+segCount*4
+		if rangeOffsets+segCount*2 > len(table) {
+			return nil, errTrueType
+		}
+		for seg := 0; seg < segCount; seg++ {
+			end := int(binary.BigEndian.Uint16(table[ends+seg*2:]))
+			start := int(binary.BigEndian.Uint16(table[starts+seg*2:]))
+			delta := binary.BigEndian.Uint16(table[deltas+seg*2:])
+			rangeOffset := int(binary.BigEndian.Uint16(table[rangeOffsets+seg*2:]))
+			for c := start; c <= end && c != 0xffff; c++ {
+				gid := uint16(c) + delta
+				if rangeOffset != 0 {
+					index := rangeOffsets + seg*2 + rangeOffset + (c-start)*2
+					if index+2 > len(table) {
+						continue
+					}
+					if gid = binary.BigEndian.Uint16(table[index:]); gid != 0 {
+						gid += delta
+					}
+				}
+				if gid != 0 {
+					mapping[rune(c)] = gid
+				}
+			}
+		}
+	case 12:
+		if len(table) < 16 {
+			return nil, errTrueType
+		}
+		numGroups := int(binary.BigEndian.Uint32(table[12:]))
+		if 16+numGroups*12 > len(table) {
+			return nil, errTrueType
+		}
+		for g := 0; g < numGroups; g++ {
+			group := table[16+g*12:]
+			start := binary.BigEndian.Uint32(group)
+			end := binary.BigEndian.Uint32(group[4:])
+			gid := binary.BigEndian.Uint32(group[8:])
+			for c := start; c <= end && c <= 0x10ffff; c++ {
+				mapping[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported character map format", errTrueType)
+	}
+	return mapping, nil
+}
+
+// trueTypeName returns the PostScript name of a font from its name table
+func trueTypeName(name []byte) string {
+	if len(name) >= 6 {
+		count := int(binary.BigEndian.Uint16(name[2:]))
+		storage := int(binary.BigEndian.Uint16(name[4:]))
+		for i := 0; i < count; i++ {
+			record := 6 + i*12
+			if record+12 > len(name) {
+				break
+			}
+			platform := binary.BigEndian.Uint16(name[record:])
+			nameID := binary.BigEndian.Uint16(name[record+6:])
+			length := int(binary.BigEndian.Uint16(name[record+8:]))
+			offset := storage + int(binary.BigEndian.Uint16(name[record+10:]))
+			if nameID != 6 || offset+length > len(name) {
+				continue
+			}
+			raw := name[offset : offset+length]
+			var b strings.Builder
+			for j := 0; j < len(raw); j++ {
+				// Windows names are UTF-16, take the low bytes of ASCII characters
+				if platform == 3 || platform == 0 {
+					j++
+					if j >= len(raw) {
+						break
+					}
+				}
+				if c := raw[j]; c > 0x20 && c < 0x7f && !strings.ContainsRune("[](){}<>/%#", rune(c)) {
+					b.WriteByte(c)
+				}
+			}
+			if b.Len() > 0 {
+				return b.String()
+			}
+		}
+	}
+	return "EmbeddedFont"
+}
+
+// glyph returns the outline data of a glyph
+func (face *trueTypeFace) glyph(gid int) []byte {
+	loca, glyf := face.tables["loca"], face.tables["glyf"]
+	var start, end int
+	if face.longLoca {
+		if (gid+2)*4 > len(loca) {
+			return nil
+		}
+		start = int(binary.BigEndian.Uint32(loca[gid*4:]))
+		end = int(binary.BigEndian.Uint32(loca[gid*4+4:]))
+	} else {
+		if (gid+2)*2 > len(loca) {
+			return nil
+		}
+		start = int(binary.BigEndian.Uint16(loca[gid*2:])) * 2
+		end = int(binary.BigEndian.Uint16(loca[gid*2+2:])) * 2
+	}
+	if start >= end || end > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// components returns the glyphs a composite glyph is built of
+func (face *trueTypeFace) components(data []byte) []int {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil
+	}
+
+	const (
+		argsAreWords  = 0x0001
+		hasScale      = 0x0008
+		moreComponent = 0x0020
+		hasXYScale    = 0x0040
+		hasTwoByTwo   = 0x0080
+	)
+	var gids []int
+	for pos := 10; pos+4 <= len(data); {
+		flags := binary.BigEndian.Uint16(data[pos:])
+		gids = append(gids, int(binary.BigEndian.Uint16(data[pos+2:])))
+		pos += 4
+		if flags&argsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&hasScale != 0:
+			pos += 2
+		case flags&hasXYScale != 0:
+			pos += 4
+		case flags&hasTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&moreComponent == 0 {
+			break
+		}
+	}
+	return gids
+}
+
+// subset returns a font file holding only the outlines of the used glyphs.
+// Glyph IDs are kept, so text encoded with the full font shows the same.
+func (face *trueTypeFace) subset(used map[uint16]rune) []byte {
+	keep := make(map[int]bool)
+	pending := []int{0}
+	for gid := range used {
+		pending = append(pending, int(gid))
+	}
+	for len(pending) > 0 {
+		gid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if keep[gid] || gid >= face.numGlyphs {
+			continue
+		}
+		keep[gid] = true
+		pending = append(pending, face.components(face.glyph(gid))...)
+	}
+
+	// Glyph data of unused glyphs is dropped, the locations stay in place
+	var glyf bytes.Buffer
+	loca := make([]byte, (face.numGlyphs+1)*4)
+	for gid := 0; gid < face.numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(loca[gid*4:], uint32(glyf.Len()))
+		if keep[gid] {
+			glyf.Write(face.glyph(gid))
+			for glyf.Len()%4 != 0 {
+				glyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[face.numGlyphs*4:], uint32(glyf.Len()))
+
+	head := append([]byte(nil), face.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)  // checkSumAdjustment, set below
+	binary.BigEndian.PutUint16(head[50:], 1) // long locations
+
+	tables := map[string][]byte{
+		"head": head, "hhea": face.tables["hhea"], "hmtx": face.tables["hmtx"],
+		"maxp": face.tables["maxp"], "loca": loca, "glyf": glyf.Bytes(),
+	}
+	for _, tag := range []string{"cmap", "cvt ", "fpgm", "prep"} {
+		if table := face.tables[tag]; table != nil {
+			tables[tag] = table
+		}
+	}
+	font, headOffset := buildTrueType(tables)
+	binary.BigEndian.PutUint32(font[headOffset+8:], 0xB1B0AFBA-trueTypeChecksum(font))
+	return font
+}
+
+// buildTrueType writes a font file with the given tables and returns it
+// with the offset of its head table
+func buildTrueType(tables map[string][]byte) ([]byte, int) {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	numTables := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	var font bytes.Buffer
+	header := make([]byte, 12+numTables*16)
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(numTables*16-searchRange))
+
+	offset, headOffset := len(header), 0
+	var body bytes.Buffer
+	for i, tag := range tags {
+		table := tables[tag]
+		if tag == "head" {
+			headOffset = offset + body.Len()
+		}
+		entry := header[12+i*16:]
+		copy(entry, tag)
+		binary.BigEndian.PutUint32(entry[4:], trueTypeChecksum(table))
+		binary.BigEndian.PutUint32(entry[8:], uint32(offset+body.Len()))
+		binary.BigEndian.PutUint32(entry[12:], uint32(len(table)))
+		body.Write(table)
+		for body.Len()%4 != 0 {
+			body.WriteByte(0)
+		}
+	}
+	font.Write(header)
+	font.Write(body.Bytes())
+	return font.Bytes(), headOffset
+}
+
+// trueTypeChecksum sums the data as big-endian 32-bit words
+func trueTypeChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// trueTypeFont is an embedded TrueType font. Text is written as glyph IDs
+// and only the glyphs used in the document are embedded.
+type trueTypeFont struct {
+	face *trueTypeFace
+	used map[uint16]rune
+	// missing collects the characters the font has no glyphs for
+	missing map[rune]bool
+}
+
+// newTrueTypeFont creates a font of the document from a parsed font file
+func newTrueTypeFont(face *trueTypeFace) *trueTypeFont {
+	return &trueTypeFont{face: face, used: make(map[uint16]rune)}
+}
+
+// glyphID returns the glyph of the character, the question mark for
+// characters the font does not have
+func (f *trueTypeFont) glyphID(r rune) uint16 {
+	if gid, ok := f.face.cmap[r]; ok {
+		return gid
+	}
+	recordMissing(f.missing, r)
+	return f.face.cmap['?']
+}
+
+// recordMissing adds a printable character a font can not write to the set,
+// if the font collects them
+func recordMissing(missing map[rune]bool, r rune) {
+	if missing != nil && r != '?' && unicode.IsGraphic(r) {
+		missing[r] = true
+	}
+}
+
+func (f *trueTypeFont) width(text string, size float64) float64 {
+	total := 0
+	for _, r := range text {
+		if gid := int(f.glyphID(r)); gid < len(f.face.advances) {
+			total += int(f.face.advances[gid])
+		}
+	}
+	return float64(total) * size / f.face.unitsPerEm
+}
+
+func (f *trueTypeFont) encode(text string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range text {
+		gid := f.glyphID(r)
+		if _, ok := f.used[gid]; !ok {
+			f.used[gid] = r
+		}
+		fmt.Fprintf(&b, "%04X", gid)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func (f *trueTypeFont) ascent() float64 {
+	return float64(f.face.ascender) / f.face.unitsPerEm
+}
+
+func (f *trueTypeFont) descent() float64 {
+	return -float64(f.face.descender) / f.face.unitsPerEm
+}
+
+// scaled converts font units to the thousandths of the font size PDF uses
+func (f *trueTypeFont) scaled(units int) int {
+	return int(float64(units) * 1000 / f.face.unitsPerEm)
+}
+
+func (f *trueTypeFont) write(w *pdfWriter) int {
+	gids := make([]int, 0, len(f.used))
+	for gid := range f.used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+
+	// Subset fonts are named with a tag derived from their glyphs
+	var key bytes.Buffer
+	for _, gid := range gids {
+		fmt.Fprintf(&key, "%d,", gid)
+	}
+	sum := crc32.ChecksumIEEE(key.Bytes())
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = byte('A' + sum%26)
+		sum /= 26
+	}
+	name := string(tag) + "+" + f.face.name
+
+	fontFile := w.newObject()
+	subset := f.face.subset(f.used)
+	w.writeStream(fontFile, fmt.Sprintf("/Length1 %d", len(subset)), subset)
+
+	descriptor := w.newObject()
+	bbox := f.face.bbox
+	w.writeObject(descriptor, fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, f.scaled(int(bbox[0])), f.scaled(int(bbox[1])), f.scaled(int(bbox[2])), f.scaled(int(bbox[3])),
+		f.scaled(int(f.face.ascender)), f.scaled(int(f.face.descender)), f.scaled(int(f.face.ascender)), fontFile))
+
+	var widths strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, f.scaled(int(f.face.advances[gid])))
+	}
+	cidFont := w.newObject()
+	w.writeObject(cidFont, fmt.Sprintf(
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+		name, descriptor, widths.String()))
+
+	// The Unicode mapping lets readers copy and search the text
+	var cmap strings.Builder
+	cmap.WriteString("/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n")
+	cmap.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	cmap.WriteString("/CMapName /Adobe-Identity-UCS def /CMapType 2 def\n")
+	cmap.WriteString("1 begincodespacerange <0000> <FFFF> endcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		end := start + 100
+		if end > len(gids) {
+			end = len(gids)
+		}
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", end-start)
+		for _, gid := range gids[start:end] {
+			fmt.Fprintf(&cmap, "<%04X> <%s>\n", gid, utf16Hex(f.used[uint16(gid)]))
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap CMapName currentdict /CMap defineresource pop end end\n")
+	toUnicode := w.newObject()
+	w.writeStream(toUnicode, "", []byte(cmap.String()))
+
+	ref := w.newObject()
+	w.writeObject(ref, fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cidFont, toUnicode))
+	return ref
+}
+
+// utf16Hex returns the character as hexadecimal UTF-16 code units
+func utf16Hex(r rune) string {
+	if r >= 0x10000 {
+		r -= 0x10000
+		return fmt.Sprintf("%04X%04X", 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+	}
+	return fmt.Sprintf("%04X", r)
+}
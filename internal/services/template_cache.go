@@ -0,0 +1,426 @@
+package services
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/sync/singleflight"
+)
+
+// compiledTemplate is a template file parsed once: its content and the
+// placeholder locations and structure of every sheet
+type compiledTemplate struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+	content []byte
+	sheets  map[string]*compiledSheet
+}
+
+// compiledSheet lists the template cells of a sheet that hold placeholders
+type compiledSheet struct {
+	// cells holds the cells with placeholders in reading order
+	cells []compiledCell
+
+	// links holds the cells whose hyperlink target has placeholders
+	links []string
+
+	// hasBlocks and hasConditions are set when rows or columns of the sheet
+	// change during rendering, so cell positions must be rescanned
+	hasBlocks     bool
+	hasConditions bool
+
+	// anchored is set when hyperlinks, comments, pictures or shapes are
+	// anchored to the cells of the sheet. The stream writer does not move
+	// them with the rows, so such sheets are rendered in memory.
+	anchored bool
+}
+
+// compiledCell is a template cell holding placeholders
+type compiledCell struct {
+	name string
+	text string
+}
+
+// isStatic reports whether the cell positions of the sheet stay as in the template
+func (cs *compiledSheet) isStatic() bool {
+	return !cs.hasBlocks && !cs.hasConditions
+}
+
+// defaultTemplateCacheSize bounds the template content kept in memory when
+// TEMPLATE_CACHE_SIZE is not set
+const defaultTemplateCacheSize = 256 << 20
+
+// templateCache keeps compiled templates by path. An entry is reused while
+// the modification time and size of the file stay the same; when they
+// change, the file is read again and compiled only if its hash differs.
+// When the content of the cached templates exceeds maxBytes, the least
+// recently used ones are dropped. Files are read and compiled outside the
+// lock, and concurrent loads of one path share the compilation.
+type templateCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	order    *list.List // of *templateEntry, most recently used first
+	entries  map[string]*list.Element
+	loads    singleflight.Group
+}
+
+// templateEntry is a compiled template with the path it was loaded from
+type templateEntry struct {
+	path     string
+	template *compiledTemplate
+}
+
+// newTemplateCache creates an empty template cache keeping at most maxBytes
+// of template content, the default size when maxBytes is not positive
+func newTemplateCache(maxBytes int64) *templateCache {
+	if maxBytes <= 0 {
+		maxBytes = defaultTemplateCacheSize
+	}
+	return &templateCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// load returns the compiled template for the path, compiling it when the
+// file is new or has changed
+func (c *templateCache) load(path string) (*compiledTemplate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if cached, fresh := c.lookup(path, info); fresh {
+		return cached, nil
+	}
+
+	compiled, err, _ := c.loads.Do(path, func() (interface{}, error) {
+		return c.compile(path, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return compiled.(*compiledTemplate), nil
+}
+
+// lookup returns the cached template of the path and whether it matches
+// the modification time and size of the file
+func (c *templateCache) lookup(path string, info os.FileInfo) (*compiledTemplate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	cached := element.Value.(*templateEntry).template
+	return cached, cached.modTime.Equal(info.ModTime()) && cached.size == info.Size()
+}
+
+// compile reads and compiles the file of the path and caches the result
+func (c *templateCache) compile(path string, info os.FileInfo) (*compiledTemplate, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(content)
+
+	// A touched file with the same content keeps its compiled model
+	cached, _ := c.lookup(path, info)
+	if cached != nil && cached.hash == hash {
+		c.mu.Lock()
+		cached.modTime, cached.size = info.ModTime(), info.Size()
+		c.mu.Unlock()
+		return cached, nil
+	}
+
+	utils.LogInfo("Compiling Excel template: %s", path)
+	compiled, err := compileTemplate(content)
+	if err != nil {
+		return nil, err
+	}
+	compiled.modTime, compiled.size, compiled.hash = info.ModTime(), info.Size(), hash
+	c.store(path, compiled)
+	return compiled, nil
+}
+
+// store caches a compiled template and drops the least recently used ones
+// over the size limit, keeping at least the stored one
+func (c *templateCache) store(path string, compiled *compiledTemplate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[path]; ok {
+		c.remove(element)
+	}
+	c.entries[path] = c.order.PushFront(&templateEntry{path: path, template: compiled})
+	c.bytes += int64(len(compiled.content))
+
+	for c.bytes > c.maxBytes && c.order.Len() > 1 {
+		evicted := c.order.Back()
+		utils.LogDebug("Evicting compiled template: %s", evicted.Value.(*templateEntry).path)
+		c.remove(evicted)
+	}
+}
+
+// evict drops the cached templates of the directory except the kept path,
+// e.g. the inactive versions of a template
+func (c *templateCache) evict(dir, keep string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for path, element := range c.entries {
+		if path != keep && filepath.Dir(path) == filepath.Clean(dir) {
+			c.remove(element)
+		}
+	}
+}
+
+// remove drops a cache entry, the lock must be held
+func (c *templateCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*templateEntry)
+	delete(c.entries, entry.path)
+	c.bytes -= int64(len(entry.template.content))
+}
+
+// compileTemplate scans every sheet of the template for placeholders
+func compileTemplate(content []byte) (*compiledTemplate, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			utils.LogError("Error closing Excel file: %v", closeErr)
+		}
+	}()
+
+	compiled := &compiledTemplate{
+		content: content,
+		sheets:  make(map[string]*compiledSheet),
+	}
+	for _, sheetName := range f.GetSheetList() {
+		sheet, err := compileSheet(f, sheetName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile sheet %s: %w", sheetName, err)
+		}
+		compiled.sheets[sheetName] = sheet
+	}
+	return compiled, nil
+}
+
+// compileSheet lists the placeholder cells and hyperlinks of a sheet
+func compileSheet(f *excelize.File, sheetName string) (*compiledSheet, error) {
+	rows, err := f.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := &compiledSheet{}
+	for rowIdx, row := range rows {
+		for colIdx, value := range row {
+			cellName, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+1)
+			if !placeholderPattern.MatchString(value) {
+				continue
+			}
+			sheet.cells = append(sheet.cells, compiledCell{name: cellName, text: value})
+			if blockOpenPattern.MatchString(value) {
+				sheet.hasBlocks = true
+			}
+			if conditionTagPattern.MatchString(value) || sheetConditionPattern.MatchString(value) {
+				sheet.hasConditions = true
+			}
+		}
+	}
+
+	// Hyperlinks are listed from the sheet, since their cells may be empty
+	linkCells, err := sheetHyperlinkCells(f, sheetName)
+	if err != nil {
+		return nil, err
+	}
+	for _, cellName := range linkCells {
+		sheet.anchored = true
+		if _, link, err := f.GetCellHyperLink(sheetName, cellName); err == nil && strings.Contains(link, "{{") {
+			sheet.links = append(sheet.links, cellName)
+		}
+	}
+
+	comments, err := f.GetComments(sheetName)
+	if err != nil {
+		return nil, err
+	}
+	hasDrawing, err := sheetHasDrawing(f, sheetName)
+	if err != nil {
+		return nil, err
+	}
+	if len(comments) > 0 || hasDrawing {
+		sheet.anchored = true
+	}
+	return sheet, nil
+}
+
+// packageRelationships lists the relationships of a part of the package
+type packageRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Type   string `xml:"Type,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// sheetHasDrawing reports whether the sheet has a drawing, which holds its
+// pictures, shapes and charts. It reads the raw parts of a template that
+// was just opened.
+func sheetHasDrawing(f *excelize.File, sheetName string) (bool, error) {
+	sheetPath, err := sheetPartPath(f, sheetName)
+	if err != nil || sheetPath == "" {
+		return false, err
+	}
+	var sheetRels packageRelationships
+	err = readPackagePart(f, path.Join(path.Dir(sheetPath), "_rels", path.Base(sheetPath)+".rels"), &sheetRels)
+	if err != nil {
+		return false, err
+	}
+	for _, sheetRel := range sheetRels.Relationships {
+		if strings.HasSuffix(sheetRel.Type, "/drawing") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sheetHyperlinkCells returns the cells with hyperlinks of a sheet, empty
+// cells included. A hyperlink over a range is listed by its top left cell.
+// It reads the raw part of a template that was just opened.
+func sheetHyperlinkCells(f *excelize.File, sheetName string) ([]string, error) {
+	sheetPath, err := sheetPartPath(f, sheetName)
+	if err != nil || sheetPath == "" {
+		return nil, err
+	}
+	var worksheet struct {
+		Hyperlinks []struct {
+			Ref string `xml:"ref,attr"`
+		} `xml:"hyperlinks>hyperlink"`
+	}
+	if err = readPackagePart(f, sheetPath, &worksheet); err != nil {
+		return nil, err
+	}
+
+	cells := make([]string, 0, len(worksheet.Hyperlinks))
+	for _, link := range worksheet.Hyperlinks {
+		cell, _, _ := strings.Cut(link.Ref, ":")
+		cells = append(cells, cell)
+	}
+	return cells, nil
+}
+
+// sheetPartPath returns the name of the package part of a sheet, empty
+// when the workbook has no such sheet
+func sheetPartPath(f *excelize.File, sheetName string) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := readPackagePart(f, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	var workbookRels packageRelationships
+	if err := readPackagePart(f, "xl/_rels/workbook.xml.rels", &workbookRels); err != nil {
+		return "", err
+	}
+
+	for _, sheet := range workbook.Sheets {
+		if sheet.Name != sheetName {
+			continue
+		}
+		for _, rel := range workbookRels.Relationships {
+			if rel.ID != sheet.ID {
+				continue
+			}
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return "xl/" + rel.Target, nil
+		}
+	}
+	return "", nil
+}
+
+// readPackagePart decodes an XML part of the package, parts that do not
+// exist leave the value empty
+func readPackagePart(f *excelize.File, name string, v interface{}) error {
+	content, ok := f.Pkg.Load(name)
+	if !ok {
+		return nil
+	}
+	data, ok := content.([]byte)
+	if !ok {
+		return nil
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// maxCompiledExpressions bounds the parsed expressions kept in memory,
+// the least recently used ones are dropped beyond it
+const maxCompiledExpressions = 4096
+
+// compiledExpression is a parsed expression with its text
+type compiledExpression struct {
+	text string
+	expr *placeholderExpr
+}
+
+// Parsed placeholder expressions by their text, shared by all renders,
+// most recently used first
+var compiledExpressions = struct {
+	sync.Mutex
+	order   *list.List // of *compiledExpression
+	entries map[string]*list.Element
+}{order: list.New(), entries: make(map[string]*list.Element)}
+
+// compilePlaceholder parses a placeholder expression once and reuses the
+// result. Expressions that fail to parse are not kept, since a filter they
+// refer to may be registered later.
+func compilePlaceholder(text string) (*placeholderExpr, error) {
+	compiledExpressions.Lock()
+	element, ok := compiledExpressions.entries[text]
+	if ok {
+		compiledExpressions.order.MoveToFront(element)
+	}
+	compiledExpressions.Unlock()
+	if ok {
+		return element.Value.(*compiledExpression).expr, nil
+	}
+
+	expr, err := parsePlaceholder(text)
+	if err != nil {
+		return nil, err
+	}
+
+	compiledExpressions.Lock()
+	defer compiledExpressions.Unlock()
+	if _, ok = compiledExpressions.entries[text]; !ok {
+		compiledExpressions.entries[text] = compiledExpressions.order.PushFront(&compiledExpression{text: text, expr: expr})
+	}
+	for compiledExpressions.order.Len() > maxCompiledExpressions {
+		evicted := compiledExpressions.order.Remove(compiledExpressions.order.Back()).(*compiledExpression)
+		delete(compiledExpressions.entries, evicted.text)
+	}
+	return expr, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTemplateCacheCompilesSheets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "template.xlsx")
+	writeTestWorkbook(t, path, map[string]interface{}{
+		"A1": "Act {{actId}}",
+		"B1": "static",
+		"A3": "{{#positions}}{{@number}}{{/positions}}",
+	}, nil)
+
+	compiled, err := newTemplateCache(0).load(path)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+
+	sheet := compiled.sheets["Sheet1"]
+	if sheet == nil {
+		t.Fatal("Sheet1 is not compiled")
+	}
+	if len(sheet.cells) != 2 || sheet.cells[0].name != "A1" || sheet.cells[1].name != "A3" {
+		t.Errorf("cells = %+v, want A1 and A3", sheet.cells)
+	}
+	if !sheet.hasBlocks || sheet.hasConditions || sheet.isStatic() {
+		t.Errorf("hasBlocks = %v, hasConditions = %v, want a block only", sheet.hasBlocks, sheet.hasConditions)
+	}
+}
+
+func TestTemplateCacheInvalidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "template.xlsx")
+	writeTestWorkbook(t, path, map[string]interface{}{"A1": "{{actId}}"}, nil)
+	cache := newTemplateCache(0)
+
+	first, err := cache.load(path)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if again, _ := cache.load(path); again != first {
+		t.Error("unchanged template was compiled again")
+	}
+
+	// A new modification time with the same content keeps the compiled model
+	touched := time.Now().Add(time.Hour)
+	if err = os.Chtimes(path, touched, touched); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.load(path); again != first {
+		t.Error("touched template was compiled again")
+	}
+
+	// Changed content is compiled again
+	writeTestWorkbook(t, path, map[string]interface{}{"A1": "{{actId}}", "A2": "{{totalCost}}"}, nil)
+	changed := time.Now().Add(2 * time.Hour)
+	if err = os.Chtimes(path, changed, changed); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := cache.load(path)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if updated == first || len(updated.sheets["Sheet1"].cells) != 2 {
+		t.Errorf("changed template was not compiled again, cells = %+v", updated.sheets["Sheet1"].cells)
+	}
+}
+
+func TestTemplateCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 3)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, "template"+strconv.Itoa(i)+".xlsx")
+		writeTestWorkbook(t, paths[i], map[string]interface{}{"A1": "{{actId}}"}, nil)
+	}
+	info, err := os.Stat(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for two templates of about the same size
+	cache := newTemplateCache(2*info.Size() + info.Size()/2)
+	first, err := cache.load(paths[0])
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	for _, path := range []string{paths[1], paths[0], paths[2]} {
+		if _, err = cache.load(path); err != nil {
+			t.Fatalf("load() error = %v", err)
+		}
+	}
+
+	if _, ok := cache.entries[paths[1]]; ok {
+		t.Error("least recently used template is still cached")
+	}
+	if again, _ := cache.load(paths[0]); again != first {
+		t.Error("recently used template was compiled again")
+	}
+	if cache.bytes > cache.maxBytes {
+		t.Errorf("cache holds %d bytes, expected at most %d", cache.bytes, cache.maxBytes)
+	}
+}
+
+func TestTemplateCacheEvictsVersions(t *testing.T) {
+	root := t.TempDir()
+	versions := filepath.Join(root, "customer")
+	if err := os.Mkdir(versions, 0755); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{filepath.Join(versions, "v1.xlsx"), filepath.Join(versions, "v2.xlsx"), filepath.Join(root, "default.xlsx")}
+	cache := newTemplateCache(0)
+	for _, path := range paths {
+		writeTestWorkbook(t, path, map[string]interface{}{"A1": "{{actId}}"}, nil)
+		if _, err := cache.load(path); err != nil {
+			t.Fatalf("load() error = %v", err)
+		}
+	}
+
+	cache.evict(versions, paths[1])
+	for i, expected := range []bool{false, true, true} {
+		if _, ok := cache.entries[paths[i]]; ok != expected {
+			t.Errorf("%s cached = %v, expected %v", paths[i], ok, expected)
+		}
+	}
+}
+
+func TestTemplateCacheSharesConcurrentLoads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "template.xlsx")
+	writeTestWorkbook(t, path, map[string]interface{}{"A1": "{{actId}}"}, nil)
+	cache := newTemplateCache(0)
+
+	results := make([]*compiledTemplate, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = cache.load(path)
+		}()
+	}
+	wg.Wait()
+
+	for i, compiled := range results {
+		if compiled == nil || compiled != results[0] {
+			t.Fatalf("load %d returned another compiled template", i)
+		}
+	}
+}
+
+func TestCompiledExpressionsAreBounded(t *testing.T) {
+	first, err := compilePlaceholder("customer")
+	if err != nil {
+		t.Fatalf("compilePlaceholder() error = %v", err)
+	}
+	for i := 0; i < maxCompiledExpressions+10; i++ {
+		if _, err = compilePlaceholder("field" + strconv.Itoa(i) + " | upper"); err != nil {
+			t.Fatalf("compilePlaceholder() error = %v", err)
+		}
+		// The expression in use stays cached
+		if i%100 == 0 {
+			if again, _ := compilePlaceholder("customer"); again != first {
+				t.Fatal("expression in use was parsed again")
+			}
+		}
+	}
+
+	compiledExpressions.Lock()
+	kept := len(compiledExpressions.entries)
+	_, oldest := compiledExpressions.entries["field0 | upper"]
+	compiledExpressions.Unlock()
+	if kept > maxCompiledExpressions {
+		t.Errorf("%d expressions kept, expected at most %d", kept, maxCompiledExpressions)
+	}
+	if oldest {
+		t.Error("least recently used expression is still cached")
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// placeholderExpr is a parsed placeholder expression: a key followed by an
+// optional chain of filters, e.g. {{totalCost | number:2}}
+type placeholderExpr struct {
+	key     string
+	filters []filterCall
+}
+
+// filterCall is a single filter application with its arguments
+type filterCall struct {
+	name string
+	args []string
+}
+
+// parsePlaceholder parses the text between {{ and }}. Filters are separated
+// by |, a filter name is followed by : and comma-separated arguments, and
+// arguments may be quoted with double or single quotes. A backslash escapes
+// the next character, e.g. a pipe or a quote within an argument. Arguments
+// of built-in filters are checked, e.g. the decimal places of number.
+func parsePlaceholder(text string) (*placeholderExpr, error) {
+	parts, err := splitUnquoted(text, '|')
+	if err != nil {
+		return nil, err
+	}
+
+	expr := &placeholderExpr{key: strings.TrimSpace(parts[0])}
+	if expr.key == "" {
+		return nil, fmt.Errorf("empty placeholder key in %q", text)
+	}
+
+	for _, part := range parts[1:] {
+		name, argText, hasArgs := strings.Cut(strings.TrimSpace(part), ":")
+		call := filterCall{name: strings.TrimSpace(name)}
+		if call.name == "" {
+			return nil, fmt.Errorf("empty filter name in %q", text)
+		}
+		if _, ok := lookupFilter(call.name); !ok {
+			return nil, fmt.Errorf("unknown filter %q", call.name)
+		}
+
+		if hasArgs {
+			args, err := splitUnquoted(argText, ',')
+			if err != nil {
+				return nil, err
+			}
+			for _, arg := range args {
+				call.args = append(call.args, unescape(unquote(strings.TrimSpace(arg))))
+			}
+		}
+		if err := checkFilterArgs(call); err != nil {
+			return nil, fmt.Errorf("filter %s: %w", call.name, err)
+		}
+		expr.filters = append(expr.filters, call)
+	}
+
+	return expr, nil
+}
+
+// evaluate resolves the key and applies the filters. A missing key is passed
+// to the filters as nil and counts as resolved only if a filter such as
+// default turns it into a value.
+func (e *placeholderExpr) evaluate(scope *templateScope) (interface{}, bool, error) {
+	value, found := scope.lookup(e.key)
+
+	for _, call := range e.filters {
+		filter, ok := lookupLocalizedFilter(call.name, scope.locale)
+		if !ok {
+			return nil, false, fmt.Errorf("unknown filter %q", call.name)
+		}
+
+		var err error
+		value, err = filter(value, call.args)
+		if err != nil {
+			return nil, false, fmt.Errorf("filter %s: %w", call.name, err)
+		}
+	}
+
+	if !found && value == nil {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// splitUnquoted splits the text on the separator, ignoring separators inside
+// quotes and escaped ones. Escapes are kept in the parts.
+func splitUnquoted(text string, separator rune) ([]string, error) {
+	var parts []string
+	var current strings.Builder
+	var quote rune
+	escaped := false
+
+	for _, r := range text {
+		switch {
+		case escaped:
+			escaped = false
+			current.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			current.WriteRune(r)
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			current.WriteRune(r)
+		case r == separator:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", text)
+	}
+	return append(parts, current.String()), nil
+}
+
+// unquote removes the surrounding quotes of a filter argument
+func unquote(arg string) string {
+	if len(arg) >= 2 && (arg[0] == '"' || arg[0] == '\'') && arg[len(arg)-1] == arg[0] {
+		return arg[1 : len(arg)-1]
+	}
+	return arg
+}
+
+// unescape removes the backslashes escaping characters of a filter argument
+func unescape(arg string) string {
+	if !strings.Contains(arg, "\\") {
+		return arg
+	}
+
+	var result strings.Builder
+	escaped := false
+	for _, r := range arg {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		result.WriteRune(r)
+	}
+	return result.String()
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+)
+
+func TestParsePlaceholder(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected *placeholderExpr
+		err      string
+	}{
+		{text: "totalCost", expected: &placeholderExpr{key: "totalCost"}},
+		{text: " customer.inn ", expected: &placeholderExpr{key: "customer.inn"}},
+		{
+			text: "totalCost | number:0 | upper",
+			expected: &placeholderExpr{key: "totalCost", filters: []filterCall{
+				{name: "number", args: []string{"0"}},
+				{name: "upper"},
+			}},
+		},
+		{
+			text:     `contractDate | date:"2 January, 2006"`,
+			expected: &placeholderExpr{key: "contractDate", filters: []filterCall{{name: "date", args: []string{"2 January, 2006"}}}},
+		},
+		{
+			text:     `note | default:'a | b'`,
+			expected: &placeholderExpr{key: "note", filters: []filterCall{{name: "default", args: []string{"a | b"}}}},
+		},
+		{
+			text:     `note | default:a\|b`,
+			expected: &placeholderExpr{key: "note", filters: []filterCall{{name: "default", args: []string{"a|b"}}}},
+		},
+		{
+			text:     `note | default:"say \"yes\", \\ no"`,
+			expected: &placeholderExpr{key: "note", filters: []filterCall{{name: "default", args: []string{`say "yes", \ no`}}}},
+		},
+		{
+			text:     `note | default:a\,b,c`,
+			expected: &placeholderExpr{key: "note", filters: []filterCall{{name: "default", args: []string{"a,b", "c"}}}},
+		},
+		{text: " | upper", err: "empty placeholder key"},
+		{text: "note | ", err: "empty filter name"},
+		{text: "note | shout", err: `unknown filter "shout"`},
+		{text: `note | default:"open`, err: "unterminated quote"},
+		{text: "amount | number:two", err: `invalid decimal places "two"`},
+		{text: "sum|number:20", err: `filter number: invalid decimal places "20", expected 0 to 15`},
+		{text: "sum | number:-1", err: `invalid decimal places "-1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			expr, err := parsePlaceholder(tt.text)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parsePlaceholder() error = %v, expected %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePlaceholder() error = %v", err)
+			}
+			if !reflect.DeepEqual(expr, tt.expected) {
+				t.Errorf("parsePlaceholder() = %+v, expected %+v", expr, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplitUnquoted(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+		err      bool
+	}{
+		{text: "", expected: []string{""}},
+		{text: "a|b|c", expected: []string{"a", "b", "c"}},
+		{text: "a||", expected: []string{"a", "", ""}},
+		{text: `a|"b|c"|'d|e'`, expected: []string{"a", `"b|c"`, `'d|e'`}},
+		{text: `"it's|ok"|x`, expected: []string{`"it's|ok"`, "x"}},
+		{text: `a\|b|c`, expected: []string{`a\|b`, "c"}},
+		{text: `"a\"|b"|c`, expected: []string{`"a\"|b"`, "c"}},
+		{text: `"a|b`, err: true},
+		{text: `'a|b"`, err: true},
+	}
+
+	for _, tt := range tests {
+		parts, err := splitUnquoted(tt.text, '|')
+		if tt.err {
+			if err == nil {
+				t.Errorf("splitUnquoted(%q) = %q, expected an error", tt.text, parts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitUnquoted(%q) error = %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(parts, tt.expected) {
+			t.Errorf("splitUnquoted(%q) = %q, expected %q", tt.text, parts, tt.expected)
+		}
+	}
+}
+
+func TestPlaceholderFilters(t *testing.T) {
+	data := map[string]interface{}{
+		"amount":   1234.5,
+		"money":    models.Money(123456),
+		"quantity": models.Quantity(1500),
+		"count":    3,
+		"text":     "Mixed Case",
+		"empty":    "",
+		"date":     time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC),
+		"dateText": "2026-10-17",
+		"word":     "abc",
+		"huge":     1e19,
+	}
+
+	tests := []struct {
+		text     string
+		locale   *utils.Locale
+		expected interface{}
+		missing  bool
+		err      string
+	}{
+		{text: "amount | number", expected: "1,234.50"},
+		{text: "amount | number:0", expected: "1,235"},
+		{text: "money | number", expected: "1,234.56"},
+		{text: "money | number", locale: utils.RussianLocale, expected: "1 234,56"},
+		{text: "quantity | number:3", expected: "1.500"},
+		{text: "money | number:15", expected: "1,234.560000000000000"},
+		{text: "count | number:1", expected: "3.0"},
+		{text: "word | number", err: "is not a number"},
+		{text: "missing | number", missing: true},
+		{text: "date | date", expected: "17.10.2026"},
+		{text: `date | date:"long"`, expected: "17 October 2026"},
+		{text: `date | date:"long"`, locale: utils.RussianLocale, expected: "17 октября 2026 г."},
+		{text: `dateText | date:"2006/01/02"`, expected: "2026/10/17"},
+		{text: "word | date", err: "is not a date"},
+		{text: "text | upper", expected: "MIXED CASE"},
+		{text: "text | lower", expected: "mixed case"},
+		{text: "count | upper", expected: "3"},
+		{text: `missing | default:"—"`, expected: "—"},
+		{text: `empty | default:"—"`, expected: "—"},
+		{text: `text | default:"—"`, expected: "Mixed Case"},
+		{text: "missing | default", err: "default value is required"},
+		{text: `missing | default:"5" | number:1`, expected: "5.0"},
+		{text: "money | words", expected: "Одна тысяча двести тридцать четыре рубля 56 копеек"},
+		{text: "word | words", err: "is not a number"},
+		{text: "huge | words", err: "too large to write in words"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			expr, err := parsePlaceholder(tt.text)
+			if err != nil {
+				t.Fatalf("parsePlaceholder() error = %v", err)
+			}
+
+			locale := tt.locale
+			if locale == nil {
+				locale = utils.DefaultLocale
+			}
+			value, found, err := expr.evaluate(newTemplateScope(data, locale))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("evaluate() error = %v, expected %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("evaluate() error = %v", err)
+			}
+			if found == tt.missing {
+				t.Fatalf("evaluate() found = %v, expected %v", found, !tt.missing)
+			}
+			if !tt.missing && value != tt.expected {
+				t.Errorf("evaluate() = %q, expected %q", value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRegisterFilter(t *testing.T) {
+	RegisterFilter("reverseTest", func(value interface{}, _ []string) (interface{}, error) {
+		runes := []rune(toText(value))
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	})
+	t.Cleanup(func() {
+		filterRegistry.Lock()
+		delete(filterRegistry.filters, "reverseTest")
+		filterRegistry.Unlock()
+	})
+
+	expr, err := parsePlaceholder("text | reverseTest")
+	if err != nil {
+		t.Fatalf("parsePlaceholder() error = %v", err)
+	}
+	value, _, err := expr.evaluate(newTemplateScope(map[string]interface{}{"text": "abc"}, utils.DefaultLocale))
+	if err != nil || value != "cba" {
+		t.Errorf("evaluate() = %v, %v, expected cba", value, err)
+	}
+}
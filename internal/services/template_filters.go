@@ -0,0 +1,277 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FilterFunc transforms a placeholder value. The value is nil when the key
+// is missing, args are the filter arguments with quotes removed.
+type FilterFunc func(value interface{}, args []string) (interface{}, error)
+
+// localizedFilterFunc is a built-in filter whose output depends on the locale
+type localizedFilterFunc func(locale *utils.Locale, value interface{}, args []string) (interface{}, error)
+
+// filterArgsCheck validates the arguments of a filter when a placeholder is
+// parsed, so that templates with invalid arguments are rejected
+type filterArgsCheck func(args []string) error
+
+// filterRegistry holds the filters available in templates. Localized
+// filters are used in place of the plain ones, and the arguments of built-in
+// filters are checked, until a filter of the same name is registered.
+var filterRegistry = struct {
+	sync.RWMutex
+	filters   map[string]FilterFunc
+	localized map[string]localizedFilterFunc
+	checks    map[string]filterArgsCheck
+}{
+	filters: map[string]FilterFunc{
+		"number":  withDefaultLocale(numberFilter),
+		"date":    withDefaultLocale(dateFilter),
+		"upper":   upperFilter,
+		"lower":   lowerFilter,
+		"default": defaultFilter,
+		"words":   wordsFilter,
+	},
+	localized: map[string]localizedFilterFunc{
+		"number": numberFilter,
+		"date":   dateFilter,
+	},
+	checks: map[string]filterArgsCheck{
+		"number": checkNumberArgs,
+	},
+}
+
+// maxDecimalPlaces is the largest number of decimal places of the number
+// filter, the digits a float keeps exactly
+const maxDecimalPlaces = 15
+
+// Date layouts accepted when a date filter receives a string value
+var dateInputLayouts = []string{
+	"02.01.2006",
+	"2006-01-02",
+	time.RFC3339,
+}
+
+// RegisterFilter makes a filter available in templates as {{key | name}},
+// replacing any filter registered under the same name
+func RegisterFilter(name string, fn FilterFunc) {
+	filterRegistry.Lock()
+	defer filterRegistry.Unlock()
+	filterRegistry.filters[name] = fn
+	delete(filterRegistry.localized, name)
+	delete(filterRegistry.checks, name)
+}
+
+// lookupFilter finds a registered filter by name
+func lookupFilter(name string) (FilterFunc, bool) {
+	filterRegistry.RLock()
+	defer filterRegistry.RUnlock()
+	fn, ok := filterRegistry.filters[name]
+	return fn, ok
+}
+
+// checkFilterArgs validates the arguments of a filter call, when the filter
+// checks them
+func checkFilterArgs(call filterCall) error {
+	filterRegistry.RLock()
+	check, ok := filterRegistry.checks[call.name]
+	filterRegistry.RUnlock()
+	if !ok {
+		return nil
+	}
+	return check(call.args)
+}
+
+// lookupLocalizedFilter finds a registered filter by name, bound to the locale
+func lookupLocalizedFilter(name string, locale *utils.Locale) (FilterFunc, bool) {
+	filterRegistry.RLock()
+	localized, ok := filterRegistry.localized[name]
+	filterRegistry.RUnlock()
+	if !ok {
+		return lookupFilter(name)
+	}
+	return func(value interface{}, args []string) (interface{}, error) {
+		return localized(locale, value, args)
+	}, true
+}
+
+// withDefaultLocale binds a localized filter to the default locale
+func withDefaultLocale(fn localizedFilterFunc) FilterFunc {
+	return func(value interface{}, args []string) (interface{}, error) {
+		return fn(utils.DefaultLocale, value, args)
+	}
+}
+
+// numberFilter formats a number with the locale separators and the given
+// number of decimal places, those of the locale by default: {{totalCost | number:0}}
+func numberFilter(locale *utils.Locale, value interface{}, args []string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	decimals := locale.Decimals
+	if len(args) > 0 {
+		var err error
+		if decimals, err = parseDecimalPlaces(args[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Money amounts and quantities are formatted exactly
+	if decimal, ok := value.(utils.Decimal); ok {
+		return locale.FormatDecimal(decimal, decimals), nil
+	}
+
+	number, ok := toFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("value %v is not a number", value)
+	}
+	return locale.FormatNumberWithDecimals(number, decimals), nil
+}
+
+// checkNumberArgs checks the decimal places of a number filter call
+func checkNumberArgs(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	_, err := parseDecimalPlaces(args[0])
+	return err
+}
+
+// parseDecimalPlaces parses the decimal places argument of the number filter
+func parseDecimalPlaces(arg string) (int, error) {
+	decimals, err := strconv.Atoi(arg)
+	if err != nil || decimals < 0 || decimals > maxDecimalPlaces {
+		return 0, fmt.Errorf("invalid decimal places %q, expected 0 to %d", arg, maxDecimalPlaces)
+	}
+	return decimals, nil
+}
+
+// dateFilter formats a date with a Go layout, the short date layout of the
+// locale by default. "long" gives the long date layout, and month names are
+// written in the language of the locale: {{contractDate | date:"long"}}
+func dateFilter(locale *utils.Locale, value interface{}, args []string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	layout := locale.DateLayout
+	if len(args) > 0 {
+		switch args[0] {
+		case "short":
+		case "long":
+			layout = locale.LongDateLayout
+		default:
+			layout = args[0]
+		}
+	}
+
+	date, ok := toTime(value)
+	if !ok {
+		return nil, fmt.Errorf("value %v is not a date", value)
+	}
+	return locale.FormatDate(date, layout), nil
+}
+
+// upperFilter converts a text to upper case: {{customer | upper}}
+func upperFilter(value interface{}, _ []string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return strings.ToUpper(toText(value)), nil
+}
+
+// lowerFilter converts a text to lower case: {{customer | lower}}
+func lowerFilter(value interface{}, _ []string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return strings.ToLower(toText(value)), nil
+}
+
+// defaultFilter replaces a missing or empty value: {{note | default:"—"}}
+func defaultFilter(value interface{}, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("default value is required")
+	}
+	if value == nil || value == "" {
+		return args[0], nil
+	}
+	return value, nil
+}
+
+// wordsFilter spells an amount in rubles in Russian words:
+// {{totalCost | words}} -> "Один миллион рублей 00 копеек"
+func wordsFilter(value interface{}, _ []string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if money, ok := value.(models.Money); ok {
+		return utils.FormatKopecksInWords(money.Units()), nil
+	}
+
+	number, ok := toFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("value %v is not a number", value)
+	}
+	return utils.FormatAmountInWords(number)
+}
+
+// toFloat converts a numeric template value to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case models.Money:
+		return v.Float64(), true
+	case models.Quantity:
+		return v.Float64(), true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toTime converts a date template value to time.Time
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case primitive.DateTime:
+		return v.Time(), true
+	case string:
+		for _, layout := range dateInputLayouts {
+			if date, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// toText converts a template value to text, strings are kept as they are
+func toText(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	return fmt.Sprintf("%v", value)
+}
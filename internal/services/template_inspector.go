@@ -0,0 +1,396 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// Parts of a workbook that may hold placeholders
+const (
+	partCell        = "cell"
+	partHyperlink   = "hyperlink"
+	partComment     = "comment"
+	partHeader      = "headerFooter"
+	partSheetName   = "sheetName"
+	partDefinedName = "definedName"
+	partShape       = "shape"
+)
+
+// Kinds of template tags
+const (
+	tagValue     = "value"
+	tagBlock     = "block"
+	tagCondition = "condition"
+	tagPicture   = "picture"
+)
+
+// Condition kinds recognized in {{#kind key}} tags
+var conditionKinds = map[string]bool{
+	"if": true, "unless": true,
+	"ifcol": true, "unlesscol": true,
+	"ifsheet": true, "unlesssheet": true,
+}
+
+// TemplatePlaceholder is a placeholder or tag found in a template
+type TemplatePlaceholder struct {
+	Sheet string `json:"sheet,omitempty"`
+	Cell  string `json:"cell,omitempty"`
+	Part  string `json:"part"`
+	Text  string `json:"text"`
+	Kind  string `json:"kind"`
+}
+
+// TemplateIssue is a syntax problem found in a template
+type TemplateIssue struct {
+	Sheet   string `json:"sheet,omitempty"`
+	Cell    string `json:"cell,omitempty"`
+	Part    string `json:"part"`
+	Text    string `json:"text"`
+	Message string `json:"message"`
+}
+
+// TemplateReport is the result of inspecting a template. Unresolved is only
+// filled when the template is inspected against a sample act.
+type TemplateReport struct {
+	Placeholders []TemplatePlaceholder `json:"placeholders"`
+	Issues       []TemplateIssue       `json:"issues"`
+	Unresolved   []TemplatePlaceholder `json:"unresolved,omitempty"`
+}
+
+// templateInspection collects the report while the template is scanned
+type templateInspection struct {
+	report TemplateReport
+	scope  *templateScope
+}
+
+// InspectTemplate lists the placeholders of a template and reports syntax
+// problems. With a sample act it also reports the value placeholders that
+// would stay unresolved. Placeholders inside repeating blocks are checked
+// against every item of the list; conditional sections are not evaluated.
+func (s *excelService) InspectTemplate(templatePath string, act *models.Act) (*TemplateReport, error) {
+	utils.LogMethodInit("ExcelService.InspectTemplate")
+
+	utils.LogInfo("Opening Excel template for inspection: %s", templatePath)
+	f, err := excelize.OpenFile(templatePath)
+	if err != nil {
+		utils.LogMethodError("ExcelService.InspectTemplate", err)
+		return nil, fmt.Errorf("failed to open template: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			utils.LogError("Error closing Excel file: %v", closeErr)
+		}
+	}()
+
+	ins := &templateInspection{
+		report: TemplateReport{
+			Placeholders: []TemplatePlaceholder{},
+			Issues:       []TemplateIssue{},
+		},
+	}
+	if act != nil {
+		ins.scope = newTemplateScope(s.buildTemplateData(act), nil)
+	}
+
+	for _, sheetName := range f.GetSheetList() {
+		if err = s.inspectSheet(ins, f, sheetName); err != nil {
+			utils.LogMethodError("ExcelService.InspectTemplate", err)
+			return nil, fmt.Errorf("failed to inspect sheet %s: %w", sheetName, err)
+		}
+	}
+
+	for _, definedName := range f.GetDefinedName() {
+		ins.inspectText(TemplatePlaceholder{Part: partDefinedName, Cell: definedName.Name}, definedName.RefersTo, ins.scope)
+		ins.inspectText(TemplatePlaceholder{Part: partDefinedName, Cell: definedName.Name}, definedName.Comment, ins.scope)
+	}
+	ins.inspectDrawings(f)
+
+	utils.LogInfo("Template inspection found %d placeholders, %d issues, %d unresolved",
+		len(ins.report.Placeholders), len(ins.report.Issues), len(ins.report.Unresolved))
+	utils.LogMethodSuccess("ExcelService.InspectTemplate")
+	return &ins.report, nil
+}
+
+// inspectSheet inspects the cells and the other parts of a single sheet
+func (s *excelService) inspectSheet(ins *templateInspection, f *excelize.File, sheetName string) error {
+	ins.inspectText(TemplatePlaceholder{Sheet: sheetName, Part: partSheetName}, sheetName, ins.scope)
+
+	rows, err := f.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+
+	blocks := ins.inspectBlocks(sheetName, rows)
+	ins.inspectConditionTags(sheetName, rows)
+
+	for rowIdx, row := range rows {
+		// Cells of a repeating block are resolved against each item of the list
+		var scopes []*templateScope
+		if ins.scope != nil {
+			scopes = []*templateScope{ins.scope}
+			for _, block := range blocks {
+				if rowIdx+1 >= block.startRow && rowIdx+1 <= block.endRow {
+					scopes = blockScopes(ins.scope, block.name)
+					break
+				}
+			}
+		}
+
+		for colIdx, value := range row {
+			cellName, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+1)
+			ins.inspectText(TemplatePlaceholder{Sheet: sheetName, Cell: cellName, Part: partCell}, value, scopes...)
+		}
+	}
+
+	// Hyperlinks are listed from the sheet, since their cells may be empty
+	linkCells, err := sheetHyperlinkCells(f, sheetName)
+	if err != nil {
+		return err
+	}
+	for _, cellName := range linkCells {
+		hasLink, link, err := f.GetCellHyperLink(sheetName, cellName)
+		if err == nil && hasLink {
+			ins.inspectText(TemplatePlaceholder{Sheet: sheetName, Cell: cellName, Part: partHyperlink}, link, ins.scope)
+		}
+	}
+
+	comments, err := f.GetComments(sheetName)
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		text := comment.Text
+		for _, paragraph := range comment.Paragraph {
+			text += paragraph.Text
+		}
+		ins.inspectText(TemplatePlaceholder{Sheet: sheetName, Cell: comment.Cell, Part: partComment}, text, ins.scope)
+	}
+
+	opts, err := f.GetHeaderFooter(sheetName)
+	if err != nil {
+		return err
+	}
+	if opts != nil {
+		for _, text := range []string{
+			opts.OddHeader, opts.OddFooter,
+			opts.EvenHeader, opts.EvenFooter,
+			opts.FirstHeader, opts.FirstFooter,
+		} {
+			ins.inspectText(TemplatePlaceholder{Sheet: sheetName, Part: partHeader}, text, ins.scope)
+		}
+	}
+	return nil
+}
+
+// inspectDrawings inspects the text of shapes and text boxes
+func (ins *templateInspection) inspectDrawings(f *excelize.File) {
+	var names []string
+	f.Pkg.Range(func(key, _ interface{}) bool {
+		if name, ok := key.(string); ok && strings.HasPrefix(name, "xl/drawings/drawing") && strings.HasSuffix(name, ".xml") {
+			names = append(names, name)
+		}
+		return true
+	})
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, _ := f.Pkg.Load(name)
+		content, ok := value.([]byte)
+		if !ok {
+			continue
+		}
+		content = drawingParagraphPattern.ReplaceAllFunc(content, mergeDrawingRuns)
+		for _, run := range drawingTextPattern.FindAllSubmatch(content, -1) {
+			ins.inspectText(TemplatePlaceholder{Cell: name, Part: partShape}, string(run[2]), ins.scope)
+		}
+	}
+}
+
+// inspectBlocks finds the repeating blocks of a sheet the same way they are
+// expanded and reports blocks that are not closed and stray closing tags
+func (ins *templateInspection) inspectBlocks(sheetName string, rows [][]string) []*rowBlock {
+	var blocks []*rowBlock
+	closeCells := make(map[string]bool)
+
+	for fromRow := 1; fromRow <= len(rows); {
+		block, err := findRowBlock(rows, fromRow)
+		if err != nil {
+			// Report the unclosed block and continue after its opening row
+			row, cellName, tag := findBlockOpenTag(rows, fromRow)
+			ins.addIssue(TemplatePlaceholder{Sheet: sheetName, Cell: cellName, Part: partCell}, tag, err.Error())
+			fromRow = row + 1
+			continue
+		}
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+		closeCells[block.closeCell] = true
+		fromRow = block.endRow + 1
+	}
+
+	for rowIdx, row := range rows {
+		for colIdx, value := range row {
+			cellName, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+1)
+			for _, match := range placeholderPattern.FindAllStringSubmatch(value, -1) {
+				name, closing := strings.CutPrefix(strings.TrimSpace(match[1]), "/")
+				if !closing || conditionKinds[name] || closeCells[cellName] {
+					continue
+				}
+				ins.addIssue(TemplatePlaceholder{Sheet: sheetName, Cell: cellName, Part: partCell}, match[0],
+					fmt.Sprintf("closing tag {{/%s}} without an opening tag", name))
+			}
+		}
+	}
+	return blocks
+}
+
+// inspectConditionTags reports conditional sections that are not closed and
+// closing tags without an opening tag, matching tags of the same kind
+func (ins *templateInspection) inspectConditionTags(sheetName string, rows [][]string) {
+	open := make(map[string][]conditionTag)
+	for _, tag := range scanConditionTags(rows) {
+		if !tag.closing {
+			open[tag.kind] = append(open[tag.kind], tag)
+			continue
+		}
+		if len(open[tag.kind]) == 0 {
+			ins.addIssue(TemplatePlaceholder{Sheet: sheetName, Cell: tag.cellName(), Part: partCell}, tag.text,
+				fmt.Sprintf("closing tag {{/%s}} without an opening tag", tag.kind))
+			continue
+		}
+		open[tag.kind] = open[tag.kind][:len(open[tag.kind])-1]
+	}
+
+	var unclosed []conditionTag
+	for _, tags := range open {
+		unclosed = append(unclosed, tags...)
+	}
+	sort.Slice(unclosed, func(i, j int) bool {
+		if unclosed[i].row != unclosed[j].row {
+			return unclosed[i].row < unclosed[j].row
+		}
+		return unclosed[i].col < unclosed[j].col
+	})
+	for _, tag := range unclosed {
+		ins.addIssue(TemplatePlaceholder{Sheet: sheetName, Cell: tag.cellName(), Part: partCell}, tag.text,
+			fmt.Sprintf("condition %s %s is not closed", tag.kind, tag.key))
+	}
+}
+
+// inspectText lists the placeholders of a text, reports their syntax
+// problems and, when scopes are given, the value placeholders that do not
+// resolve in one of them
+func (ins *templateInspection) inspectText(loc TemplatePlaceholder, text string, scopes ...*templateScope) {
+	if !strings.Contains(text, "{{") && !strings.Contains(text, "}}") {
+		return
+	}
+
+	rest := placeholderPattern.ReplaceAllString(text, "")
+	if strings.Contains(rest, "{{") || strings.Contains(rest, "}}") {
+		ins.addIssue(loc, text, "unbalanced braces")
+	}
+
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		inner := strings.TrimSpace(match[1])
+		if strings.Contains(inner, "{") {
+			ins.addIssue(loc, match[0], "unbalanced braces")
+			continue
+		}
+
+		placeholder := loc
+		placeholder.Text = match[0]
+
+		switch {
+		case picturePlaceholderPattern.MatchString(match[0]):
+			placeholder.Kind = tagPicture
+			exprText := picturePlaceholderPattern.FindStringSubmatch(match[0])[2]
+			expr, err := parsePlaceholder(exprText)
+			if err != nil {
+				ins.addIssue(loc, match[0], err.Error())
+				break
+			}
+			ins.checkResolved(placeholder, expr, scopes)
+		case strings.HasPrefix(inner, "#"):
+			kind, exprText, _ := strings.Cut(inner[1:], " ")
+			if conditionKinds[kind] {
+				placeholder.Kind = tagCondition
+				if _, err := parsePlaceholder(exprText); err != nil {
+					ins.addIssue(loc, match[0], err.Error())
+				}
+			} else {
+				placeholder.Kind = tagBlock
+				if !blockOpenPattern.MatchString(match[0]) {
+					ins.addIssue(loc, match[0], "invalid block name")
+				}
+			}
+		case strings.HasPrefix(inner, "/"):
+			name := strings.TrimSpace(inner[1:])
+			placeholder.Kind = tagBlock
+			if conditionKinds[name] {
+				placeholder.Kind = tagCondition
+			}
+		default:
+			placeholder.Kind = tagValue
+			expr, err := parsePlaceholder(inner)
+			if err != nil {
+				ins.addIssue(loc, match[0], err.Error())
+				break
+			}
+			ins.checkResolved(placeholder, expr, scopes)
+		}
+
+		ins.report.Placeholders = append(ins.report.Placeholders, placeholder)
+	}
+}
+
+// checkResolved records the placeholder as unresolved when the expression
+// has no value in one of the scopes
+func (ins *templateInspection) checkResolved(placeholder TemplatePlaceholder, expr *placeholderExpr, scopes []*templateScope) {
+	for _, scope := range scopes {
+		value, found, err := expr.evaluate(scope)
+		if err != nil || !found || (placeholder.Kind == tagPicture && (value == nil || value == "")) {
+			ins.report.Unresolved = append(ins.report.Unresolved, placeholder)
+			return
+		}
+	}
+}
+
+// addIssue records a syntax problem
+func (ins *templateInspection) addIssue(loc TemplatePlaceholder, text, message string) {
+	ins.report.Issues = append(ins.report.Issues, TemplateIssue{
+		Sheet:   loc.Sheet,
+		Cell:    loc.Cell,
+		Part:    loc.Part,
+		Text:    text,
+		Message: message,
+	})
+}
+
+// findBlockOpenTag finds the first opening block tag at or below fromRow
+func findBlockOpenTag(rows [][]string, fromRow int) (int, string, string) {
+	for rowIdx := fromRow - 1; rowIdx < len(rows); rowIdx++ {
+		for colIdx, value := range rows[rowIdx] {
+			if tag := blockOpenPattern.FindString(value); tag != "" {
+				cellName, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+1)
+				return rowIdx + 1, cellName, tag
+			}
+		}
+	}
+	return len(rows), "", ""
+}
+
+// blockScopes returns the scopes a repeating block is filled with, one per item
+func blockScopes(scope *templateScope, name string) []*templateScope {
+	items := blockItems(scope, name)
+	scopes := make([]*templateScope, 0, len(items))
+	for idx, item := range items {
+		scopes = append(scopes, scope.child(blockItemData(item, idx)))
+	}
+	return scopes
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// templateScope resolves placeholder keys for a part of the template.
+// Repeating blocks create a child scope per item, and keys that the item
+// does not define fall back to the enclosing scope.
+type templateScope struct {
+	data   map[string]interface{}
+	parent *templateScope
+
+	// locale writes the numbers and dates of the scope, nested scopes share it
+	locale *utils.Locale
+}
+
+// newTemplateScope creates a root scope from the act template data, a nil
+// locale gives the default locale
+func newTemplateScope(data map[string]interface{}, locale *utils.Locale) *templateScope {
+	if locale == nil {
+		locale = utils.DefaultLocale
+	}
+	return &templateScope{data: data, locale: locale}
+}
+
+// child creates a nested scope on top of the current one
+func (sc *templateScope) child(data map[string]interface{}) *templateScope {
+	return &templateScope{data: data, parent: sc, locale: sc.locale}
+}
+
+// pathSegment is a single step of a value path: a document field or a list index
+type pathSegment struct {
+	field   string
+	index   int
+	isIndex bool
+}
+
+// lookup finds a key in the scope chain, innermost scope first. Keys may be
+// paths into nested documents and lists, e.g. customer.inn or signers[0].name.
+func (sc *templateScope) lookup(key string) (interface{}, bool) {
+	if value, ok := sc.lookupKey(key); ok {
+		return value, true
+	}
+
+	segments, ok := parsePath(key)
+	if !ok || len(segments) < 2 {
+		return nil, false
+	}
+
+	value, ok := sc.lookupKey(segments[0].field)
+	if !ok {
+		return nil, false
+	}
+	for _, segment := range segments[1:] {
+		value, ok = descend(value, segment)
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// lookupKey finds a top-level key in the scope chain
+func (sc *templateScope) lookupKey(key string) (interface{}, bool) {
+	for current := sc; current != nil; current = current.parent {
+		if value, ok := current.data[key]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// parsePath splits a path like signers[0].name into its segments. The first
+// segment is always a field.
+func parsePath(path string) ([]pathSegment, bool) {
+	var segments []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		field, rest, _ := strings.Cut(part, "[")
+		if field == "" && (len(segments) == 0 || rest == "") {
+			return nil, false
+		}
+		if field != "" {
+			segments = append(segments, pathSegment{field: field})
+		}
+
+		// Indexes follow the field, e.g. matrix[1][2]
+		for rest != "" {
+			indexText, tail, found := strings.Cut(rest, "]")
+			if !found {
+				return nil, false
+			}
+			index, err := strconv.Atoi(indexText)
+			if err != nil || index < 0 {
+				return nil, false
+			}
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+
+			if tail == "" {
+				break
+			}
+			if !strings.HasPrefix(tail, "[") {
+				return nil, false
+			}
+			rest = tail[1:]
+		}
+	}
+	return segments, len(segments) > 0
+}
+
+// descend steps from a value into one of its fields or list items
+func descend(value interface{}, segment pathSegment) (interface{}, bool) {
+	if segment.isIndex {
+		items, ok := toList(value)
+		if !ok || segment.index >= len(items) {
+			return nil, false
+		}
+		return items[segment.index], true
+	}
+
+	fields, ok := toMap(value)
+	if !ok {
+		return nil, false
+	}
+	result, ok := fields[segment.field]
+	return result, ok
+}
+
+// toMap converts a document value decoded from JSON or BSON into a map
+func toMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case primitive.M:
+		return v, true
+	case primitive.D:
+		result := make(map[string]interface{}, len(v))
+		for _, elem := range v {
+			result[elem.Key] = elem.Value
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// toList converts a slice value decoded from JSON or BSON into a list of items
+func toList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case []interface{}:
+		return v, true
+	case primitive.A:
+		return v, true
+	case string, []byte:
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	result := make([]interface{}, rv.Len())
+	for i := range result {
+		result[i] = rv.Index(i).Interface()
+	}
+	return result, true
+}
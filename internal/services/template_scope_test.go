@@ -0,0 +1,140 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []pathSegment
+	}{
+		{path: "customer", expected: []pathSegment{{field: "customer"}}},
+		{path: "customer.inn", expected: []pathSegment{{field: "customer"}, {field: "inn"}}},
+		{path: "signers[0].name", expected: []pathSegment{{field: "signers"}, {index: 0, isIndex: true}, {field: "name"}}},
+		{path: "matrix[1][2]", expected: []pathSegment{{field: "matrix"}, {index: 1, isIndex: true}, {index: 2, isIndex: true}}},
+		{path: "a.b.c[10]", expected: []pathSegment{{field: "a"}, {field: "b"}, {field: "c"}, {index: 10, isIndex: true}}},
+		{path: ""},
+		{path: "[0]"},
+		{path: "a..b"},
+		{path: "a."},
+		{path: "a[-1]"},
+		{path: "a[x]"},
+		{path: "a[0"},
+		{path: "a[0]b"},
+		{path: "a[]"},
+	}
+
+	for _, tt := range tests {
+		segments, ok := parsePath(tt.path)
+		if ok != (tt.expected != nil) {
+			t.Errorf("parsePath(%q) ok = %v, expected %v", tt.path, ok, tt.expected != nil)
+			continue
+		}
+		if ok && !reflect.DeepEqual(segments, tt.expected) {
+			t.Errorf("parsePath(%q) = %+v, expected %+v", tt.path, segments, tt.expected)
+		}
+	}
+}
+
+func TestScopeLookup(t *testing.T) {
+	root := newTemplateScope(map[string]interface{}{
+		"customer": map[string]interface{}{
+			"name": "Customer",
+			"bank": primitive.M{"bik": "044525225"},
+		},
+		"signers": []interface{}{
+			map[string]interface{}{"name": "Ivanov"},
+			primitive.D{{Key: "name", Value: "Petrov"}},
+		},
+		"matrix":      primitive.A{[]string{"a", "b"}, []int{1, 2, 3}},
+		"title":       "Act",
+		"count":       3,
+		"empty":       nil,
+		"dotted.key":  "flat",
+		"emptyList":   []interface{}{},
+		"nestedEmpty": map[string]interface{}{"note": ""},
+	}, nil)
+	item := root.child(map[string]interface{}{"name": "Item", "customer": "shadowed"})
+
+	tests := []struct {
+		scope    *templateScope
+		key      string
+		expected interface{}
+		missing  bool
+	}{
+		{scope: root, key: "title", expected: "Act"},
+		{scope: root, key: "customer.name", expected: "Customer"},
+		{scope: root, key: "customer.bank.bik", expected: "044525225"},
+		{scope: root, key: "signers[0].name", expected: "Ivanov"},
+		{scope: root, key: "signers[1].name", expected: "Petrov"},
+		{scope: root, key: "matrix[0][1]", expected: "b"},
+		{scope: root, key: "matrix[1][2]", expected: 3},
+		{scope: root, key: "dotted.key", expected: "flat"},
+		{scope: root, key: "nestedEmpty.note", expected: ""},
+		{scope: root, key: "empty", expected: nil},
+		{scope: root, key: "signers[2].name", missing: true},
+		{scope: root, key: "matrix[0][2]", missing: true},
+		{scope: root, key: "emptyList[0]", missing: true},
+		{scope: root, key: "customer.phone", missing: true},
+		{scope: root, key: "customer[0]", missing: true},
+		{scope: root, key: "title.length", missing: true},
+		{scope: root, key: "count.value", missing: true},
+		{scope: root, key: "empty.value", missing: true},
+		{scope: root, key: "signers.name", missing: true},
+		{scope: root, key: "missing.name", missing: true},
+		{scope: root, key: "signers[x]", missing: true},
+		{scope: item, key: "name", expected: "Item"},
+		{scope: item, key: "title", expected: "Act"},
+		{scope: item, key: "signers[0].name", expected: "Ivanov"},
+		{scope: item, key: "customer", expected: "shadowed"},
+		{scope: item, key: "customer.name", missing: true},
+	}
+
+	for _, tt := range tests {
+		value, found := tt.scope.lookup(tt.key)
+		if found == tt.missing {
+			t.Errorf("lookup(%q) found = %v, expected %v", tt.key, found, !tt.missing)
+			continue
+		}
+		if found && !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("lookup(%q) = %v, expected %v", tt.key, value, tt.expected)
+		}
+	}
+}
+
+func TestDescend(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		segment  pathSegment
+		expected interface{}
+		missing  bool
+	}{
+		{name: "map field", value: map[string]interface{}{"a": 1}, segment: pathSegment{field: "a"}, expected: 1},
+		{name: "BSON document field", value: primitive.D{{Key: "a", Value: 2}}, segment: pathSegment{field: "a"}, expected: 2},
+		{name: "list item", value: []interface{}{"x", "y"}, segment: pathSegment{index: 1, isIndex: true}, expected: "y"},
+		{name: "typed slice item", value: []int{4, 5}, segment: pathSegment{index: 0, isIndex: true}, expected: 4},
+		{name: "index out of range", value: []interface{}{"x"}, segment: pathSegment{index: 1, isIndex: true}, missing: true},
+		{name: "index into a document", value: map[string]interface{}{"0": "x"}, segment: pathSegment{index: 0, isIndex: true}, missing: true},
+		{name: "index into a string", value: "text", segment: pathSegment{index: 0, isIndex: true}, missing: true},
+		{name: "field of a list", value: []interface{}{"x"}, segment: pathSegment{field: "a"}, missing: true},
+		{name: "field of a scalar", value: 7, segment: pathSegment{field: "a"}, missing: true},
+		{name: "field of nil", value: nil, segment: pathSegment{field: "a"}, missing: true},
+		{name: "missing field", value: map[string]interface{}{"a": 1}, segment: pathSegment{field: "b"}, missing: true},
+	}
+
+	for _, tt := range tests {
+		value, ok := descend(tt.value, tt.segment)
+		if ok == tt.missing {
+			t.Errorf("%s: ok = %v, expected %v", tt.name, ok, !tt.missing)
+			continue
+		}
+		if ok && !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("%s: value = %v, expected %v", tt.name, value, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/config"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/utils"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidTemplate is returned when an uploaded template is rejected
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// Template IDs are used in file paths, so only safe characters are allowed
+var templateIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// TemplateService defines the interface for template registry operations
+type TemplateService interface {
+	Upload(ctx context.Context, upload TemplateUpload) (*models.Template, error)
+	List(ctx context.Context) ([]models.Template, error)
+	Activate(ctx context.Context, templateID string, version int) error
+	ResolveTemplate(ctx context.Context, act *models.Act) (*models.Template, error)
+	Inspect(ctx context.Context, templateID string, version int, actID string) (*TemplateReport, error)
+}
+
+// TemplateUpload describes a template file uploaded to the registry
+type TemplateUpload struct {
+	TemplateID       string
+	FileName         string
+	Content          []byte
+	Activate         bool
+	MissingKeyPolicy string
+	Locale           string
+}
+
+// templateService implements TemplateService
+type templateService struct {
+	repo         repository.TemplateRepository
+	actRepo      repository.ActRepository
+	excelService ExcelService
+	config       *config.Config
+}
+
+// NewTemplateService creates a new TemplateService
+func NewTemplateService(repo repository.TemplateRepository, actRepo repository.ActRepository, excelService ExcelService, cfg *config.Config) TemplateService {
+	return &templateService{
+		repo:         repo,
+		actRepo:      actRepo,
+		excelService: excelService,
+		config:       cfg,
+	}
+}
+
+// Upload stores a new version of a template. The first version of a
+// template becomes active right away, later versions only when requested.
+func (s *templateService) Upload(ctx context.Context, upload TemplateUpload) (*models.Template, error) {
+	utils.LogMethodInit("TemplateService.Upload")
+
+	templateID := upload.TemplateID
+	if !templateIDPattern.MatchString(templateID) {
+		err := fmt.Errorf("%w: template ID %q may only contain letters, digits, _ and -", ErrInvalidTemplate, templateID)
+		utils.LogMethodError("TemplateService.Upload", err)
+		return nil, err
+	}
+
+	policy, err := ParseMissingKeyPolicy(upload.MissingKeyPolicy)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		utils.LogMethodError("TemplateService.Upload", err)
+		return nil, err
+	}
+
+	if upload.Locale != "" {
+		if _, err = utils.LookupLocale(upload.Locale); err != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+			utils.LogMethodError("TemplateService.Upload", err)
+			return nil, err
+		}
+	}
+
+	// Make sure the file is a workbook before storing it
+	f, err := excelize.OpenReader(bytes.NewReader(upload.Content))
+	if err != nil {
+		err = fmt.Errorf("%w: failed to open workbook: %v", ErrInvalidTemplate, err)
+		utils.LogMethodError("TemplateService.Upload", err)
+		return nil, err
+	}
+	if closeErr := f.Close(); closeErr != nil {
+		utils.LogError("Error closing Excel file: %v", closeErr)
+	}
+
+	latest, err := s.repo.LatestVersion(ctx, templateID)
+	if err != nil {
+		utils.LogMethodError("TemplateService.Upload", err)
+		return nil, fmt.Errorf("failed to get template version: %w", err)
+	}
+
+	template := &models.Template{
+		TemplateID:       templateID,
+		Version:          latest + 1,
+		FileName:         filepath.Base(upload.FileName),
+		MissingKeyPolicy: string(policy),
+		Locale:           upload.Locale,
+		CreatedAt:        time.Now(),
+	}
+	template.FilePath = s.versionPath(templateID, template.Version)
+
+	// The file is written under a temporary name and moved in place once the
+	// version is stored, so a concurrent upload that loses the race for the
+	// version number never overwrites the file of the winner
+	utils.LogInfo("Saving template %s version %d to %s", templateID, template.Version, template.FilePath)
+	if err = os.MkdirAll(filepath.Dir(template.FilePath), 0755); err != nil {
+		utils.LogMethodError("TemplateService.Upload", err)
+		return nil, fmt.Errorf("failed to create template directory: %w", err)
+	}
+	tempPath, err := writeTempFile(filepath.Dir(template.FilePath), upload.Content)
+	if err != nil {
+		utils.LogMethodError("TemplateService.Upload", err)
+		return nil, fmt.Errorf("failed to save template file: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	id, err := s.repo.Create(ctx, template)
+	if err != nil {
+		utils.LogMethodError("TemplateService.Upload", err)
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	template.ID, _ = primitive.ObjectIDFromHex(id)
+
+	if err = os.Rename(tempPath, template.FilePath); err != nil {
+		utils.LogMethodError("TemplateService.Upload", err)
+		if deleteErr := s.repo.Delete(ctx, id); deleteErr != nil {
+			utils.LogError("Error deleting template %s version %d without a file: %v", templateID, template.Version, deleteErr)
+		}
+		return nil, fmt.Errorf("failed to save template file: %w", err)
+	}
+
+	if upload.Activate || template.Version == 1 {
+		if err = s.repo.Activate(ctx, templateID, template.Version); err != nil {
+			utils.LogMethodError("TemplateService.Upload", err)
+			return nil, fmt.Errorf("failed to activate template: %w", err)
+		}
+		template.Active = true
+		s.evictInactiveVersions(templateID, template.Version)
+	}
+
+	utils.LogInfo("Successfully uploaded template %s version %d", templateID, template.Version)
+	utils.LogMethodSuccess("TemplateService.Upload")
+	return template, nil
+}
+
+// writeTempFile writes the content to a new temporary file in the directory
+// and returns its path
+func writeTempFile(dir string, content []byte) (string, error) {
+	file, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	if err = file.Chmod(0644); err == nil {
+		_, err = file.Write(content)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// List returns all template versions
+func (s *templateService) List(ctx context.Context) ([]models.Template, error) {
+	utils.LogMethodInit("TemplateService.List")
+
+	templates, err := s.repo.FindAll(ctx)
+	if err != nil {
+		utils.LogMethodError("TemplateService.List", err)
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	utils.LogMethodSuccess("TemplateService.List")
+	return templates, nil
+}
+
+// Activate makes a template version the one used by acts that do not pin a version
+func (s *templateService) Activate(ctx context.Context, templateID string, version int) error {
+	utils.LogMethodInit("TemplateService.Activate")
+
+	if err := s.repo.Activate(ctx, templateID, version); err != nil {
+		utils.LogMethodError("TemplateService.Activate", err)
+		return fmt.Errorf("failed to activate template: %w", err)
+	}
+	s.evictInactiveVersions(templateID, version)
+
+	utils.LogMethodSuccess("TemplateService.Activate")
+	return nil
+}
+
+// versionPath returns the path of the file of a template version
+func (s *templateService) versionPath(templateID string, version int) string {
+	return filepath.Join(s.config.TemplatesDir, templateID, fmt.Sprintf("v%d.xlsx", version))
+}
+
+// evictInactiveVersions drops the compiled versions of a template other
+// than the active one from the cache. Acts pinned to an older version still
+// render, their template is compiled again.
+func (s *templateService) evictInactiveVersions(templateID string, active int) {
+	activePath := s.versionPath(templateID, active)
+	s.excelService.EvictTemplates(filepath.Dir(activePath), activePath)
+}
+
+// ResolveTemplate returns the template an act renders with. Acts without a
+// template ID use the default template from the configuration, acts without
+// a template version use the active version.
+func (s *templateService) ResolveTemplate(ctx context.Context, act *models.Act) (*models.Template, error) {
+	return s.resolve(ctx, act.TemplateID, act.TemplateVersion)
+}
+
+// Inspect lists the placeholders and syntax problems of a template. When an
+// act ID is given, the act serves as sample data for finding unresolved
+// placeholders, and its own template is inspected unless a template ID is given.
+func (s *templateService) Inspect(ctx context.Context, templateID string, version int, actID string) (*TemplateReport, error) {
+	utils.LogMethodInit("TemplateService.Inspect")
+
+	var act *models.Act
+	if actID != "" {
+		// A malformed ID cannot name an act
+		if _, err := primitive.ObjectIDFromHex(actID); err != nil {
+			err = fmt.Errorf("act not found: %w: invalid ID %q", repository.ErrActNotFound, actID)
+			utils.LogMethodError("TemplateService.Inspect", err)
+			return nil, err
+		}
+		var err error
+		act, err = s.actRepo.FindByID(ctx, actID)
+		if err != nil {
+			utils.LogMethodError("TemplateService.Inspect", err)
+			return nil, fmt.Errorf("act not found: %w", err)
+		}
+		if templateID == "" {
+			templateID, version = act.TemplateID, act.TemplateVersion
+		}
+	}
+
+	template, err := s.resolve(ctx, templateID, version)
+	if err != nil {
+		utils.LogMethodError("TemplateService.Inspect", err)
+		return nil, err
+	}
+
+	report, err := s.excelService.InspectTemplate(template.FilePath, act)
+	if err != nil {
+		utils.LogMethodError("TemplateService.Inspect", err)
+		return nil, err
+	}
+
+	utils.LogMethodSuccess("TemplateService.Inspect")
+	return report, nil
+}
+
+// resolve returns a template version, the active version when version is 0,
+// and the default template when templateID is empty
+func (s *templateService) resolve(ctx context.Context, templateID string, version int) (*models.Template, error) {
+	if templateID == "" {
+		return &models.Template{FilePath: s.config.TemplatePath}, nil
+	}
+
+	template, err := s.repo.FindByTemplateID(ctx, templateID, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve template %s: %w", templateID, err)
+	}
+
+	utils.LogDebug("Resolved template %s version %d: %s", template.TemplateID, template.Version, template.FilePath)
+	return template, nil
+}
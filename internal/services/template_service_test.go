@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/models"
+	"github.com/stepanpotapov/Excel-Template-Engine/internal/repository"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// uploadTemplateRepository stores template versions in memory and rejects
+// a version that is already stored, like the unique index does
+type uploadTemplateRepository struct {
+	repository.TemplateRepository
+	versions  map[int]bool
+	latest    int
+	activated []int
+}
+
+// LatestVersion returns the version the next upload reads, which may be
+// stale when uploads run concurrently
+func (r *uploadTemplateRepository) LatestVersion(_ context.Context, _ string) (int, error) {
+	return r.latest, nil
+}
+
+// Create stores the version unless it exists
+func (r *uploadTemplateRepository) Create(_ context.Context, template *models.Template) (string, error) {
+	if r.versions[template.Version] {
+		return "", repository.ErrTemplateVersionExists
+	}
+	r.versions[template.Version] = true
+	return primitive.NewObjectID().Hex(), nil
+}
+
+// Activate records the activated version
+func (r *uploadTemplateRepository) Activate(_ context.Context, _ string, version int) error {
+	r.activated = append(r.activated, version)
+	return nil
+}
+
+// testWorkbookContent returns the content of a one-cell workbook
+func testWorkbookContent(t *testing.T, value string) []byte {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetCellValue(f.GetSheetName(0), "A1", value); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadKeepsFileOfStoredVersion(t *testing.T) {
+	cfg := testConfig()
+	cfg.TemplatesDir = t.TempDir()
+	repo := &uploadTemplateRepository{versions: make(map[int]bool)}
+	service := NewTemplateService(repo, nil, NewExcelService(cfg), cfg)
+
+	first := testWorkbookContent(t, "first")
+	template, err := service.Upload(context.Background(), TemplateUpload{TemplateID: "customer", FileName: "a.xlsx", Content: first})
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if template.Version != 1 || !template.Active || len(repo.activated) != 1 {
+		t.Errorf("template = version %d, active %v, activations %v, expected the active version 1", template.Version, template.Active, repo.activated)
+	}
+
+	// A concurrent upload read the same latest version and loses the race
+	_, err = service.Upload(context.Background(), TemplateUpload{TemplateID: "customer", FileName: "b.xlsx", Content: testWorkbookContent(t, "second")})
+	if !errors.Is(err, repository.ErrTemplateVersionExists) {
+		t.Fatalf("Upload() error = %v, expected ErrTemplateVersionExists", err)
+	}
+
+	stored, err := os.ReadFile(template.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, first) {
+		t.Error("file of version 1 was overwritten by the rejected upload")
+	}
+	entries, err := os.ReadDir(filepath.Dir(template.FilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("template directory holds %d files, expected only the stored version", len(entries))
+	}
+}
+
+func TestInspectUnknownAct(t *testing.T) {
+	actRepo := &missingActRepository{}
+	service := NewTemplateService(nil, actRepo, NewExcelService(testConfig()), testConfig())
+
+	for _, actID := range []string{"6523f0a1b2c3d4e5f6a7b8c9", "not-an-id"} {
+		_, err := service.Inspect(context.Background(), "", 0, actID)
+		if !errors.Is(err, repository.ErrActNotFound) {
+			t.Errorf("Inspect(%q) error = %v, expected ErrActNotFound", actID, err)
+		}
+	}
+	if actRepo.lookups != 1 {
+		t.Errorf("acts looked up %d times, expected only the well-formed ID", actRepo.lookups)
+	}
+}
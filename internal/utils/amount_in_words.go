@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// wordForms holds the singular, few (2-4) and many forms of a Russian noun
+type wordForms [3]string
+
+var (
+	rubleForms  = wordForms{"рубль", "рубля", "рублей"}
+	kopeckForms = wordForms{"копейка", "копейки", "копеек"}
+)
+
+// numberScale describes a thousands group: its noun forms and grammatical gender
+type numberScale struct {
+	forms    wordForms
+	feminine bool
+}
+
+// Scales of thousands groups from the lowest one, the units group is named
+// by the currency itself. They cover every int64.
+var numberScales = []numberScale{
+	{forms: wordForms{"", "", ""}},
+	{forms: wordForms{"тысяча", "тысячи", "тысяч"}, feminine: true},
+	{forms: wordForms{"миллион", "миллиона", "миллионов"}},
+	{forms: wordForms{"миллиард", "миллиарда", "миллиардов"}},
+	{forms: wordForms{"триллион", "триллиона", "триллионов"}},
+	{forms: wordForms{"квадриллион", "квадриллиона", "квадриллионов"}},
+	{forms: wordForms{"квинтиллион", "квинтиллиона", "квинтиллионов"}},
+}
+
+// maxWordsAmount bounds the amounts FormatAmountInWords spells, 2^63 rubles
+const maxWordsAmount = 1 << 63
+
+var (
+	unitWordsMasculine = []string{"", "один", "два", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять"}
+	unitWordsFeminine  = []string{"", "одна", "две", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять"}
+	teenWords          = []string{"десять", "одиннадцать", "двенадцать", "тринадцать", "четырнадцать", "пятнадцать", "шестнадцать", "семнадцать", "восемнадцать", "девятнадцать"}
+	tenWords           = []string{"", "", "двадцать", "тридцать", "сорок", "пятьдесят", "шестьдесят", "семьдесят", "восемьдесят", "девяносто"}
+	hundredWords       = []string{"", "сто", "двести", "триста", "четыреста", "пятьсот", "шестьсот", "семьсот", "восемьсот", "девятьсот"}
+)
+
+// FormatAmountInWords formats an amount in rubles in Russian words, as
+// written in acts and invoices ("сумма прописью"). Kopecks are kept as digits.
+// Amounts that are not finite or reach 2^63 rubles are an error.
+// Example: 1000000 -> "Один миллион рублей 00 копеек"
+func FormatAmountInWords(value float64) (string, error) {
+	if math.IsNaN(value) || math.Abs(value) >= maxWordsAmount {
+		return "", fmt.Errorf("amount %g is too large to write in words", value)
+	}
+
+	// Split into rubles and kopecks, rounded the same way as FormatNumber
+	negative := value < 0
+	if negative {
+		value = -value
+	}
+	rubles := int64(math.Floor(value))
+	kopecks := int64(math.Round((value - math.Floor(value)) * 100))
+	if kopecks >= 100 {
+		rubles++
+		kopecks = 0
+	}
+	return formatRublesInWords(negative, rubles, kopecks), nil
+}
+
+// FormatKopecksInWords formats an exact amount given in kopecks in Russian words
+// Example: 100000050 -> "Один миллион рублей 50 копеек"
+func FormatKopecksInWords(amount int64) string {
+	// The magnitude is taken unsigned, so the lowest int64 does not overflow
+	negative := amount < 0
+	abs := uint64(amount)
+	if negative {
+		abs = -abs
+	}
+	return formatRublesInWords(negative, int64(abs/100), int64(abs%100))
+}
+
+// formatRublesInWords spells rubles in words and keeps kopecks as digits
+func formatRublesInWords(negative bool, rubles, kopecks int64) string {
+	words := integerInWords(rubles)
+	if negative {
+		words = "минус " + words
+	}
+
+	result := fmt.Sprintf("%s %s %02d %s", words, pluralForm(rubles, rubleForms), kopecks, pluralForm(kopecks, kopeckForms))
+	return capitalize(result)
+}
+
+// integerInWords spells a non-negative integer in Russian words, the units
+// group agrees with a masculine noun such as рубль
+func integerInWords(n int64) string {
+	if n == 0 {
+		return "ноль"
+	}
+
+	var groups []string
+	for scaleIdx := 0; n > 0 && scaleIdx < len(numberScales); scaleIdx++ {
+		group := n % 1000
+		n /= 1000
+		if group == 0 {
+			continue
+		}
+
+		scale := numberScales[scaleIdx]
+		words := tripletInWords(group, scale.feminine)
+		if scaleIdx > 0 {
+			words += " " + pluralForm(group, scale.forms)
+		}
+		groups = append([]string{words}, groups...)
+	}
+
+	return strings.Join(groups, " ")
+}
+
+// tripletInWords spells a number from 1 to 999 in Russian words
+func tripletInWords(n int64, feminine bool) string {
+	var words []string
+
+	if hundreds := n / 100; hundreds > 0 {
+		words = append(words, hundredWords[hundreds])
+	}
+
+	rest := n % 100
+	switch {
+	case rest >= 10 && rest < 20:
+		words = append(words, teenWords[rest-10])
+	default:
+		if tens := rest / 10; tens > 0 {
+			words = append(words, tenWords[tens])
+		}
+		if units := rest % 10; units > 0 {
+			if feminine {
+				words = append(words, unitWordsFeminine[units])
+			} else {
+				words = append(words, unitWordsMasculine[units])
+			}
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// pluralForm picks the Russian noun form agreeing with the number
+func pluralForm(n int64, forms wordForms) string {
+	n %= 100
+	switch {
+	case n >= 11 && n <= 14:
+		return forms[2]
+	case n%10 == 1:
+		return forms[0]
+	case n%10 >= 2 && n%10 <= 4:
+		return forms[1]
+	default:
+		return forms[2]
+	}
+}
+
+// capitalize converts the first letter of the text to upper case
+func capitalize(text string) string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return text
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
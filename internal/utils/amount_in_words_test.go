@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatAmountInWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    float64
+		expected string
+	}{
+		{
+			name:     "one million",
+			input:    1000000,
+			expected: "Один миллион рублей 00 копеек",
+		},
+		{
+			name:     "zero",
+			input:    0,
+			expected: "Ноль рублей 00 копеек",
+		},
+		{
+			name:     "kopecks only",
+			input:    0.5,
+			expected: "Ноль рублей 50 копеек",
+		},
+		{
+			name:     "one ruble one kopeck",
+			input:    1.01,
+			expected: "Один рубль 01 копейка",
+		},
+		{
+			name:     "few form",
+			input:    2.02,
+			expected: "Два рубля 02 копейки",
+		},
+		{
+			name:     "many form",
+			input:    5.05,
+			expected: "Пять рублей 05 копеек",
+		},
+		{
+			name:     "teens use many form",
+			input:    11.11,
+			expected: "Одиннадцать рублей 11 копеек",
+		},
+		{
+			name:     "twelve to fourteen use many form",
+			input:    14.12,
+			expected: "Четырнадцать рублей 12 копеек",
+		},
+		{
+			name:     "compound one",
+			input:    21.21,
+			expected: "Двадцать один рубль 21 копейка",
+		},
+		{
+			name:     "compound few",
+			input:    102.34,
+			expected: "Сто два рубля 34 копейки",
+		},
+		{
+			name:     "hundred with teen",
+			input:    112,
+			expected: "Сто двенадцать рублей 00 копеек",
+		},
+		{
+			name:     "one thousand is feminine",
+			input:    1000,
+			expected: "Одна тысяча рублей 00 копеек",
+		},
+		{
+			name:     "two thousand is feminine",
+			input:    2000,
+			expected: "Две тысячи рублей 00 копеек",
+		},
+		{
+			name:     "five thousand",
+			input:    5000,
+			expected: "Пять тысяч рублей 00 копеек",
+		},
+		{
+			name:     "eleven thousand",
+			input:    11000,
+			expected: "Одиннадцать тысяч рублей 00 копеек",
+		},
+		{
+			name:     "twenty one thousand",
+			input:    21001,
+			expected: "Двадцать одна тысяча один рубль 00 копеек",
+		},
+		{
+			name:     "two millions are masculine",
+			input:    2000000,
+			expected: "Два миллиона рублей 00 копеек",
+		},
+		{
+			name:     "full number",
+			input:    1234567.89,
+			expected: "Один миллион двести тридцать четыре тысячи пятьсот шестьдесят семь рублей 89 копеек",
+		},
+		{
+			name:     "empty groups are skipped",
+			input:    1000001,
+			expected: "Один миллион один рубль 00 копеек",
+		},
+		{
+			name:     "billions",
+			input:    3000000000,
+			expected: "Три миллиарда рублей 00 копеек",
+		},
+		{
+			name:     "all hundreds and tens",
+			input:    999999.99,
+			expected: "Девятьсот девяносто девять тысяч девятьсот девяносто девять рублей 99 копеек",
+		},
+		{
+			name:     "rounding carries into rubles",
+			input:    9.999,
+			expected: "Десять рублей 00 копеек",
+		},
+		{
+			name:     "negative amount",
+			input:    -40.4,
+			expected: "Минус сорок рублей 40 копеек",
+		},
+		{
+			name:     "quadrillions",
+			input:    2e15,
+			expected: "Два квадриллиона рублей 00 копеек",
+		},
+		{
+			name:     "quintillions",
+			input:    5e18,
+			expected: "Пять квинтиллионов рублей 00 копеек",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FormatAmountInWords(tt.input)
+			if err != nil {
+				t.Fatalf("FormatAmountInWords(%f) error = %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("FormatAmountInWords(%f) = %s; expected %s", tt.input, result, tt.expected)
+			}
+		})
+	}
+
+	for _, input := range []float64{1e19, -1e19, math.Inf(1), math.NaN()} {
+		if result, err := FormatAmountInWords(input); err == nil {
+			t.Errorf("FormatAmountInWords(%g) = %s, expected an error", input, result)
+		}
+	}
+}
+
+func TestFormatKopecksInWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    int64
+		expected string
+	}{
+		{
+			name:     "rubles and kopecks",
+			input:    100000050,
+			expected: "Один миллион рублей 50 копеек",
+		},
+		{
+			name:     "kopecks only",
+			input:    1,
+			expected: "Ноль рублей 01 копейка",
+		},
+		{
+			name:     "negative amount",
+			input:    -4040,
+			expected: "Минус сорок рублей 40 копеек",
+		},
+		{
+			name:     "quadrillion",
+			input:    100000000000000000,
+			expected: "Один квадриллион рублей 00 копеек",
+		},
+		{
+			name:  "lowest amount",
+			input: math.MinInt64,
+			expected: "Минус девяносто два квадриллиона двести тридцать три триллиона семьсот двадцать миллиардов " +
+				"триста шестьдесят восемь миллионов пятьсот сорок семь тысяч семьсот пятьдесят восемь рублей 08 копеек",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FormatKopecksInWords(tt.input)
+			if result != tt.expected {
+				t.Errorf("FormatKopecksInWords(%d) = %s; expected %s", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Locale describes how numbers and dates are written in generated documents
+type Locale struct {
+	// Name is the name the locale is selected by
+	Name string
+
+	// GroupSeparator separates groups of three digits of the integer part
+	GroupSeparator string
+
+	// DecimalSeparator separates the integer and the fractional part
+	DecimalSeparator string
+
+	// Decimals is the number of decimal places numbers are written with
+	Decimals int
+
+	// DateLayout and LongDateLayout are Go layouts for short and long dates
+	DateLayout     string
+	LongDateLayout string
+
+	// Months are the month names used in place of January to December,
+	// ShortMonths in place of Jan to Dec. English names are kept when empty.
+	Months      [12]string
+	ShortMonths [12]string
+}
+
+// DefaultLocale writes numbers as 1,234,567.89 and dates as 02.01.2006
+var DefaultLocale = &Locale{
+	Name:             "default",
+	GroupSeparator:   ",",
+	DecimalSeparator: ".",
+	Decimals:         2,
+	DateLayout:       "02.01.2006",
+	LongDateLayout:   "2 January 2006",
+}
+
+// RussianLocale writes numbers as 1 234 567,89 with a non-breaking space
+// between groups and dates as 02.01.2006 or 17 октября 2026 г.
+var RussianLocale = &Locale{
+	Name:             "ru",
+	GroupSeparator:   "\u00a0",
+	DecimalSeparator: ",",
+	Decimals:         2,
+	DateLayout:       "02.01.2006",
+	LongDateLayout:   "2 January 2006 г.",
+	Months: [12]string{
+		"января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря",
+	},
+	ShortMonths: [12]string{
+		"янв", "фев", "мар", "апр", "мая", "июн",
+		"июл", "авг", "сен", "окт", "ноя", "дек",
+	},
+}
+
+// EnglishLocale writes numbers as 1,234,567.89 and dates as 01/02/2006 or October 17, 2026
+var EnglishLocale = &Locale{
+	Name:             "en",
+	GroupSeparator:   ",",
+	DecimalSeparator: ".",
+	Decimals:         2,
+	DateLayout:       "01/02/2006",
+	LongDateLayout:   "January 2, 2006",
+}
+
+// Locales available by name
+var locales = map[string]*Locale{
+	DefaultLocale.Name: DefaultLocale,
+	RussianLocale.Name: RussianLocale,
+	EnglishLocale.Name: EnglishLocale,
+}
+
+// LookupLocale finds a locale by name. An empty name gives the default locale.
+func LookupLocale(name string) (*Locale, error) {
+	if name == "" {
+		return DefaultLocale, nil
+	}
+	locale, ok := locales[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown locale %q", name)
+	}
+	return locale, nil
+}
+
+// FormatNumber formats a number with the locale separators and decimal places
+// Example (ru): 1234567.89 -> "1 234 567,89"
+func (l *Locale) FormatNumber(value float64) string {
+	return l.FormatNumberWithDecimals(value, l.Decimals)
+}
+
+// FormatNumberWithDecimals formats a number with the locale separators and
+// the given number of decimal places
+func (l *Locale) FormatNumberWithDecimals(value float64, decimals int) string {
+	if decimals < 0 {
+		decimals = 0
+	}
+
+	// Handle negative numbers
+	negative := value < 0
+	if negative {
+		value = -value
+	}
+
+	// Split into integer and decimal parts
+	scale := math.Pow(10, float64(decimals))
+	integerPart := int64(math.Floor(value))
+	decimalPart := int64(math.Round((value - math.Floor(value)) * scale))
+
+	// Handle rounding edge case where decimal becomes 10^decimals
+	if decimalPart >= int64(scale) {
+		integerPart++
+		decimalPart = 0
+	}
+
+	// Format integer part with group separators
+	result := groupDigits(integerPart, l.GroupSeparator)
+
+	// Format decimal part (always the requested number of digits)
+	if decimals > 0 {
+		result = fmt.Sprintf("%s%s%0*d", result, l.DecimalSeparator, decimals, decimalPart)
+	}
+
+	// Values rounding to zero are written without a sign
+	if negative && (integerPart != 0 || decimalPart != 0) {
+		result = "-" + result
+	}
+
+	return result
+}
+
+// Decimal is a fixed-point number given by its value in the smallest units
+// and the number of decimal places, e.g. 123456 kopecks with scale 2 is 1234.56
+type Decimal interface {
+	Units() int64
+	Scale() int
+}
+
+// FormatDecimal formats a fixed-point number exactly with the locale
+// separators and the given number of decimal places. Dropped digits are
+// rounded half away from zero.
+func (l *Locale) FormatDecimal(value Decimal, decimals int) string {
+	if decimals < 0 {
+		decimals = 0
+	}
+
+	units, scale := value.Units(), value.Scale()
+	negative := units < 0
+	abs := new(big.Int).Abs(big.NewInt(units))
+
+	// Bring the units to the requested number of decimal places
+	if decimals >= scale {
+		abs.Mul(abs, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals-scale)), nil))
+	} else {
+		divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-decimals)), nil)
+		remainder := new(big.Int)
+		abs.QuoRem(abs, divisor, remainder)
+		if remainder.Lsh(remainder, 1).Cmp(divisor) >= 0 {
+			abs.Add(abs, big.NewInt(1))
+		}
+	}
+
+	digits := abs.String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	integerPart, _ := new(big.Int).SetString(digits[:len(digits)-decimals], 10)
+
+	result := groupDigits(integerPart.Int64(), l.GroupSeparator)
+	if decimals > 0 {
+		result += l.DecimalSeparator + digits[len(digits)-decimals:]
+	}
+	if negative && abs.Sign() != 0 {
+		result = "-" + result
+	}
+	return result
+}
+
+// NormalizeNumber turns a number written with the locale separators into
+// plain digits with a point, dropping group separators and spaces
+// Example (ru): "1 234 567,89" -> "1234567.89"
+func (l *Locale) NormalizeNumber(text string) string {
+	text = strings.TrimSpace(text)
+	if l.GroupSeparator != "" {
+		text = strings.ReplaceAll(text, l.GroupSeparator, "")
+	}
+	text = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "").Replace(text)
+	if l.DecimalSeparator != "" && l.DecimalSeparator != "." {
+		text = strings.ReplaceAll(text, l.DecimalSeparator, ".")
+	}
+	return text
+}
+
+// excelDateCodes maps the elements of a Go date layout to Excel date codes
+var excelDateCodes = strings.NewReplacer("2006", "yyyy", "01", "mm", "02", "dd", "06", "yy", "_2", "d", "1", "m", "2", "d")
+
+// ExcelDateFormat returns the short date layout as an Excel number format,
+// so dates typed into cells read like dates written as text
+// Example (en): "01/02/2006" -> "mm/dd/yyyy"
+func (l *Locale) ExcelDateFormat() string {
+	return excelDateCodes.Replace(l.DateLayout)
+}
+
+// FormatDate formats a date with a Go layout, replacing English month names
+// with the names of the locale. An empty layout gives the short date layout.
+func (l *Locale) FormatDate(date time.Time, layout string) string {
+	if layout == "" {
+		layout = l.DateLayout
+	}
+	result := date.Format(layout)
+
+	month := int(date.Month()) - 1
+	switch {
+	case strings.Contains(layout, "January") && l.Months[month] != "":
+		result = strings.Replace(result, date.Month().String(), l.Months[month], 1)
+	case strings.Contains(layout, "Jan") && l.ShortMonths[month] != "":
+		result = strings.Replace(result, date.Month().String()[:3], l.ShortMonths[month], 1)
+	}
+	return result
+}
+
+// groupDigits writes an integer with the separator between groups of three digits
+func groupDigits(n int64, separator string) string {
+	str := fmt.Sprintf("%d", n)
+
+	var result strings.Builder
+	length := len(str)
+	for i, digit := range str {
+		if i > 0 && (length-i)%3 == 0 {
+			result.WriteString(separator)
+		}
+		result.WriteRune(digit)
+	}
+	return result.String()
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocaleFormatNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   *Locale
+		input    float64
+		decimals int
+		expected string
+	}{
+		{
+			name:     "default locale",
+			locale:   DefaultLocale,
+			input:    1234567.89,
+			decimals: 2,
+			expected: "1,234,567.89",
+		},
+		{
+			name:     "russian locale",
+			locale:   RussianLocale,
+			input:    1234567.89,
+			decimals: 2,
+			expected: "1\u00a0234\u00a0567,89",
+		},
+		{
+			name:     "russian locale without decimals",
+			locale:   RussianLocale,
+			input:    -1000,
+			decimals: 0,
+			expected: "-1\u00a0000",
+		},
+		{
+			name:     "negative value rounding to zero",
+			locale:   DefaultLocale,
+			input:    -0.001,
+			decimals: 2,
+			expected: "0.00",
+		},
+		{
+			name:     "negative zero without decimals",
+			locale:   RussianLocale,
+			input:    -0.4,
+			decimals: 0,
+			expected: "0",
+		},
+		{
+			name:     "russian locale rounding up",
+			locale:   RussianLocale,
+			input:    999.999,
+			decimals: 2,
+			expected: "1\u00a0000,00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.locale.FormatNumberWithDecimals(tt.input, tt.decimals)
+			if result != tt.expected {
+				t.Errorf("FormatNumberWithDecimals(%v, %d) = %q, expected %q", tt.input, tt.decimals, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocaleNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		locale   *Locale
+		input    string
+		expected string
+	}{
+		{locale: DefaultLocale, input: "1,234,567.89", expected: "1234567.89"},
+		{locale: RussianLocale, input: "1\u00a0234\u00a0567,89", expected: "1234567.89"},
+		{locale: RussianLocale, input: " -1 000,5 ", expected: "-1000.5"},
+		{locale: EnglishLocale, input: "42", expected: "42"},
+	}
+
+	for _, tt := range tests {
+		if result := tt.locale.NormalizeNumber(tt.input); result != tt.expected {
+			t.Errorf("%s NormalizeNumber(%q) = %q, expected %q", tt.locale.Name, tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestLocaleExcelDateFormat(t *testing.T) {
+	tests := []struct {
+		locale   *Locale
+		expected string
+	}{
+		{locale: DefaultLocale, expected: "dd.mm.yyyy"},
+		{locale: RussianLocale, expected: "dd.mm.yyyy"},
+		{locale: EnglishLocale, expected: "mm/dd/yyyy"},
+		{locale: &Locale{DateLayout: "2/1/06"}, expected: "d/m/yy"},
+	}
+
+	for _, tt := range tests {
+		if result := tt.locale.ExcelDateFormat(); result != tt.expected {
+			t.Errorf("ExcelDateFormat(%q) = %q, expected %q", tt.locale.DateLayout, result, tt.expected)
+		}
+	}
+}
+
+func TestLocaleFormatDate(t *testing.T) {
+	date := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		locale   *Locale
+		layout   string
+		expected string
+	}{
+		{
+			name:     "default short date",
+			locale:   DefaultLocale,
+			layout:   "",
+			expected: "17.10.2026",
+		},
+		{
+			name:     "russian long date",
+			locale:   RussianLocale,
+			layout:   RussianLocale.LongDateLayout,
+			expected: "17 октября 2026 г.",
+		},
+		{
+			name:     "russian short month",
+			locale:   RussianLocale,
+			layout:   "02 Jan 2006",
+			expected: "17 окт 2026",
+		},
+		{
+			name:     "english long date",
+			locale:   EnglishLocale,
+			layout:   EnglishLocale.LongDateLayout,
+			expected: "October 17, 2026",
+		},
+		{
+			name:     "english short date",
+			locale:   EnglishLocale,
+			layout:   "",
+			expected: "10/17/2026",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.locale.FormatDate(date, tt.layout)
+			if result != tt.expected {
+				t.Errorf("FormatDate(%q) = %q, expected %q", tt.layout, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLookupLocale(t *testing.T) {
+	if locale, err := LookupLocale(""); err != nil || locale != DefaultLocale {
+		t.Errorf("LookupLocale(\"\") = %v, %v, expected the default locale", locale, err)
+	}
+	if locale, err := LookupLocale("RU"); err != nil || locale != RussianLocale {
+		t.Errorf("LookupLocale(\"RU\") = %v, %v, expected the russian locale", locale, err)
+	}
+	if _, err := LookupLocale("xx"); err == nil {
+		t.Error("LookupLocale(\"xx\") expected an error")
+	}
+}
@@ -56,3 +56,122 @@ func TestFormatNumber(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatNumberWithDecimals(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    float64
+		decimals int
+		expected string
+	}{
+		{
+			name:     "no decimals",
+			input:    1234567.89,
+			decimals: 0,
+			expected: "1,234,568",
+		},
+		{
+			name:     "one decimal",
+			input:    1234.56,
+			decimals: 1,
+			expected: "1,234.6",
+		},
+		{
+			name:     "three decimals",
+			input:    1234567.891,
+			decimals: 3,
+			expected: "1,234,567.891",
+		},
+		{
+			name:     "padded decimals",
+			input:    5.5,
+			decimals: 4,
+			expected: "5.5000",
+		},
+		{
+			name:     "rounding carries into integer part",
+			input:    999.999,
+			decimals: 2,
+			expected: "1,000.00",
+		},
+		{
+			name:     "negative number",
+			input:    -1234.5,
+			decimals: 0,
+			expected: "-1,235",
+		},
+		{
+			name:     "negative decimals treated as zero",
+			input:    42.4,
+			decimals: -1,
+			expected: "42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FormatNumberWithDecimals(tt.input, tt.decimals)
+			if result != tt.expected {
+				t.Errorf("FormatNumberWithDecimals(%f, %d) = %s; expected %s", tt.input, tt.decimals, result, tt.expected)
+			}
+		})
+	}
+}
+
+// testDecimal is a fixed-point number for FormatDecimal tests
+type testDecimal struct {
+	units int64
+	scale int
+}
+
+func (d testDecimal) Units() int64 { return d.units }
+func (d testDecimal) Scale() int   { return d.scale }
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    testDecimal
+		decimals int
+		expected string
+	}{
+		{
+			name:     "kopecks",
+			input:    testDecimal{units: 123456789, scale: 2},
+			decimals: 2,
+			expected: "1,234,567.89",
+		},
+		{
+			name:     "large amount without float drift",
+			input:    testDecimal{units: 900719925474099199, scale: 2},
+			decimals: 2,
+			expected: "9,007,199,254,740,991.99",
+		},
+		{
+			name:     "rounding half away from zero",
+			input:    testDecimal{units: -250, scale: 2},
+			decimals: 0,
+			expected: "-3",
+		},
+		{
+			name:     "more decimals than the scale",
+			input:    testDecimal{units: 5, scale: 2},
+			decimals: 3,
+			expected: "0.050",
+		},
+		{
+			name:     "negative rounded to zero",
+			input:    testDecimal{units: -4, scale: 2},
+			decimals: 1,
+			expected: "0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DefaultLocale.FormatDecimal(tt.input, tt.decimals)
+			if result != tt.expected {
+				t.Errorf("FormatDecimal(%v, %d) = %s; expected %s", tt.input, tt.decimals, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,532 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// QRLevel is the error correction level of a QR code
+type QRLevel int
+
+// Error correction levels, recovering about 7%, 15%, 25% and 30% of the code
+const (
+	QRLevelL QRLevel = iota
+	QRLevelM
+	QRLevelQ
+	QRLevelH
+)
+
+// ErrQRDataTooLong is returned when the data does not fit into a version 40 QR code
+var ErrQRDataTooLong = errors.New("data too long for a QR code")
+
+// Width of the light border around a QR code image, in modules
+const qrQuietZone = 4
+
+// Format bits of the error correction levels
+var qrLevelFormatBits = [4]int{1, 0, 3, 2}
+
+// Error correction codewords per block by level and version
+var qrECCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// Error correction blocks by level and version
+var qrECBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// QRCode is an encoded QR code, a square of dark and light modules
+type QRCode struct {
+	Version int
+	Size    int
+
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// EncodeQR encodes a text as a QR code in byte mode, using the smallest
+// version that fits the text at the given error correction level
+func EncodeQR(text string, level QRLevel) (*QRCode, error) {
+	data := []byte(text)
+
+	version := 1
+	for ; version <= 40; version++ {
+		if qrDataBits(len(data), version) <= qrDataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > 40 {
+		return nil, ErrQRDataTooLong
+	}
+
+	qr := newQRCode(version)
+	qr.drawFunctionPatterns()
+	qr.drawCodewords(qrAddErrorCorrection(qrEncodeData(data, version, level), version, level))
+	qr.applyBestMask(level)
+	return qr, nil
+}
+
+// Dark reports whether the module at the column x and row y is dark
+func (qr *QRCode) Dark(x, y int) bool {
+	return qr.modules[y][x]
+}
+
+// PNG renders the QR code as a black and white PNG image with the given
+// size of a module in pixels and the standard quiet zone
+func (qr *QRCode) PNG(moduleSize int) ([]byte, error) {
+	if moduleSize < 1 {
+		moduleSize = 1
+	}
+
+	size := (qr.Size + 2*qrQuietZone) * moduleSize
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if !qr.modules[y][x] {
+				continue
+			}
+			top, left := (y+qrQuietZone)*moduleSize, (x+qrQuietZone)*moduleSize
+			for dy := 0; dy < moduleSize; dy++ {
+				for dx := 0; dx < moduleSize; dx++ {
+					img.SetColorIndex(left+dx, top+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeQRPNG encodes a text as a QR code with medium error correction and
+// renders it as a PNG image
+func EncodeQRPNG(text string, moduleSize int) ([]byte, error) {
+	qr, err := EncodeQR(text, QRLevelM)
+	if err != nil {
+		return nil, err
+	}
+	return qr.PNG(moduleSize)
+}
+
+// newQRCode creates an empty QR code of a version
+func newQRCode(version int) *QRCode {
+	size := version*4 + 17
+	qr := &QRCode{
+		Version:    version,
+		Size:       size,
+		modules:    make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := range qr.modules {
+		qr.modules[i] = make([]bool, size)
+		qr.isFunction[i] = make([]bool, size)
+	}
+	return qr
+}
+
+// qrCharCountBits returns the length of the byte mode character count field
+func qrCharCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// qrDataBits returns the number of bits the byte mode segment takes
+func qrDataBits(length, version int) int {
+	if length >= 1<<uint(qrCharCountBits(version)) {
+		return 1 << 30
+	}
+	return 4 + qrCharCountBits(version) + length*8
+}
+
+// qrRawModules returns the number of modules available for data and error
+// correction codewords, with the remainder bits included
+func qrRawModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// qrDataCodewords returns the number of data codewords of a version and level
+func qrDataCodewords(version int, level QRLevel) int {
+	return qrRawModules(version)/8 - qrECCodewordsPerBlock[level][version]*qrECBlocks[level][version]
+}
+
+// qrEncodeData builds the data codewords: the byte mode segment, the
+// terminator and the padding
+func qrEncodeData(data []byte, version int, level QRLevel) []byte {
+	var bits qrBitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), qrCharCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := qrDataCodewords(version, level) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	result := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			result[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+	return result
+}
+
+// qrAddErrorCorrection splits the data into blocks, adds the error
+// correction codewords of each block and interleaves the blocks
+func qrAddErrorCorrection(data []byte, version int, level QRLevel) []byte {
+	numBlocks := qrECBlocks[level][version]
+	ecLen := qrECCodewordsPerBlock[level][version]
+	rawCodewords := qrRawModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(ecLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		dataLen := shortBlockLen - ecLen
+		if i >= numShortBlocks {
+			dataLen++
+		}
+		blockData := data[k : k+dataLen]
+		k += dataLen
+
+		block := append([]byte{}, blockData...)
+		if i < numShortBlocks {
+			block = append(block, 0) // Keeps blocks aligned, skipped when interleaving
+		}
+		blocks[i] = append(block, reedSolomonRemainder(blockData, divisor)...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-ecLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and
+// reserves the format and version areas
+func (qr *QRCode) drawFunctionPatterns() {
+	for i := 0; i < qr.Size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+
+	qr.drawFinderPattern(3, 3)
+	qr.drawFinderPattern(qr.Size-4, 3)
+	qr.drawFinderPattern(3, qr.Size-4)
+
+	positions := qr.alignmentPositions()
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// The corners are taken by the finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			qr.drawAlignmentPattern(x, y)
+		}
+	}
+
+	// Format bits are drawn for real once the mask is chosen
+	qr.drawFormatBits(QRLevelL, 0)
+	qr.drawVersion()
+}
+
+// drawFinderPattern draws a finder pattern with its separator around the center
+func (qr *QRCode) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= qr.Size || yy < 0 || yy >= qr.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			qr.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignmentPattern draws an alignment pattern around the center
+func (qr *QRCode) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			qr.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPositions returns the centers of the alignment patterns along each axis
+func (qr *QRCode) alignmentPositions() []int {
+	if qr.Version == 1 {
+		return nil
+	}
+
+	numAlign := qr.Version/7 + 2
+	step := (qr.Version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, qr.Size-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// drawFormatBits draws both copies of the error correction level and mask
+func (qr *QRCode) drawFormatBits(level QRLevel, mask int) {
+	data := qrLevelFormatBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	// Around the top left finder pattern
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, qrBit(bits, i))
+	}
+	qr.setFunction(8, 7, qrBit(bits, 6))
+	qr.setFunction(8, 8, qrBit(bits, 7))
+	qr.setFunction(7, 8, qrBit(bits, 8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, qrBit(bits, i))
+	}
+
+	// Next to the top right and bottom left finder patterns
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.Size-1-i, 8, qrBit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.Size-15+i, qrBit(bits, i))
+	}
+	qr.setFunction(8, qr.Size-8, true) // Always dark
+}
+
+// drawVersion draws both copies of the version information, versions 7 and up
+func (qr *QRCode) drawVersion() {
+	if qr.Version < 7 {
+		return
+	}
+
+	rem := qr.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := qr.Version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := qr.Size-11+i%3, i/3
+		qr.setFunction(a, b, qrBit(bits, i))
+		qr.setFunction(b, a, qrBit(bits, i))
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order, two columns at a
+// time from the bottom right corner, skipping the function modules
+func (qr *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := qr.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Skip the vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < qr.Size; vert++ {
+			y := vert
+			if upward {
+				y = qr.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if qr.isFunction[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				qr.modules[y][x] = qrBit(int(codewords[i>>3]), 7-i&7)
+				i++
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask with the lowest penalty score
+func (qr *QRCode) applyBestMask(level QRLevel) {
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(level, mask)
+		if penalty := qr.penaltyScore(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask) // Masks are XOR, applying again undoes it
+	}
+
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(level, bestMask)
+}
+
+// applyMask inverts the data modules selected by a mask pattern
+func (qr *QRCode) applyMask(mask int) {
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			qr.modules[y][x] = qr.modules[y][x] != invert
+		}
+	}
+}
+
+// Finder-like patterns penalized by the masking rules, with four light modules on one side
+var qrFinderLikePatterns = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penaltyScore scores the symbol by the four masking rules of the specification
+func (qr *QRCode) penaltyScore() int {
+	penalty := 0
+	dark := 0
+
+	line := make([]bool, qr.Size)
+	for _, horizontal := range []bool{true, false} {
+		for i := 0; i < qr.Size; i++ {
+			for j := 0; j < qr.Size; j++ {
+				if horizontal {
+					line[j] = qr.modules[i][j]
+				} else {
+					line[j] = qr.modules[j][i]
+				}
+			}
+
+			// Runs of five or more modules of the same color
+			run := 1
+			for j := 1; j <= qr.Size; j++ {
+				if j < qr.Size && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += run - 2
+				}
+				run = 1
+			}
+
+			// Patterns looking like a finder pattern
+			for j := 0; j+len(qrFinderLikePatterns[0]) <= qr.Size; j++ {
+				for _, pattern := range qrFinderLikePatterns {
+					if qrMatches(line[j:], pattern) {
+						penalty += 40
+					}
+				}
+			}
+		}
+	}
+
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			// Blocks of 2x2 modules of the same color
+			if x > 0 && y > 0 {
+				c := qr.modules[y][x]
+				if c == qr.modules[y-1][x] && c == qr.modules[y][x-1] && c == qr.modules[y-1][x-1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	// Deviation of the share of dark modules from 50%, in steps of 5%
+	total := qr.Size * qr.Size
+	deviation := abs(dark*20 - total*10)
+	penalty += (deviation + total - 1) / total * 10
+	return penalty
+}
+
+// setFunction sets a function module, which is never masked or used for data
+func (qr *QRCode) setFunction(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+// qrBitBuffer is a sequence of bits, most significant bit first
+type qrBitBuffer []bool
+
+// append adds the lowest length bits of the value
+func (b *qrBitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+// qrMatches reports whether the line starts with the pattern
+func qrMatches(line, pattern []bool) bool {
+	for i, module := range pattern {
+		if line[i] != module {
+			return false
+		}
+	}
+	return true
+}
+
+// qrBit returns the bit of the value at the index
+func qrBit(value, index int) bool {
+	return (value>>uint(index))&1 != 0
+}
+
+// abs returns the absolute value of an integer
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bytes"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeQRCapacity(t *testing.T) {
+	// Byte mode capacities from the QR code specification
+	tests := []struct {
+		version  int
+		level    QRLevel
+		capacity int
+	}{
+		{1, QRLevelL, 17},
+		{1, QRLevelM, 14},
+		{1, QRLevelQ, 11},
+		{1, QRLevelH, 7},
+		{2, QRLevelM, 26},
+		{7, QRLevelQ, 86},
+		{10, QRLevelM, 213},
+		{5, QRLevelQ, 60},
+		{40, QRLevelL, 2953},
+		{40, QRLevelH, 1273},
+	}
+
+	for _, tt := range tests {
+		qr, err := EncodeQR(strings.Repeat("a", tt.capacity), tt.level)
+		if err != nil {
+			t.Fatalf("EncodeQR(%d bytes) error = %v", tt.capacity, err)
+		}
+		if qr.Version != tt.version {
+			t.Errorf("EncodeQR(%d bytes, level %d) version = %d, want %d", tt.capacity, tt.level, qr.Version, tt.version)
+		}
+		if tt.version == 40 {
+			continue
+		}
+		qr, err = EncodeQR(strings.Repeat("a", tt.capacity+1), tt.level)
+		if err != nil {
+			t.Fatalf("EncodeQR(%d bytes) error = %v", tt.capacity+1, err)
+		}
+		if qr.Version != tt.version+1 {
+			t.Errorf("EncodeQR(%d bytes, level %d) version = %d, want %d", tt.capacity+1, tt.level, qr.Version, tt.version+1)
+		}
+	}
+
+	if _, err := EncodeQR(strings.Repeat("a", 2954), QRLevelL); err != ErrQRDataTooLong {
+		t.Errorf("EncodeQR(2954 bytes) error = %v, want %v", err, ErrQRDataTooLong)
+	}
+}
+
+func TestQRFormatBits(t *testing.T) {
+	// Format strings from the QR code specification, bit 14 first
+	tests := []struct {
+		level QRLevel
+		mask  int
+		want  string
+	}{
+		{QRLevelL, 4, "110011000101111"},
+		{QRLevelM, 0, "101010000010010"},
+		{QRLevelL, 0, "111011111000100"},
+		{QRLevelQ, 0, "011010101011111"},
+		{QRLevelH, 0, "001011010001001"},
+	}
+
+	for _, tt := range tests {
+		qr := newQRCode(1)
+		qr.drawFormatBits(tt.level, tt.mask)
+
+		// The second copy runs along row 8 from the right edge, bit 0 first
+		var got strings.Builder
+		for i := 14; i >= 0; i-- {
+			var dark bool
+			if i < 8 {
+				dark = qr.Dark(qr.Size-1-i, 8)
+			} else {
+				dark = qr.Dark(8, qr.Size-15+i)
+			}
+			if dark {
+				got.WriteByte('1')
+			} else {
+				got.WriteByte('0')
+			}
+		}
+		if got.String() != tt.want {
+			t.Errorf("format bits of level %d mask %d = %s, want %s", tt.level, tt.mask, got.String(), tt.want)
+		}
+	}
+}
+
+func TestQRVersionBits(t *testing.T) {
+	qr := newQRCode(7)
+	qr.drawVersion()
+
+	// Version 7 information from the QR code specification, bit 17 first
+	want := "000111110010010100"
+	var got strings.Builder
+	for i := 17; i >= 0; i-- {
+		if qr.Dark(i/3, qr.Size-11+i%3) {
+			got.WriteByte('1')
+		} else {
+			got.WriteByte('0')
+		}
+	}
+	if got.String() != want {
+		t.Errorf("version bits = %s, want %s", got.String(), want)
+	}
+}
+
+func TestQRAlignmentPositions(t *testing.T) {
+	tests := []struct {
+		version int
+		want    []int
+	}{
+		{1, nil},
+		{2, []int{6, 18}},
+		{7, []int{6, 22, 38}},
+		{32, []int{6, 34, 60, 86, 112, 138}},
+		{40, []int{6, 30, 58, 86, 114, 142, 170}},
+	}
+
+	for _, tt := range tests {
+		if got := newQRCode(tt.version).alignmentPositions(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("alignmentPositions(%d) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeQRFunctionPatterns(t *testing.T) {
+	qr, err := EncodeQR("https://example.com/api/act/verify?id=6650f0c2a1b2c3d4e5f60718", QRLevelM)
+	if err != nil {
+		t.Fatalf("EncodeQR() error = %v", err)
+	}
+
+	// Finder patterns: dark ring, light ring, dark 3x3 center
+	for _, corner := range [][2]int{{0, 0}, {qr.Size - 7, 0}, {0, qr.Size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				dist := max(abs(dx-3), abs(dy-3))
+				if want := dist != 2; qr.Dark(corner[0]+dx, corner[1]+dy) != want {
+					t.Fatalf("finder module (%d,%d) dark = %t, want %t", corner[0]+dx, corner[1]+dy, !want, want)
+				}
+			}
+		}
+	}
+
+	// Timing patterns alternate between the finder patterns
+	for i := 8; i < qr.Size-8; i++ {
+		if qr.Dark(i, 6) != (i%2 == 0) || qr.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("timing module %d has the wrong color", i)
+		}
+	}
+
+	if !qr.Dark(8, qr.Size-8) {
+		t.Error("dark module is light")
+	}
+}
+
+func TestEncodeQRPNG(t *testing.T) {
+	content, err := EncodeQRPNG("ACT-001", 4)
+	if err != nil {
+		t.Fatalf("EncodeQRPNG() error = %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+
+	// Version 1 is 21 modules wide, plus a quiet zone of 4 modules on each side
+	if size := img.Bounds().Dx(); size != (21+8)*4 {
+		t.Errorf("image width = %d, want %d", size, (21+8)*4)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Error("quiet zone is dark")
+	}
+	if r, _, _, _ := img.At(4*4, 4*4).RGBA(); r != 0 {
+		t.Error("top left finder module is light")
+	}
+}
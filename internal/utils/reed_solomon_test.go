@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGFMultiply(t *testing.T) {
+	tests := []struct {
+		x, y, want byte
+	}{
+		{0, 0x53, 0},
+		{1, 0x53, 0x53},
+		{2, 0x80, 0x1D},
+		{0x53, 0xCA, 0x8F},
+	}
+
+	for _, tt := range tests {
+		if got := gfMultiply(tt.x, tt.y); got != tt.want {
+			t.Errorf("gfMultiply(%#x, %#x) = %#x, want %#x", tt.x, tt.y, got, tt.want)
+		}
+		if got := gfMultiply(tt.y, tt.x); got != tt.want {
+			t.Errorf("gfMultiply(%#x, %#x) = %#x, want %#x", tt.y, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// Version 1-M "HELLO WORLD" example from the QR code specification tutorials
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	if !bytes.Equal(got, want) {
+		t.Errorf("reedSolomonRemainder() = %v, want %v", got, want)
+	}
+}
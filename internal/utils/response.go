@@ -8,9 +8,10 @@ import (
 
 // ErrorResponse represents an error response structure
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-	Code    int    `json:"code"`
+	Error   string      `json:"error"`
+	Message string      `json:"message,omitempty"`
+	Code    int         `json:"code"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 // SuccessResponse represents a generic success response
@@ -27,6 +28,16 @@ func RespondWithError(c *gin.Context, code int, message string) {
 	})
 }
 
+// RespondWithErrorDetails sends an error response with structured details
+func RespondWithErrorDetails(c *gin.Context, code int, message string, details interface{}) {
+	c.JSON(code, ErrorResponse{
+		Error:   http.StatusText(code),
+		Message: message,
+		Code:    code,
+		Details: details,
+	})
+}
+
 // RespondWithSuccess sends a success response
 func RespondWithSuccess(c *gin.Context, code int, data interface{}) {
 	c.JSON(code, data)
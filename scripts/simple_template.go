@@ -70,10 +70,17 @@ func main() {
 		{"Объект:", "{{objectName}}"},
 		{"", ""},
 		{"Общая стоимость:", "{{totalCost}}"},
-		{"Стоимость инспекции:", "{{totalCostInspection}}"},
+		{"Сумма прописью:", "{{totalCost | words}}"},
+		{"{{#vatBreakdown}}НДС {{rate}}:", "{{vat}}{{/vatBreakdown}}"},
+		{"Итого НДС:", "{{vatTotal}}"},
+		{"Итого с НДС:", "{{totalWithVat}}"},
+		{"{{#if totalCostInspection}}Стоимость инспекции:", "{{totalCostInspection}}{{/if}}"},
 		{"Стоимость рассмотрения:", "{{totalCostConsiderations}}"},
 		{"ID позиций:", "{{positionIds}}"},
 		{"", ""},
+		{"Позиция", "Стоимость за период"},
+		{"{{#positions}}{{@number}}. {{id}}", "{{currentPeriodCost}}{{/positions}}"},
+		{"", ""},
 		{"Дата создания:", "{{createdAt}}"},
 	}
 